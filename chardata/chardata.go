@@ -0,0 +1,260 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for character data: Kangxi radical, stroke counts, and components,
+// for looking up characters by radical or by component.
+//
+// The data is loaded from a Unihan style tab separated file with a character,
+// radical and residual strokes in kRSUnicode form, eg 38.3, total strokes, and
+// components on each line, eg
+//
+//	好	38.3	6	女子
+//
+// Components may be written as an ideographic description sequence, eg ⿰女子.
+// Lines starting with # are comments.
+package chardata
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+)
+
+// The number of Kangxi radicals
+const NumRadicals = 214
+
+// CharInfo holds the data for a single character
+type CharInfo struct {
+	Character       string
+	Radical         int
+	ResidualStrokes int
+	Strokes         int
+	Components      []string
+}
+
+// CharData looks up characters by radical and component
+type CharData interface {
+
+	// Lookup gets the data for a single character
+	Lookup(ch string) (CharInfo, bool)
+
+	// FindByRadical finds the characters with the given Kangxi radical number
+	// and residual strokes, any residual strokes if residual is negative,
+	// ordered by residual strokes
+	FindByRadical(radical, residual int) []CharInfo
+
+	// FindByComponent finds the characters that contain the component,
+	// directly or within one of their components, ordered by stroke count
+	FindByComponent(component string) []CharInfo
+}
+
+type charDataMem struct {
+	chars       map[string]CharInfo
+	byRadical   map[int][]string
+	byComponent map[string][]string
+}
+
+// Load reads character data from a Unihan style TSV file
+func Load(r io.Reader) (CharData, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = '\t'
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	cd := charDataMem{
+		chars:       make(map[string]CharInfo),
+		byRadical:   make(map[int][]string),
+		byComponent: make(map[string][]string),
+	}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("chardata.Load, could not parse file: %v", err)
+		}
+		ci, err := parseRow(row)
+		if err != nil {
+			line, _ := reader.FieldPos(0)
+			log.Printf("chardata.Load, skipping line %d: %v", line, err)
+			continue
+		}
+		if _, ok := cd.chars[ci.Character]; ok {
+			continue
+		}
+		cd.chars[ci.Character] = ci
+		cd.byRadical[ci.Radical] = append(cd.byRadical[ci.Radical], ci.Character)
+		for _, c := range ci.Components {
+			cd.byComponent[c] = append(cd.byComponent[c], ci.Character)
+		}
+	}
+	return cd, nil
+}
+
+// parseRow parses a line of the file
+func parseRow(row []string) (CharInfo, error) {
+	if len(row) < 3 {
+		return CharInfo{}, fmt.Errorf("expected at least 3 columns but found %d", len(row))
+	}
+	ch := strings.TrimSpace(row[0])
+	if utf8.RuneCountInString(ch) != 1 {
+		return CharInfo{}, fmt.Errorf("not a single character: %q", ch)
+	}
+	radical, residual, err := ParseRadicalStrokes(row[1])
+	if err != nil {
+		return CharInfo{}, err
+	}
+	strokes, err := strconv.Atoi(firstField(row[2]))
+	if err != nil {
+		return CharInfo{}, fmt.Errorf("total strokes not a number: %q", row[2])
+	}
+	components := []string{}
+	if len(row) > 3 {
+		components = parseComponents(ch, row[3])
+	}
+	return CharInfo{
+		Character:       ch,
+		Radical:         radical,
+		ResidualStrokes: residual,
+		Strokes:         strokes,
+		Components:      components,
+	}, nil
+}
+
+// ParseRadicalStrokes parses radical and residual strokes in kRSUnicode form,
+// eg 38.3 or 120'.3 for a simplified form of the radical. Where there is more
+// than one value only the first is used.
+func ParseRadicalStrokes(rs string) (int, int, error) {
+	rs = firstField(rs)
+	parts := strings.Split(rs, ".")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("radical and strokes not in form radical.strokes: %q", rs)
+	}
+	radical, err := strconv.Atoi(strings.TrimRight(parts[0], "'"))
+	if err != nil || radical < 1 || radical > NumRadicals {
+		return 0, 0, fmt.Errorf("not a Kangxi radical number: %q", rs)
+	}
+	residual, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("residual strokes not a number: %q", rs)
+	}
+	return radical, residual, nil
+}
+
+// parseComponents gives the components of a character, skipping ideographic
+// description characters, separators, and the character itself
+func parseComponents(ch, s string) []string {
+	seen := make(map[string]bool)
+	components := []string{}
+	for _, r := range s {
+		c := string(r)
+		if isIDC(r) || r == ' ' || r == ',' || c == ch || seen[c] {
+			continue
+		}
+		seen[c] = true
+		components = append(components, c)
+	}
+	return components
+}
+
+// isIDC tests whether the rune is an ideographic description character
+func isIDC(r rune) bool {
+	return dicttypes.ClassifyRune(r) == dicttypes.CJKDescription
+}
+
+// firstField gives the first of space separated values
+func firstField(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// RadicalChar gives the Kangxi radical character for the radical number, eg ⼥
+// for 38, or empty if the number is not a radical
+func RadicalChar(radical int) string {
+	if radical < 1 || radical > NumRadicals {
+		return ""
+	}
+	return string(rune(0x2F00 + radical - 1))
+}
+
+// Radical is a Kangxi radical
+type Radical struct {
+	Number    int
+	Character string
+}
+
+// Radicals lists the Kangxi radicals in order
+func Radicals() []Radical {
+	radicals := make([]Radical, NumRadicals)
+	for i := range radicals {
+		radicals[i] = Radical{Number: i + 1, Character: RadicalChar(i + 1)}
+	}
+	return radicals
+}
+
+func (cd charDataMem) Lookup(ch string) (CharInfo, bool) {
+	ci, ok := cd.chars[ch]
+	return ci, ok
+}
+
+func (cd charDataMem) FindByRadical(radical, residual int) []CharInfo {
+	results := []CharInfo{}
+	for _, ch := range cd.byRadical[radical] {
+		ci := cd.chars[ch]
+		if residual < 0 || ci.ResidualStrokes == residual {
+			results = append(results, ci)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].ResidualStrokes != results[j].ResidualStrokes {
+			return results[i].ResidualStrokes < results[j].ResidualStrokes
+		}
+		return results[i].Character < results[j].Character
+	})
+	return results
+}
+
+func (cd charDataMem) FindByComponent(component string) []CharInfo {
+	seen := map[string]bool{component: true}
+	results := []CharInfo{}
+	queue := []string{component}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		for _, ch := range cd.byComponent[c] {
+			if seen[ch] {
+				continue
+			}
+			seen[ch] = true
+			results = append(results, cd.chars[ch])
+			queue = append(queue, ch)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Strokes != results[j].Strokes {
+			return results[i].Strokes < results[j].Strokes
+		}
+		return results[i].Character < results[j].Character
+	})
+	return results
+}
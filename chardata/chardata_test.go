@@ -0,0 +1,182 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Unit tests for the chardata package
+package chardata
+
+import (
+	"strings"
+	"testing"
+)
+
+const testData = `# char	radical.residual	strokes	components
+女	38.0	3
+子	39.0	3
+好	38.3	6	⿰女子
+妈	38'.3	6	⿰女马
+媽	38.10	13	⿰女馬
+字	40.3	6	⿱宀子
+孩	39.6	9	⿰子亥
+嬉	38.12	15	⿰女喜
+bad	38.1	2
+好	99.9	9
+𡥧	39.9	12	⿰孩子
+`
+
+func loadTestData(t *testing.T) CharData {
+	cd, err := Load(strings.NewReader(testData))
+	if err != nil {
+		t.Fatalf("loadTestData: unexpected error: %v", err)
+	}
+	return cd
+}
+
+// charString joins the characters found
+func charString(chars []CharInfo) string {
+	var b strings.Builder
+	for _, ci := range chars {
+		b.WriteString(ci.Character)
+	}
+	return b.String()
+}
+
+// TestLookup tests getting the data for a character
+func TestLookup(t *testing.T) {
+	cd := loadTestData(t)
+	ci, ok := cd.Lookup("好")
+	if !ok {
+		t.Fatal("TestLookup: 好 not found")
+	}
+	if ci.Radical != 38 || ci.ResidualStrokes != 3 || ci.Strokes != 6 {
+		t.Errorf("TestLookup: unexpected values for 好, the first entry: %v", ci)
+	}
+	if strings.Join(ci.Components, "") != "女子" {
+		t.Errorf("TestLookup: expected components 女子, got %v", ci.Components)
+	}
+	if ci, ok := cd.Lookup("妈"); !ok || ci.Radical != 38 {
+		t.Errorf("TestLookup: expected radical 38 for simplified form, got %v", ci)
+	}
+	if _, ok := cd.Lookup("bad"); ok {
+		t.Error("TestLookup: expected bad line to be skipped")
+	}
+	if _, ok := cd.Lookup("猫"); ok {
+		t.Error("TestLookup: expected 猫 not to be found")
+	}
+}
+
+// TestFindByRadical tests lookup by radical and residual strokes
+func TestFindByRadical(t *testing.T) {
+	cd := loadTestData(t)
+	type test struct {
+		radical  int
+		residual int
+		expect   string
+	}
+	tests := []test{
+		{38, -1, "女好妈媽嬉"},
+		{38, 3, "好妈"},
+		{38, 10, "媽"},
+		{39, -1, "子孩𡥧"},
+		{38, 1, ""},
+		{200, -1, ""},
+	}
+	for _, tc := range tests {
+		got := charString(cd.FindByRadical(tc.radical, tc.residual))
+		if got != tc.expect {
+			t.Errorf("FindByRadical(%d, %d): expected %s, got %s", tc.radical,
+				tc.residual, tc.expect, got)
+		}
+	}
+}
+
+// TestFindByComponent tests lookup by component
+func TestFindByComponent(t *testing.T) {
+	cd := loadTestData(t)
+	tests := map[string]string{
+		"女": "好妈媽嬉",
+		"子": "好字孩𡥧",
+		"亥": "孩𡥧",
+		"马": "妈",
+		"猫": "",
+	}
+	for component, expect := range tests {
+		if got := charString(cd.FindByComponent(component)); got != expect {
+			t.Errorf("FindByComponent(%s): expected %s, got %s", component, expect,
+				got)
+		}
+	}
+}
+
+// TestParseRadicalStrokes tests parsing kRSUnicode values
+func TestParseRadicalStrokes(t *testing.T) {
+	type test struct {
+		input     string
+		radical   int
+		residual  int
+		expectErr bool
+	}
+	tests := []test{
+		{"38.3", 38, 3, false},
+		{"120'.3", 120, 3, false},
+		{"9.5 9.6", 9, 5, false},
+		{"215.1", 0, 0, true},
+		{"38", 0, 0, true},
+		{"x.1", 0, 0, true},
+	}
+	for _, tc := range tests {
+		radical, residual, err := ParseRadicalStrokes(tc.input)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("ParseRadicalStrokes(%s): expected an error", tc.input)
+			}
+			continue
+		}
+		if err != nil || radical != tc.radical || residual != tc.residual {
+			t.Errorf("ParseRadicalStrokes(%s): expected %d.%d, got %d.%d, %v",
+				tc.input, tc.radical, tc.residual, radical, residual, err)
+		}
+	}
+}
+
+// TestRadicalChar tests the Kangxi radical characters
+func TestRadicalChar(t *testing.T) {
+	tests := map[int]string{1: "⼀", 38: "⼥", 214: "⿕", 0: "", 215: ""}
+	for radical, expect := range tests {
+		if got := RadicalChar(radical); got != expect {
+			t.Errorf("RadicalChar(%d): expected %s, got %s", radical, expect, got)
+		}
+	}
+}
+
+// TestRadicals tests the list of radicals
+func TestRadicals(t *testing.T) {
+	radicals := Radicals()
+	if len(radicals) != NumRadicals {
+		t.Fatalf("TestRadicals: expected %d radicals, got %d", NumRadicals,
+			len(radicals))
+	}
+	if r := radicals[37]; r.Number != 38 || r.Character != "⼥" {
+		t.Errorf("TestRadicals: expected 38 ⼥, got %v", r)
+	}
+}
+
+// TestIsIDC tests ideographic description characters, including the
+// subtraction character in the CJK strokes block
+func TestIsIDC(t *testing.T) {
+	tests := map[rune]bool{'⿰': true, '⿻': true, 0x2FFC: true, 0x31EF: true,
+		'子': false, '㇀': false}
+	for r, expect := range tests {
+		if got := isIDC(r); got != expect {
+			t.Errorf("isIDC(%U): expected %t, got %t", r, expect, got)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command line utility to check dictionary files before deployment.
+//
+// With no arguments the files listed in LUFiles in config.yaml are checked,
+// otherwise the files given on the command line, in the same
+// file[:format[:priority]] form as LUFiles. A report of problems is
+// written to stdout and the exit status is non-zero if there are any in
+// strict mode.
+//
+// Example:
+//
+//	go run ./cmd/dictcheck -strict -pos data/grammar.txt -domains data/topics.txt
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/alexamies/chinesenotes-go/config"
+	"github.com/alexamies/chinesenotes-go/dictionary"
+)
+
+func main() {
+	var strict = flag.Bool("strict", false,
+		"Treat any problem as an error")
+	var posFile = flag.String("pos", "",
+		"Optional file listing valid parts of speech, one per line")
+	var domainFile = flag.String("domains", "",
+		"Optional TSV file listing valid domains")
+	flag.Parse()
+	appConfig := config.InitConfig()
+	if flag.NArg() > 0 {
+		appConfig.LUFileNames = flag.Args()
+		appConfig.LUSources = nil
+	}
+	log.Printf("dictcheck: checking %d files, strict: %t", len(appConfig.LUFileNames), *strict)
+
+	// Load in lenient mode so that all problems are collected
+	dict, report, err := dictionary.LoadDictFileReport(appConfig, false)
+	if err != nil {
+		log.Fatalf("dictcheck: could not load dictionary: %v", err)
+	}
+	if len(*posFile) > 0 && len(*domainFile) > 0 {
+		validator, err := newValidator(*posFile, *domainFile)
+		if err != nil {
+			log.Fatalf("dictcheck: could not create validator: %v", err)
+		}
+		dictionary.CheckDict(dict.Wdict, validator, report)
+	}
+	if err := report.Write(os.Stdout); err != nil {
+		log.Fatalf("dictcheck: %v", err)
+	}
+	report.Strict = *strict
+	if err := report.Err(); err != nil {
+		log.Printf("dictcheck: %v", err)
+		os.Exit(1)
+	}
+}
+
+// newValidator creates a Validator from the given files
+func newValidator(posFile, domainFile string) (dictionary.Validator, error) {
+	posReader, err := os.Open(posFile)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v", posFile, err)
+	}
+	defer posReader.Close()
+	domainReader, err := os.Open(domainFile)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v", domainFile, err)
+	}
+	defer domainReader.Close()
+	return dictionary.NewValidator(posReader, domainReader)
+}
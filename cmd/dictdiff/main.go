@@ -0,0 +1,131 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command line utility to compare or merge versions of the dictionary.
+//
+// Each version is a comma separated list of files in the same
+// file[:format[:priority]] form as LUFiles. With -old and -new the word senses
+// added, removed, and modified are written to stdout. With -base, -local, and
+// -upstream the changes made locally and upstream since the base version are
+// merged, conflicts are written to stdout, and the merged dictionary is
+// written in TSV format to the -out file. The exit status is non-zero if there
+// are any differences or conflicts.
+//
+// Examples:
+//
+//	go run ./cmd/dictdiff -old v1/words.txt -new words.txt
+//	go run ./cmd/dictdiff -base v1/words.txt -local words.txt \
+//	  -upstream upstream/words.txt -out merged.txt
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/alexamies/chinesenotes-go/config"
+	"github.com/alexamies/chinesenotes-go/dictionary"
+)
+
+func main() {
+	var oldFiles = flag.String("old", "", "Files for the old version to compare")
+	var newFiles = flag.String("new", "", "Files for the new version to compare")
+	var baseFiles = flag.String("base", "", "Files for the common base version to merge")
+	var localFiles = flag.String("local", "", "Files for the local version to merge")
+	var upFiles = flag.String("upstream", "", "Files for the upstream version to merge")
+	var outFile = flag.String("out", "", "Output file for the merged dictionary")
+	var asJSON = flag.Bool("json", false, "Write differences or conflicts as JSON")
+	flag.Parse()
+
+	if len(*baseFiles) > 0 {
+		if len(*localFiles) == 0 || len(*upFiles) == 0 || len(*outFile) == 0 {
+			log.Fatal("dictdiff: -local, -upstream, and -out are required with -base")
+		}
+		result := dictionary.Merge(loadDict(*baseFiles), loadDict(*localFiles),
+			loadDict(*upFiles))
+		writeMerged(result.Dictionary(), *outFile)
+		if *asJSON {
+			writeJSON(result.Conflicts)
+		} else if err := result.WriteConflicts(os.Stdout); err != nil {
+			log.Fatalf("dictdiff: %v", err)
+		}
+		if len(result.Conflicts) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(*oldFiles) == 0 || len(*newFiles) == 0 {
+		log.Fatal("dictdiff: either -old and -new or -base, -local, and -upstream are required")
+	}
+	diff := dictionary.Diff(loadDict(*oldFiles), loadDict(*newFiles))
+	if *asJSON {
+		writeJSON(diff)
+	} else if err := diff.Write(os.Stdout); err != nil {
+		log.Fatalf("dictdiff: %v", err)
+	}
+	if !diff.Empty() {
+		os.Exit(1)
+	}
+}
+
+// loadDict loads a version of the dictionary from a comma separated list of
+// files
+func loadDict(files string) *dictionary.Dictionary {
+	appConfig := config.AppConfig{
+		ConfigVars: map[string]string{},
+	}
+	for _, f := range strings.Split(files, ",") {
+		src, err := config.ParseLUSource(f)
+		if err != nil {
+			log.Fatalf("dictdiff: %v", err)
+		}
+		appConfig.LUSources = append(appConfig.LUSources, src)
+	}
+	dict, _, err := dictionary.LoadDictFileReport(appConfig, false)
+	if err != nil {
+		log.Fatalf("dictdiff: could not load %s: %v", files, err)
+	}
+	return dict
+}
+
+// writeMerged writes the merged dictionary in TSV format
+func writeMerged(dict *dictionary.Dictionary, outFile string) {
+	exporter, err := dictionary.NewExporter("tsv")
+	if err != nil {
+		log.Fatalf("dictdiff: %v", err)
+	}
+	f, err := os.Create(outFile)
+	if err != nil {
+		log.Fatalf("dictdiff: could not create %s: %v", outFile, err)
+	}
+	if err := exporter.Export(f, dict, nil); err != nil {
+		f.Close()
+		log.Fatalf("dictdiff: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("dictdiff: could not close %s: %v", outFile, err)
+	}
+	log.Printf("dictdiff: wrote %s", outFile)
+}
+
+// writeJSON writes the value to stdout as JSON
+func writeJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", " ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatalf("dictdiff: could not encode JSON: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command line utility to export the dictionary to other formats.
+//
+// The dictionary is loaded from the files listed in LUFiles in config.yaml.
+// The formats are tsv, json, cedict, anki, and stardict. For StarDict the
+// output is the base name of the .ifo, .idx, and .dict files.
+//
+// Example:
+//
+//	go run ./cmd/dictexport -format anki -domain Buddhism -out buddhism.csv
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/alexamies/chinesenotes-go/config"
+	"github.com/alexamies/chinesenotes-go/dictionary"
+)
+
+func main() {
+	var format = flag.String("format", "json",
+		"Export format: tsv, json, cedict, anki, or stardict")
+	var outFile = flag.String("out", "",
+		"Output file, or the base name of the files for stardict")
+	var domains = flag.String("domain", "",
+		"Optional comma separated list of domains or subdomains to export")
+	var bookName = flag.String("bookname", "Chinese Notes",
+		"Name of the dictionary for stardict")
+	flag.Parse()
+	if len(*outFile) == 0 {
+		log.Fatal("dictexport: the -out flag is required")
+	}
+	appConfig := config.InitConfig()
+	dict, err := dictionary.LoadDictFile(appConfig)
+	if err != nil {
+		log.Fatalf("dictexport: could not load dictionary: %v", err)
+	}
+	filter := dictionary.DomainFilter(strings.Split(*domains, ",")...)
+	if *format == "stardict" {
+		err = dictionary.ExportStarDict(*outFile, *bookName, dict, filter)
+		if err != nil {
+			log.Fatalf("dictexport: %v", err)
+		}
+		log.Printf("dictexport: wrote StarDict files %s.*", *outFile)
+		return
+	}
+	exporter, err := dictionary.NewExporter(*format)
+	if err != nil {
+		log.Fatalf("dictexport: %v", err)
+	}
+	f, err := os.Create(*outFile)
+	if err != nil {
+		log.Fatalf("dictexport: could not create %s: %v", *outFile, err)
+	}
+	if err := exporter.Export(f, dict, filter); err != nil {
+		f.Close()
+		log.Fatalf("dictexport: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("dictexport: could not close %s: %v", *outFile, err)
+	}
+	log.Printf("dictexport: wrote %s", *outFile)
+}
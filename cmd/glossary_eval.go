@@ -21,6 +21,7 @@
 package main
 
 import (
+    "context"
     "encoding/csv"
     "flag"
     "fmt"
@@ -127,7 +128,7 @@ func initDeepLClient() (transtools.ApiClient, error) {
     if !ok {
         return nil, fmt.Errorf("%s not set\n", deepLKeyName)
     }
-    return transtools.NewDeepLClient(deepLKey), nil
+    return transtools.NewDeepLClient(deepLKey, ""), nil
 }
 
 // Initializes Google translation API client
@@ -141,7 +142,7 @@ func initGlossaryClient(glossaryName string) (transtools.ApiClient, error) {
     if !ok {
         return nil, fmt.Errorf("%s not set\n", projectIDKey)
     }
-    return transtools.NewGlossaryClient(projectID, glossaryName), nil
+    return transtools.NewGlossaryClient(projectID, "", glossaryName), nil
 }
 
 func loadTestSuite(f io.Reader) (*[]testCase, error) {
@@ -186,36 +187,42 @@ func runTestSuite(glossaryName string, testSuite *[]testCase) (*[]testResult, er
     if err != nil {
         return nil, err
     }
+    ctx := context.Background()
     results := []testResult{}
     for _, tc := range *testSuite {
-        glossaryTarget, err := glossaryApiClient.Translate(tc.source)
+        req := transtools.Request{
+            Text: tc.source,
+            SourceLang: transtools.DefaultSourceLang,
+            TargetLang: transtools.DefaultTargetLang,
+        }
+        glossaryTarget, err := glossaryApiClient.Translate(ctx, req)
         if err != nil {
             return nil, err
         }
-        glossaryPass, glossaryReason := compareSimilarity(tc.model,*glossaryTarget, tc.mustInclude)
+        glossaryPass, glossaryReason := compareSimilarity(tc.model, glossaryTarget.Text, tc.mustInclude)
 
-        deepLTarget, err := deepLApiClient.Translate(tc.source)
+        deepLTarget, err := deepLApiClient.Translate(ctx, req)
         if err != nil {
             return nil, err
         }
-        deepLPass, deepLReason := compareSimilarity(tc.model,*deepLTarget, tc.mustInclude)
+        deepLPass, deepLReason := compareSimilarity(tc.model, deepLTarget.Text, tc.mustInclude)
 
-        googleTarget, err := googleApiClient.Translate(tc.source)
+        googleTarget, err := googleApiClient.Translate(ctx, req)
         if err != nil {
             return nil, err
         }
-        googlePass, googleReason := compareSimilarity(tc.model,*googleTarget, tc.mustInclude)
+        googlePass, googleReason := compareSimilarity(tc.model, googleTarget.Text, tc.mustInclude)
 
         tr := testResult{
             testNo: tc.testNo,
             source: tc.source,
-            glossaryTarget: *glossaryTarget,
+            glossaryTarget: glossaryTarget.Text,
             glossaryPass: glossaryPass,
             glossaryReason: glossaryReason,
-            deepLTarget: *deepLTarget,
+            deepLTarget: deepLTarget.Text,
             deepLPass: deepLPass,
             deepLReason: deepLReason,
-            googleTarget: *googleTarget,
+            googleTarget: googleTarget.Text,
             googlePass: googlePass,
             googleReason: googleReason,
         }
@@ -0,0 +1,126 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command line utility to generate machine translation glossaries from the
+// dictionary.
+//
+// The dictionary is loaded from the files listed in LUFiles in config.yaml.
+// The formats are csv and tsv for Google Translation unidirectional glossaries
+// and deepl for DeepL glossary entries. Terms with empty translations or the
+// same source as an earlier term are left out and reported. With -previous
+// the differences from the previous version of the glossary are written to
+// stdout. The exit status is non-zero with -strict if any terms are left out.
+//
+// Example:
+//
+//	go run ./cmd/glossarygen -format csv -domain Buddhism -propernouns \
+//	  -previous data/glossary/fgdb-test-glossary.csv -out glossary.csv
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/alexamies/chinesenotes-go/config"
+	"github.com/alexamies/chinesenotes-go/dictionary"
+	"github.com/alexamies/chinesenotes-go/transtools"
+)
+
+func main() {
+	var format = flag.String("format", transtools.GlossaryFormatCSV,
+		"Glossary format: csv, tsv, or deepl")
+	var outFile = flag.String("out", "", "Output file for the glossary")
+	var domains = flag.String("domain", "",
+		"Optional comma separated list of domains or subdomains to include")
+	var properNouns = flag.Bool("propernouns", false,
+		"Include proper nouns only, translated with a proper noun sense")
+	var prevFile = flag.String("previous", "",
+		"Optional previous version of the glossary to compare with")
+	var prevFormat = flag.String("previous_format", "",
+		"Format of the previous version, default the same as -format")
+	var asJSON = flag.Bool("json", false, "Write differences as JSON")
+	var strict = flag.Bool("strict", false,
+		"Exit with non-zero status if any terms are left out")
+	flag.Parse()
+	if len(*outFile) == 0 {
+		log.Fatal("glossarygen: the -out flag is required")
+	}
+	appConfig := config.InitConfig()
+	dict, err := dictionary.LoadDictFile(appConfig)
+	if err != nil {
+		log.Fatalf("glossarygen: could not load dictionary: %v", err)
+	}
+	opts := transtools.GlossaryOptions{
+		Domains:     strings.Split(*domains, ","),
+		ProperNouns: *properNouns,
+	}
+	terms, issues := transtools.ValidateGlossary(transtools.GenerateGlossary(dict, opts))
+	for _, issue := range issues {
+		log.Printf("glossarygen: %s", issue)
+	}
+
+	f, err := os.Create(*outFile)
+	if err != nil {
+		log.Fatalf("glossarygen: could not create %s: %v", *outFile, err)
+	}
+	if err := transtools.WriteGlossary(f, terms, *format); err != nil {
+		f.Close()
+		log.Fatalf("glossarygen: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("glossarygen: could not close %s: %v", *outFile, err)
+	}
+	log.Printf("glossarygen: wrote %d terms to %s, %d left out", len(terms),
+		*outFile, len(issues))
+
+	if len(*prevFile) > 0 {
+		if len(*prevFormat) == 0 {
+			prevFormat = format
+		}
+		diff := transtools.DiffGlossary(readGlossary(*prevFile, *prevFormat), terms)
+		if *asJSON {
+			writeJSON(diff)
+		} else if err := diff.Write(os.Stdout); err != nil {
+			log.Fatalf("glossarygen: %v", err)
+		}
+	}
+	if *strict && len(issues) > 0 {
+		os.Exit(1)
+	}
+}
+
+// readGlossary reads a previous version of the glossary
+func readGlossary(fName, format string) []transtools.GlossaryTerm {
+	f, err := os.Open(fName)
+	if err != nil {
+		log.Fatalf("glossarygen: could not open %s: %v", fName, err)
+	}
+	defer f.Close()
+	terms, err := transtools.ReadGlossary(f, format)
+	if err != nil {
+		log.Fatalf("glossarygen: could not read %s: %v", fName, err)
+	}
+	return terms
+}
+
+// writeJSON writes the value to stdout as JSON
+func writeJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", " ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatalf("glossarygen: could not encode JSON: %v", err)
+	}
+}
@@ -28,33 +28,37 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
 	"cloud.google.com/go/firestore"
 	"cloud.google.com/go/storage"
 
+	"github.com/alexamies/chinesenotes-go/chardata"
 	"github.com/alexamies/chinesenotes-go/config"
+	"github.com/alexamies/chinesenotes-go/dictedit"
 	"github.com/alexamies/chinesenotes-go/dictionary"
 	"github.com/alexamies/chinesenotes-go/dicttypes"
 	"github.com/alexamies/chinesenotes-go/find"
 	"github.com/alexamies/chinesenotes-go/fulltext"
 	"github.com/alexamies/chinesenotes-go/httphandling"
 	"github.com/alexamies/chinesenotes-go/identity"
+	"github.com/alexamies/chinesenotes-go/romanization"
 	"github.com/alexamies/chinesenotes-go/templates"
 	"github.com/alexamies/chinesenotes-go/termfreq"
+	"github.com/alexamies/chinesenotes-go/tokenizer"
 	"github.com/alexamies/chinesenotes-go/transmemory"
 	"github.com/alexamies/chinesenotes-go/transtools"
 )
 
 const (
-	deepLKeyName         = "DEEPL_AUTH_KEY" // Only needed if using machine translation
 	defTitle             = "Chinese Notes Translation Portal"
-	glossaryKeyName      = "TRANSLATION_GLOSSARY" // Google Translation API glossary
-	projectIDKey         = "PROJECT_ID"           // For GCP project
+	projectIDKey         = "PROJECT_ID" // For GCP project
 	colFileName          = "collections.csv"
 	titleIndexFN         = "documents.tsv"
 	translationTemplFile = "web-resources/translation.html"
+	compareTimeout       = 30 * time.Second // For each platform when comparing
 )
 
 var (
@@ -70,15 +74,23 @@ type backends struct {
 	parser                                                find.QueryParser
 	reverseIndex                                          dictionary.ReverseIndex
 	substrIndex                                           dictionary.SubstringIndex
+	relatedFinder                                         dictionary.RelatedFinder
 	templates                                             map[string]*template.Template
 	tmSearcher                                            transmemory.Searcher
+	segmentStore                                          transmemory.SegmentStore
 	webConfig                                             config.WebAppConfig
 	deepLApiClient, translateApiClient, glossaryApiClient transtools.ApiClient
 	translationProcessor                                  transtools.Processor
+	postEditor                                            transtools.PostEditor
 	docTitleFinder                                        find.TitleFinder
+	charData                                              chardata.CharData
+	termIndex                                             termfreq.TermIndex
+	usageCache                                            *usageCache
 	authenticator                                         identity.Authenticator
+	authMu                                                sync.Mutex // Guards authenticator
 	sessionEnforcer                                       httphandling.SessionEnforcer
 	pageDisplayer                                         httphandling.PageDisplayer
+	dictEdit                                              dictedit.ProposalStore
 }
 
 // htmlContent holds content for HTML template
@@ -102,10 +114,16 @@ type ChangePasswordHTML struct {
 // Data for displaying the translation page.
 type translationPage struct {
 	SourceText, TranslatedText, SuggestedText, Message, Title string
+	Domain                                                    string
 	Notes                                                     []transtools.Note
 	DeepLChecked, GCPChecked, GlossaryChecked, PostProcessing string
+	CompareChecked                                            string
+	Comparisons                                               []transtools.Comparison
 }
 
+// Platforms to compare translations from, in the order shown
+var comparePlatforms = []string{"DeepL", "gcp", "withGlossary"}
+
 func initApp(ctx context.Context) (*backends, error) {
 	log.Println("initApp Initializing cnweb")
 	appConfig := config.InitConfig()
@@ -174,7 +192,11 @@ func initApp(ctx context.Context) (*backends, error) {
 	if err != nil {
 		log.Printf("initApp, non-fatal error, unable to initialize NotesExtractor: %v", err)
 	}
-	reverseIndex := dictionary.NewReverseIndex(dict, extractor)
+	rankConfig := dictionary.RankConfig{
+		WordFreq: loadWordFreq(webConfig.WordFreqFile()),
+		Domains:  webConfig.PreferredDomains(),
+	}
+	reverseIndex := dictionary.NewRankedReverseIndex(dict, extractor, rankConfig)
 	if fsClient != nil {
 		substrIndex, err = initDictSSIndexFS(fsClient, appConfig, dict)
 		if err != nil {
@@ -186,6 +208,22 @@ func initApp(ctx context.Context) (*backends, error) {
 		}
 	}
 
+	if substrIndex == nil {
+		substrIndex, err = dictionary.NewSubstringIndexMem(ctx, dict)
+		if err != nil {
+			log.Printf("initApp, non-fatal error, unable to initialize in-memory substrIndex: %v", err)
+		}
+	}
+	if tms == nil {
+		tms, err = transmemory.NewMemSearcher(dict.Wdict, reverseIndex)
+		if err != nil {
+			log.Printf("initApp, non-fatal error, unable to initialize in-memory TM searcher: %v", err)
+		}
+	}
+	if tms != nil && len(webConfig.TMTrainingFile()) > 0 {
+		tms = trainTMSearcher(tms, webConfig.TMTrainingFile())
+	}
+
 	var tfDocFinder find.TermFreqDocFinder
 	if fsClient != nil {
 		log.Println("fsClient set, configuring full text search")
@@ -209,21 +247,35 @@ func initApp(ctx context.Context) (*backends, error) {
 		parser:          parser,
 		reverseIndex:    reverseIndex,
 		substrIndex:     substrIndex,
+		relatedFinder:   dictionary.NewRelatedFinder(dict, substrIndex),
+		charData:        loadCharData(webConfig.CharDataFile()),
 		templates:       templates,
 		tmSearcher:      tms,
+		segmentStore:    loadSegmentStore(webConfig, docMap),
 		webConfig:       webConfig,
 		authenticator:   authenticator,
 		sessionEnforcer: sessionEnforcer,
 		pageDisplayer:   pageDisplayer,
 	}
+	if titleFinder != nil {
+		bends.docTitleFinder = titleFinder
+	}
+	bends.termIndex, err = initTermIndex(appConfig)
+	if err != nil {
+		log.Printf("initApp, non-fatal error, unable to load term index: %v", err)
+	}
+	bends.usageCache = newUsageCache()
+	if authenticator != nil {
+		bends.dictEdit, err = initDictEdit(webConfig, dict)
+		if err != nil {
+			log.Printf("initApp, non-fatal error, unable to initialize dictionary editing: %v", err)
+		}
+	}
 	return bends, nil
 }
 
 // initDocTitleFinder initializes the document title finder
 func initDocTitleFinder(ctx context.Context, appConfig config.AppConfig, project string) (find.TitleFinder, error) {
-	if b != nil && b.docTitleFinder != nil {
-		return b.docTitleFinder, nil
-	}
 	colFileName := appConfig.CorpusDataDir() + "/" + colFileName
 	cr, err := os.Open(colFileName)
 	if err != nil {
@@ -256,18 +308,12 @@ func initDocTitleFinder(ctx context.Context, appConfig config.AppConfig, project
 			} else {
 				indexGen := appConfig.IndexGen()
 				docTitleFinder = find.NewFirestoreTitleFinder(client, indexCorpus, indexGen, colMap, dInfoCN, docMap)
-				if b != nil {
-					b.docTitleFinder = docTitleFinder
-				}
 				return docTitleFinder, nil
 			}
 		}
 	}
 	log.Println("initDocTitleFinder fall back to a file based TitleFinder")
 	docTitleFinder = find.NewFileTitleFinder(colMap, dInfoCN, docMap)
-	if b != nil {
-		b.docTitleFinder = docTitleFinder
-	}
 	return docTitleFinder, nil
 }
 
@@ -283,18 +329,85 @@ func initDictSSIndexFS(client *firestore.Client, c config.AppConfig, dict *dicti
 	return dictionary.NewSubstringIndexFS(client, indexCorpus, c.IndexGen(), dict)
 }
 
+// loadWordFreq loads word frequencies for ranking reverse lookup, nil if the
+// file is not configured or cannot be read
+func loadWordFreq(fileName string) map[string]int {
+	if len(fileName) == 0 {
+		return nil
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		log.Printf("loadWordFreq, non-fatal error, cannot open %s: %v", fileName, err)
+		return nil
+	}
+	defer f.Close()
+	freq, err := dictionary.LoadWordFreq(f)
+	if err != nil {
+		log.Printf("loadWordFreq, non-fatal error, cannot load %s: %v", fileName, err)
+		return nil
+	}
+	log.Printf("loadWordFreq, loaded %d word frequencies from %s", len(freq), fileName)
+	return freq
+}
+
+// trainTMSearcher trains the translation memory relevance model from the
+// labelled file, keeping the default model if there is an error
+func trainTMSearcher(tms transmemory.Searcher, fileName string) transmemory.Searcher {
+	f, err := os.Open(fileName)
+	if err != nil {
+		log.Printf("trainTMSearcher, non-fatal error, cannot open %s: %v", fileName, err)
+		return tms
+	}
+	defer f.Close()
+	examples, err := transmemory.LoadExamples(f)
+	if err != nil {
+		log.Printf("trainTMSearcher, non-fatal error, cannot load %s: %v", fileName, err)
+		return tms
+	}
+	model, err := transmemory.TrainModel(examples)
+	if err != nil {
+		log.Printf("trainTMSearcher, non-fatal error, cannot train model: %v", err)
+		return tms
+	}
+	trained, err := transmemory.WithModel(tms, model)
+	if err != nil {
+		log.Printf("trainTMSearcher, non-fatal error, cannot set model: %v", err)
+		return tms
+	}
+	log.Printf("trainTMSearcher, trained on %d examples from %s: %v", len(examples), fileName, model)
+	return trained
+}
+
+// loadCharData loads the character data, nil if not configured or there is an
+// error
+func loadCharData(fileName string) chardata.CharData {
+	if len(fileName) == 0 {
+		return nil
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		log.Printf("loadCharData, non-fatal error, cannot open %s: %v", fileName, err)
+		return nil
+	}
+	defer f.Close()
+	cd, err := chardata.Load(f)
+	if err != nil {
+		log.Printf("loadCharData, non-fatal error, cannot load %s: %v", fileName, err)
+		return nil
+	}
+	return cd
+}
+
 // Process a change password request
 func changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	log.Print("changePasswordHandler enter")
 	ctx := context.Background()
-	if b.authenticator == nil {
-		var err error
-		b.authenticator, err = initAuth(ctx)
-		if err != nil {
-			log.Printf("changePasswordHandler authenticator could not be initialized: %v", err)
-			http.Error(w, "Server error", http.StatusInternalServerError)
-			return
-		}
+	auth, err := getAuthenticator(ctx, b)
+	if err != nil {
+		log.Printf("changePasswordHandler authenticator could not be initialized: %v", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
 	}
 	sessionInfo := b.sessionEnforcer.EnforceValidSession(ctx, w, r)
 	if sessionInfo.Authenticated != 1 {
@@ -304,7 +417,7 @@ func changePasswordHandler(w http.ResponseWriter, r *http.Request) {
 	} else {
 		oldPassword := r.PostFormValue("OldPassword")
 		password := r.PostFormValue("Password")
-		result := b.authenticator.ChangePassword(ctx, sessionInfo.User, oldPassword,
+		result := auth.ChangePassword(ctx, sessionInfo.User, oldPassword,
 			password)
 		if strings.Contains(r.Header.Get("Accept"), "application/json") {
 			sendJSON(w, result)
@@ -323,6 +436,7 @@ func changePasswordHandler(w http.ResponseWriter, r *http.Request) {
 
 // Display change password form
 func changePasswordFormHandler(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	ctx := context.Background()
 	sessionInfo := b.sessionEnforcer.EnforceValidSession(ctx, w, r)
 	if sessionInfo.Authenticated != 1 {
@@ -342,12 +456,36 @@ func changePasswordFormHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // Custom 404 page handler
-func custom404(w http.ResponseWriter, r *http.Request, url string) {
+func custom404(w http.ResponseWriter, r *http.Request, b *backends, url string) {
 	log.Printf("custom404: sending 404 for %s", url)
 	b.pageDisplayer.DisplayPage(w, "404.html", nil)
 }
 
-func initAuth(ctx context.Context) (identity.Authenticator, error) {
+// getAuthenticator gives the authenticator, initializing it the first time it
+// is needed. The backends are shared by concurrent requests, so authMu is held
+// to set it.
+func getAuthenticator(ctx context.Context, b *backends) (identity.Authenticator, error) {
+	b.authMu.Lock()
+	defer b.authMu.Unlock()
+	if b.authenticator == nil {
+		a, err := initAuth(ctx, b)
+		if err != nil {
+			return nil, err
+		}
+		b.authenticator = a
+	}
+	return b.authenticator, nil
+}
+
+// loadedAuthenticator gives the authenticator if it has been initialized, nil
+// otherwise
+func loadedAuthenticator(b *backends) identity.Authenticator {
+	b.authMu.Lock()
+	defer b.authMu.Unlock()
+	return b.authenticator
+}
+
+func initAuth(ctx context.Context, b *backends) (identity.Authenticator, error) {
 	var fsClient *firestore.Client
 	projectID, ok := os.LookupEnv(projectIDKey)
 	if !ok {
@@ -370,6 +508,7 @@ func initAuth(ctx context.Context) (identity.Authenticator, error) {
 // displayHome shows a simple page, for health checks and testing.
 // End users may also to see this when accessing direct from the browser
 func displayHome(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	log.Printf("displayHome: url %s", r.URL.Path)
 
 	// Tell health check probes that we are alive
@@ -384,19 +523,16 @@ func displayHome(w http.ResponseWriter, r *http.Request) {
 	}
 	if config.PasswordProtected() {
 		ctx := context.Background()
-		if b.authenticator == nil {
-			var err error
-			b.authenticator, err = initAuth(ctx)
-			if err != nil {
-				log.Print("displayHome authenticator could not be initialized")
-				http.Error(w, "Server error", http.StatusInternalServerError)
-				return
-			}
+		auth, err := getAuthenticator(ctx, b)
+		if err != nil {
+			log.Print("displayHome authenticator could not be initialized")
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
 		}
 		sessionInfo := identity.InvalidSession()
 		cookie, err := r.Cookie("session")
 		if err == nil {
-			sessionInfo = b.authenticator.CheckSession(ctx, cookie.Value)
+			sessionInfo = auth.CheckSession(ctx, cookie.Value)
 		} else {
 			log.Printf("displayHome error getting cookie: %v", err)
 			b.pageDisplayer.DisplayPage(w, "login_form.html", content)
@@ -418,6 +554,7 @@ func displayHome(w http.ResponseWriter, r *http.Request) {
 
 // Finds documents matching the given query with search in text body
 func findFullText(response http.ResponseWriter, request *http.Request) {
+	b := getBackends()
 	log.Println("findFullText, enter")
 	q := getSingleValue(request, "query")
 	if len(q) == 0 {
@@ -437,7 +574,7 @@ func findFullText(response http.ResponseWriter, request *http.Request) {
 	if b == nil {
 		log.Println("main.findFullText re-initializing app")
 		var err error
-		b, err = initApp(ctx)
+		b, err = reloadBackends(ctx)
 		if err != nil {
 			log.Printf("main.findFullText error initializing app: %v", err)
 			http.Error(response, "Internal error", http.StatusInternalServerError)
@@ -490,7 +627,10 @@ func findDocs(ctx context.Context, response http.ResponseWriter, request *http.R
 		if !ok {
 			log.Printf("main.findDocs, %s not set", projectIDKey)
 		}
-		docTitleFinder, err := initDocTitleFinder(ctx, b.appConfig, projectID)
+		docTitleFinder := b.docTitleFinder
+		if docTitleFinder == nil {
+			docTitleFinder, err = initDocTitleFinder(ctx, b.appConfig, projectID)
+		}
 		if err == nil {
 			docs, err := docTitleFinder.FindDocsByTitle(ctx, q)
 			results = &find.QueryResults{
@@ -541,6 +681,11 @@ func findDocs(ctx context.Context, response http.ResponseWriter, request *http.R
 		}
 	}
 
+	if system := getRomanization(request); system != romanization.Pinyin {
+		r := romanizeResults(*results, system)
+		results = &r
+	}
+
 	// Return HTML if method is post
 	if httphandling.AcceptHTML(request) {
 		templateFile := "find_results.html"
@@ -655,24 +800,17 @@ func highlightMatches(r find.QueryResults) find.QueryResults {
 // initTranslationClients initializes translation API clients and processing utility.
 func initTranslationClients(b *backends) {
 	log.Println("cnweb.initTranslationClients enter")
-	deepLKey, ok := os.LookupEnv(deepLKeyName)
-	if !ok {
-		log.Printf("%s not set\n", deepLKeyName)
+	if localURL := b.webConfig.LocalTranslationURL(); len(localURL) > 0 {
+		// Offline, the local server stands in for all the platforms
+		log.Printf("Using local translation server %s", localURL)
+		local := transtools.NewLocalClient(localURL, b.webConfig.LocalTranslationKey())
+		b.deepLApiClient = local
+		b.translateApiClient = local
+		b.glossaryApiClient = local
 	} else {
-		b.deepLApiClient = transtools.NewDeepLClient(deepLKey)
-	}
-	b.translateApiClient = transtools.NewGoogleClient()
-	glossaryName, ok := os.LookupEnv(glossaryKeyName)
-	if !ok {
-		log.Printf("%s not set\n", glossaryKeyName)
-	} else {
-		projectID, ok := os.LookupEnv(projectIDKey)
-		if !ok {
-			log.Printf("%s not set\n", projectIDKey)
-		} else {
-			b.glossaryApiClient = transtools.NewGlossaryClient(projectID, glossaryName)
-		}
+		initCloudTranslationClients(b)
 	}
+	initPostEditor(b)
 	fExpected, err := os.Open(transtools.ExpectedDataFile)
 	if err != nil {
 		log.Printf("initTranslationClients: Error opening expected file: %v", err)
@@ -694,8 +832,62 @@ func initTranslationClients(b *backends) {
 	b.translationProcessor = transtools.NewProcessor(fExpected, fReplace)
 }
 
+// initPostEditor initializes the post-editor for machine translations, if a
+// glossary of preferred translations is configured
+func initPostEditor(b *backends) {
+	fName := b.webConfig.PostEditGlossaryFile()
+	if len(fName) == 0 || b.dict == nil {
+		return
+	}
+	f, err := os.Open(fName)
+	if err != nil {
+		log.Printf("initPostEditor: Error opening glossary file: %v", err)
+		return
+	}
+	defer f.Close()
+	glossary, err := transtools.LoadGlossary(f)
+	if err != nil {
+		log.Printf("initPostEditor: Error loading glossary: %v", err)
+		return
+	}
+	mode := b.webConfig.PostEditMode()
+	log.Printf("initPostEditor: loaded %d glossary terms from %s, mode %s",
+		len(glossary), fName, mode)
+	tok := tokenizer.NewDictTokenizer(b.dict.Wdict)
+	b.postEditor = transtools.NewPostEditor(tok, glossary, mode)
+}
+
+// initCloudTranslationClients initializes the DeepL and Google translation API
+// clients that have keys configured
+func initCloudTranslationClients(b *backends) {
+	deepLKey := b.webConfig.DeepLAuthKey()
+	if len(deepLKey) == 0 {
+		log.Println("DeepL auth key not set")
+	} else {
+		b.deepLApiClient = transtools.NewDeepLClient(deepLKey, b.webConfig.DeepLURL())
+	}
+	b.translateApiClient = transtools.NewGoogleClient()
+	glossaryName := b.webConfig.TranslationGlossary()
+	projectID := b.webConfig.TranslationProjectID()
+	if len(glossaryName) == 0 || len(projectID) == 0 {
+		log.Println("Translation glossary or project ID not set")
+	} else {
+		b.glossaryApiClient = transtools.NewGlossaryClient(projectID,
+			b.webConfig.TranslationLocation(), glossaryName)
+	}
+}
+
 // processTranslation performs translation and post processing of source text.
 func processTranslation(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
+	// Check the session before calling any of the paid translation APIs
+	if config.PasswordProtected() {
+		ctx := context.Background()
+		sessionInfo := b.sessionEnforcer.EnforceValidSession(ctx, w, r)
+		if !sessionInfo.Valid {
+			return
+		}
+	}
 	title := b.webConfig.GetVarWithDefault("Title", defTitle)
 	if b.translationProcessor == nil {
 		p := &translationPage{
@@ -712,6 +904,8 @@ func processTranslation(w http.ResponseWriter, r *http.Request) {
 	deepLChecked := "checked"
 	gcpChecked := ""
 	glossaryChecked := ""
+	compareChecked := ""
+	comparisons := []transtools.Comparison{}
 	platform := r.FormValue("platform")
 	if platform == "gcp" {
 		deepLChecked = ""
@@ -721,37 +915,44 @@ func processTranslation(w http.ResponseWriter, r *http.Request) {
 		deepLChecked = ""
 		gcpChecked = ""
 		glossaryChecked = "checked"
+	} else if platform == "compare" {
+		deepLChecked = ""
+		compareChecked = "checked"
 	}
 	log.Printf("processTranslation, glossaryChecked %s, source: %s", glossaryChecked, source)
 	processingChecked := r.FormValue("processing")
-	if len(source) > 0 {
+	domain := r.FormValue("domain")
+	if len(source) > 0 && platform == "compare" {
+		comparisons = compareTranslations(r.Context(), b, source, domain,
+			processingChecked == "on")
+		for _, c := range comparisons {
+			if c.Result != nil {
+				translated = c.Result.Text
+				break
+			}
+		}
+		if len(translated) == 0 {
+			message = "None of the platforms could translate the text"
+		}
+	} else if len(source) > 0 {
 		log.Printf("platform: %s", platform)
-		trText, err := translate(b, source, platform)
+		trResult, err := translate(r.Context(), b, source, platform)
 		if err != nil {
 			log.Printf("Translation error: %v", err)
 			message = err.Error()
 		} else {
-			log.Printf("Translation result: %s", *trText)
-			translated = *trText
+			log.Printf("Translation result from %s, detected language %s: %s",
+				trResult.Provider, trResult.DetectedSourceLang, trResult.Text)
+			translated = trResult.Text
+		}
+		if len(translated) > 0 && processingChecked == "on" {
+			translated, notes = postProcess(b, source, translated, domain)
 		}
 	} else {
 		message = "Please enter translated text or click Translate for a machine translation"
 	}
-	if len(translated) > 0 && processingChecked == "on" {
-		result := b.translationProcessor.Suggest(source, translated)
-		translated = result.Replacement
-		notes = result.Notes
-		log.Printf("suggestion notes: %s, suggested translation: %s", notes, translated)
-	}
 	log.Printf("deepLChecked: %s, gcpChecked: %s, glossaryChecked: %s, processingChecked: %s, len(translated) = %d",
 		deepLChecked, gcpChecked, glossaryChecked, processingChecked, len(translated))
-	if config.PasswordProtected() {
-		ctx := context.Background()
-		sessionInfo := b.sessionEnforcer.EnforceValidSession(ctx, w, r)
-		if !sessionInfo.Valid {
-			return
-		}
-	}
 	postProcessing := ""
 	if processingChecked == "on" {
 		postProcessing = "checked"
@@ -762,14 +963,131 @@ func processTranslation(w http.ResponseWriter, r *http.Request) {
 		Message:         message,
 		Title:           title,
 		Notes:           notes,
+		Domain:          domain,
 		DeepLChecked:    deepLChecked,
 		GCPChecked:      gcpChecked,
 		GlossaryChecked: glossaryChecked,
 		PostProcessing:  postProcessing,
+		CompareChecked:  compareChecked,
+		Comparisons:     comparisons,
+	}
+	showTranslationPage(w, b, p)
+}
+
+// postProcess gives suggestions for the translation and replaces terms with
+// the preferred ones in the glossary
+func postProcess(b *backends, source, translated, domain string) (string, []transtools.Note) {
+	notes := []transtools.Note{}
+	if b.translationProcessor != nil {
+		result := b.translationProcessor.Suggest(source, translated)
+		translated = result.Replacement
+		notes = result.Notes
+		log.Printf("suggestion notes: %v, suggested translation: %s", notes, translated)
+	}
+	if b.postEditor != nil {
+		edited := b.postEditor.PostEdit(source, translated, domain)
+		translated = edited.Replacement
+		notes = append(notes, edited.Notes...)
+	}
+	return translated, notes
+}
+
+// compareTranslations translates the text with all the platforms for showing
+// side by side, with differences between them highlighted
+func compareTranslations(ctx context.Context, b *backends, source, domain string, process bool) []transtools.Comparison {
+	engines := []transtools.Engine{}
+	for _, platform := range comparePlatforms {
+		e := transtools.Engine{Name: platform}
+		if client, err := translationClient(b, platform); err == nil {
+			e.Client = client
+		}
+		engines = append(engines, e)
+	}
+	comparisons := transtools.CompareTranslations(ctx, engines,
+		translationRequest(b, source), compareTimeout)
+	for i, c := range comparisons {
+		if c.Result == nil {
+			log.Printf("compareTranslations: error from %s: %s", c.Engine, c.Error)
+			continue
+		}
+		if process {
+			comparisons[i].Result.Text, comparisons[i].Notes = postProcess(b, source,
+				c.Result.Text, domain)
+		}
+	}
+	transtools.HighlightDifferences(comparisons)
+	return comparisons
+}
+
+// translationChoice saves the translation the user picked from the comparison
+// of platforms, or merged from them, for later evaluation
+func translationChoice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	b := getBackends()
+	if config.PasswordProtected() {
+		ctx := context.Background()
+		sessionInfo := b.sessionEnforcer.EnforceValidSession(ctx, w, r)
+		if !sessionInfo.Valid {
+			return
+		}
+	}
+	choice := transtools.Choice{
+		Time:    time.Now(),
+		Source:  r.FormValue("source"),
+		Engine:  r.FormValue("engine"),
+		Text:    r.FormValue("translated"),
+		Outputs: map[string]string{},
+	}
+	for _, platform := range comparePlatforms {
+		if out := r.FormValue("output_" + platform); len(out) > 0 {
+			choice.Outputs[platform] = out
+		}
+	}
+	if len(choice.Text) == 0 {
+		choice.Text = choice.Outputs[choice.Engine]
+	}
+	if out, ok := choice.Outputs[choice.Engine]; !ok || out != choice.Text {
+		choice.Engine = transtools.ChoiceMerged
+	}
+	message := "Your choice of translation has been saved"
+	if len(choice.Text) == 0 {
+		message = "Please pick or enter a translation"
+	} else if err := saveChoice(b, choice); err != nil {
+		log.Printf("translationChoice: %v", err)
+		message = "Sorry, your choice could not be saved"
+	}
+	p := &translationPage{
+		SourceText:     choice.Source,
+		TranslatedText: choice.Text,
+		Message:        message,
+		Title:          b.webConfig.GetVarWithDefault("Title", defTitle),
+		CompareChecked: "checked",
+		PostProcessing: "checked",
 	}
 	showTranslationPage(w, b, p)
 }
 
+// saveChoice appends the choice to the configured file or else writes it to
+// the log
+func saveChoice(b *backends, choice transtools.Choice) error {
+	fName := b.webConfig.TranslationChoiceFile()
+	if len(fName) == 0 {
+		return transtools.WriteChoice(log.Writer(), choice)
+	}
+	f, err := os.OpenFile(fName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("saveChoice: could not open %s: %v", fName, err)
+	}
+	if err := transtools.WriteChoice(f, choice); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 // showQueryResults displays query results on a HTML page
 func showQueryResults(w io.Writer, b *backends, results find.QueryResults, templateFile string) error {
 	res := results
@@ -836,6 +1154,7 @@ func showTranslationPage(w http.ResponseWriter, b *backends, p *translationPage)
 
 // findHandler finds documents matching the given query.
 func findHandler(response http.ResponseWriter, request *http.Request) {
+	b := getBackends()
 	log.Printf("findHandler: url %s", request.URL.Path)
 	ctx := context.Background()
 	findDocs(ctx, response, request, b, false)
@@ -843,6 +1162,7 @@ func findHandler(response http.ResponseWriter, request *http.Request) {
 
 // findSubstring finds terms matching the given query with a substring match.
 func findSubstring(response http.ResponseWriter, request *http.Request) {
+	b := getBackends()
 	log.Println("main.findSubstring, enter")
 	url := request.URL
 	queryString := url.Query()
@@ -857,7 +1177,7 @@ func findSubstring(response http.ResponseWriter, request *http.Request) {
 		t = topic[0]
 	}
 	subtopic := queryString["subtopic"]
-	st := "placeholder"
+	st := ""
 	if len(subtopic) > 0 {
 		st = subtopic[0]
 	}
@@ -884,6 +1204,111 @@ func findSubstring(response http.ResponseWriter, request *http.Request) {
 	}
 }
 
+// charDataResults holds the results of a character lookup
+type charDataResults struct {
+	Query           string
+	Char            *chardata.CharInfo
+	Radical         int
+	RadicalChar     string
+	ResidualStrokes int
+	Chars           []chardata.CharInfo
+}
+
+// charDataHandler looks up a character, characters by Kangxi radical and
+// residual strokes, or characters containing a component
+func charDataHandler(response http.ResponseWriter, request *http.Request) {
+	b := getBackends()
+	log.Println("main.charDataHandler, enter")
+	if b.charData == nil {
+		log.Println("main.charDataHandler character data not configured")
+		http.Error(response, "Error, character data not configured",
+			http.StatusInternalServerError)
+		return
+	}
+	results := charDataResults{
+		Chars: []chardata.CharInfo{},
+	}
+	ch := getSingleValue(request, "char")
+	component := getSingleValue(request, "component")
+	radical := getSingleValue(request, "radical")
+	switch {
+	case len(ch) > 0:
+		results.Query = ch
+		if ci, ok := b.charData.Lookup(ch); ok {
+			results.Char = &ci
+		}
+		results.Chars = b.charData.FindByComponent(ch)
+	case len(component) > 0:
+		results.Query = component
+		results.Chars = b.charData.FindByComponent(component)
+	case len(radical) > 0:
+		r, err := strconv.Atoi(radical)
+		if err != nil || len(chardata.RadicalChar(r)) == 0 {
+			log.Printf("main.charDataHandler bad radical %s", radical)
+			http.Error(response, "Bad radical, expected a number from 1 to 214",
+				http.StatusBadRequest)
+			return
+		}
+		residual := -1
+		if rs := getSingleValue(request, "residual"); len(rs) > 0 {
+			residual, err = strconv.Atoi(rs)
+			if err != nil {
+				log.Printf("main.charDataHandler bad residual strokes %s", rs)
+				http.Error(response, "Bad residual strokes, expected a number",
+					http.StatusBadRequest)
+				return
+			}
+		}
+		results.Query = radical
+		results.Radical = r
+		results.RadicalChar = chardata.RadicalChar(r)
+		results.ResidualStrokes = residual
+		results.Chars = b.charData.FindByRadical(r, residual)
+	}
+
+	if httphandling.AcceptHTML(request) {
+		title := b.webConfig.GetVarWithDefault("Title", defTitle)
+		content := htmlContent{
+			Title: title,
+			Query: results.Query,
+			Data: struct {
+				Results  charDataResults
+				Radicals []chardata.Radical
+			}{
+				Results:  results,
+				Radicals: chardata.Radicals(),
+			},
+		}
+		b.pageDisplayer.DisplayPage(response, "chardata.html", content)
+		return
+	}
+
+	resultsJson, err := json.Marshal(results)
+	if err != nil {
+		log.Printf("main.charDataHandler error marshalling JSON, %v", err)
+		http.Error(response, "Error marshalling results",
+			http.StatusInternalServerError)
+	} else {
+		response.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprint(response, string(resultsJson))
+	}
+}
+
+// wordChars gives the distinct characters in the simplified and traditional
+// forms of a word
+func wordChars(w dicttypes.Word) []string {
+	chars := []string{}
+	seen := make(map[rune]bool)
+	for _, r := range w.Simplified + w.Traditional {
+		if seen[r] || !dicttypes.IsCJKChar(string(r)) {
+			continue
+		}
+		seen[r] = true
+		chars = append(chars, string(r))
+	}
+	return chars
+}
+
 // Health check for monitoring or load balancing system, checks reachability
 func healthcheck(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, "OK")
@@ -892,6 +1317,7 @@ func healthcheck(w http.ResponseWriter, r *http.Request) {
 
 // Display library page for digital texts
 func library(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	log.Printf("library: url %s", r.URL.Path)
 
 	title := b.webConfig.GetVarWithDefault("Title", defTitle)
@@ -900,19 +1326,16 @@ func library(w http.ResponseWriter, r *http.Request) {
 	}
 	if config.PasswordProtected() {
 		ctx := context.Background()
-		if b.authenticator == nil {
-			var err error
-			b.authenticator, err = initAuth(ctx)
-			if err != nil {
-				log.Print("library authenticator could not be initialized")
-				http.Error(w, "Server error", http.StatusInternalServerError)
-				return
-			}
+		auth, err := getAuthenticator(ctx, b)
+		if err != nil {
+			log.Print("library authenticator could not be initialized")
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
 		}
 		sessionInfo := identity.InvalidSession()
 		cookie, err := r.Cookie("session")
 		if err == nil {
-			sessionInfo = b.authenticator.CheckSession(ctx, cookie.Value)
+			sessionInfo = auth.CheckSession(ctx, cookie.Value)
 		} else {
 			log.Printf("displayHome error getting cookie: %v", err)
 			b.pageDisplayer.DisplayPage(w, "login_form.html", content)
@@ -932,23 +1355,22 @@ func library(w http.ResponseWriter, r *http.Request) {
 
 // Display login form for the Translation Portal
 func loginFormHandler(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	b.pageDisplayer.DisplayPage(w, "login_form.html", nil)
 }
 
 // Process a login request
 func loginHandler(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	ctx := context.Background()
-	if b.authenticator == nil {
-		var err error
-		b.authenticator, err = initAuth(ctx)
-		if err != nil {
-			log.Print("loginHandler authenticator could not be initialized")
-			http.Error(w, "Server error", http.StatusInternalServerError)
-			return
-		}
+	auth, err := getAuthenticator(ctx, b)
+	if err != nil {
+		log.Print("loginHandler authenticator could not be initialized")
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
 	}
 	sessionInfo := identity.InvalidSession()
-	err := r.ParseForm()
+	err = r.ParseForm()
 	if err != nil {
 		log.Printf("loginHandler: error parsing form: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -957,7 +1379,7 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 	username := r.PostFormValue("UserName")
 	log.Printf("loginHandler: username = %s", username)
 	password := r.PostFormValue("Password")
-	users, err := b.authenticator.CheckLogin(ctx, username, password)
+	users, err := auth.CheckLogin(ctx, username, password)
 	if err != nil {
 		log.Printf("main.loginHandler checking login, %v", err)
 		http.Error(w, "Error checking login", http.StatusInternalServerError)
@@ -969,7 +1391,7 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		cookie, err := r.Cookie("session")
 		if err == nil {
 			log.Printf("loginHandler: updating session: %s", cookie.Value)
-			sessionInfo = b.authenticator.UpdateSession(ctx, cookie.Value, users[0], 1)
+			sessionInfo = auth.UpdateSession(ctx, cookie.Value, users[0], 1)
 		}
 		if (err != nil) || !sessionInfo.Valid {
 			sessionid := identity.NewSessionId()
@@ -984,7 +1406,7 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 				MaxAge: 86400 * 30, // One month
 			}
 			http.SetCookie(w, cookie)
-			sessionInfo = b.authenticator.SaveSession(ctx, sessionid, users[0], 1)
+			sessionInfo = auth.SaveSession(ctx, sessionid, users[0], 1)
 		}
 	}
 	if strings.Contains(r.Header.Get("Accept"), "application/json") {
@@ -1004,6 +1426,7 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 
 // logoutForm displays a form button to logout the user
 func logoutForm(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	log.Print("logoutForm: display form")
 	title := b.webConfig.GetVarWithDefault("Title", defTitle)
 	content := htmlContent{
@@ -1014,23 +1437,21 @@ func logoutForm(w http.ResponseWriter, r *http.Request) {
 
 // logoutHandler logs the user out of their session
 func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	log.Print("logoutHandler: process form")
 	ctx := context.Background()
-	if b.authenticator == nil {
-		var err error
-		b.authenticator, err = initAuth(ctx)
-		if err != nil {
-			log.Print("logoutHandler authenticator could not be initialized")
-			http.Error(w, "Server error", http.StatusInternalServerError)
-			return
-		}
+	auth, err := getAuthenticator(ctx, b)
+	if err != nil {
+		log.Print("logoutHandler authenticator could not be initialized")
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
 	}
 	cookie, err := r.Cookie("session")
 	if err != nil {
 		// OK, just don't show the contents that require a login
 		log.Println("logoutHandler: no cookie")
 	} else {
-		b.authenticator.Logout(ctx, cookie.Value)
+		auth.Logout(ctx, cookie.Value)
 		cookie.MaxAge = -1
 		http.SetCookie(w, cookie)
 	}
@@ -1052,27 +1473,25 @@ func logoutHandler(w http.ResponseWriter, r *http.Request) {
 
 // portalHandler is the starting point for the Translation Portal
 func portalHandler(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	ctx := context.Background()
-	if b.authenticator == nil {
-		var err error
-		b.authenticator, err = initAuth(ctx)
-		if err != nil {
-			log.Print("portalHandler authenticator could not be initialized")
-			http.Error(w, "Server error", http.StatusInternalServerError)
-			return
-		}
+	auth, err := getAuthenticator(ctx, b)
+	if err != nil {
+		log.Print("portalHandler authenticator could not be initialized")
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
 	}
 	sessionInfo := identity.InvalidSession()
 	cookie, err := r.Cookie("session")
 	if err == nil {
-		sessionInfo = b.authenticator.CheckSession(ctx, cookie.Value)
+		sessionInfo = auth.CheckSession(ctx, cookie.Value)
 	} else {
 		log.Printf("portalHandler error getting cookie: %v", err)
 		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
 	}
 	user := sessionInfo.User
-	if identity.IsAuthorized(user, "translation_portal") {
+	if identity.IsAuthorized(user, identity.PermTranslationPortal) {
 		displayHome(w, r)
 	} else {
 		log.Printf("portalHandler %s with role %s not authorized for portal",
@@ -1083,28 +1502,26 @@ func portalHandler(w http.ResponseWriter, r *http.Request) {
 
 // portalLibraryHandler handles static but private pages
 func portalLibraryHandler(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	log.Printf("portalLibraryHandler: url %s", r.URL.Path)
 	ctx := context.Background()
-	if b.authenticator == nil {
-		var err error
-		b.authenticator, err = initAuth(ctx)
-		if err != nil {
-			log.Print("portalLibraryHandler authenticator could not be initialized")
-			http.Error(w, "Server error", http.StatusInternalServerError)
-			return
-		}
+	auth, err := getAuthenticator(ctx, b)
+	if err != nil {
+		log.Print("portalLibraryHandler authenticator could not be initialized")
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
 	}
 	sessionInfo := identity.InvalidSession()
 	cookie, err := r.Cookie("session")
 	if err == nil {
-		sessionInfo = b.authenticator.CheckSession(ctx, cookie.Value)
+		sessionInfo = auth.CheckSession(ctx, cookie.Value)
 	} else {
 		log.Printf("portalLibraryHandler error getting cookie: %v", err)
 		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
 	}
 	user := sessionInfo.User
-	if identity.IsAuthorized(user, "translation_portal") {
+	if identity.IsAuthorized(user, identity.PermTranslationPortal) {
 		portalLibHome := os.Getenv("PORTAL_LIB_HOME")
 		filepart := r.URL.Path[len("/loggedin/portal_library/"):]
 		filename := portalLibHome + "/" + filepart
@@ -1112,7 +1529,7 @@ func portalLibraryHandler(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			log.Printf("portalLibraryHandler os.Stat error: %v for file %s",
 				err, filename)
-			custom404(w, r, filename)
+			custom404(w, r, b, filename)
 			return
 		}
 		log.Printf("portalLibraryHandler: serving file %s", filename)
@@ -1126,6 +1543,7 @@ func portalLibraryHandler(w http.ResponseWriter, r *http.Request) {
 
 // Display form to request a password reset
 func requestResetFormHandler(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	data := identity.RequestResetResult{
 		EmailValid:          true,
 		RequestResetSuccess: false,
@@ -1145,18 +1563,16 @@ func requestResetFormHandler(w http.ResponseWriter, r *http.Request) {
 
 // requestResetHandler processes requests for password reset
 func requestResetHandler(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	ctx := context.Background()
-	if b.authenticator == nil {
-		var err error
-		b.authenticator, err = initAuth(ctx)
-		if err != nil {
-			log.Print("requestResetHandler authenticator could not be initialized")
-			http.Error(w, "Server error", http.StatusInternalServerError)
-			return
-		}
+	auth, err := getAuthenticator(ctx, b)
+	if err != nil {
+		log.Print("requestResetHandler authenticator could not be initialized")
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
 	}
 	email := r.PostFormValue("Email")
-	result := b.authenticator.RequestPasswordReset(ctx, email)
+	result := auth.RequestPasswordReset(ctx, email)
 	if result.RequestResetSuccess {
 		err := identity.SendPasswordReset(result.User, result.Token, b.webConfig)
 		if err != nil {
@@ -1179,6 +1595,7 @@ func requestResetHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func resetPasswordFormHandler(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	queryString := r.URL.Query()
 	token := queryString["token"]
 	content := make(map[string]string)
@@ -1191,20 +1608,18 @@ func resetPasswordFormHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	log.Println("resetPasswordHandler enter")
 	ctx := context.Background()
-	if b.authenticator == nil {
-		var err error
-		b.authenticator, err = initAuth(ctx)
-		if err != nil {
-			log.Print("resetPasswordHandler authenticator could not be initialized")
-			http.Error(w, "Server error", http.StatusInternalServerError)
-			return
-		}
+	auth, err := getAuthenticator(ctx, b)
+	if err != nil {
+		log.Print("resetPasswordHandler authenticator could not be initialized")
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
 	}
 	token := r.PostFormValue("Token")
 	newPassword := r.PostFormValue("NewPassword")
-	result := b.authenticator.ResetPassword(ctx, token, newPassword)
+	result := auth.ResetPassword(ctx, token, newPassword)
 	content := make(map[string]bool)
 	if result {
 		content["ResetPasswordSuccessful"] = true
@@ -1230,20 +1645,18 @@ func sendJSON(w http.ResponseWriter, obj interface{}) {
 // sessionHandler checks to see if the user has a session.
 // It is used by a JavaScript client to maintain a session.
 func sessionHandler(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	ctx := context.Background()
-	if b.authenticator == nil {
-		var err error
-		b.authenticator, err = initAuth(ctx)
-		if err != nil {
-			log.Print("sessionHandler authenticator could not be initialized")
-			http.Error(w, "Server error", http.StatusInternalServerError)
-			return
-		}
+	auth, err := getAuthenticator(ctx, b)
+	if err != nil {
+		log.Print("sessionHandler authenticator could not be initialized")
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
 	}
 	sessionInfo := identity.InvalidSession()
 	cookie, err := r.Cookie("session")
 	if err == nil {
-		sessionInfo = b.authenticator.CheckSession(ctx, cookie.Value)
+		sessionInfo = auth.CheckSession(ctx, cookie.Value)
 	}
 	if (err != nil) || (!sessionInfo.Valid) {
 		// OK, just don't show the contents that don't require a login
@@ -1264,7 +1677,7 @@ func sessionHandler(w http.ResponseWriter, r *http.Request) {
 			FullName: "",
 			Role:     "",
 		}
-		b.authenticator.SaveSession(ctx, sessionid, userInfo, 0)
+		auth.SaveSession(ctx, sessionid, userInfo, 0)
 	}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	resultsJson, err := json.Marshal(sessionInfo)
@@ -1275,27 +1688,49 @@ func sessionHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // Call the relevant API to translate text.
-func translate(b *backends, sourceText, platform string) (*string, error) {
+func translate(ctx context.Context, b *backends, sourceText, platform string) (*transtools.Result, error) {
+	client, err := translationClient(b, platform)
+	if err != nil {
+		return nil, err
+	}
+	return client.Translate(ctx, translationRequest(b, sourceText))
+}
+
+// translationRequest gives a request to translate the text with the languages
+// and options in the web app configuration
+func translationRequest(b *backends, text string) transtools.Request {
+	return transtools.Request{
+		Text:       text,
+		SourceLang: b.webConfig.TranslationSourceLang(),
+		TargetLang: b.webConfig.TranslationTargetLang(),
+		Formality:  b.webConfig.TranslationFormality(),
+	}
+}
+
+// translationClient gets the API client for the platform, DeepL, gcp, or
+// otherwise the client with a glossary
+func translationClient(b *backends, platform string) (transtools.ApiClient, error) {
 	if platform == "DeepL" {
 		if b.deepLApiClient == nil {
 			return nil, fmt.Errorf("DeepL API client not initialized: %s", platform)
 		}
-		return b.deepLApiClient.Translate(sourceText)
+		return b.deepLApiClient, nil
 	}
 	if platform == "gcp" {
 		if b.translateApiClient == nil {
 			return nil, fmt.Errorf("GCP API client not initialized: %s", platform)
 		}
-		return b.translateApiClient.Translate(sourceText)
+		return b.translateApiClient, nil
 	}
 	if b.glossaryApiClient == nil {
 		return nil, fmt.Errorf("API client still not initialized: %s", platform)
 	}
-	return b.glossaryApiClient.Translate(sourceText)
+	return b.glossaryApiClient, nil
 }
 
 // Initialzie an empty translation page and display it.
 func translationHome(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	if config.PasswordProtected() {
 		ctx := context.Background()
 		sessionInfo := b.sessionEnforcer.EnforceValidSession(ctx, w, r)
@@ -1319,6 +1754,7 @@ func translationHome(w http.ResponseWriter, r *http.Request) {
 
 // translationMemory handles requests for translation memory searches
 func translationMemory(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	ctx := context.Background()
 	if config.PasswordProtected() {
 		sessionInfo := b.sessionEnforcer.EnforceValidSession(ctx, w, r)
@@ -1345,19 +1781,39 @@ func translationMemory(w http.ResponseWriter, r *http.Request) {
 	log.Printf("main.translationMemory Query: %s, domain: %s", q, d)
 	if b == nil {
 		var err error
-		b, err = initApp(ctx)
+		b, err = reloadBackends(ctx)
 		if err != nil {
 			log.Printf("translationMemory initalizing app, error: %v", err)
 			http.Error(w, "Internal error", http.StatusInternalServerError)
 			return
 		}
 	}
+	if getSingleValue(r, "mode") == "sentence" {
+		findSegments(w, r, b, q, title)
+		return
+	}
 	if b.tmSearcher == nil || b.dict == nil {
 		log.Printf("main.translationMemory b.tmSearcher == nil || dict == nil: %v, %v", b.tmSearcher, b.dict)
 		http.Error(w, "Internal Error", http.StatusInternalServerError)
 		return
 	}
-	results, err := b.tmSearcher.Search(ctx, q, d, true, b.dict.Wdict)
+	opts := transmemory.DomainOptions{
+		Boost: getSingleValue(r, "boost") == "true",
+	}
+	if len(d) > 0 {
+		opts.Domains = strings.Split(d, ",")
+	}
+	var results *transmemory.Results
+	var err error
+	if ds, ok := b.tmSearcher.(transmemory.DomainSearcher); ok {
+		results, err = ds.SearchDomains(ctx, q, opts, true, b.dict.Wdict)
+	} else {
+		domain := ""
+		if len(opts.Domains) == 1 && !opts.Boost {
+			domain = opts.Domains[0]
+		}
+		results, err = b.tmSearcher.Search(ctx, q, domain, true, b.dict.Wdict)
+	}
 	if err != nil {
 		log.Printf("main.translationMemory error searching, %v", err)
 		http.Error(w, "Internal Error", http.StatusInternalServerError)
@@ -1399,8 +1855,46 @@ func getHeadwordId(path string) (int, error) {
 	return hwId, nil
 }
 
+// relatedWords gives the compounds, sub-words, and words with the same concept
+// or domain for a headword, returns JSON
+func relatedWords(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
+	if config.PasswordProtected() {
+		sessionInfo := b.sessionEnforcer.EnforceValidSession(r.Context(), w, r)
+		if !sessionInfo.Valid {
+			return
+		}
+	}
+	hwId, err := getHeadwordId(r.URL.Path)
+	if err != nil {
+		log.Printf("main.relatedWords headword not found: %v", err)
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if b.relatedFinder == nil {
+		log.Println("main.relatedWords related word finder not configured")
+		http.Error(w, "Error, related words not configured",
+			http.StatusInternalServerError)
+		return
+	}
+	related, ok := b.relatedFinder.FindRelated(r.Context(), hwId)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Not found: %d", hwId), http.StatusNotFound)
+		return
+	}
+	resultsJson, err := json.Marshal(related)
+	if err != nil {
+		log.Printf("main.relatedWords error marshalling JSON, %v", err)
+		http.Error(w, "Error marshalling results", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprint(w, string(resultsJson))
+}
+
 // wordDetail shows details for a single word entry, returns HTML
 func wordDetail(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
 	if config.PasswordProtected() {
 		ctx := context.Background()
 		sessionInfo := b.sessionEnforcer.EnforceValidSession(ctx, w, r)
@@ -1422,12 +1916,35 @@ func wordDetail(w http.ResponseWriter, r *http.Request) {
 		replace := b.webConfig.GetVar("NotesReplace")
 		processor := dictionary.NewNotesProcessor(match, replace)
 		word := processor.Process(*hw)
+		system := getRomanization(r)
+		word = romanization.ApplyWord(word, system)
+		var chars []string
+		if b.charData != nil {
+			chars = wordChars(*hw)
+		}
+		usage := getWordUsage(b, *hw, fulltext.GetConcordance)
+		var related *dictionary.RelatedWords
+		if b.relatedFinder != nil {
+			related, _ = b.relatedFinder.FindRelated(r.Context(), hwId)
+		}
 		content := htmlContent{
 			Title: title,
 			Data: struct {
-				Word dicttypes.Word
+				Word         dicttypes.Word
+				Entry        dicttypes.Word // Before processing notes, for editing
+				CanPropose   bool
+				Romanization string
+				Characters   []string // For links to character data, if loaded
+				Usage        *wordUsage
+				Related      *dictionary.RelatedWords
 			}{
-				Word: word,
+				Word:         word,
+				Entry:        *hw,
+				CanPropose:   canProposeEdits(r.Context(), b, r),
+				Romanization: system,
+				Characters:   chars,
+				Usage:        usage,
+				Related:      related,
 			},
 		}
 		b.pageDisplayer.DisplayPage(w, "word_detail.html", content)
@@ -1438,6 +1955,41 @@ func wordDetail(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, msg, http.StatusNotFound)
 }
 
+// romanizeResults replaces the pinyin in the terms found with the given
+// romanization
+func romanizeResults(results find.QueryResults, system string) find.QueryResults {
+	romanize := func(segments []find.TextSegment) []find.TextSegment {
+		if segments == nil {
+			return nil
+		}
+		r := make([]find.TextSegment, len(segments))
+		for i, seg := range segments {
+			senses := make([]dicttypes.WordSense, len(seg.Senses))
+			for j, ws := range seg.Senses {
+				senses[j] = romanization.ApplySense(ws, system)
+			}
+			r[i] = find.TextSegment{
+				QueryText: seg.QueryText,
+				DictEntry: romanization.ApplyWord(seg.DictEntry, system),
+				Senses:    senses,
+			}
+		}
+		return r
+	}
+	results.Terms = romanize(results.Terms)
+	results.SimilarTerms = romanize(results.SimilarTerms)
+	return results
+}
+
+// getRomanization gives the romanization system requested, pinyin by default
+func getRomanization(r *http.Request) string {
+	system := strings.ToLower(getSingleValue(r, "romanization"))
+	if !romanization.IsValid(system) {
+		return romanization.Pinyin
+	}
+	return system
+}
+
 // Entry point for the web application
 func main() {
 	start := time.Now()
@@ -1458,7 +2010,10 @@ func main() {
 	http.HandleFunc("/find/", findHandler)
 	http.HandleFunc("/findadvanced/", findFullText)
 	http.HandleFunc("/findsubstring", findSubstring)
+	http.HandleFunc("/chardata", charDataHandler)
 	http.HandleFunc("/findtm", translationMemory)
+	http.HandleFunc("/tmx", tmxHandler)
+	http.HandleFunc("/xliff", xliffHandler)
 	http.HandleFunc("/healthcheck", healthcheck)
 	http.HandleFunc("/loggedin/changepassword", changePasswordFormHandler)
 	http.HandleFunc("/library", library)
@@ -1474,14 +2029,21 @@ func main() {
 	http.HandleFunc("/loggedin/reset_password", resetPasswordFormHandler)
 	http.HandleFunc("/loggedin/reset_password_submit", resetPasswordHandler)
 	http.HandleFunc("/loggedin/submitcpwd", changePasswordHandler)
+	http.HandleFunc("/loggedin/admin/reload", reloadHandler)
+	http.HandleFunc("/loggedin/propose", proposeHandler)
+	http.HandleFunc("/loggedin/review", reviewHandler)
+	http.HandleFunc("/loggedin/importtmx", importTMXHandler)
+	http.HandleFunc("/loggedin/importxliff", importXLIFFHandler)
 	if b != nil {
 		initTranslationClients(b)
 	} else {
 		log.Println("cnweb.man b == nil")
 	}
 	http.HandleFunc("/translateprocess", processTranslation)
+	http.HandleFunc("/translatechoice", translationChoice)
 	http.HandleFunc("/translate", translationHome)
 	http.HandleFunc("/words/", wordDetail)
+	http.HandleFunc("/relatedwords/", relatedWords)
 
 	// If serving static HTML content
 	staticBucket := b.webConfig.GetVar("StaticBucket")
@@ -1505,6 +2067,12 @@ func main() {
 		http.Handle("/web/", http.StripPrefix("/web/", sh))
 	}
 
+	if b != nil {
+		if interval := b.webConfig.ReloadInterval(); interval > 0 {
+			go pollForChanges(ctx, interval)
+		}
+	}
+
 	portStr := ":" + strconv.Itoa(config.GetPort())
 	startupTime := time.Since(start)
 	log.Printf("cnweb.main Started in %d millis, http server running at http://localhost%s", startupTime.Milliseconds(), portStr)
@@ -2,15 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/alexamies/chinesenotes-go/chardata"
 	"github.com/alexamies/chinesenotes-go/config"
 	"github.com/alexamies/chinesenotes-go/dictionary"
 	"github.com/alexamies/chinesenotes-go/dicttypes"
@@ -19,7 +22,9 @@ import (
 	"github.com/alexamies/chinesenotes-go/httphandling"
 	"github.com/alexamies/chinesenotes-go/identity"
 	"github.com/alexamies/chinesenotes-go/templates"
+	"github.com/alexamies/chinesenotes-go/termfreq"
 	"github.com/alexamies/chinesenotes-go/transmemory"
+	"github.com/alexamies/chinesenotes-go/transtools"
 )
 
 type AuthenticatorMock struct{}
@@ -241,12 +246,30 @@ func (m mockDocFinder) Inititialized() bool {
 type mockApiClient struct {
 }
 
-func (m mockApiClient) Translate(sourceText string) (*string, error) {
-	if sourceText == "人" {
-		translation := "person"
-		return &translation, nil
+func (m mockApiClient) Translate(ctx context.Context, req transtools.Request) (*transtools.Result, error) {
+	if req.Text == "人" {
+		return &transtools.Result{Text: "person", Provider: "mock"}, nil
 	}
-	return nil, fmt.Errorf("Do not know how to translate %s", sourceText)
+	return nil, fmt.Errorf("Do not know how to translate %s", req.Text)
+}
+
+// mockTextApiClient gives the same translation for any text
+type mockTextApiClient struct {
+	text string
+}
+
+func (m mockTextApiClient) Translate(ctx context.Context, req transtools.Request) (*transtools.Result, error) {
+	return &transtools.Result{Text: m.text, Provider: "mock"}, nil
+}
+
+// countingApiClient counts the calls made to it
+type countingApiClient struct {
+	calls *int
+}
+
+func (m countingApiClient) Translate(ctx context.Context, req transtools.Request) (*transtools.Result, error) {
+	*m.calls++
+	return &transtools.Result{Text: "counted", Provider: "mock"}, nil
 }
 
 // TestMain runs integration tests if the flag -integration is set
@@ -417,7 +440,7 @@ func TestChangePasswordFormHandler(t *testing.T) {
 func TestCustom404(t *testing.T) {
 	templates := templates.NewTemplateMap(config.WebAppConfig{})
 	pageDisplayer := httphandling.NewPageDisplayer(templates)
-	b = &backends{
+	b := &backends{
 		templates:     templates,
 		pageDisplayer: pageDisplayer,
 	}
@@ -434,14 +457,13 @@ func TestCustom404(t *testing.T) {
 	for _, tc := range tests {
 		r := httptest.NewRequest(http.MethodGet, "/xyz", nil)
 		w := httptest.NewRecorder()
-		custom404(w, r, "/xyz")
+		custom404(w, r, b, "/xyz")
 		result := w.Body.String()
 		if !strings.Contains(result, tc.expectContains) {
 			t.Errorf("TestCustom404 %s: expectContains %q, got %q",
 				tc.name, tc.expectContains, result)
 		}
 	}
-	b = nil
 }
 
 type mockTitleFinder struct {
@@ -606,9 +628,6 @@ func TestFindDocs(t *testing.T) {
 		colMap := map[string]string{}
 		docMap := map[string]find.DocInfo{}
 		titleFinder := newMockTitleFinder(collections, tc.docs, colMap, docMap)
-		b = &backends{
-			docTitleFinder: titleFinder,
-		}
 
 		dict := dictionary.NewDictionary(mockSmallDict())
 
@@ -622,10 +641,11 @@ func TestFindDocs(t *testing.T) {
 			df: mockDocFinder{
 				reverseIndex: reverseIndex,
 			},
-			tmSearcher: mockTMSearcher{},
-			dict:       dict,
-			parser:     find.NewQueryParser(dict.Wdict),
-			templates:  templates.NewTemplateMap(config.WebAppConfig{}),
+			tmSearcher:     mockTMSearcher{},
+			dict:           dict,
+			parser:         find.NewQueryParser(dict.Wdict),
+			templates:      templates.NewTemplateMap(config.WebAppConfig{}),
+			docTitleFinder: titleFinder,
 		}
 		r := httptest.NewRequest(http.MethodGet, u, nil)
 		r.Header.Add("Accept", tc.acceptHeader)
@@ -825,9 +845,45 @@ func TestFindSubstring(t *testing.T) {
 			query:          "可思议",
 			expectContains: "Error, index not configured\n",
 		},
+		{
+			name:           "In memory",
+			query:          "可思议",
+			expectContains: `"Simplified":"不可思议"`,
+		},
+		{
+			name:           "In memory with wildcard",
+			query:          "不_思议",
+			expectContains: `"Simplified":"不可思议"`,
+		},
 	}
 	for _, tc := range tests {
-		u := "/findsubstring?query=" + tc.query
+		if tc.name != "No configured" {
+			s1 := "不可思议"
+			ws := dicttypes.WordSense{
+				Id:          1,
+				HeadwordId:  1,
+				Simplified:  s1,
+				Traditional: "\\N",
+				Pinyin:      "bùkěsīyì",
+				English:     "inconceivable",
+			}
+			wdict := map[string]*dicttypes.Word{
+				s1: {
+					HeadwordId:  1,
+					Simplified:  s1,
+					Traditional: "\\N",
+					Pinyin:      ws.Pinyin,
+					Senses:      []dicttypes.WordSense{ws},
+				},
+			}
+			substrIndex, err := dictionary.NewSubstringIndexMem(context.Background(),
+				dictionary.NewDictionary(wdict))
+			if err != nil {
+				t.Fatalf("TestFindSubstring %s: unexpected error: %v", tc.name, err)
+			}
+			b.substrIndex = substrIndex
+		}
+		u := "/findsubstring?query=" + url.QueryEscape(tc.query)
 		r := httptest.NewRequest(http.MethodGet, u, nil)
 		w := httptest.NewRecorder()
 		findSubstring(w, r)
@@ -840,6 +896,147 @@ func TestFindSubstring(t *testing.T) {
 	b = nil
 }
 
+// TestCharDataHandler tests character lookup by radical and component
+func TestCharDataHandler(t *testing.T) {
+	templates := templates.NewTemplateMap(config.WebAppConfig{})
+	pageDisplayer := httphandling.NewPageDisplayer(templates)
+	b = &backends{
+		templates:     templates,
+		pageDisplayer: pageDisplayer,
+	}
+	r := httptest.NewRequest(http.MethodGet, "/chardata?char=好", nil)
+	w := httptest.NewRecorder()
+	charDataHandler(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("TestCharDataHandler not configured: got status %d", w.Code)
+	}
+	cd, err := chardata.Load(strings.NewReader("好\t38.3\t6\t⿰女子\n字\t39.3\t6\t⿱宀子\n"))
+	if err != nil {
+		t.Fatalf("TestCharDataHandler: unexpected error: %v", err)
+	}
+	b.charData = cd
+	type test struct {
+		name           string
+		query          string
+		html           bool
+		expectCode     int
+		expectContains string
+	}
+	tests := []test{
+		{
+			name:           "Character",
+			query:          "char=" + url.QueryEscape("好"),
+			expectCode:     http.StatusOK,
+			expectContains: `"Char":{"Character":"好","Radical":38`,
+		},
+		{
+			name:           "Component",
+			query:          "component=" + url.QueryEscape("子"),
+			expectCode:     http.StatusOK,
+			expectContains: `"Chars":[{"Character":"好"`,
+		},
+		{
+			name:           "Radical and residual strokes",
+			query:          "radical=39&residual=3",
+			expectCode:     http.StatusOK,
+			expectContains: `"Chars":[{"Character":"字"`,
+		},
+		{
+			name:           "Bad radical",
+			query:          "radical=300",
+			expectCode:     http.StatusBadRequest,
+			expectContains: "Bad radical",
+		},
+		{
+			name:           "HTML",
+			query:          "radical=38",
+			html:           true,
+			expectCode:     http.StatusOK,
+			expectContains: `<a href="/chardata?char=%E5%A5%BD">好</a>`,
+		},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/chardata?"+tc.query, nil)
+		if tc.html {
+			r.Header.Set("Accept", "text/html")
+		}
+		w := httptest.NewRecorder()
+		charDataHandler(w, r)
+		if w.Code != tc.expectCode {
+			t.Errorf("TestCharDataHandler %s: got status %d, want %d", tc.name,
+				w.Code, tc.expectCode)
+		}
+		result := w.Body.String()
+		if !strings.Contains(result, tc.expectContains) {
+			t.Errorf("TestCharDataHandler %s: got %q, expectContains %q", tc.name,
+				result, tc.expectContains)
+		}
+	}
+	b = nil
+}
+
+// TestRelatedWords tests the JSON API for related words
+func TestRelatedWords(t *testing.T) {
+	smallDict := mockSmallDict()
+	dict := dictionary.NewDictionary(smallDict)
+	substrIndex, err := dictionary.NewSubstringIndexMem(context.Background(), dict)
+	if err != nil {
+		t.Fatalf("TestRelatedWords: unexpected error: %v", err)
+	}
+	b = &backends{dict: dict}
+	type test struct {
+		name           string
+		path           string
+		finder         dictionary.RelatedFinder
+		expectCode     int
+		expectContains string
+	}
+	tests := []test{
+		{
+			name:           "Not configured",
+			path:           "/relatedwords/1",
+			expectCode:     http.StatusInternalServerError,
+			expectContains: "Error, related words not configured",
+		},
+		{
+			name:           "No headword id",
+			path:           "/relatedwords/",
+			finder:         dictionary.NewRelatedFinder(dict, substrIndex),
+			expectCode:     http.StatusNotFound,
+			expectContains: "Not found",
+		},
+		{
+			name:           "Headword not found",
+			path:           "/relatedwords/123",
+			finder:         dictionary.NewRelatedFinder(dict, substrIndex),
+			expectCode:     http.StatusNotFound,
+			expectContains: "Not found: 123",
+		},
+		{
+			name:           "Found",
+			path:           "/relatedwords/1",
+			finder:         dictionary.NewRelatedFinder(dict, substrIndex),
+			expectCode:     http.StatusOK,
+			expectContains: `{"HeadwordId":1,"Compounds":[],`,
+		},
+	}
+	for _, tc := range tests {
+		b.relatedFinder = tc.finder
+		r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		w := httptest.NewRecorder()
+		relatedWords(w, r)
+		if w.Code != tc.expectCode {
+			t.Errorf("TestRelatedWords %s: got status %d, want %d", tc.name, w.Code,
+				tc.expectCode)
+		}
+		if result := w.Body.String(); !strings.Contains(result, tc.expectContains) {
+			t.Errorf("TestRelatedWords %s: got %q, want contains %q", tc.name,
+				result, tc.expectContains)
+		}
+	}
+	b = nil
+}
+
 // Test site domain
 func TestGetSiteDomain(t *testing.T) {
 	domain := config.GetSiteDomain()
@@ -1026,6 +1223,18 @@ func (s mockTMSearcher) Search(ctx context.Context,
 	return &r, nil
 }
 
+type mockDomainTMSearcher struct {
+	mockTMSearcher
+}
+
+func (s mockDomainTMSearcher) SearchDomains(ctx context.Context,
+	query string,
+	opts transmemory.DomainOptions,
+	includeSubstrings bool,
+	wdict map[string]*dicttypes.Word) (*transmemory.Results, error) {
+	return s.Search(ctx, query, strings.Join(opts.Domains, ","), includeSubstrings, wdict)
+}
+
 // TestTranslationMemory tests translationMemory function.
 func TestTranslationMemory(t *testing.T) {
 	jieshi := dicttypes.Word{
@@ -1078,21 +1287,27 @@ func TestTranslationMemory(t *testing.T) {
 			t.Fatalf("TestTranslationMemory %s: not able to create extractor: %v", tc.name, err)
 		}
 		reverseIndex := dictionary.NewReverseIndex(dict, extractor)
-		b = &backends{
-			reverseIndex: reverseIndex,
-			df:           mockDocFinder{},
-			tmSearcher:   mockTMSearcher{tc.words},
-			dict:         dictionary.NewDictionary(wdict),
-			parser:       find.NewQueryParser(wdict),
+		searchers := []transmemory.Searcher{
+			mockTMSearcher{tc.words},
+			mockDomainTMSearcher{mockTMSearcher{tc.words}},
 		}
-		u := "/findtm?query=" + tc.query
-		r := httptest.NewRequest(http.MethodGet, u, nil)
-		w := httptest.NewRecorder()
-		translationMemory(w, r)
-		result := w.Body.String()
-		if !strings.Contains(result, tc.expectContains) {
-			t.Errorf("TestTranslationMemory %s: got %q, want %q, ", tc.name, result,
-				tc.expectContains)
+		for _, tms := range searchers {
+			b = &backends{
+				reverseIndex: reverseIndex,
+				df:           mockDocFinder{},
+				tmSearcher:   tms,
+				dict:         dictionary.NewDictionary(wdict),
+				parser:       find.NewQueryParser(wdict),
+			}
+			u := "/findtm?query=" + tc.query
+			r := httptest.NewRequest(http.MethodGet, u, nil)
+			w := httptest.NewRecorder()
+			translationMemory(w, r)
+			result := w.Body.String()
+			if !strings.Contains(result, tc.expectContains) {
+				t.Errorf("TestTranslationMemory %s with %T: got %q, want %q, ", tc.name,
+					tms, result, tc.expectContains)
+			}
 		}
 	}
 	b = nil
@@ -1242,6 +1457,178 @@ func TestProcessTranslation(t *testing.T) {
 	b = nil
 }
 
+// TestProcessTranslationOffline tests the translation workflow with a local
+// translation server configured in place of the cloud APIs
+func TestProcessTranslationOffline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Q, Source, Target string
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"translatedText":"offline %s to %s"}`, req.Source, req.Target)
+	}))
+	defer server.Close()
+	webConfig := config.WebAppConfig{
+		ConfigVars: map[string]string{
+			"LocalTranslationURL": server.URL,
+		},
+	}
+	templates := templates.NewTemplateMap(webConfig)
+	b = &backends{
+		templates:     templates,
+		webConfig:     webConfig,
+		pageDisplayer: httphandling.NewPageDisplayer(templates),
+	}
+	initTranslationClients(b)
+	for _, platform := range []string{"DeepL", "gcp", "withGlossary"} {
+		r := &http.Request{
+			Method: "POST",
+			URL:    &url.URL{Path: "translateprocess"},
+			Form: url.Values{
+				"source":   []string{"人"},
+				"platform": []string{platform},
+			},
+		}
+		w := httptest.NewRecorder()
+		processTranslation(w, r)
+		if result := w.Body.String(); !strings.Contains(result, "offline zh to en") {
+			t.Errorf("TestProcessTranslationOffline %s: got %q", platform, result)
+		}
+	}
+	b = nil
+}
+
+// TestProcessTranslationCompare tests the comparison of all platforms
+func TestProcessTranslationCompare(t *testing.T) {
+	webConfig := config.WebAppConfig{
+		ConfigVars: map[string]string{},
+	}
+	templates := templates.NewTemplateMap(webConfig)
+	b = &backends{
+		templates:         templates,
+		webConfig:         webConfig,
+		pageDisplayer:     httphandling.NewPageDisplayer(templates),
+		deepLApiClient:    mockTextApiClient{"a good person"},
+		glossaryApiClient: mockTextApiClient{"a kind person"},
+	}
+	r := &http.Request{
+		Method: "POST",
+		URL:    &url.URL{Path: "translateprocess"},
+		Form: url.Values{
+			"source":   []string{"好人"},
+			"platform": []string{"compare"},
+		},
+	}
+	w := httptest.NewRecorder()
+	processTranslation(w, r)
+	result := w.Body.String()
+	expected := []string{
+		"a <mark>good</mark> person",
+		"a <mark>kind</mark> person",
+		"gcp: gcp is not configured",
+		`name="output_withGlossary"`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(result, e) {
+			t.Errorf("TestProcessTranslationCompare: got %q, want contains %q", result, e)
+		}
+	}
+
+	// Without a session no translation API is called
+	os.Setenv("PROTECTED", "true")
+	defer os.Unsetenv("PROTECTED")
+	calls := 0
+	authenticator := makeAuthenticatorMock()
+	b.authenticator = authenticator
+	b.sessionEnforcer = httphandling.NewSessionEnforcer(authenticator, b.pageDisplayer)
+	b.deepLApiClient = countingApiClient{&calls}
+	b.glossaryApiClient = countingApiClient{&calls}
+	processTranslation(httptest.NewRecorder(), r)
+	if calls != 0 {
+		t.Errorf("TestProcessTranslationCompare: got %d API calls without a session", calls)
+	}
+	b = nil
+}
+
+// TestTranslationChoice tests saving the translation picked or merged
+func TestTranslationChoice(t *testing.T) {
+	fName := filepath.Join(t.TempDir(), "choices.jsonl")
+	webConfig := config.WebAppConfig{
+		ConfigVars: map[string]string{
+			"TranslationChoiceFile": fName,
+		},
+	}
+	templates := templates.NewTemplateMap(webConfig)
+	b = &backends{
+		templates:     templates,
+		webConfig:     webConfig,
+		pageDisplayer: httphandling.NewPageDisplayer(templates),
+	}
+	tests := []struct {
+		name       string
+		translated string
+		want       string
+	}{
+		{
+			name: "Picked",
+			want: "DeepL",
+		},
+		{
+			name:       "Merged",
+			translated: "a kind and good person",
+			want:       transtools.ChoiceMerged,
+		},
+	}
+	for _, tc := range tests {
+		r := &http.Request{
+			Method: "POST",
+			URL:    &url.URL{Path: "translatechoice"},
+			Form: url.Values{
+				"source":              []string{"好人"},
+				"engine":              []string{"DeepL"},
+				"translated":          []string{tc.translated},
+				"output_DeepL":        []string{"a good person"},
+				"output_withGlossary": []string{"a kind person"},
+			},
+		}
+		w := httptest.NewRecorder()
+		translationChoice(w, r)
+		if result := w.Body.String(); !strings.Contains(result, "has been saved") {
+			t.Errorf("TestTranslationChoice %s: got %q", tc.name, result)
+		}
+	}
+	// A GET does not save a choice
+	r := httptest.NewRequest(http.MethodGet,
+		"/translatechoice?source=x&engine=DeepL&output_DeepL=y", nil)
+	w := httptest.NewRecorder()
+	translationChoice(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("TestTranslationChoice GET: got status %d, want %d", w.Code,
+			http.StatusMethodNotAllowed)
+	}
+	data, err := os.ReadFile(fName)
+	if err != nil {
+		t.Fatalf("TestTranslationChoice: could not read choices: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != len(tests) {
+		t.Fatalf("TestTranslationChoice: got %d choices, want %d", len(lines), len(tests))
+	}
+	for i, tc := range tests {
+		var choice transtools.Choice
+		if err := json.Unmarshal([]byte(lines[i]), &choice); err != nil {
+			t.Fatalf("TestTranslationChoice %s: could not decode: %v", tc.name, err)
+		}
+		if choice.Engine != tc.want || len(choice.Outputs) != 2 {
+			t.Errorf("TestTranslationChoice %s: got %+v, want engine %s", tc.name, choice, tc.want)
+		}
+	}
+	b = nil
+}
+
 func TestWordDetail(t *testing.T) {
 	smallDict := mockSmallDict()
 	s := "一時三相"
@@ -1267,6 +1654,10 @@ func TestWordDetail(t *testing.T) {
 	type test struct {
 		name           string
 		hwId           int
+		query          string
+		charData       bool
+		termIndex      string
+		related        bool
 		wdict          map[string]*dicttypes.Word
 		expectContains string
 	}
@@ -1289,9 +1680,51 @@ func TestWordDetail(t *testing.T) {
 			wdict:          dictWNotes,
 			expectContains: `<a href="/web/1.html">FGDB entry</a>`,
 		},
+		{
+			name:           "Zhuyin",
+			hwId:           1,
+			query:          "?romanization=zhuyin",
+			wdict:          smallDict,
+			expectContains: "ㄈㄢˊ ㄊㄧˇ ㄓㄨㄥ ㄨㄣˊ",
+		},
+		{
+			name:           "Wade-Giles",
+			hwId:           1,
+			query:          "?romanization=wadegiles",
+			wdict:          smallDict,
+			expectContains: "fan²-t'i³ chung¹-wên²",
+		},
+		{
+			name:           "Unknown romanization",
+			hwId:           1,
+			query:          "?romanization=klingon",
+			wdict:          smallDict,
+			expectContains: "fántǐ zhōngwén",
+		},
+		{
+			name:           "Character links",
+			hwId:           1,
+			wdict:          smallDict,
+			charData:       true,
+			expectContains: `<a href="/chardata?char=%E9%AB%94">體</a>`,
+		},
+		{
+			name:           "Corpus usage",
+			hwId:           1,
+			wdict:          smallDict,
+			termIndex:      `{"繁體中文":[{"Filename":"a.html","Count":3}]}`,
+			expectContains: "Frequency in the corpus: 3",
+		},
+		{
+			name:           "Related words",
+			hwId:           1,
+			wdict:          smallDict,
+			related:        true,
+			expectContains: "<h3>Related Words</h3>",
+		},
 	}
 	for _, tc := range tests {
-		u := fmt.Sprintf("/words/%d.html", tc.hwId)
+		u := fmt.Sprintf("/words/%d.html%s", tc.hwId, tc.query)
 		dict := dictionary.NewDictionary(tc.wdict)
 		extractor, err := dictionary.NewNotesExtractor("")
 		if err != nil {
@@ -1310,6 +1743,21 @@ func TestWordDetail(t *testing.T) {
 			webConfig:     webConfig,
 			pageDisplayer: pageDisplayer,
 		}
+		if tc.related {
+			b.relatedFinder = dictionary.NewRelatedFinder(dict, nil)
+		}
+		if len(tc.termIndex) > 0 {
+			b.termIndex, err = termfreq.LoadKeywordIndex(strings.NewReader(tc.termIndex))
+			if err != nil {
+				t.Fatalf("TestWordDetail %s: not able to load term index: %v", tc.name, err)
+			}
+		}
+		if tc.charData {
+			b.charData, err = chardata.Load(strings.NewReader(""))
+			if err != nil {
+				t.Fatalf("TestWordDetail %s: not able to load char data: %v", tc.name, err)
+			}
+		}
 		r := httptest.NewRequest(http.MethodGet, u, nil)
 		w := httptest.NewRecorder()
 		wordDetail(w, r)
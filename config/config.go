@@ -37,6 +37,25 @@ type AppConfig struct {
 
 	// A list of files to read the lexical units in the dictionary from
 	LUFileNames []string
+
+	// The files in LUFileNames with their format and priority
+	LUSources []LUSource
+}
+
+// LUSource is a file to read lexical units from.
+//
+// In config.yaml each entry in LUFiles has the form file[:format[:priority]],
+// eg words.txt,cedict_ts.u8:cedict:-1. If the format is omitted it is guessed
+// from the file extension. When the same headword is found in more than one
+// source the entries from the source with highest priority are used. The
+// default priority is 0.
+type LUSource struct {
+	FileName string
+
+	// One of tsv, cedict, or json
+	Format string
+
+	Priority int
 }
 
 // InitConfig sets application configuration data
@@ -58,7 +77,11 @@ func InitConfig() AppConfig {
 		configVars = make(map[string]string)
 	}
 	c.ConfigVars = configVars
-	c.LUFileNames = readLUFileNames(configVars, c.DictionaryDir())
+	c.LUSources = readLUSources(configVars, c.DictionaryDir())
+	c.LUFileNames = []string{}
+	for _, src := range c.LUSources {
+		c.LUFileNames = append(c.LUFileNames, src.FileName)
+	}
 	return c
 }
 
@@ -124,6 +147,13 @@ func (c AppConfig) IndexGen() int {
 	return gen
 }
 
+// RenumberIds tests whether dictionary sources other than TSV files may have
+// their ids changed when they collide with ids used by another source,
+// RenumberIds: true. Default: false, an error.
+func (c AppConfig) RenumberIds() bool {
+	return strings.ToLower(c.ConfigVars["RenumberIds"]) == "true"
+}
+
 // GetVar gets a configuration variable value
 func (c AppConfig) GetVar(key string) string {
 	val, ok := c.ConfigVars[key]
@@ -134,18 +164,57 @@ func (c AppConfig) GetVar(key string) string {
 	return val
 }
 
-// readLUFileNames gets the name of the text files with lexical units (word senses)
-func readLUFileNames(configVars map[string]string, dictionaryDir string) []string {
-	fileNames := []string{}
+// ParseLUSource parses an entry in the LUFiles list, file[:format[:priority]]
+func ParseLUSource(spec string) (LUSource, error) {
+	parts := strings.Split(strings.TrimSpace(spec), ":")
+	src := LUSource{
+		FileName: parts[0],
+		Format:   guessLUFormat(parts[0]),
+	}
+	if len(parts) > 3 {
+		return src, fmt.Errorf("config.ParseLUSource: too many fields in %s", spec)
+	}
+	if len(parts) > 1 && len(parts[1]) > 0 {
+		src.Format = parts[1]
+	}
+	if len(parts) > 2 {
+		p, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return src, fmt.Errorf("config.ParseLUSource: bad priority in %s: %v", spec, err)
+		}
+		src.Priority = p
+	}
+	return src, nil
+}
+
+// guessLUFormat guesses the format of a lexical unit file from its extension
+func guessLUFormat(fName string) string {
+	if strings.HasSuffix(fName, ".json") {
+		return "json"
+	}
+	if strings.HasSuffix(fName, ".u8") || strings.Contains(fName, "cedict") {
+		return "cedict"
+	}
+	return "tsv"
+}
+
+// readLUSources gets the text files with lexical units (word senses)
+func readLUSources(configVars map[string]string, dictionaryDir string) []LUSource {
+	sources := []LUSource{}
 	val, ok := configVars["LUFiles"]
 	if ok {
 		tokens := strings.Split(val, ",")
-		fileNames = []string{}
 		for _, token := range tokens {
-			fileNames = append(fileNames, dictionaryDir+"/"+token)
+			src, err := ParseLUSource(token)
+			if err != nil {
+				log.Printf("config.readLUSources, skipping: %v", err)
+				continue
+			}
+			src.FileName = dictionaryDir + "/" + src.FileName
+			sources = append(sources, src)
 		}
 	}
-	return fileNames
+	return sources
 }
 
 // readConfig reads the configuration file with project variables
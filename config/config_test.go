@@ -27,3 +27,61 @@ func TestAvoidSubDomains(t *testing.T) {
 		t.Errorf("expected: %v, got: %v", expect, result)
 	}
 }
+
+// Test ParseLUSource
+func TestParseLUSource(t *testing.T) {
+	type test struct {
+		name        string
+		spec        string
+		expectError bool
+		expect      LUSource
+	}
+	tests := []test{
+		{
+			name:   "File name only",
+			spec:   "words.txt",
+			expect: LUSource{FileName: "words.txt", Format: "tsv", Priority: 0},
+		},
+		{
+			name:   "Guess CEDICT",
+			spec:   " cedict_ts.u8",
+			expect: LUSource{FileName: "cedict_ts.u8", Format: "cedict", Priority: 0},
+		},
+		{
+			name:   "Guess JSON",
+			spec:   "extra.json",
+			expect: LUSource{FileName: "extra.json", Format: "json", Priority: 0},
+		},
+		{
+			name:   "Format and priority",
+			spec:   "cc.txt:cedict:-1",
+			expect: LUSource{FileName: "cc.txt", Format: "cedict", Priority: -1},
+		},
+		{
+			name:   "Priority only",
+			spec:   "words.txt::2",
+			expect: LUSource{FileName: "words.txt", Format: "tsv", Priority: 2},
+		},
+		{
+			name:        "Bad priority",
+			spec:        "words.txt:tsv:high",
+			expectError: true,
+		},
+	}
+	for _, tc := range tests {
+		got, err := ParseLUSource(tc.spec)
+		if tc.expectError {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(tc.expect, got) {
+			t.Errorf("%s: expected: %v, got: %v", tc.name, tc.expect, got)
+		}
+	}
+}
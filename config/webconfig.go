@@ -8,6 +8,13 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
+)
+
+// Defaults for machine translation of XLIFF exports
+const (
+	defXLIFFMaxMTUnits = 100
+	defXLIFFMTTimeout  = 2 * time.Minute
 )
 
 // WebAppConfig holds application configuration data that is specific to the web app
@@ -54,6 +61,112 @@ func (c WebAppConfig) GetPasswordResetURL() string {
 	return passwordResetURL
 }
 
+// DeepLAuthKey gets the DeepL API authentication key from the environment
+// variable DEEPL_AUTH_KEY or else config variable DeepLAuthKey, default empty
+// for no DeepL translation
+func (c WebAppConfig) DeepLAuthKey() string {
+	key := os.Getenv("DEEPL_AUTH_KEY")
+	if len(key) == 0 {
+		key = c.GetVarWithDefault("DeepLAuthKey", "")
+	}
+	return key
+}
+
+// DeepLURL gets the DeepL API URL from DeepLURL, default empty to choose the
+// free or pro API from the key
+func (c WebAppConfig) DeepLURL() string {
+	return c.GetVarWithDefault("DeepLURL", "")
+}
+
+// LocalTranslationURL gets the address of a translation server with the
+// LibreTranslate API, used instead of the cloud translation APIs, from the
+// environment variable LOCAL_TRANSLATION_URL or else config variable
+// LocalTranslationURL, default empty for none
+func (c WebAppConfig) LocalTranslationURL() string {
+	apiURL := os.Getenv("LOCAL_TRANSLATION_URL")
+	if len(apiURL) == 0 {
+		apiURL = c.GetVarWithDefault("LocalTranslationURL", "")
+	}
+	return apiURL
+}
+
+// LocalTranslationKey gets the API key for the local translation server from
+// the environment variable LOCAL_TRANSLATION_KEY or else config variable
+// LocalTranslationKey, default empty for none
+func (c WebAppConfig) LocalTranslationKey() string {
+	key := os.Getenv("LOCAL_TRANSLATION_KEY")
+	if len(key) == 0 {
+		key = c.GetVarWithDefault("LocalTranslationKey", "")
+	}
+	return key
+}
+
+// PostEditGlossaryFile gets the name of the CSV file with preferred
+// translations of terms, used to post-edit machine translations, default
+// empty for none
+func (c WebAppConfig) PostEditGlossaryFile() string {
+	return c.GetVarWithDefault("PostEditGlossaryFile", "")
+}
+
+// PostEditMode gets what to do with other translations of glossary terms
+// found in machine translations: flag to flag the terms only, glossary to
+// replace the other translations listed in the glossary, or dictionary to
+// also replace the dictionary English, default flag
+func (c WebAppConfig) PostEditMode() string {
+	return c.GetVarWithDefault("PostEditMode", "flag")
+}
+
+// TranslationProjectID gets the GCP project for the Translation API glossary
+// from the environment variable PROJECT_ID or else config variable
+// TranslationProjectID
+func (c WebAppConfig) TranslationProjectID() string {
+	projectID := os.Getenv("PROJECT_ID")
+	if len(projectID) == 0 {
+		projectID = c.GetVarWithDefault("TranslationProjectID", "")
+	}
+	return projectID
+}
+
+// TranslationGlossary gets the Translation API glossary from the environment
+// variable TRANSLATION_GLOSSARY or else config variable TranslationGlossary
+func (c WebAppConfig) TranslationGlossary() string {
+	glossary := os.Getenv("TRANSLATION_GLOSSARY")
+	if len(glossary) == 0 {
+		glossary = c.GetVarWithDefault("TranslationGlossary", "")
+	}
+	return glossary
+}
+
+// TranslationLocation gets the location of the Translation API glossary from
+// TranslationLocation, default us-central1
+func (c WebAppConfig) TranslationLocation() string {
+	return c.GetVarWithDefault("TranslationLocation", "us-central1")
+}
+
+// TranslationSourceLang gets the language to translate from, from
+// TranslationSourceLang, default zh
+func (c WebAppConfig) TranslationSourceLang() string {
+	return c.GetVarWithDefault("TranslationSourceLang", "zh")
+}
+
+// TranslationTargetLang gets the language to translate to, from
+// TranslationTargetLang, default en
+func (c WebAppConfig) TranslationTargetLang() string {
+	return c.GetVarWithDefault("TranslationTargetLang", "en")
+}
+
+// TranslationFormality gets the formality for machine translation, more or
+// less, from TranslationFormality, default empty for the provider's default
+func (c WebAppConfig) TranslationFormality() string {
+	return c.GetVarWithDefault("TranslationFormality", "")
+}
+
+// TranslationChoiceFile gets the name of the file to append the translations
+// chosen by users comparing platforms to, default empty to write to the log
+func (c WebAppConfig) TranslationChoiceFile() string {
+	return c.GetVarWithDefault("TranslationChoiceFile", "")
+}
+
 // NotesExtractorPattern gets regular expression for extracting multilingual equivalents in the notes
 func (c WebAppConfig) NotesExtractorPattern() string {
 	val, ok := c.ConfigVars["NotesExtractorPattern"]
@@ -73,6 +186,128 @@ func (c WebAppConfig) AddDirectoryToCol() bool {
 	return false
 }
 
+// GetReloadToken gets the environment or config variable for the shared secret
+// that allows the dictionary and indexes to be reloaded, default empty, which
+// only allows reloading by admin users when password protected
+func (c WebAppConfig) GetReloadToken() string {
+	token := os.Getenv("RELOAD_TOKEN")
+	if len(token) == 0 {
+		token = c.GetVarWithDefault("ReloadToken", "")
+	}
+	return token
+}
+
+// DictEditDir gets the directory for proposed dictionary changes, the patch
+// file of approved changes, and the audit log, from DictEditDir. The default
+// is dictedit in the web application home directory.
+func (c WebAppConfig) DictEditDir() string {
+	dir := c.GetVarWithDefault("DictEditDir", "")
+	if len(dir) > 0 {
+		return dir
+	}
+	home := GetCnWebHome()
+	if len(home) == 0 {
+		return "dictedit"
+	}
+	return home + "/dictedit"
+}
+
+// PreferredDomains gets the dictionary domains, English or Chinese, that are
+// ranked higher in reverse lookup, from the comma separated PreferredDomains
+func (c WebAppConfig) PreferredDomains() []string {
+	domains := []string{}
+	for _, d := range strings.Split(c.GetVarWithDefault("PreferredDomains", ""), ",") {
+		if d = strings.TrimSpace(d); len(d) > 0 {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// WordFreqFile gets the file of Chinese word frequencies used for ranking
+// reverse lookup, from WordFreqFile, default empty for no frequencies
+func (c WebAppConfig) WordFreqFile() string {
+	return c.GetVarWithDefault("WordFreqFile", "")
+}
+
+// CharDataFile gets the Unihan style file of character radicals, strokes, and
+// components, from CharDataFile, default empty for no character lookup
+func (c WebAppConfig) CharDataFile() string {
+	return c.GetVarWithDefault("CharDataFile", "")
+}
+
+// SegmentTMXFile gets the TMX file of aligned Chinese and English sentences
+// for the translation memory, from SegmentTMXFile, default empty for none
+func (c WebAppConfig) SegmentTMXFile() string {
+	return c.GetVarWithDefault("SegmentTMXFile", "")
+}
+
+// SegmentImportFile gets the TMX file that translations imported into the
+// sentence level translation memory are saved to and loaded from at startup,
+// from SegmentImportFile, default empty for keeping imports in memory only
+func (c WebAppConfig) SegmentImportFile() string {
+	return c.GetVarWithDefault("SegmentImportFile", "")
+}
+
+// TMTrainingFile gets the CSV file of translation memory results labelled for
+// relevance, used to train the relevance model, from TMTrainingFile, default
+// empty to use the built-in model
+func (c WebAppConfig) TMTrainingFile() string {
+	return c.GetVarWithDefault("TMTrainingFile", "")
+}
+
+// BibNotesDir gets the directory of the bibliographic notes CSV files, from
+// BibNotesDir. Bilingual files of parallel translations referenced in the
+// notes are also read from here. Default is empty for none.
+func (c WebAppConfig) BibNotesDir() string {
+	return c.GetVarWithDefault("BibNotesDir", "")
+}
+
+// ReloadInterval gets the interval for polling the dictionary and index files
+// for changes, from ReloadIntervalSeconds. Zero, the default, means no polling.
+func (c WebAppConfig) ReloadInterval() time.Duration {
+	val, ok := c.ConfigVars["ReloadIntervalSeconds"]
+	if !ok {
+		return 0
+	}
+	secs, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil || secs < 0 {
+		log.Printf("WebAppConfig.ReloadInterval: bad value %s, not polling", val)
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// XLIFFMaxMTUnits gets the maximum number of sentences machine translated when
+// exporting a document as XLIFF, from XLIFFMaxMTUnits, default 100
+func (c WebAppConfig) XLIFFMaxMTUnits() int {
+	val, ok := c.ConfigVars["XLIFFMaxMTUnits"]
+	if !ok {
+		return defXLIFFMaxMTUnits
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil || n < 0 {
+		log.Printf("WebAppConfig.XLIFFMaxMTUnits: bad value %s, using %d", val, defXLIFFMaxMTUnits)
+		return defXLIFFMaxMTUnits
+	}
+	return n
+}
+
+// XLIFFMTTimeout gets the time allowed for machine translating a document
+// exported as XLIFF, from XLIFFMTTimeoutSeconds, default 120 seconds
+func (c WebAppConfig) XLIFFMTTimeout() time.Duration {
+	val, ok := c.ConfigVars["XLIFFMTTimeoutSeconds"]
+	if !ok {
+		return defXLIFFMTTimeout
+	}
+	secs, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil || secs <= 0 {
+		log.Printf("WebAppConfig.XLIFFMTTimeout: bad value %s, using %v", val, defXLIFFMTTimeout)
+		return defXLIFFMTTimeout
+	}
+	return time.Duration(secs) * time.Second
+}
+
 // GetVar gets a configuration variable value, default empty string
 func (c WebAppConfig) GetVar(key string) string {
 	val, ok := c.ConfigVars[key]
@@ -2,8 +2,10 @@ package config
 
 import (
 	"fmt"
+	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 // TestGetPort tests default serving port
@@ -77,6 +79,107 @@ func TestAddDirectoryToCol(t *testing.T) {
 	}
 }
 
+// TestReloadInterval tests ReloadInterval
+func TestReloadInterval(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		want  time.Duration
+	}{
+		{
+			name:  "Empty",
+			input: "",
+			want:  0,
+		},
+		{
+			name:  "Five minutes",
+			input: `ReloadIntervalSeconds: 300`,
+			want:  5 * time.Minute,
+		},
+		{
+			name:  "Invalid",
+			input: `ReloadIntervalSeconds: often`,
+			want:  0,
+		},
+	}
+	for _, tc := range testCases {
+		r := strings.NewReader(tc.input)
+		c := InitWeb(r)
+		got := c.ReloadInterval()
+		if got != tc.want {
+			t.Errorf("TestReloadInterval %s: got %v vs want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// TestXLIFFMTLimits tests the limits on machine translation of XLIFF exports
+func TestXLIFFMTLimits(t *testing.T) {
+	testCases := []struct {
+		name        string
+		input       string
+		wantUnits   int
+		wantTimeout time.Duration
+	}{
+		{
+			name:        "Defaults",
+			input:       "",
+			wantUnits:   100,
+			wantTimeout: 2 * time.Minute,
+		},
+		{
+			name:        "Set",
+			input:       "XLIFFMaxMTUnits: 20\nXLIFFMTTimeoutSeconds: 30",
+			wantUnits:   20,
+			wantTimeout: 30 * time.Second,
+		},
+		{
+			name:        "Invalid",
+			input:       "XLIFFMaxMTUnits: many\nXLIFFMTTimeoutSeconds: 0",
+			wantUnits:   100,
+			wantTimeout: 2 * time.Minute,
+		},
+	}
+	for _, tc := range testCases {
+		c := InitWeb(strings.NewReader(tc.input))
+		if got := c.XLIFFMaxMTUnits(); got != tc.wantUnits {
+			t.Errorf("TestXLIFFMTLimits %s: got %d units vs want %d", tc.name, got, tc.wantUnits)
+		}
+		if got := c.XLIFFMTTimeout(); got != tc.wantTimeout {
+			t.Errorf("TestXLIFFMTLimits %s: got %v vs want %v", tc.name, got, tc.wantTimeout)
+		}
+	}
+}
+
+// TestDictEditDir tests config of the dictionary edit directory
+func TestDictEditDir(t *testing.T) {
+	os.Setenv("CNWEB_HOME", "/srv/cnweb")
+	defer os.Unsetenv("CNWEB_HOME")
+	testCases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "Default",
+			input: "",
+			want:  "/srv/cnweb/dictedit",
+		},
+		{
+			name:  "Configured",
+			input: `DictEditDir: /data/edits`,
+			want:  "/data/edits",
+		},
+	}
+	for _, tc := range testCases {
+		r := strings.NewReader(tc.input)
+		c := InitWeb(r)
+		got := c.DictEditDir()
+		if got != tc.want {
+			t.Errorf("TestDictEditDir %s: got %s vs want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
 // TestGetFromEmail tests config of from email
 func TestGetFromEmail(t *testing.T) {
 	const email = "do_not_reply@example.org"
@@ -147,3 +250,31 @@ func TestWebonfigInit(t *testing.T) {
 		t.Error("TestWebconfigInit: c.ConfigVars == nil")
 	}
 }
+
+// TestPreferredDomains tests config of domains preferred in reverse lookup
+func TestPreferredDomains(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "Empty",
+			input: "",
+			want:  []string{},
+		},
+		{
+			name:  "Two domains",
+			input: `PreferredDomains: Buddhism, 文学`,
+			want:  []string{"Buddhism", "文学"},
+		},
+	}
+	for _, tc := range testCases {
+		r := strings.NewReader(tc.input)
+		c := InitWeb(r)
+		got := c.PreferredDomains()
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("TestPreferredDomains %s: got %v vs want %v", tc.name, got, tc.want)
+		}
+	}
+}
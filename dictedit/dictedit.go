@@ -0,0 +1,408 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for proposing, reviewing and applying changes to dictionary entries.
+//
+// Approved changes are written to a patch file in the same tab separated
+// format as the dictionary. The patch holds all senses of each headword
+// changed so that, when listed in LUFiles with a higher priority than the
+// dictionary, eg dict_patch.tsv:tsv:10, it replaces the headword entries.
+// Every action is recorded in an audit log.
+package dictedit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexamies/chinesenotes-go/dictionary"
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+)
+
+// Kinds of proposal
+const (
+	KindNew    = "new"
+	KindChange = "change"
+)
+
+// Status of a proposal
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// File names in the edit directory
+const (
+	proposalsFN = "proposals.json"
+	patchFN     = "dict_patch.tsv"
+	auditFN     = "audit_log.tsv"
+)
+
+// Proposal is a suggested new or changed word sense
+type Proposal struct {
+	Id         int
+	Kind       string
+	Sense      dicttypes.WordSense
+	Original   dicttypes.WordSense   // The sense before the change, if any
+	Siblings   []dicttypes.WordSense // Other senses of the same headword
+	ProposedBy string
+	Comment    string
+	Status     string
+	Reviewer   string
+	ReviewNote string
+	Created    time.Time
+	Reviewed   time.Time
+}
+
+// ProposalStore saves proposals and applies the approved ones
+type ProposalStore interface {
+
+	// Propose saves a new proposal, returning it with the id set
+	Propose(ctx context.Context, p Proposal) (Proposal, error)
+
+	// Get finds a proposal by id
+	Get(ctx context.Context, id int) (Proposal, error)
+
+	// List gives proposals with the given status, or all if empty, oldest first
+	List(ctx context.Context, status string) ([]Proposal, error)
+
+	// Approve accepts a pending proposal and writes it to the patch file
+	Approve(ctx context.Context, id int, reviewer, note string) (Proposal, error)
+
+	// Reject declines a pending proposal
+	Reject(ctx context.Context, id int, reviewer, note string) (Proposal, error)
+
+	// PatchFile gives the name of the TSV patch file
+	PatchFile() string
+}
+
+// IdAllocator is implemented by proposal stores that give new word senses
+// ids, so that the first id can be raised when the dictionary is reloaded
+type IdAllocator interface {
+
+	// SetFirstId sets the lowest id given to new word senses
+	SetFirstId(firstId int)
+}
+
+// fileStore keeps proposals in a JSON file in a directory, together with the
+// patch file and audit log
+type fileStore struct {
+	mu        sync.Mutex
+	dir       string
+	firstId   int
+	proposals []Proposal
+}
+
+// NewFileStore creates a ProposalStore in the given directory, loading any
+// proposals saved there before. New word senses are given ids starting from
+// firstId, which should be above the highest id used in the dictionary.
+func NewFileStore(dir string, firstId int) (ProposalStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("dictedit.NewFileStore, could not create %s: %v", dir, err)
+	}
+	s := &fileStore{
+		dir:       dir,
+		firstId:   firstId,
+		proposals: []Proposal{},
+	}
+	f, err := os.Open(filepath.Join(dir, proposalsFN))
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dictedit.NewFileStore, could not open proposals: %v", err)
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(&s.proposals); err != nil {
+		return nil, fmt.Errorf("dictedit.NewFileStore, could not parse proposals: %v", err)
+	}
+	log.Printf("dictedit.NewFileStore, loaded %d proposals from %s", len(s.proposals), dir)
+	return s, nil
+}
+
+// SetFirstId sets the lowest id given to new word senses
+func (s *fileStore) SetFirstId(firstId int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.firstId = firstId
+}
+
+// Propose saves a new proposal
+func (s *fileStore) Propose(ctx context.Context, p Proposal) (Proposal, error) {
+	if p.Kind != KindNew && p.Kind != KindChange {
+		return Proposal{}, fmt.Errorf("unknown kind of proposal: %s", p.Kind)
+	}
+	if len(strings.TrimSpace(p.Sense.Simplified)) == 0 {
+		return Proposal{}, fmt.Errorf("missing headword")
+	}
+	if len(strings.TrimSpace(p.Sense.English)) == 0 {
+		return Proposal{}, fmt.Errorf("missing English")
+	}
+	if p.Kind == KindChange && p.Sense.Id == 0 {
+		return Proposal{}, fmt.Errorf("missing id of the word sense to change")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.Id = len(s.proposals) + 1
+	p.Status = StatusPending
+	p.Reviewer = ""
+	p.ReviewNote = ""
+	p.Reviewed = time.Time{}
+	if p.Created.IsZero() {
+		p.Created = time.Now()
+	}
+	s.proposals = append(s.proposals, p)
+	if err := s.save(); err != nil {
+		s.proposals = s.proposals[:len(s.proposals)-1]
+		return Proposal{}, err
+	}
+	s.audit(p.Created, p.ProposedBy, "propose", p, p.Comment)
+	return p, nil
+}
+
+// Get finds a proposal by id
+func (s *fileStore) Get(ctx context.Context, id int) (Proposal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id < 1 || id > len(s.proposals) {
+		return Proposal{}, fmt.Errorf("proposal %d not found", id)
+	}
+	return s.proposals[id-1], nil
+}
+
+// List gives proposals with the given status
+func (s *fileStore) List(ctx context.Context, status string) ([]Proposal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	proposals := []Proposal{}
+	for _, p := range s.proposals {
+		if len(status) == 0 || p.Status == status {
+			proposals = append(proposals, p)
+		}
+	}
+	return proposals, nil
+}
+
+// Approve accepts a proposal, giving new senses an id, and rewrites the patch
+func (s *fileStore) Approve(ctx context.Context, id int, reviewer, note string) (Proposal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, err := s.review(id, reviewer, note, StatusApproved)
+	if err != nil {
+		return Proposal{}, err
+	}
+	if p.Sense.Id == 0 {
+		p.Sense.Id = s.nextId()
+	}
+	if p.Sense.HeadwordId == 0 {
+		p.Sense.HeadwordId = p.Sense.Id
+	}
+	prev := s.proposals[id-1]
+	s.proposals[id-1] = p
+	if err := s.writePatch(); err != nil {
+		s.proposals[id-1] = prev
+		return Proposal{}, err
+	}
+	if err := s.save(); err != nil {
+		// Keep the patch consistent with the proposals saved
+		s.proposals[id-1] = prev
+		if perr := s.writePatch(); perr != nil {
+			log.Printf("dictedit.Approve, could not restore patch: %v", perr)
+		}
+		return Proposal{}, err
+	}
+	s.audit(p.Reviewed, reviewer, "approve", p, note)
+	return p, nil
+}
+
+// Reject declines a proposal
+func (s *fileStore) Reject(ctx context.Context, id int, reviewer, note string) (Proposal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, err := s.review(id, reviewer, note, StatusRejected)
+	if err != nil {
+		return Proposal{}, err
+	}
+	prev := s.proposals[id-1]
+	s.proposals[id-1] = p
+	if err := s.save(); err != nil {
+		s.proposals[id-1] = prev
+		return Proposal{}, err
+	}
+	s.audit(p.Reviewed, reviewer, "reject", p, note)
+	return p, nil
+}
+
+// PatchFile gives the name of the TSV patch file
+func (s *fileStore) PatchFile() string {
+	return filepath.Join(s.dir, patchFN)
+}
+
+// review gives a copy of a pending proposal with the review recorded
+func (s *fileStore) review(id int, reviewer, note, status string) (Proposal, error) {
+	if id < 1 || id > len(s.proposals) {
+		return Proposal{}, fmt.Errorf("proposal %d not found", id)
+	}
+	p := s.proposals[id-1]
+	if p.Status != StatusPending {
+		return Proposal{}, fmt.Errorf("proposal %d has already been %s", id, p.Status)
+	}
+	p.Status = status
+	p.Reviewer = reviewer
+	p.ReviewNote = note
+	p.Reviewed = time.Now()
+	return p, nil
+}
+
+// nextId gives an id for a new word sense above those already approved
+func (s *fileStore) nextId() int {
+	next := s.firstId
+	for _, p := range s.proposals {
+		if p.Status != StatusApproved {
+			continue
+		}
+		senses := []dicttypes.WordSense{p.Sense}
+		for _, ws := range append(senses, p.Siblings...) {
+			if ws.Id >= next {
+				next = ws.Id + 1
+			}
+			if ws.HeadwordId >= next {
+				next = ws.HeadwordId + 1
+			}
+		}
+	}
+	return next
+}
+
+// approvedSenses gives the senses from all approved proposals, later
+// approvals replacing earlier ones for the same sense id
+func (s *fileStore) approvedSenses() []dicttypes.WordSense {
+	approved := []Proposal{}
+	for _, p := range s.proposals {
+		if p.Status == StatusApproved {
+			approved = append(approved, p)
+		}
+	}
+	sort.SliceStable(approved, func(i, j int) bool {
+		return approved[i].Reviewed.Before(approved[j].Reviewed)
+	})
+	senses := make(map[int]dicttypes.WordSense)
+	for _, p := range approved {
+		for _, ws := range p.Siblings {
+			if _, ok := senses[ws.Id]; !ok {
+				senses[ws.Id] = ws
+			}
+		}
+		senses[p.Sense.Id] = p.Sense
+	}
+	list := make([]dicttypes.WordSense, 0, len(senses))
+	for _, ws := range senses {
+		list = append(list, ws)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].HeadwordId != list[j].HeadwordId {
+			return list[i].HeadwordId < list[j].HeadwordId
+		}
+		return list[i].Id < list[j].Id
+	})
+	return list
+}
+
+// writePatch rewrites the patch file with all approved senses
+func (s *fileStore) writePatch() error {
+	return s.writeFile(patchFN, func(w io.Writer) error {
+		fmt.Fprintln(w, "# Dictionary changes approved by reviewers, do not edit")
+		for _, ws := range s.approvedSenses() {
+			if _, err := fmt.Fprintln(w, dictionary.TSVRow(ws)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// save writes all proposals to the JSON file
+func (s *fileStore) save() error {
+	return s.writeFile(proposalsFN, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s.proposals)
+	})
+}
+
+// writeFile writes to a temporary file and then renames it so that readers
+// never see a partly written file
+func (s *fileStore) writeFile(name string, write func(w io.Writer) error) error {
+	fileName := filepath.Join(s.dir, name)
+	f, err := os.CreateTemp(s.dir, name+".*")
+	if err != nil {
+		return fmt.Errorf("dictedit.writeFile, could not create %s: %v", fileName, err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("dictedit.writeFile, could not write %s: %v", fileName, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("dictedit.writeFile, could not close %s: %v", fileName, err)
+	}
+	if err := os.Rename(f.Name(), fileName); err != nil {
+		return fmt.Errorf("dictedit.writeFile, could not rename to %s: %v", fileName, err)
+	}
+	return nil
+}
+
+// audit appends a line to the audit log with the time, user, action, proposal
+// id, word sense id, headword and comment. Errors are logged but not returned
+// since the action has already been saved.
+func (s *fileStore) audit(t time.Time, user, action string, p Proposal, comment string) {
+	fileName := filepath.Join(s.dir, auditFN)
+	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("dictedit.audit, could not open %s: %v", fileName, err)
+		return
+	}
+	defer f.Close()
+	fields := []string{
+		t.UTC().Format(time.RFC3339),
+		user,
+		action,
+		strconv.Itoa(p.Id),
+		strconv.Itoa(p.Sense.Id),
+		p.Sense.Simplified,
+		comment,
+	}
+	if _, err := fmt.Fprintln(f, strings.Join(cleanFields(fields), "\t")); err != nil {
+		log.Printf("dictedit.audit, could not write %s: %v", fileName, err)
+	}
+}
+
+// cleanFields replaces tabs and line breaks, which would break the audit log
+func cleanFields(fields []string) []string {
+	r := strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+	cleaned := make([]string, len(fields))
+	for i, f := range fields {
+		cleaned[i] = strings.TrimSpace(r.Replace(f))
+	}
+	return cleaned
+}
@@ -0,0 +1,208 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Unit tests for the dictedit package
+package dictedit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexamies/chinesenotes-go/dictionary"
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+)
+
+func mockSense(id, hwId int, simp, english string) dicttypes.WordSense {
+	return dicttypes.WordSense{
+		Id:          id,
+		HeadwordId:  hwId,
+		Simplified:  simp,
+		Traditional: "\\N",
+		Pinyin:      "pīnyīn",
+		English:     english,
+		Grammar:     "noun",
+		Domain:      "Modern Chinese",
+		DomainCN:    "现代汉语",
+		Image:       "\\N",
+		MP3:         "\\N",
+	}
+}
+
+// TestFileStore tests proposing, approving and rejecting changes
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, 1000)
+	if err != nil {
+		t.Fatalf("TestFileStore: unexpected error: %v", err)
+	}
+	if _, err := store.Propose(ctx, Proposal{Kind: KindNew}); err == nil {
+		t.Error("TestFileStore: expected error for missing headword")
+	}
+	orig := mockSense(10, 10, "中文", "Chinese")
+	sibling := mockSense(11, 10, "中文", "Chinese writing")
+	changed := orig
+	changed.English = "Chinese language"
+	p1, err := store.Propose(ctx, Proposal{
+		Kind:       KindChange,
+		Sense:      changed,
+		Original:   orig,
+		Siblings:   []dicttypes.WordSense{sibling},
+		ProposedBy: "alice",
+		Comment:    "More precise",
+	})
+	if err != nil {
+		t.Fatalf("TestFileStore: unexpected error proposing change: %v", err)
+	}
+	p2, err := store.Propose(ctx, Proposal{
+		Kind:       KindNew,
+		Sense:      mockSense(0, 0, "汉字", "Chinese character"),
+		ProposedBy: "alice",
+	})
+	if err != nil {
+		t.Fatalf("TestFileStore: unexpected error proposing new: %v", err)
+	}
+	p3, err := store.Propose(ctx, Proposal{
+		Kind:       KindNew,
+		Sense:      mockSense(0, 0, "错字", "typo"),
+		ProposedBy: "bob",
+	})
+	if err != nil {
+		t.Fatalf("TestFileStore: unexpected error proposing new: %v", err)
+	}
+	pending, _ := store.List(ctx, StatusPending)
+	if len(pending) != 3 {
+		t.Errorf("TestFileStore: expected 3 pending, got %d", len(pending))
+	}
+
+	if _, err := store.Approve(ctx, p1.Id, "carol", "ok"); err != nil {
+		t.Fatalf("TestFileStore: unexpected error approving: %v", err)
+	}
+	if _, err := store.Approve(ctx, p1.Id, "carol", "again"); err == nil {
+		t.Error("TestFileStore: expected error approving twice")
+	}
+	approved, err := store.Approve(ctx, p2.Id, "carol", "")
+	if err != nil {
+		t.Fatalf("TestFileStore: unexpected error approving: %v", err)
+	}
+	if approved.Sense.Id != 1000 || approved.Sense.HeadwordId != 1000 {
+		t.Errorf("TestFileStore: expected new id 1000, got %d, hw %d",
+			approved.Sense.Id, approved.Sense.HeadwordId)
+	}
+	if _, err := store.Reject(ctx, p3.Id, "carol", "not a word"); err != nil {
+		t.Fatalf("TestFileStore: unexpected error rejecting: %v", err)
+	}
+
+	patch, err := os.ReadFile(store.PatchFile())
+	if err != nil {
+		t.Fatalf("TestFileStore: could not read patch: %v", err)
+	}
+	rows := []string{}
+	for _, line := range strings.Split(string(patch), "\n") {
+		if len(line) > 0 && !strings.HasPrefix(line, "#") {
+			rows = append(rows, line)
+		}
+	}
+	expectRows := []string{
+		dictionary.TSVRow(changed),
+		dictionary.TSVRow(sibling),
+		dictionary.TSVRow(approved.Sense),
+	}
+	if len(rows) != len(expectRows) {
+		t.Fatalf("TestFileStore: expected %d rows in patch, got %d: %v",
+			len(expectRows), len(rows), rows)
+	}
+	for i, e := range expectRows {
+		if rows[i] != e {
+			t.Errorf("TestFileStore: row %d expected %q, got %q", i, e, rows[i])
+		}
+	}
+
+	audit, err := os.ReadFile(filepath.Join(dir, auditFN))
+	if err != nil {
+		t.Fatalf("TestFileStore: could not read audit log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(audit)), "\n")
+	actions := []string{}
+	for _, line := range lines {
+		actions = append(actions, strings.Split(line, "\t")[2])
+	}
+	expectActions := "propose propose propose approve approve reject"
+	if got := strings.Join(actions, " "); got != expectActions {
+		t.Errorf("TestFileStore: audit expected %s, got %s", expectActions, got)
+	}
+
+	// Reopen and check that the reviews were saved
+	reopened, err := NewFileStore(dir, 1000)
+	if err != nil {
+		t.Fatalf("TestFileStore: unexpected error reopening: %v", err)
+	}
+	p, err := reopened.Get(ctx, p3.Id)
+	if err != nil {
+		t.Fatalf("TestFileStore: unexpected error getting: %v", err)
+	}
+	if p.Status != StatusRejected || p.Reviewer != "carol" {
+		t.Errorf("TestFileStore: expected rejected by carol, got %s by %s", p.Status, p.Reviewer)
+	}
+	all, _ := reopened.List(ctx, "")
+	if len(all) != 3 {
+		t.Errorf("TestFileStore: expected 3 proposals, got %d", len(all))
+	}
+}
+
+// TestApproveSaveError tests that an approval is undone if the proposals
+// cannot be saved
+func TestApproveSaveError(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, 1000)
+	if err != nil {
+		t.Fatalf("TestApproveSaveError: unexpected error: %v", err)
+	}
+	p, err := store.Propose(ctx, Proposal{
+		Kind:       KindNew,
+		Sense:      mockSense(0, 0, "汉字", "Chinese character"),
+		ProposedBy: "alice",
+	})
+	if err != nil {
+		t.Fatalf("TestApproveSaveError: unexpected error proposing: %v", err)
+	}
+
+	// The proposals file cannot be replaced by a non-empty directory
+	fileName := filepath.Join(dir, proposalsFN)
+	if err := os.Remove(fileName); err != nil {
+		t.Fatalf("TestApproveSaveError: could not remove proposals: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(fileName, "x"), 0755); err != nil {
+		t.Fatalf("TestApproveSaveError: could not create directory: %v", err)
+	}
+	if _, err := store.Approve(ctx, p.Id, "carol", ""); err == nil {
+		t.Fatal("TestApproveSaveError: expected error saving")
+	}
+	got, err := store.Get(ctx, p.Id)
+	if err != nil {
+		t.Fatalf("TestApproveSaveError: unexpected error getting: %v", err)
+	}
+	if got.Status != StatusPending {
+		t.Errorf("TestApproveSaveError: expected pending, got %s", got.Status)
+	}
+	patch, err := os.ReadFile(store.PatchFile())
+	if err != nil {
+		t.Fatalf("TestApproveSaveError: could not read patch: %v", err)
+	}
+	if strings.Contains(string(patch), "汉字") {
+		t.Errorf("TestApproveSaveError: expected sense not in patch, got %s", patch)
+	}
+}
@@ -0,0 +1,105 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Reader for the CC-CEDICT dictionary format
+
+package dictionary
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+	"github.com/alexamies/chinesenotes-go/pinyin"
+)
+
+// A CC-CEDICT line, eg 漢語 汉语 [Han4 yu3] /Chinese language/
+var cedictLine = regexp.MustCompile(`^(\S+) (\S+) \[([^\]]*)\] /(.*)/\s*$`)
+
+// cedictReader reads the CC-CEDICT text format
+type cedictReader struct{}
+
+// NewCEDICTReader creates a reader for CC-CEDICT text. Each line becomes a
+// word sense with the definitions separated by semicolons and numbered pinyin
+// converted to tone marks. Lines for the same simplified headword share a
+// headword id.
+func NewCEDICTReader() DictReader {
+	return cedictReader{}
+}
+
+// ReadSenses reads word senses from CC-CEDICT text
+func (cedictReader) ReadSenses(r io.Reader, source string,
+	report *ValidationReport) ([]dicttypes.WordSense, error) {
+	senses := []dicttypes.WordSense{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		report.Rows++
+		m := cedictLine.FindStringSubmatch(text)
+		if m == nil {
+			report.add(source, line, 0, -1, "", "not a CC-CEDICT entry")
+			continue
+		}
+		trad, simp := m[1], m[2]
+		if !dicttypes.ContainsCJK(simp) {
+			report.add(source, line, 0, 1, simp, "no Chinese characters")
+		}
+		if trad == simp {
+			trad = "\\N"
+		}
+		glosses := []string{}
+		for _, g := range strings.Split(m[4], "/") {
+			if g = strings.TrimSpace(g); len(g) > 0 {
+				glosses = append(glosses, g)
+			}
+		}
+		if len(glosses) == 0 {
+			report.add(source, line, 0, 4, m[4], "missing English")
+		}
+		senses = append(senses, dicttypes.WordSense{
+			Simplified:  simp,
+			Traditional: trad,
+			Pinyin:      cedictPinyin(m[3]),
+			English:     strings.Join(glosses, "; "),
+			Grammar:     "\\N",
+			Image:       "\\N",
+			MP3:         "\\N",
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read CC-CEDICT file: %v", err)
+	}
+	return senses, nil
+}
+
+// cedictPinyin converts numbered pinyin, eg Han4 yu3, to tone marks written
+// as one word, eg Hànyǔ
+func cedictPinyin(numbered string) string {
+	var b strings.Builder
+	for _, syllable := range strings.Fields(numbered) {
+		s := pinyin.ToMarked(syllable)
+		if b.Len() > 0 && pinyin.NeedsApostrophe(s) {
+			b.WriteRune('\'')
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
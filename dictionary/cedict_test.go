@@ -0,0 +1,126 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dictionary
+
+import (
+	"strings"
+	"testing"
+)
+
+const inputCEDICT = `# CC-CEDICT
+# License: Creative Commons Attribution-ShareAlike 4.0 International License
+漢語 汉语 [Han4 yu3] /Chinese language/CL:門|门[men2]/
+了 了 [le5] /(modal particle)/
+瞭 了 [liao3] /to understand/
+西安 西安 [Xi1 an1] /Xi'an, capital of Shaanxi/
+綠 绿 [lu:4] /green/
+not an entry
+`
+
+// TestLoadCEDICT tests loading CC-CEDICT text
+func TestLoadCEDICT(t *testing.T) {
+	dict, err := LoadCEDICT(strings.NewReader(inputCEDICT))
+	if err != nil {
+		t.Fatalf("TestLoadCEDICT: unexpected error: %v", err)
+	}
+	type test struct {
+		name          string
+		key           string
+		expectPinyin  string
+		expectEnglish string
+		expectSenses  int
+	}
+	tests := []test{
+		{
+			name:          "Traditional different",
+			key:           "漢語",
+			expectPinyin:  "Hànyǔ",
+			expectEnglish: "Chinese language; CL:門|门[men2]",
+			expectSenses:  1,
+		},
+		{
+			name:          "Two lines for one headword",
+			key:           "了",
+			expectPinyin:  "le",
+			expectEnglish: "(modal particle)",
+			expectSenses:  2,
+		},
+		{
+			name:          "Apostrophe",
+			key:           "西安",
+			expectPinyin:  "Xī'ān",
+			expectEnglish: "Xi'an, capital of Shaanxi",
+			expectSenses:  1,
+		},
+		{
+			name:          "U umlaut",
+			key:           "绿",
+			expectPinyin:  "lǜ",
+			expectEnglish: "green",
+			expectSenses:  1,
+		},
+	}
+	for _, tc := range tests {
+		w, ok := dict.Wdict[tc.key]
+		if !ok {
+			t.Errorf("%s: %s not found", tc.name, tc.key)
+			continue
+		}
+		if tc.expectSenses != len(w.Senses) {
+			t.Errorf("%s: expected %d senses, got %d", tc.name, tc.expectSenses, len(w.Senses))
+			continue
+		}
+		s := w.Senses[0]
+		if tc.expectPinyin != s.Pinyin {
+			t.Errorf("%s: expected pinyin %s, got %s", tc.name, tc.expectPinyin, s.Pinyin)
+		}
+		if tc.expectEnglish != s.English {
+			t.Errorf("%s: expected English %s, got %s", tc.name, tc.expectEnglish, s.English)
+		}
+		if s.Id == 0 || s.HeadwordId == 0 {
+			t.Errorf("%s: expected ids to be assigned, got %d, %d", tc.name, s.Id, s.HeadwordId)
+		}
+		if len(w.Senses) > 1 && w.Senses[1].HeadwordId != s.HeadwordId {
+			t.Errorf("%s: expected the same headword id, got %d, %d", tc.name,
+				s.HeadwordId, w.Senses[1].HeadwordId)
+		}
+	}
+	report := NewValidationReport(false)
+	_, err = NewCEDICTReader().ReadSenses(strings.NewReader(inputCEDICT), "test", report)
+	if err != nil {
+		t.Fatalf("TestLoadCEDICT: unexpected error: %v", err)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Row != 8 {
+		t.Errorf("TestLoadCEDICT: expected one issue on line 8, got %v", report.Issues)
+	}
+}
+
+// TestCedictPinyin tests conversion of numbered pinyin
+func TestCedictPinyin(t *testing.T) {
+	tests := map[string]string{
+		"zhong1 guo2": "zhōngguó",
+		"gou3":        "gǒu",
+		"liu2":        "liú",
+		"gui4":        "guì",
+		"nu:3":        "nǚ",
+		"ma5":         "ma",
+		"Ai4 er3":     "Ài'ěr",
+		"A A":         "A'A",
+		"xx5":         "xx",
+	}
+	for input, expect := range tests {
+		if got := cedictPinyin(input); got != expect {
+			t.Errorf("cedictPinyin(%s): expected %s, got %s", input, expect, got)
+		}
+	}
+}
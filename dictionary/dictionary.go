@@ -19,6 +19,7 @@ import (
 	"strings"
 
 	"github.com/alexamies/chinesenotes-go/dicttypes"
+	"github.com/alexamies/chinesenotes-go/pinyin"
 )
 
 // Dictionary is a struct to hold word dictionary indexes
@@ -45,15 +46,36 @@ type ReverseIndex interface {
 	Find(ctx context.Context, query string) ([]dicttypes.WordSense, error)
 }
 
+// RankedReverseIndex is a ReverseIndex that can also rank the results
+type RankedReverseIndex interface {
+	ReverseIndex
+
+	// FindRanked searches like Find but also matches stemmed English words and
+	// phrases within glosses, giving the best matches first
+	FindRanked(ctx context.Context, query string, limit int) ([]RankedSense, error)
+}
+
 type SubstringIndex interface {
 	LookupSubstr(ctx context.Context, query, topic_en, subtopic_en string) (*Results, error)
 }
 
 type reverseIndexMem struct {
-	revIndex map[string][]dicttypes.WordSense
+	revIndex  map[string][]dicttypes.WordSense
+	senses    []dicttypes.WordSense
+	glosses   []gloss
+	stemIndex map[string][]int // English word stem to gloss indexes
+	freq      map[string]int
+	maxFreq   int
+	domains   map[string]bool
 }
 
+// NewReverseIndex creates a ReverseIndex with no word frequencies or domain
+// preferences for ranking
 func NewReverseIndex(dict *Dictionary, nExtractor *NotesExtractor) ReverseIndex {
+	return NewRankedReverseIndex(dict, nExtractor, RankConfig{})
+}
+
+func newReverseIndexMem(dict *Dictionary, nExtractor *NotesExtractor) reverseIndexMem {
 	revIndex := map[string][]dicttypes.WordSense{}
 	for _, v := range dict.HeadwordIds {
 		for _, s := range v.Senses {
@@ -63,9 +85,8 @@ func NewReverseIndex(dict *Dictionary, nExtractor *NotesExtractor) ReverseIndex
 				add(revIndex, e, s)
 			}
 			if len(s.Pinyin) > 0 {
-				p := dicttypes.NormalizePinyin(s.Pinyin)
+				p := pinyin.Normalize(s.Pinyin)
 				add(revIndex, p, s)
-
 			}
 			if len(s.Notes) > 0 {
 				equivalents := nExtractor.Extract(s.Notes)
@@ -77,7 +98,8 @@ func NewReverseIndex(dict *Dictionary, nExtractor *NotesExtractor) ReverseIndex
 		}
 	}
 	return reverseIndexMem{
-		revIndex: revIndex,
+		revIndex:  revIndex,
+		stemIndex: make(map[string][]int),
 	}
 }
 
@@ -104,7 +126,31 @@ func add(revIndex map[string][]dicttypes.WordSense, key string, s dicttypes.Word
 }
 
 func (r reverseIndexMem) Find(ctx context.Context, query string) ([]dicttypes.WordSense, error) {
-	return r.revIndex[query], nil
+	return r.exactMatches(query), nil
+}
+
+// exactMatches finds the senses with English, pinyin, or equivalents in the
+// notes equal to the query. Pinyin is matched however it is written, with tone
+// marks, tone numbers, or no tones, and with or without spaces.
+func (r reverseIndexMem) exactMatches(query string) []dicttypes.WordSense {
+	senses := r.revIndex[query]
+	key := pinyin.Normalize(query)
+	if key == query || !pinyin.IsPinyin(query) {
+		return senses
+	}
+	merged := append([]dicttypes.WordSense{}, senses...)
+	for _, ws := range r.revIndex[key] {
+		found := false
+		for _, s := range merged {
+			if s.HeadwordId == ws.HeadwordId {
+				found = true
+			}
+		}
+		if !found {
+			merged = append(merged, ws)
+		}
+	}
+	return merged
 }
 
 func splitEnglish(eng string) []string {
@@ -153,6 +153,30 @@ func TestFind(t *testing.T) {
 			expectTrad:  "蓮花",
 			expectHwId:  1,
 		},
+		{
+			name:        "From pinyin with tone numbers",
+			extractRe:   "",
+			query:       "lian2 hua1",
+			expectCount: 2,
+			expectTrad:  "蓮花",
+			expectHwId:  1,
+		},
+		{
+			name:        "From pinyin with tone marks and spaces",
+			extractRe:   "",
+			query:       "Xǐmǎlāyǎ xuěsōng",
+			expectCount: 1,
+			expectTrad:  "喜馬拉雅雪松",
+			expectHwId:  3,
+		},
+		{
+			name:        "From pinyin without spaces",
+			extractRe:   "",
+			query:       "ximalayaxuesong",
+			expectCount: 1,
+			expectTrad:  "喜馬拉雅雪松",
+			expectHwId:  3,
+		},
 		{
 			name:        "Equivalent from notes",
 			extractRe:   `"Scientific name: (.*?)[\(,\,,\;]","Species: (.*?)[\(,\,,\;]"`,
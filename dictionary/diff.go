@@ -0,0 +1,384 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Comparison of dictionary versions and three-way merging
+
+package dictionary
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+)
+
+// FieldDiff is a change to one field of a word sense
+type FieldDiff struct {
+	// Name of the column, as in the TSV format
+	Field string
+	Old   string
+	New   string
+}
+
+// SenseDiff is a word sense added, removed, or modified
+type SenseDiff struct {
+	Id         int
+	HeadwordId int
+	Simplified string
+
+	// The word sense before, empty if added
+	Old dicttypes.WordSense
+
+	// The word sense after, empty if removed
+	New dicttypes.WordSense
+
+	// Fields changed, for modified senses only
+	Fields []FieldDiff
+}
+
+// DictDiff lists the differences between two versions of the dictionary, in
+// order of word sense id
+type DictDiff struct {
+	Added    []SenseDiff
+	Removed  []SenseDiff
+	Modified []SenseDiff
+}
+
+// Empty tests whether there are no differences
+func (d DictDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// Write writes the differences as text, one line per word sense or field
+// changed, like a unified diff
+func (d DictDiff) Write(w io.Writer) error {
+	for _, s := range d.Removed {
+		if _, err := fmt.Fprintf(w, "- %s\n", TSVRow(s.Old)); err != nil {
+			return fmt.Errorf("DictDiff.Write, could not write: %v", err)
+		}
+	}
+	for _, s := range d.Added {
+		if _, err := fmt.Fprintf(w, "+ %s\n", TSVRow(s.New)); err != nil {
+			return fmt.Errorf("DictDiff.Write, could not write: %v", err)
+		}
+	}
+	for _, s := range d.Modified {
+		for _, f := range s.Fields {
+			_, err := fmt.Fprintf(w, "~ %d %s %s: %q -> %q\n", s.Id, s.Simplified,
+				f.Field, f.Old, f.New)
+			if err != nil {
+				return fmt.Errorf("DictDiff.Write, could not write: %v", err)
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d added, %d removed, %d modified\n", len(d.Added),
+		len(d.Removed), len(d.Modified))
+	if err != nil {
+		return fmt.Errorf("DictDiff.Write, could not write: %v", err)
+	}
+	return nil
+}
+
+// Diff compares two versions of the dictionary by word sense id. A sense is
+// modified if any field differs, including the headword id.
+func Diff(oldDict, newDict *Dictionary) DictDiff {
+	oldSenses := sensesById(oldDict)
+	newSenses := sensesById(newDict)
+	d := DictDiff{
+		Added:    []SenseDiff{},
+		Removed:  []SenseDiff{},
+		Modified: []SenseDiff{},
+	}
+	for _, id := range unionIds(oldSenses, newSenses) {
+		o, inOld := oldSenses[id]
+		n, inNew := newSenses[id]
+		switch {
+		case !inOld:
+			d.Added = append(d.Added, SenseDiff{
+				Id:         id,
+				HeadwordId: n.HeadwordId,
+				Simplified: n.Simplified,
+				New:        n,
+			})
+		case !inNew:
+			d.Removed = append(d.Removed, SenseDiff{
+				Id:         id,
+				HeadwordId: o.HeadwordId,
+				Simplified: o.Simplified,
+				Old:        o,
+			})
+		default:
+			if fields := diffFields(o, n); len(fields) > 0 {
+				d.Modified = append(d.Modified, SenseDiff{
+					Id:         id,
+					HeadwordId: n.HeadwordId,
+					Simplified: n.Simplified,
+					Old:        o,
+					New:        n,
+					Fields:     fields,
+				})
+			}
+		}
+	}
+	return d
+}
+
+// Conflict is a change made differently in the local and upstream versions
+type Conflict struct {
+	Id         int
+	Simplified string
+
+	// Name of the column, empty if the sense was removed on one side and
+	// changed on the other
+	Field    string
+	Base     string
+	Local    string
+	Upstream string
+
+	// Description of the conflict
+	Problem string
+}
+
+func (c Conflict) String() string {
+	if len(c.Field) == 0 {
+		return fmt.Sprintf("id %d %s: %s", c.Id, c.Simplified, c.Problem)
+	}
+	return fmt.Sprintf("id %d %s: %s %s, base %q, local %q, upstream %q", c.Id,
+		c.Simplified, c.Field, c.Problem, c.Base, c.Local, c.Upstream)
+}
+
+// MergeResult holds the word senses from a three-way merge and the conflicts
+// found
+type MergeResult struct {
+	// Merged word senses in order of headword id and word sense id
+	Senses []dicttypes.WordSense
+
+	// Conflicts in order of word sense id, where the local version was kept
+	Conflicts []Conflict
+}
+
+// Dictionary creates a dictionary from the merged word senses
+func (m MergeResult) Dictionary() *Dictionary {
+	wdict := make(map[string]*dicttypes.Word)
+	for _, ws := range m.Senses {
+		addSense(wdict, ws)
+	}
+	return NewDictionary(wdict)
+}
+
+// WriteConflicts writes the conflicts as text, one per line
+func (m MergeResult) WriteConflicts(w io.Writer) error {
+	for _, c := range m.Conflicts {
+		if _, err := fmt.Fprintln(w, c); err != nil {
+			return fmt.Errorf("MergeResult.WriteConflicts, could not write: %v", err)
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d senses merged, %d conflicts\n", len(m.Senses),
+		len(m.Conflicts))
+	if err != nil {
+		return fmt.Errorf("MergeResult.WriteConflicts, could not write: %v", err)
+	}
+	return nil
+}
+
+// Merge combines the changes made to a local fork of the dictionary with the
+// changes made upstream since the base version the fork was made from. Word
+// senses are matched by id and merged field by field. Where both sides changed
+// the same field differently, or one side removed a sense that the other
+// changed, the local version is kept and a conflict is reported.
+func Merge(base, local, upstream *Dictionary) MergeResult {
+	baseSenses := sensesById(base)
+	localSenses := sensesById(local)
+	upSenses := sensesById(upstream)
+	result := MergeResult{
+		Senses:    []dicttypes.WordSense{},
+		Conflicts: []Conflict{},
+	}
+	for _, id := range unionIds(baseSenses, localSenses, upSenses) {
+		b, inBase := baseSenses[id]
+		l, inLocal := localSenses[id]
+		u, inUp := upSenses[id]
+		switch {
+		case inLocal && inUp:
+			var baseValues []string
+			if inBase {
+				baseValues = senseValues(b)
+			}
+			ws, conflicts := mergeFields(baseValues, l, u)
+			result.Senses = append(result.Senses, ws)
+			result.Conflicts = append(result.Conflicts, conflicts...)
+		case inLocal && !inBase:
+			result.Senses = append(result.Senses, l)
+		case inUp && !inBase:
+			result.Senses = append(result.Senses, u)
+		case inLocal:
+			// Removed upstream
+			if l != b {
+				result.Senses = append(result.Senses, l)
+				result.Conflicts = append(result.Conflicts, Conflict{
+					Id:         id,
+					Simplified: l.Simplified,
+					Problem:    "removed upstream but changed locally",
+				})
+			}
+		case inUp:
+			// Removed locally
+			if u != b {
+				result.Conflicts = append(result.Conflicts, Conflict{
+					Id:         id,
+					Simplified: u.Simplified,
+					Problem:    "removed locally but changed upstream",
+				})
+			}
+		}
+	}
+	sort.SliceStable(result.Senses, func(i, j int) bool {
+		if result.Senses[i].HeadwordId != result.Senses[j].HeadwordId {
+			return result.Senses[i].HeadwordId < result.Senses[j].HeadwordId
+		}
+		return result.Senses[i].Id < result.Senses[j].Id
+	})
+	return result
+}
+
+// mergeFields merges the local and upstream versions of a word sense field by
+// field. With no base, as when both sides added the same id, any difference is
+// a conflict.
+func mergeFields(base []string, local, upstream dicttypes.WordSense) (dicttypes.WordSense, []Conflict) {
+	lv := senseValues(local)
+	uv := senseValues(upstream)
+	merged := make([]string, len(lv))
+	conflicts := []Conflict{}
+	for i := range lv {
+		switch {
+		case lv[i] == uv[i]:
+			merged[i] = lv[i]
+		case base != nil && lv[i] == base[i]:
+			merged[i] = uv[i]
+		case base != nil && uv[i] == base[i]:
+			merged[i] = lv[i]
+		default:
+			merged[i] = lv[i]
+			c := Conflict{
+				Id:         local.Id,
+				Simplified: local.Simplified,
+				Field:      luColumns[i],
+				Local:      lv[i],
+				Upstream:   uv[i],
+				Problem:    "changed differently",
+			}
+			if base != nil {
+				c.Base = base[i]
+			} else {
+				c.Problem = "added differently"
+			}
+			conflicts = append(conflicts, c)
+		}
+	}
+	return senseFromValues(merged), conflicts
+}
+
+// sensesById gives each word sense in the dictionary by id
+func sensesById(dict *Dictionary) map[int]dicttypes.WordSense {
+	senses := make(map[int]dicttypes.WordSense)
+	for _, w := range dict.HeadwordIds {
+		for _, ws := range w.Senses {
+			if _, ok := senses[ws.Id]; !ok {
+				senses[ws.Id] = ws
+			}
+		}
+	}
+	return senses
+}
+
+// unionIds gives the ids found in any of the maps, in order
+func unionIds(maps ...map[int]dicttypes.WordSense) []int {
+	seen := make(map[int]bool)
+	ids := []int{}
+	for _, m := range maps {
+		for id := range m {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// diffFields lists the fields that differ between two word senses
+func diffFields(o, n dicttypes.WordSense) []FieldDiff {
+	ov := senseValues(o)
+	nv := senseValues(n)
+	fields := []FieldDiff{}
+	for i := range ov {
+		if ov[i] != nv[i] {
+			fields = append(fields, FieldDiff{
+				Field: luColumns[i],
+				Old:   ov[i],
+				New:   nv[i],
+			})
+		}
+	}
+	return fields
+}
+
+// senseValues gives the fields of a word sense in the order of the TSV columns
+func senseValues(ws dicttypes.WordSense) []string {
+	return []string{
+		strconv.Itoa(ws.Id),
+		ws.Simplified,
+		ws.Traditional,
+		ws.Pinyin,
+		ws.English,
+		ws.Grammar,
+		ws.ConceptCN,
+		ws.Concept,
+		ws.DomainCN,
+		ws.Domain,
+		ws.SubdomainCN,
+		ws.Subdomain,
+		ws.Image,
+		ws.MP3,
+		ws.Notes,
+		strconv.Itoa(ws.HeadwordId),
+		ws.Jyutping,
+	}
+}
+
+// senseFromValues is the inverse of senseValues
+func senseFromValues(v []string) dicttypes.WordSense {
+	id, _ := strconv.Atoi(v[0])
+	hwId, _ := strconv.Atoi(v[15])
+	return dicttypes.WordSense{
+		Id:          id,
+		Simplified:  v[1],
+		Traditional: v[2],
+		Pinyin:      v[3],
+		English:     v[4],
+		Grammar:     v[5],
+		ConceptCN:   v[6],
+		Concept:     v[7],
+		DomainCN:    v[8],
+		Domain:      v[9],
+		SubdomainCN: v[10],
+		Subdomain:   v[11],
+		Image:       v[12],
+		MP3:         v[13],
+		Notes:       v[14],
+		HeadwordId:  hwId,
+		Jyutping:    v[16],
+	}
+}
@@ -0,0 +1,196 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dictionary
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+)
+
+// diffTestDict creates a dictionary from the given senses
+func diffTestDict(senses ...dicttypes.WordSense) *Dictionary {
+	wdict := make(map[string]*dicttypes.Word)
+	for _, ws := range senses {
+		addSense(wdict, ws)
+	}
+	return NewDictionary(wdict)
+}
+
+func diffSense(id, hwId int, simp, english string) dicttypes.WordSense {
+	return dicttypes.WordSense{
+		Id:          id,
+		HeadwordId:  hwId,
+		Simplified:  simp,
+		Traditional: "\\N",
+		Pinyin:      "pīnyīn",
+		English:     english,
+		Grammar:     "noun",
+	}
+}
+
+// TestDiff tests added, removed, and modified senses
+func TestDiff(t *testing.T) {
+	a := diffSense(1, 1, "中文", "Chinese")
+	b := diffSense(2, 2, "汉字", "Chinese character")
+	c := diffSense(3, 3, "错字", "typo")
+	bChanged := b
+	bChanged.English = "Chinese characters"
+	bChanged.Grammar = "noun phrase"
+	d := diffSense(4, 1, "中文", "Chinese writing")
+	oldDict := diffTestDict(a, b, c)
+	newDict := diffTestDict(a, bChanged, d)
+
+	diff := Diff(oldDict, newDict)
+	if len(diff.Added) != 1 || diff.Added[0].Id != 4 {
+		t.Errorf("TestDiff: expected 4 added, got %v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Id != 3 {
+		t.Errorf("TestDiff: expected 3 removed, got %v", diff.Removed)
+	}
+	if len(diff.Modified) != 1 || diff.Modified[0].Id != 2 {
+		t.Fatalf("TestDiff: expected 2 modified, got %v", diff.Modified)
+	}
+	expectFields := []FieldDiff{
+		{"english", "Chinese character", "Chinese characters"},
+		{"grammar", "noun", "noun phrase"},
+	}
+	fields := diff.Modified[0].Fields
+	if len(fields) != len(expectFields) {
+		t.Fatalf("TestDiff: expected fields %v, got %v", expectFields, fields)
+	}
+	for i, f := range expectFields {
+		if fields[i] != f {
+			t.Errorf("TestDiff: field %d expected %v, got %v", i, f, fields[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := diff.Write(&buf); err != nil {
+		t.Fatalf("TestDiff: unexpected error writing: %v", err)
+	}
+	out := buf.String()
+	for _, e := range []string{
+		"- 3\t错字",
+		"+ 4\t中文",
+		`~ 2 汉字 english: "Chinese character" -> "Chinese characters"`,
+		"1 added, 1 removed, 1 modified",
+	} {
+		if !strings.Contains(out, e) {
+			t.Errorf("TestDiff: expected output to contain %q, got\n%s", e, out)
+		}
+	}
+
+	if !Diff(oldDict, oldDict).Empty() {
+		t.Error("TestDiff: expected no difference with itself")
+	}
+}
+
+// TestMerge tests three-way merging of a local fork with upstream changes
+func TestMerge(t *testing.T) {
+	base := []dicttypes.WordSense{
+		diffSense(1, 1, "中文", "Chinese"),
+		diffSense(2, 2, "汉字", "Chinese character"),
+		diffSense(3, 3, "错字", "typo"),
+		diffSense(4, 4, "北京", "Beijing"),
+		diffSense(5, 5, "南京", "Nanjing"),
+		diffSense(6, 6, "上海", "Shanghai"),
+	}
+	local := make([]dicttypes.WordSense, len(base))
+	copy(local, base)
+	upstream := make([]dicttypes.WordSense, len(base))
+	copy(upstream, base)
+
+	// Different fields of 1 changed on each side, merged without conflict
+	local[0].English = "Chinese language"
+	upstream[0].Grammar = "proper noun"
+	// The same field of 2 changed differently, a conflict
+	local[1].English = "Chinese characters"
+	upstream[1].English = "Han character"
+	// 3 removed upstream and unchanged locally
+	upstream = append(upstream[:2], upstream[3:]...)
+	// 4 removed upstream but changed locally, a conflict
+	local[3].English = "Peking"
+	upstream = append(upstream[:2], upstream[3:]...)
+	// 5 removed locally but changed upstream, a conflict
+	upstream[2].English = "Nanking"
+	local = append(local[:4], local[5:]...)
+	// New senses on each side
+	local = append(local, diffSense(7, 7, "广州", "Guangzhou"))
+	upstream = append(upstream, diffSense(8, 8, "深圳", "Shenzhen"))
+
+	result := Merge(diffTestDict(base...), diffTestDict(local...),
+		diffTestDict(upstream...))
+
+	english := make(map[int]string)
+	for _, ws := range result.Senses {
+		english[ws.Id] = ws.English
+	}
+	expectEnglish := map[int]string{
+		1: "Chinese language",
+		2: "Chinese characters",
+		4: "Peking",
+		6: "Shanghai",
+		7: "Guangzhou",
+		8: "Shenzhen",
+	}
+	if len(english) != len(expectEnglish) {
+		t.Errorf("TestMerge: expected senses %v, got %v", expectEnglish, english)
+	}
+	for id, e := range expectEnglish {
+		if english[id] != e {
+			t.Errorf("TestMerge: sense %d expected %q, got %q", id, e, english[id])
+		}
+	}
+	if result.Senses[0].Grammar != "proper noun" {
+		t.Errorf("TestMerge: expected upstream grammar change, got %s", result.Senses[0].Grammar)
+	}
+
+	expectConflicts := []string{
+		`id 2 汉字: english changed differently, base "Chinese character", local "Chinese characters", upstream "Han character"`,
+		"id 4 北京: removed upstream but changed locally",
+		"id 5 南京: removed locally but changed upstream",
+	}
+	if len(result.Conflicts) != len(expectConflicts) {
+		t.Fatalf("TestMerge: expected %d conflicts, got %v", len(expectConflicts), result.Conflicts)
+	}
+	for i, e := range expectConflicts {
+		if got := result.Conflicts[i].String(); got != e {
+			t.Errorf("TestMerge: conflict %d expected %q, got %q", i, e, got)
+		}
+	}
+
+	dict := result.Dictionary()
+	if w, ok := dict.Wdict["深圳"]; !ok || w.HeadwordId != 8 {
+		t.Errorf("TestMerge: expected 深圳 in merged dictionary, got %v", w)
+	}
+}
+
+// TestMergeBothAdded tests senses added with the same id on both sides
+func TestMergeBothAdded(t *testing.T) {
+	base := diffTestDict(diffSense(1, 1, "中文", "Chinese"))
+	local := diffTestDict(diffSense(1, 1, "中文", "Chinese"), diffSense(2, 2, "广州", "Guangzhou"))
+	upstream := diffTestDict(diffSense(1, 1, "中文", "Chinese"), diffSense(2, 2, "深圳", "Shenzhen"))
+	result := Merge(base, local, upstream)
+	if len(result.Conflicts) != 2 {
+		t.Fatalf("TestMergeBothAdded: expected 2 conflicts, got %v", result.Conflicts)
+	}
+	if result.Conflicts[0].Problem != "added differently" {
+		t.Errorf("TestMergeBothAdded: unexpected conflict %v", result.Conflicts[0])
+	}
+	if len(result.Senses) != 2 || result.Senses[1].Simplified != "广州" {
+		t.Errorf("TestMergeBothAdded: expected local sense kept, got %v", result.Senses)
+	}
+}
@@ -0,0 +1,347 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Export of the dictionary to other formats
+
+package dictionary
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+	"github.com/alexamies/chinesenotes-go/pinyin"
+)
+
+// SenseFilter selects the word senses to export
+type SenseFilter func(ws dicttypes.WordSense) bool
+
+// DomainFilter selects word senses in any of the given domains or subdomains,
+// matching either the English or Chinese name. With no domains all senses are
+// selected.
+func DomainFilter(domains ...string) SenseFilter {
+	selected := make(map[string]bool)
+	for _, d := range domains {
+		if d = strings.TrimSpace(d); len(d) > 0 {
+			selected[strings.ToLower(d)] = true
+		}
+	}
+	return func(ws dicttypes.WordSense) bool {
+		if len(selected) == 0 {
+			return true
+		}
+		return selected[strings.ToLower(ws.Domain)] || selected[ws.DomainCN] ||
+			selected[strings.ToLower(ws.Subdomain)] || selected[ws.SubdomainCN]
+	}
+}
+
+// Exporter writes the dictionary to a single file in a particular format
+type Exporter interface {
+	Export(w io.Writer, dict *Dictionary, filter SenseFilter) error
+}
+
+// NewExporter creates an Exporter for one of the formats tsv, json, cedict,
+// or anki. StarDict needs several files, see ExportStarDict.
+func NewExporter(format string) (Exporter, error) {
+	switch format {
+	case "tsv":
+		return tsvExporter{}, nil
+	case "json":
+		return jsonExporter{}, nil
+	case "cedict":
+		return cedictExporter{}, nil
+	case "anki":
+		return ankiExporter{}, nil
+	}
+	return nil, fmt.Errorf("dictionary.NewExporter, unknown format %s", format)
+}
+
+// exportWords groups the word senses selected by headword id, in order of
+// headword id. Each word sense is included only once, even though the
+// dictionary is keyed by both simplified and traditional.
+func exportWords(dict *Dictionary, filter SenseFilter) []dicttypes.Word {
+	seen := make(map[int]bool)
+	byHw := make(map[int][]dicttypes.WordSense)
+	for _, w := range dict.Wdict {
+		for _, ws := range w.Senses {
+			if seen[ws.Id] || (filter != nil && !filter(ws)) {
+				continue
+			}
+			seen[ws.Id] = true
+			byHw[ws.HeadwordId] = append(byHw[ws.HeadwordId], ws)
+		}
+	}
+	hwIds := []int{}
+	for hw := range byHw {
+		hwIds = append(hwIds, hw)
+	}
+	sort.Ints(hwIds)
+	words := []dicttypes.Word{}
+	for _, hw := range hwIds {
+		senses := byHw[hw]
+		sort.Slice(senses, func(i, j int) bool {
+			return senses[i].Id < senses[j].Id
+		})
+		words = append(words, dicttypes.Word{
+			Simplified:  senses[0].Simplified,
+			Traditional: senses[0].Traditional,
+			Pinyin:      senses[0].Pinyin,
+			HeadwordId:  hw,
+			Senses:      senses,
+		})
+	}
+	return words
+}
+
+// tsvExporter writes the Chinese Notes tab separated format, one line per
+// word sense
+type tsvExporter struct{}
+
+func (tsvExporter) Export(w io.Writer, dict *Dictionary, filter SenseFilter) error {
+	bw := bufio.NewWriter(w)
+	for _, word := range exportWords(dict, filter) {
+		for _, ws := range word.Senses {
+			fmt.Fprintln(bw, TSVRow(ws))
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("tsvExporter.Export, could not write: %v", err)
+	}
+	return nil
+}
+
+// TSVRow formats a word sense as a line in the tab separated dictionary
+// format, without the line ending. Empty fields are written as \N. Tabs and
+// line breaks are replaced with spaces and double quotes with single quotes,
+// since the reader would treat them as quoting.
+func TSVRow(ws dicttypes.WordSense) string {
+	fields := senseValues(ws)
+	// The jyutping column is optional, omit it to keep 16 columns when empty
+	if len(ws.Jyutping) == 0 {
+		fields = fields[:len(fields)-1]
+	}
+	for i, f := range fields {
+		f = strings.TrimSpace(tsvReplacer.Replace(f))
+		if len(f) == 0 {
+			f = "\\N"
+		}
+		fields[i] = f
+	}
+	return strings.Join(fields, "\t")
+}
+
+// Replaces characters that would break a TSV field
+var tsvReplacer = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ",
+	"\r", " ", "\"", "'")
+
+// jsonExporter writes an array of headwords with their senses, which can be
+// read again with NewJSONReader
+type jsonExporter struct{}
+
+func (jsonExporter) Export(w io.Writer, dict *Dictionary, filter SenseFilter) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", " ")
+	if err := enc.Encode(exportWords(dict, filter)); err != nil {
+		return fmt.Errorf("jsonExporter.Export, could not encode: %v", err)
+	}
+	return nil
+}
+
+// cedictExporter writes CC-CEDICT lines, one per word sense
+type cedictExporter struct{}
+
+func (cedictExporter) Export(w io.Writer, dict *Dictionary, filter SenseFilter) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# Exported from the Chinese Notes dictionary")
+	for _, word := range exportWords(dict, filter) {
+		for _, ws := range word.Senses {
+			trad := ws.Traditional
+			if trad == "\\N" || len(trad) == 0 {
+				trad = ws.Simplified
+			}
+			n := utf8.RuneCountInString(ws.Simplified)
+			numbered := pinyin.ToNumbered(ws.Pinyin, n)
+			defs := []string{}
+			for _, e := range strings.Split(ws.English, "; ") {
+				e = strings.ReplaceAll(e, "/", ",")
+				if len(e) > 0 {
+					defs = append(defs, e)
+				}
+			}
+			fmt.Fprintf(bw, "%s %s [%s] /%s/\n", trad, ws.Simplified, numbered,
+				strings.Join(defs, "/"))
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("cedictExporter.Export, could not write: %v", err)
+	}
+	return nil
+}
+
+// ankiExporter writes CSV that can be imported into Anki, one note per
+// headword
+type ankiExporter struct{}
+
+func (ankiExporter) Export(w io.Writer, dict *Dictionary, filter SenseFilter) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#separator:Comma")
+	fmt.Fprintln(bw, "#html:false")
+	fmt.Fprintln(bw, "#columns:Simplified,Traditional,Pinyin,English,Notes")
+	cw := csv.NewWriter(bw)
+	for _, word := range exportWords(dict, filter) {
+		trad := word.Traditional
+		if trad == "\\N" {
+			trad = ""
+		}
+		english := []string{}
+		notes := []string{}
+		for i, ws := range word.Senses {
+			if len(word.Senses) > 1 {
+				english = append(english, fmt.Sprintf("%d. %s", i+1, ws.English))
+			} else {
+				english = append(english, ws.English)
+			}
+			if len(ws.Notes) > 0 {
+				notes = append(notes, ws.Notes)
+			}
+		}
+		record := []string{
+			word.Simplified,
+			trad,
+			word.Pinyin,
+			strings.Join(english, " "),
+			strings.Join(notes, " "),
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("ankiExporter.Export, could not write: %v", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("ankiExporter.Export, could not write: %v", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("ankiExporter.Export, could not write: %v", err)
+	}
+	return nil
+}
+
+// ExportStarDict writes the dictionary in StarDict format to the files
+// basePath.ifo, basePath.idx, and basePath.dict. Both simplified and
+// traditional headwords are indexed.
+func ExportStarDict(basePath, bookName string, dict *Dictionary, filter SenseFilter) error {
+	var idx, data bytes.Buffer
+	wordCount, err := writeStarDict(&idx, &data, dict, filter)
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{
+		basePath + ".idx":  idx.Bytes(),
+		basePath + ".dict": data.Bytes(),
+	}
+	var ifo bytes.Buffer
+	fmt.Fprintln(&ifo, "StarDict's dict ifo file")
+	fmt.Fprintln(&ifo, "version=2.4.2")
+	fmt.Fprintf(&ifo, "bookname=%s\n", bookName)
+	fmt.Fprintf(&ifo, "wordcount=%d\n", wordCount)
+	fmt.Fprintf(&ifo, "idxfilesize=%d\n", idx.Len())
+	fmt.Fprintln(&ifo, "sametypesequence=m")
+	files[basePath+".ifo"] = ifo.Bytes()
+	for fName, content := range files {
+		if err := os.WriteFile(fName, content, 0644); err != nil {
+			return fmt.Errorf("dictionary.ExportStarDict, could not write %s: %v", fName, err)
+		}
+	}
+	return nil
+}
+
+// starDictEntry is an index entry pointing to a definition in the .dict file
+type starDictEntry struct {
+	word         string
+	offset, size uint32
+}
+
+// writeStarDict writes the index and definitions, returning the number of
+// index entries
+func writeStarDict(idx, data io.Writer, dict *Dictionary, filter SenseFilter) (int, error) {
+	entries := []starDictEntry{}
+	var offset uint32
+	for _, word := range exportWords(dict, filter) {
+		var def strings.Builder
+		def.WriteString(word.Pinyin)
+		for i, ws := range word.Senses {
+			def.WriteString("\n")
+			if len(word.Senses) > 1 {
+				fmt.Fprintf(&def, "%d. ", i+1)
+			}
+			def.WriteString(ws.English)
+			if len(ws.Grammar) > 0 && ws.Grammar != "\\N" {
+				fmt.Fprintf(&def, " (%s)", ws.Grammar)
+			}
+			if len(ws.Notes) > 0 {
+				fmt.Fprintf(&def, "\n%s", ws.Notes)
+			}
+		}
+		b := []byte(def.String())
+		if _, err := data.Write(b); err != nil {
+			return 0, fmt.Errorf("dictionary.writeStarDict, could not write: %v", err)
+		}
+		size := uint32(len(b))
+		entries = append(entries, starDictEntry{word.Simplified, offset, size})
+		if word.Traditional != "\\N" && word.Traditional != word.Simplified {
+			entries = append(entries, starDictEntry{word.Traditional, offset, size})
+		}
+		offset += size
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return starDictLess(entries[i].word, entries[j].word)
+	})
+	for _, e := range entries {
+		rec := append([]byte(e.word), 0)
+		rec = binary.BigEndian.AppendUint32(rec, e.offset)
+		rec = binary.BigEndian.AppendUint32(rec, e.size)
+		if _, err := idx.Write(rec); err != nil {
+			return 0, fmt.Errorf("dictionary.writeStarDict, could not write index: %v", err)
+		}
+	}
+	return len(entries), nil
+}
+
+// starDictLess orders index entries as StarDict expects, ASCII case
+// insensitive first and then byte order
+func starDictLess(a, b string) bool {
+	if c := bytes.Compare(asciiLower(a), asciiLower(b)); c != 0 {
+		return c < 0
+	}
+	return a < b
+}
+
+// asciiLower lower cases ASCII letters only
+func asciiLower(s string) []byte {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return b
+}
@@ -0,0 +1,226 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dictionary
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+)
+
+// exportTestDict loads a small dictionary for testing export
+func exportTestDict(t *testing.T) *Dictionary {
+	wdict := make(map[string]*dicttypes.Word)
+	input := inputTwoEntries + inputTradDifferent
+	err := loadDictReader(strings.NewReader(input), "test", wdict,
+		map[string]bool{}, NewValidationReport(false))
+	if err != nil {
+		t.Fatalf("exportTestDict: unexpected error: %v", err)
+	}
+	return NewDictionary(wdict)
+}
+
+// TestExport tests the single file export formats
+func TestExport(t *testing.T) {
+	dict := exportTestDict(t)
+	type test struct {
+		name   string
+		format string
+		filter SenseFilter
+		expect string
+	}
+	tests := []test{
+		{
+			name:   "CC-CEDICT",
+			format: "cedict",
+			filter: nil,
+			expect: `# Exported from the Chinese Notes dictionary
+邃古 邃古 [sui4 gu3] /remote antiquity/
+漢語 汉语 [han4 yu3] /Chinese language/
+平地 平地 [ping2 di4] /flat land/
+`,
+		},
+		{
+			name:   "Anki with domain filter",
+			format: "anki",
+			filter: DomainFilter("geography"),
+			expect: `#separator:Comma
+#html:false
+#columns:Simplified,Traditional,Pinyin,English,Notes
+平地,,píngdì,flat land,(CC-CEDICT '平地'; Guoyu '平地' 1)
+`,
+		},
+	}
+	for _, tc := range tests {
+		exporter, err := NewExporter(tc.format)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		var buf bytes.Buffer
+		if err := exporter.Export(&buf, dict, tc.filter); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if got := buf.String(); got != tc.expect {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.name, got, tc.expect)
+		}
+	}
+	if _, err := NewExporter("xml"); err == nil {
+		t.Error("TestExport: expected an error for an unknown format")
+	}
+}
+
+// TestExportJSON tests that exported JSON can be read back
+func TestExportJSON(t *testing.T) {
+	dict := exportTestDict(t)
+	exporter, err := NewExporter("json")
+	if err != nil {
+		t.Fatalf("TestExportJSON: unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := exporter.Export(&buf, dict, DomainFilter("Modern Chinese")); err != nil {
+		t.Fatalf("TestExportJSON: unexpected error: %v", err)
+	}
+	words := []dicttypes.Word{}
+	if err := json.Unmarshal(buf.Bytes(), &words); err != nil {
+		t.Fatalf("TestExportJSON: could not parse output: %v", err)
+	}
+	if len(words) != 3 {
+		t.Fatalf("TestExportJSON: expected 3 words, got %d", len(words))
+	}
+	if words[1].Traditional != "漢語" || len(words[1].Senses) != 1 {
+		t.Errorf("TestExportJSON: unexpected word %v", words[1])
+	}
+	report := NewValidationReport(false)
+	senses, err := NewJSONReader().ReadSenses(&buf, "export.json", report)
+	if err != nil {
+		t.Fatalf("TestExportJSON: could not read back: %v", err)
+	}
+	if len(report.Issues) > 0 {
+		t.Errorf("TestExportJSON: unexpected problems reading back: %v",
+			report.Issues)
+	}
+	if len(senses) != 3 {
+		t.Fatalf("TestExportJSON: expected 3 senses read back, got %d", len(senses))
+	}
+	if senses[1] != words[1].Senses[0] {
+		t.Errorf("TestExportJSON: read back %v, want %v", senses[1],
+			words[1].Senses[0])
+	}
+}
+
+// TestExportTSV tests that exported TSV loads to the same dictionary
+func TestExportTSV(t *testing.T) {
+	dict := exportTestDict(t)
+	exporter, err := NewExporter("tsv")
+	if err != nil {
+		t.Fatalf("TestExportTSV: unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := exporter.Export(&buf, dict, nil); err != nil {
+		t.Fatalf("TestExportTSV: unexpected error: %v", err)
+	}
+	wdict := make(map[string]*dicttypes.Word)
+	report := NewValidationReport(true)
+	err = loadDictReader(&buf, "test", wdict, map[string]bool{}, report)
+	if err != nil {
+		t.Fatalf("TestExportTSV: could not load output: %v", err)
+	}
+	for key, w := range dict.Wdict {
+		got, ok := wdict[key]
+		if !ok {
+			t.Errorf("TestExportTSV: %s missing", key)
+			continue
+		}
+		if len(got.Senses) != len(w.Senses) || got.Senses[0] != w.Senses[0] {
+			t.Errorf("TestExportTSV: %s got %v, want %v", key, got.Senses, w.Senses)
+		}
+	}
+}
+
+// TestTSVRow tests escaping of fields in TSV rows
+func TestTSVRow(t *testing.T) {
+	ws := dicttypes.WordSense{
+		Id:         42,
+		HeadwordId: 41,
+		Simplified: "中文",
+		English:    "Chinese\tlanguage",
+		Notes:      "See \"Zhongwen\"\n",
+	}
+	fields := strings.Split(TSVRow(ws), "\t")
+	if len(fields) != 16 {
+		t.Fatalf("TestTSVRow: expected 16 fields, got %d: %q", len(fields), fields)
+	}
+	expect := map[int]string{
+		0:  "42",
+		1:  "中文",
+		2:  "\\N",
+		4:  "Chinese language",
+		14: "See 'Zhongwen'",
+		15: "41",
+	}
+	for i, e := range expect {
+		if fields[i] != e {
+			t.Errorf("TestTSVRow: field %d expected %q, got %q", i, e, fields[i])
+		}
+	}
+}
+
+// TestExportStarDict tests writing StarDict files
+func TestExportStarDict(t *testing.T) {
+	dict := exportTestDict(t)
+	base := filepath.Join(t.TempDir(), "cn")
+	if err := ExportStarDict(base, "Chinese Notes", dict, nil); err != nil {
+		t.Fatalf("TestExportStarDict: unexpected error: %v", err)
+	}
+	ifo, err := os.ReadFile(base + ".ifo")
+	if err != nil {
+		t.Fatalf("TestExportStarDict: could not read ifo: %v", err)
+	}
+	idx, err := os.ReadFile(base + ".idx")
+	if err != nil {
+		t.Fatalf("TestExportStarDict: could not read idx: %v", err)
+	}
+	data, err := os.ReadFile(base + ".dict")
+	if err != nil {
+		t.Fatalf("TestExportStarDict: could not read dict: %v", err)
+	}
+	if !strings.Contains(string(ifo), "wordcount=4\n") {
+		t.Errorf("TestExportStarDict: unexpected ifo\n%s", ifo)
+	}
+	// Read the index entries back and check each definition
+	defs := make(map[string]string)
+	for len(idx) > 0 {
+		end := bytes.IndexByte(idx, 0)
+		if end < 0 || len(idx) < end+9 {
+			t.Fatalf("TestExportStarDict: truncated index")
+		}
+		word := string(idx[:end])
+		offset := binary.BigEndian.Uint32(idx[end+1:])
+		size := binary.BigEndian.Uint32(idx[end+5:])
+		defs[word] = string(data[offset : offset+size])
+		idx = idx[end+9:]
+	}
+	expect := "hànyǔ\nChinese language (noun)"
+	if defs["漢語"] != expect || defs["汉语"] != expect {
+		t.Errorf("TestExportStarDict: got %q, %q, want %q", defs["漢語"], defs["汉语"], expect)
+	}
+	if len(defs) != 4 {
+		t.Errorf("TestExportStarDict: expected 4 entries, got %v", defs)
+	}
+}
@@ -15,45 +15,119 @@ import (
 	"github.com/alexamies/chinesenotes-go/dicttypes"
 )
 
-// LoadDictFile loads all words from static files
+// LoadDictFile loads all words from static files, skipping invalid rows
 func LoadDictFile(appConfig config.AppConfig) (*Dictionary, error) {
-	fNames := appConfig.LUFileNames
-	log.Printf("LoadDictFile, loading %d files", len(fNames))
-	wdict := make(map[string]*dicttypes.Word)
-	avoidSub := appConfig.AvoidSubDomains()
-	for _, fName := range fNames {
-		log.Printf("fileloader.LoadDictFile: fName: %s", fName)
-		wsfile, err := os.Open(fName)
+	dict, report, err := LoadDictFileReport(appConfig, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, issue := range report.Issues {
+		log.Printf("LoadDictFile, skipped or suspect entry: %v", issue)
+	}
+	return dict, nil
+}
+
+// LoadDictFileReport loads all words from static files, reporting problems
+// found. In strict mode an error is returned if there are any problems.
+func LoadDictFileReport(appConfig config.AppConfig, strict bool) (*Dictionary, *ValidationReport, error) {
+	luSources := getLUSources(appConfig)
+	log.Printf("LoadDictFile, loading %d files", len(luSources))
+	readers := dictReaders(appConfig.AvoidSubDomains())
+	report := NewValidationReport(strict)
+	sources := []sourceSenses{}
+	for _, src := range luSources {
+		log.Printf("fileloader.LoadDictFile: fName: %s, format: %s, priority: %d",
+			src.FileName, src.Format, src.Priority)
+		reader, ok := readers[src.Format]
+		if !ok {
+			return nil, report, fmt.Errorf("fileloader.LoadDictFile, unknown format %s for %s",
+				src.Format, src.FileName)
+		}
+		wsfile, err := os.Open(src.FileName)
 		if err != nil {
-			return nil, fmt.Errorf("fileloader.LoadDictFile, error opening %s: %v",
-				fName, err)
+			return nil, report, fmt.Errorf("fileloader.LoadDictFile, error opening %s: %v",
+				src.FileName, err)
 		}
-		defer wsfile.Close()
-		err = loadDictReader(wsfile, wdict, avoidSub)
+		senses, err := reader.ReadSenses(wsfile, src.FileName, report)
+		wsfile.Close()
 		if err != nil {
-			return nil, fmt.Errorf("fileloader.LoadDictFile, error reading from %s: %v",
-				fName, err)
+			return nil, report, fmt.Errorf("fileloader.LoadDictFile, error reading from %s: %v",
+				src.FileName, err)
 		}
+		sources = append(sources, sourceSenses{
+			source:    src.FileName,
+			priority:  src.Priority,
+			senses:    senses,
+			stableIds: src.Format == "tsv",
+		})
 	}
-	log.Printf("LoadDictFile, loaded %d entries", len(wdict))
-	return NewDictionary(wdict), nil
+	merged, err := mergeSenses(sources, report, appConfig.RenumberIds())
+	if err != nil {
+		return nil, report, fmt.Errorf("fileloader.LoadDictFile, %v", err)
+	}
+	wdict := make(map[string]*dicttypes.Word)
+	for _, ws := range merged {
+		addSense(wdict, ws)
+	}
+	log.Printf("LoadDictFile, loaded %d entries with %d problems", len(wdict),
+		len(report.Issues))
+	if err := report.Err(); err != nil {
+		return nil, report, fmt.Errorf("fileloader.LoadDictFile, %v", err)
+	}
+	return NewDictionary(wdict), report, nil
+}
+
+// getLUSources gets the sources to load from the config, falling back to the
+// file names with the format guessed for apps that only set LUFileNames
+func getLUSources(appConfig config.AppConfig) []config.LUSource {
+	if len(appConfig.LUSources) > 0 {
+		return appConfig.LUSources
+	}
+	sources := []config.LUSource{}
+	for _, fName := range appConfig.LUFileNames {
+		src, err := config.ParseLUSource(fName)
+		if err != nil {
+			log.Printf("fileloader.getLUSources, skipping: %v", err)
+			continue
+		}
+		sources = append(sources, src)
+	}
+	return sources
 }
 
 // LoadDictKeys loads the keys only from static files
 func LoadDictKeys(appConfig config.AppConfig) (*map[string]bool, error) {
-	fNames := appConfig.LUFileNames
-	log.Printf("LoadDictFile, loading %d files", len(fNames))
+	luSources := getLUSources(appConfig)
+	log.Printf("LoadDictFile, loading %d files", len(luSources))
 	wdict := make(map[string]bool)
 	avoidSub := appConfig.AvoidSubDomains()
-	for _, fName := range fNames {
+	readers := dictReaders(avoidSub)
+	for _, src := range luSources {
+		fName := src.FileName
 		log.Printf("fileloader.LoadDictKeys: fName: %s", fName)
+		reader, ok := readers[src.Format]
+		if !ok {
+			return nil, fmt.Errorf("fileloader.LoadDictKeys, unknown format %s for %s",
+				src.Format, fName)
+		}
 		wsfile, err := os.Open(fName)
 		if err != nil {
 			return nil, fmt.Errorf("fileloader.LoadDictKeys, error opening %s: %v",
 				fName, err)
 		}
-		defer wsfile.Close()
-		err = loadDictKeys(wsfile, wdict, avoidSub)
+		if src.Format == "tsv" {
+			err = loadDictKeys(wsfile, wdict, avoidSub)
+		} else {
+			var senses []dicttypes.WordSense
+			senses, err = reader.ReadSenses(wsfile, fName, NewValidationReport(false))
+			for _, ws := range senses {
+				wdict[ws.Simplified] = true
+				if ws.Traditional != "\\N" {
+					wdict[ws.Traditional] = true
+				}
+			}
+		}
+		wsfile.Close()
 		if err != nil {
 			return nil, fmt.Errorf("fileloader.LoadDictKeys, error reading from %s: %v",
 				fName, err)
@@ -74,38 +148,95 @@ func LoadDictURL(appConfig config.AppConfig, url string) (*Dictionary, error) {
 	defer resp.Body.Close()
 	wdict := make(map[string]*dicttypes.Word)
 	avoidSub := appConfig.AvoidSubDomains()
-	err = loadDictReader(resp.Body, wdict, avoidSub)
+	report := NewValidationReport(false)
+	err = loadDictReader(resp.Body, url, wdict, avoidSub, report)
 	if err != nil {
 		return nil, fmt.Errorf("fileloader.LoadDictFile, error reading from %s: %v",
 			url, err)
 	}
+	for _, issue := range report.Issues {
+		log.Printf("LoadDictURL, skipped or suspect entry: %v", issue)
+	}
 	return NewDictionary(wdict), nil
 }
 
-// loadDictReader ads words from an io.Reader to the given dictionary
-func loadDictReader(r io.Reader, wdict map[string]*dicttypes.Word,
-	avoidSub map[string]bool) error {
+// loadDictReader ads words from an io.Reader to the given dictionary. Problems
+// are added to the report and rows that cannot be read are skipped. The
+// source is the file name or URL, used for reporting.
+func loadDictReader(r io.Reader, source string, wdict map[string]*dicttypes.Word,
+	avoidSub map[string]bool, report *ValidationReport) error {
+	senses, err := tsvReader{avoidSub}.ReadSenses(r, source, report)
+	if err != nil {
+		return err
+	}
+	for _, ws := range senses {
+		addSense(wdict, ws)
+	}
+	return nil
+}
+
+// tsvReader reads the Chinese Notes tab separated dictionary format
+type tsvReader struct {
+	avoidSub map[string]bool
+}
+
+// ReadSenses reads word senses from tab separated values, skipping subdomains
+// that should be avoided. Duplicate ids are reported within the source only,
+// ids in other sources are resolved when the sources are merged.
+func (tr tsvReader) ReadSenses(r io.Reader, source string,
+	report *ValidationReport) ([]dicttypes.WordSense, error) {
+	senses := []dicttypes.WordSense{}
+	ids := make(map[int]int) // line each id was first used on
 	reader := csv.NewReader(r)
 	reader.FieldsPerRecord = -1
 	reader.Comma = rune('\t')
 	reader.Comment = '#'
-	rawCSVdata, err := reader.ReadAll()
-	if err != nil {
-		return fmt.Errorf("could not parse lexical units file: %v", err)
-	}
-	for i, row := range rawCSVdata {
-		if len(row) < 15 {
-			fmt.Printf("only %d elements (less than 15) for row %d, text: %v", len(row), i, row)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not parse lexical units file: %v", err)
+		}
+		report.Rows++
+		line, _ := reader.FieldPos(0)
+		if len(row) < 15 || len(row) > 17 {
+			report.add(source, line, 0, -1, "",
+				fmt.Sprintf("expected 16 or 17 columns but found %d", len(row)))
 			continue
 		}
 		id, err := strconv.ParseInt(row[0], 10, 0)
 		if err != nil {
-			fmt.Printf("Could not parse word id %d for word %v", i, err)
+			report.add(source, line, 0, 0, row[0], "word sense id is not a number")
+			continue
+		}
+		if prev, ok := ids[int(id)]; ok {
+			report.add(source, line, int(id), 0, row[0],
+				fmt.Sprintf("duplicate word sense id, first used at %s:%d", source, prev))
+		} else {
+			ids[int(id)] = line
 		}
 		simp := row[1]
+		if len(simp) == 0 || simp == "\\N" {
+			report.add(source, line, int(id), 1, simp, "missing headword")
+			continue
+		}
+		if !dicttypes.ContainsCJK(simp) {
+			report.add(source, line, int(id), 1, simp, "no Chinese characters")
+		}
 		trad := row[2]
+		if trad != "\\N" && !dicttypes.ContainsCJK(trad) {
+			report.add(source, line, int(id), 2, trad, "no Chinese characters")
+		}
 		pinyin := row[3]
+		if len(pinyin) == 0 {
+			report.add(source, line, int(id), 3, pinyin, "missing pinyin")
+		}
 		english := row[4]
+		if len(english) == 0 {
+			report.add(source, line, int(id), 4, english, "missing English")
+		}
 		grammar := row[5]
 		conceptCN := row[6]
 		if conceptCN == "\\N" {
@@ -117,42 +248,39 @@ func loadDictReader(r io.Reader, wdict map[string]*dicttypes.Word,
 		}
 		domainCN := row[8]
 		domain := row[9]
+		subdomainCN := row[10]
+		if subdomainCN == "\\N" {
+			subdomainCN = ""
+		}
 		subdomain := row[11]
 		if subdomain == "\\N" {
 			subdomain = ""
 		}
 		// If subdomain, aka parent, should be avoided, then skip
-		if _, ok := avoidSub[subdomain]; ok {
+		if _, ok := tr.avoidSub[subdomain]; ok {
 			continue
 		}
-		subdomainCN := row[12]
-		if subdomainCN == "\\N" {
-			subdomainCN = ""
-		}
 		image := row[12]
 		mp3 := row[13]
-		notes := ""
-		hwId := 0
-		if len(row) == 16 {
-			notes = row[14]
-			if notes == "\\N" {
-				notes = ""
-			}
+		notes := row[14]
+		if notes == "\\N" {
+			notes = ""
+		}
+		// Default to a headword of its own so that the entry can still be found
+		hwId := int(id)
+		if len(row) >= 16 {
 			hwIdInt, err := strconv.ParseInt(row[15], 10, 0)
 			if err != nil {
-				log.Printf("loadDictFile, id: %d, simp: %s, trad: %s, "+
-					"pinyin: %s, english: %s, grammar: %s\n",
-					id, simp, trad, pinyin, english, grammar)
-				log.Printf("loadDictFile: Could not parse headword id for word %d: %v",
-					id, err)
+				report.add(source, line, int(id), 15, row[15], "headword id is not a number")
+			} else {
+				hwId = int(hwIdInt)
 			}
-			hwId = int(hwIdInt)
 		} else {
-			log.Printf("loadDictFile, No. cols: %d", len(row))
-			log.Printf("loadDictFile, id: %d, simp: %s, trad: %s, pinyin: %s, "+
-				"english: %s, grammar: %s\n",
-				id, simp, trad, pinyin, english, grammar)
-			log.Printf("loadDictFile wrong number of columns %d: %v", id, err)
+			report.add(source, line, int(id), 15, "", "missing headword id")
+		}
+		jyutping := ""
+		if len(row) == 17 && row[16] != "\\N" {
+			jyutping = row[16]
 		}
 		ws := dicttypes.WordSense{
 			Id:          int(id),
@@ -171,12 +299,35 @@ func loadDictReader(r io.Reader, wdict map[string]*dicttypes.Word,
 			Image:       image,
 			MP3:         mp3,
 			Notes:       notes,
+			Jyutping:    jyutping,
 		}
-		word, ok := wdict[simp]
+		senses = append(senses, ws)
+	}
+	return senses, nil
+}
+
+// addSense adds a word sense to the dictionary, keyed by both simplified and
+// traditional
+func addSense(wdict map[string]*dicttypes.Word, ws dicttypes.WordSense) {
+	simp := ws.Simplified
+	trad := ws.Traditional
+	word, ok := wdict[simp]
+	if ok {
+		word.Senses = append(word.Senses, ws)
+	} else {
+		wdict[simp] = &dicttypes.Word{
+			Simplified:  ws.Simplified,
+			Traditional: ws.Traditional,
+			Pinyin:      ws.Pinyin,
+			HeadwordId:  ws.HeadwordId,
+			Senses:      []dicttypes.WordSense{ws},
+		}
+	}
+	if trad != "\\N" {
 		if ok {
-			word.Senses = append(word.Senses, ws)
+			wdict[trad] = word
 		} else {
-			wdict[simp] = &dicttypes.Word{
+			wdict[trad] = &dicttypes.Word{
 				Simplified:  ws.Simplified,
 				Traditional: ws.Traditional,
 				Pinyin:      ws.Pinyin,
@@ -184,21 +335,7 @@ func loadDictReader(r io.Reader, wdict map[string]*dicttypes.Word,
 				Senses:      []dicttypes.WordSense{ws},
 			}
 		}
-		if trad != "\\N" {
-			if ok {
-				wdict[trad] = word
-			} else {
-				wdict[trad] = &dicttypes.Word{
-					Simplified:  ws.Simplified,
-					Traditional: ws.Traditional,
-					Pinyin:      ws.Pinyin,
-					HeadwordId:  ws.HeadwordId,
-					Senses:      []dicttypes.WordSense{ws},
-				}
-			}
-		}
 	}
-	return nil
 }
 
 // loadDictKeys ads keys only from an io.Reader to the given dictionary
@@ -161,7 +161,7 @@ func TestLoadDictReader(t *testing.T) {
   for _, tc := range tests {
 		wdict := make(map[string]*dicttypes.Word)
 		r := strings.NewReader(tc.input)
-		err := loadDictReader(r, wdict, avoidSub)
+		err := loadDictReader(r, "test", wdict, avoidSub, NewValidationReport(false))
 		if tc.expectError && (err == nil) {
 			t.Fatalf("%s: expected an error but got none", tc.name)
 		}
@@ -273,6 +273,7 @@ func TestLoadDictReaderValues(t *testing.T) {
 		Concept: "Aspectual Particle",
 		DomainCN: "现代汉语",
 		Domain: "Modern Chinese",
+		SubdomainCN: "虚词",
 		Subdomain: "Function Words",
 		Image: "\\N",
 		MP3: "le.mp3",
@@ -307,6 +308,7 @@ func TestLoadDictReaderValues(t *testing.T) {
 		Concept: "Modal Particle",
 		DomainCN: "现代汉语",
 		Domain: "Modern Chinese",
+		SubdomainCN: "虚词",
 		Subdomain: "Function Words",
 		Image: "\\N",
 		MP3: "le.mp3",
@@ -334,7 +336,7 @@ func TestLoadDictReaderValues(t *testing.T) {
   for _, tc := range tests {
 		wdict := make(map[string]*dicttypes.Word)
 		r := strings.NewReader(tc.input)
-		err := loadDictReader(r, wdict, avoidSub)
+		err := loadDictReader(r, "test", wdict, avoidSub, NewValidationReport(false))
 		if err != nil {
 			t.Fatalf("%s: unexpected an error %v", tc.name, err)
 		}
@@ -346,4 +348,129 @@ func TestLoadDictReaderValues(t *testing.T) {
 			t.Fatalf("%s: got senses\n%v\nwant\n%v", tc.name, w.Senses, tc.expectWord.Senses)
 		}
 	}
-}
\ No newline at end of file
+}
+
+// TestLoadDictReaderJyutping tests the optional jyutping column
+func TestLoadDictReaderJyutping(t *testing.T) {
+	input := "8422\t汉语\t漢語\thànyǔ\tChinese language\tnoun\t\\N\t\\N\t现代汉语\tModern Chinese\t\\N\t\\N\t\\N\t\\N\t\\N\t8422\thon3 jyu5\n" +
+		"2\t邃古\t\\N\tsuìgǔ\tremote antiquity\tnoun\t\\N\t\\N\t现代汉语\tModern Chinese\t\\N\t\\N\t\\N\t\\N\t\\N\t2\t\\N\n"
+	wdict := make(map[string]*dicttypes.Word)
+	report := NewValidationReport(false)
+	err := loadDictReader(strings.NewReader(input), "test", wdict,
+		make(map[string]bool), report)
+	if err != nil {
+		t.Fatalf("TestLoadDictReaderJyutping: unexpected error %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Errorf("TestLoadDictReaderJyutping: unexpected issues %v", report.Issues)
+	}
+	expect := map[string]string{"汉语": "hon3 jyu5", "邃古": ""}
+	for simp, jyutping := range expect {
+		w, ok := wdict[simp]
+		if !ok {
+			t.Fatalf("TestLoadDictReaderJyutping: %s not found", simp)
+		}
+		if got := w.Senses[0].Jyutping; got != jyutping {
+			t.Errorf("TestLoadDictReaderJyutping: %s expected %q, got %q", simp,
+				jyutping, got)
+		}
+	}
+}
+
+// TestLoadDictReaderReport tests the problems reported by loadDictReader
+func TestLoadDictReaderReport(t *testing.T) {
+	avoidSub := make(map[string]bool)
+	type test struct {
+		name         string
+		input        string
+		strict       bool
+		expectError  bool
+		expectSize   int
+		expectIssues []Issue
+	}
+	tests := []test{
+		{
+			name:         "Valid entries",
+			input:        inputTwoEntries,
+			strict:       true,
+			expectError:  false,
+			expectSize:   2,
+			expectIssues: []Issue{},
+		},
+		{
+			name:        "Too few columns",
+			input:       "# comment\nHello, Dictionary!",
+			strict:      false,
+			expectError: false,
+			expectSize:  0,
+			expectIssues: []Issue{
+				{"test", 2, 0, "", "", "expected 16 or 17 columns but found 1"},
+			},
+		},
+		{
+			name:        "Bad id",
+			input:       "x2	邃古	\\N	suìgǔ	remote antiquity	noun	\\N	\\N	现代汉语	Modern Chinese	\\N	\\N	\\N	\\N	\\N	2\n",
+			strict:      false,
+			expectError: false,
+			expectSize:  0,
+			expectIssues: []Issue{
+				{"test", 1, 0, "id", "x2", "word sense id is not a number"},
+			},
+		},
+		{
+			name:        "No Chinese and bad headword id",
+			input:       "2	gu	\\N	suìgǔ	remote antiquity	noun	\\N	\\N	现代汉语	Modern Chinese	\\N	\\N	\\N	\\N	\\N	y\n",
+			strict:      false,
+			expectError: false,
+			expectSize:  1,
+			expectIssues: []Issue{
+				{"test", 1, 2, "simplified", "gu", "no Chinese characters"},
+				{"test", 1, 2, "headword", "y", "headword id is not a number"},
+			},
+		},
+		{
+			name:        "Duplicate id strict",
+			input:       "2	邃古	\\N	suìgǔ	remote antiquity	noun	\\N	\\N	现代汉语	Modern Chinese	\\N	\\N	\\N	\\N	\\N	2\n2	平地	\\N	píngdì	flat land	noun	\\N	\\N	现代汉语	Modern Chinese	\\N	\\N	\\N	\\N	\\N	2\n",
+			strict:      true,
+			expectError: true,
+			expectSize:  2,
+			expectIssues: []Issue{
+				{"test", 2, 2, "id", "2", "duplicate word sense id, first used at test:1"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		wdict := make(map[string]*dicttypes.Word)
+		r := strings.NewReader(tc.input)
+		report := NewValidationReport(tc.strict)
+		err := loadDictReader(r, "test", wdict, avoidSub, report)
+		if err != nil {
+			t.Fatalf("%s: unexpected error reading: %v", tc.name, err)
+		}
+		gotErr := report.Err()
+		if tc.expectError && gotErr == nil {
+			t.Errorf("%s: expected an error but got none", tc.name)
+		}
+		if !tc.expectError && gotErr != nil {
+			t.Errorf("%s: did not expect an error but got %v", tc.name, gotErr)
+		}
+		if tc.expectSize != len(wdict) {
+			t.Errorf("%s: expectSize got %d, want %d", tc.name, len(wdict), tc.expectSize)
+		}
+		if !reflect.DeepEqual(tc.expectIssues, report.Issues) {
+			t.Errorf("%s: got issues\n%v\nwant\n%v", tc.name, report.Issues, tc.expectIssues)
+		}
+	}
+	// The same id in another source, for example a patch, is not a duplicate
+	const row = "2	邃古	\\N	suìgǔ	remote antiquity	noun	\\N	\\N	现代汉语	Modern Chinese	\\N	\\N	\\N	\\N	\\N	2\n"
+	report := NewValidationReport(true)
+	for _, source := range []string{"words.tsv", "dict_patch.tsv"} {
+		_, err := tsvReader{avoidSub}.ReadSenses(strings.NewReader(row), source, report)
+		if err != nil {
+			t.Fatalf("%s: unexpected error reading: %v", source, err)
+		}
+	}
+	if len(report.Issues) != 0 {
+		t.Errorf("Id in two sources: expected no issues, got %v", report.Issues)
+	}
+}
@@ -0,0 +1,281 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Readers for dictionary formats and merging of multiple sources
+
+package dictionary
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+)
+
+// DictReader reads word senses from a dictionary source in a particular
+// format. Senses without an id or headword id have them set to zero and are
+// given new ones when loaded.
+type DictReader interface {
+	// ReadSenses reads all word senses, adding problems found to the report
+	ReadSenses(r io.Reader, source string, report *ValidationReport) ([]dicttypes.WordSense, error)
+}
+
+// dictReaders gives the readers for each of the formats supported in LUFiles
+func dictReaders(avoidSub map[string]bool) map[string]DictReader {
+	return map[string]DictReader{
+		"tsv":    tsvReader{avoidSub},
+		"cedict": NewCEDICTReader(),
+		"json":   NewJSONReader(),
+	}
+}
+
+// LoadCEDICT loads a dictionary in CC-CEDICT format
+func LoadCEDICT(r io.Reader) (*Dictionary, error) {
+	return loadFormat(r, NewCEDICTReader(), "CC-CEDICT")
+}
+
+// LoadJSON loads a dictionary from a JSON array of word senses
+func LoadJSON(r io.Reader) (*Dictionary, error) {
+	return loadFormat(r, NewJSONReader(), "JSON")
+}
+
+// loadFormat loads a dictionary from a single source with the given reader
+func loadFormat(r io.Reader, reader DictReader, source string) (*Dictionary, error) {
+	report := NewValidationReport(false)
+	senses, err := reader.ReadSenses(r, source, report)
+	if err != nil {
+		return nil, fmt.Errorf("dictionary.loadFormat, error reading %s: %v", source, err)
+	}
+	merged, err := mergeSenses([]sourceSenses{{source, 0, senses, false}}, report, false)
+	if err != nil {
+		return nil, fmt.Errorf("dictionary.loadFormat, error loading %s: %v", source, err)
+	}
+	wdict := make(map[string]*dicttypes.Word)
+	for _, ws := range merged {
+		addSense(wdict, ws)
+	}
+	return NewDictionary(wdict), nil
+}
+
+// jsonReader reads a JSON array of objects with the same fields as WordSense,
+// or of headwords with their senses
+type jsonReader struct{}
+
+// jsonEntry is a word sense or, as written by the JSON exporter, a headword
+// with its senses
+type jsonEntry struct {
+	dicttypes.WordSense
+	Senses []dicttypes.WordSense
+}
+
+// NewJSONReader creates a reader for JSON lexical units, eg
+//
+//	[{"Simplified": "汉语", "Traditional": "漢語", "Pinyin": "hànyǔ",
+//	  "English": "Chinese language", "Grammar": "noun"}]
+//
+// Headwords with their senses, as exported, can also be read, eg
+//
+//	[{"Simplified": "汉语", "HeadwordId": 1, "Senses": [{"Id": 1,
+//	  "HeadwordId": 1, "Simplified": "汉语", ...}]}]
+func NewJSONReader() DictReader {
+	return jsonReader{}
+}
+
+// ReadSenses reads word senses from JSON. The row in problems reported is the
+// position of the entry in the array, starting from 1.
+func (jsonReader) ReadSenses(r io.Reader, source string,
+	report *ValidationReport) ([]dicttypes.WordSense, error) {
+	entries := []jsonEntry{}
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("could not parse JSON lexical units: %v", err)
+	}
+	senses := []dicttypes.WordSense{}
+	for i, e := range entries {
+		if len(e.Senses) > 0 {
+			for _, ws := range e.Senses {
+				senses = readJSONSense(ws, i+1, source, report, senses)
+			}
+			continue
+		}
+		senses = readJSONSense(e.WordSense, i+1, source, report, senses)
+	}
+	return senses, nil
+}
+
+// readJSONSense checks a word sense read from JSON and adds it to the senses
+// unless the headword is missing
+func readJSONSense(ws dicttypes.WordSense, row int, source string,
+	report *ValidationReport, senses []dicttypes.WordSense) []dicttypes.WordSense {
+	report.Rows++
+	if len(ws.Simplified) == 0 {
+		report.add(source, row, ws.Id, 1, "", "missing headword")
+		return senses
+	}
+	if !dicttypes.ContainsCJK(ws.Simplified) {
+		report.add(source, row, ws.Id, 1, ws.Simplified, "no Chinese characters")
+	}
+	if len(ws.Traditional) == 0 || ws.Traditional == ws.Simplified {
+		ws.Traditional = "\\N"
+	}
+	if len(ws.Pinyin) == 0 {
+		report.add(source, row, ws.Id, 3, "", "missing pinyin")
+	}
+	if len(ws.English) == 0 {
+		report.add(source, row, ws.Id, 4, "", "missing English")
+	}
+	return append(senses, ws)
+}
+
+// sourceSenses holds the word senses read from one source
+type sourceSenses struct {
+	source   string
+	priority int
+	senses   []dicttypes.WordSense
+
+	// Ids in the source are kept as is, as for TSV files, since dictionary
+	// edits and diffs refer to them
+	stableIds bool
+}
+
+// mergeSenses combines the senses from all sources. When a headword occurs in
+// sources with different priorities only the senses from the highest priority
+// are kept. Senses with a missing id are given a new one, and likewise for
+// headword ids. It is an error for a source to use an id already used by
+// another source, or a headword id already used for a different headword,
+// unless renumber is true and the source does not have stable ids, in which
+// case the id is changed and the change reported.
+func mergeSenses(sources []sourceSenses, report *ValidationReport,
+	renumber bool) ([]dicttypes.WordSense, error) {
+	sorted := make([]sourceSenses, len(sources))
+	copy(sorted, sources)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].priority > sorted[j].priority
+	})
+	owner := make(map[string]int) // headword to priority of source
+	kept := make([][]dicttypes.WordSense, len(sorted))
+	nextId, nextHwId := 1, 1
+	for i, src := range sorted {
+		for _, ws := range src.senses {
+			if p, ok := owner[ws.Simplified]; ok && p > src.priority {
+				continue
+			}
+			owner[ws.Simplified] = src.priority
+			kept[i] = append(kept[i], ws)
+			if ws.Id >= nextId {
+				nextId = ws.Id + 1
+			}
+			if ws.HeadwordId >= nextHwId {
+				nextHwId = ws.HeadwordId + 1
+			}
+		}
+	}
+
+	// Claim the stable ids first, so that other sources are renumbered around
+	// them
+	idSource := make(map[int]string)
+	hwOwner := make(map[int]headwordOwner)
+	for i, src := range sorted {
+		if !src.stableIds {
+			continue
+		}
+		for _, ws := range kept[i] {
+			if err := claimIds(src.source, ws, idSource, hwOwner); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	merged := []dicttypes.WordSense{}
+	for i, src := range sorted {
+		hwIds := make(map[int]int)
+		hwBySimp := make(map[string]int)
+		for _, ws := range kept[i] {
+			if !src.stableIds {
+				if s, ok := idSource[ws.Id]; ok && ws.Id != 0 && s != src.source {
+					if !renumber {
+						return nil, fmt.Errorf("dictionary.mergeSenses, id %d in %s already used in %s",
+							ws.Id, src.source, s)
+					}
+					report.add(src.source, 0, ws.Id, 0, fmt.Sprint(ws.Id),
+						fmt.Sprintf("id used by another source, changed to %d", nextId))
+					ws.Id = 0
+				}
+				if hw, ok := hwIds[ws.HeadwordId]; ok {
+					ws.HeadwordId = hw
+				} else if o, ok := hwOwner[ws.HeadwordId]; ok && ws.HeadwordId != 0 &&
+					o.source != src.source && o.simplified != ws.Simplified {
+					if !renumber {
+						return nil, fmt.Errorf("dictionary.mergeSenses, headword id %d in %s already used in %s for %s",
+							ws.HeadwordId, src.source, o.source, o.simplified)
+					}
+					report.add(src.source, 0, ws.Id, 0, fmt.Sprint(ws.HeadwordId),
+						fmt.Sprintf("headword id used by another source, changed to %d", nextHwId))
+					hwIds[ws.HeadwordId] = nextHwId
+					ws.HeadwordId = nextHwId
+					nextHwId++
+				}
+			}
+			if ws.Id == 0 {
+				ws.Id = nextId
+				nextId++
+			}
+			if ws.HeadwordId == 0 {
+				hw, ok := hwBySimp[ws.Simplified]
+				if !ok {
+					hw = nextHwId
+					nextHwId++
+					hwBySimp[ws.Simplified] = hw
+				}
+				ws.HeadwordId = hw
+			}
+			if !src.stableIds {
+				if err := claimIds(src.source, ws, idSource, hwOwner); err != nil {
+					return nil, err
+				}
+			}
+			merged = append(merged, ws)
+		}
+	}
+	return merged, nil
+}
+
+// headwordOwner is the source that first used a headword id
+type headwordOwner struct {
+	source     string
+	simplified string
+}
+
+// claimIds records the ids of a word sense as used by the source, giving an
+// error if another source already uses them
+func claimIds(source string, ws dicttypes.WordSense, idSource map[int]string,
+	hwOwner map[int]headwordOwner) error {
+	if ws.Id != 0 {
+		if s, ok := idSource[ws.Id]; ok && s != source {
+			return fmt.Errorf("dictionary.mergeSenses, id %d in %s already used in %s",
+				ws.Id, source, s)
+		}
+		idSource[ws.Id] = source
+	}
+	if ws.HeadwordId != 0 {
+		o, ok := hwOwner[ws.HeadwordId]
+		if ok && o.source != source && o.simplified != ws.Simplified {
+			return fmt.Errorf("dictionary.mergeSenses, headword id %d in %s already used in %s for %s",
+				ws.HeadwordId, source, o.source, o.simplified)
+		}
+		if !ok {
+			hwOwner[ws.HeadwordId] = headwordOwner{source, ws.Simplified}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dictionary
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alexamies/chinesenotes-go/config"
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+)
+
+const inputJSON = `[
+  {"Id": 100, "HeadwordId": 100, "Simplified": "汉语", "Traditional": "漢語",
+   "Pinyin": "hànyǔ", "English": "Chinese language", "Grammar": "noun"},
+  {"Simplified": "平地", "Pinyin": "píngdì", "English": "level ground"}
+]`
+
+// TestLoadJSON tests loading JSON lexical units
+func TestLoadJSON(t *testing.T) {
+	dict, err := LoadJSON(strings.NewReader(inputJSON))
+	if err != nil {
+		t.Fatalf("TestLoadJSON: unexpected error: %v", err)
+	}
+	if len(dict.Wdict) != 3 {
+		t.Errorf("TestLoadJSON: expected 3 keys, got %d", len(dict.Wdict))
+	}
+	w, ok := dict.Wdict["漢語"]
+	if !ok {
+		t.Fatal("TestLoadJSON: traditional not found")
+	}
+	if w.HeadwordId != 100 || w.Senses[0].Grammar != "noun" {
+		t.Errorf("TestLoadJSON: unexpected word %v", w)
+	}
+	w, ok = dict.Wdict["平地"]
+	if !ok {
+		t.Fatal("TestLoadJSON: 平地 not found")
+	}
+	if w.Traditional != "\\N" || w.Senses[0].Id <= 100 {
+		t.Errorf("TestLoadJSON: unexpected word %v", w)
+	}
+	_, err = LoadJSON(strings.NewReader("[{"))
+	if err == nil {
+		t.Error("TestLoadJSON: expected an error for bad JSON")
+	}
+}
+
+// TestMergeSenses tests merging of sources by priority
+func TestMergeSenses(t *testing.T) {
+	cn := []dicttypes.WordSense{
+		{Id: 2, HeadwordId: 2, Simplified: "平地", English: "flat land"},
+		{Id: 3, HeadwordId: 2, Simplified: "平地", English: "a plain"},
+	}
+	cedict := []dicttypes.WordSense{
+		{Simplified: "平地", English: "level ground"},
+		{Simplified: "汉语", English: "Chinese language"},
+	}
+	other := []dicttypes.WordSense{
+		{Id: 2, HeadwordId: 2, Simplified: "邃古", English: "remote antiquity"},
+	}
+
+	// Ids in TSV files are never changed
+	tsvSources := []sourceSenses{
+		{"cn", 0, cn, true},
+		{"other", 0, other, true},
+	}
+	if _, err := mergeSenses(tsvSources, NewValidationReport(false), true); err == nil {
+		t.Error("TestMergeSenses: expected error for id used in two TSV files")
+	}
+
+	sources := []sourceSenses{
+		{"cedict", -1, cedict, false},
+		{"cn", 0, cn, true},
+		{"other", 0, other, false},
+	}
+	if _, err := mergeSenses(sources, NewValidationReport(false), false); err == nil {
+		t.Error("TestMergeSenses: expected error for id collision without renumbering")
+	}
+	report := NewValidationReport(false)
+	merged, err := mergeSenses(sources, report, true)
+	if err != nil {
+		t.Fatalf("TestMergeSenses: unexpected error: %v", err)
+	}
+	want := []dicttypes.WordSense{
+		{Id: 2, HeadwordId: 2, Simplified: "平地", English: "flat land"},
+		{Id: 3, HeadwordId: 2, Simplified: "平地", English: "a plain"},
+		{Id: 4, HeadwordId: 3, Simplified: "邃古", English: "remote antiquity"},
+		{Id: 5, HeadwordId: 4, Simplified: "汉语", English: "Chinese language"},
+	}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("TestMergeSenses: got %v, want %v", merged, want)
+	}
+	if len(report.Issues) != 2 {
+		t.Errorf("TestMergeSenses: expected 2 issues for the id collisions, got %v", report.Issues)
+	}
+}
+
+// TestLoadDictFileFormats tests loading a mix of formats from files
+func TestLoadDictFileFormats(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"words.txt":    inputTwoEntries,
+		"cedict_ts.u8": inputCEDICT,
+		"extra.json":   inputJSON,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("TestLoadDictFileFormats: could not write %s: %v", name, err)
+		}
+	}
+	appConfig := config.AppConfig{
+		LUSources: []config.LUSource{
+			{FileName: filepath.Join(dir, "words.txt"), Format: "tsv", Priority: 1},
+			{FileName: filepath.Join(dir, "cedict_ts.u8"), Format: "cedict", Priority: 0},
+			{FileName: filepath.Join(dir, "extra.json"), Format: "json", Priority: 0},
+		},
+	}
+	dict, err := LoadDictFile(appConfig)
+	if err != nil {
+		t.Fatalf("TestLoadDictFileFormats: unexpected error: %v", err)
+	}
+	for _, key := range []string{"邃古", "平地", "漢語", "了", "绿"} {
+		if _, ok := dict.Wdict[key]; !ok {
+			t.Errorf("TestLoadDictFileFormats: %s not found", key)
+		}
+	}
+	if n := len(dict.Wdict["汉语"].Senses); n != 2 {
+		t.Errorf("TestLoadDictFileFormats: expected 2 senses for 汉语 from equal priority sources, got %d", n)
+	}
+	appConfig.LUSources[1].Format = "xml"
+	if _, err = LoadDictFile(appConfig); err == nil {
+		t.Error("TestLoadDictFileFormats: expected an error for unknown format")
+	}
+	keys, err := LoadDictKeys(config.AppConfig{
+		LUFileNames: []string{filepath.Join(dir, "cedict_ts.u8")},
+	})
+	if err != nil {
+		t.Fatalf("TestLoadDictFileFormats: unexpected error loading keys: %v", err)
+	}
+	if !(*keys)["漢語"] {
+		t.Error("TestLoadDictFileFormats: expected key 漢語 from CC-CEDICT")
+	}
+}
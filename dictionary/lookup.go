@@ -7,6 +7,10 @@ package dictionary
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/alexamies/chinesenotes-go/dicttypes"
 )
@@ -18,21 +22,128 @@ type Results struct {
 }
 
 
-// SubstringIndexMem looks up substrings from a map loaded from a file.
+// Maximum number of headwords returned by substring lookup, as for Firestore
+const maxSubstrResults = 100
+
+// SubstringIndexMem looks up headwords containing a substring using an index
+// of the characters in the simplified and traditional forms.
 type SubstringIndexMem struct {
+	dict      *Dictionary
+	charIndex map[rune][]int // Character to sorted headword ids
 }
 
-// NewSubstringIndexMem initialize a SubstringIndexMem
-func NewSubstringIndexMem(ctx context.Context) (SubstringIndex, error) {
-	return &SubstringIndexMem{}, nil
+// NewSubstringIndexMem initialize a SubstringIndexMem for the headwords in the
+// dictionary
+func NewSubstringIndexMem(ctx context.Context, dict *Dictionary) (SubstringIndex, error) {
+	if dict == nil {
+		return nil, fmt.Errorf("NewSubstringIndexMem, dictionary is nil")
+	}
+	charIndex := make(map[rune][]int)
+	for id, w := range dict.HeadwordIds {
+		seen := make(map[rune]bool)
+		for _, c := range w.Simplified + w.Traditional {
+			if !seen[c] {
+				seen[c] = true
+				charIndex[c] = append(charIndex[c], id)
+			}
+		}
+	}
+	for _, ids := range charIndex {
+		sort.Ints(ids)
+	}
+	return &SubstringIndexMem{
+		dict:      dict,
+		charIndex: charIndex,
+	}, nil
 }
 
-// Lookup a term based on a substring and a topic
+// Lookup a term based on a substring and a topic. The query may contain the
+// wildcards _ for one character and * for any number of characters. Topic and
+// subtopic are English domain and subdomain names, ignored if empty. Shorter
+// headwords are given first.
 func (searcher SubstringIndexMem) LookupSubstr(ctx context.Context, query, topic_en, subtopic_en string) (*Results, error) {
 	if query == "" {
 		return nil, fmt.Errorf("query string is empty")
 	}
-	return &Results{}, nil
+	match, literals, err := substrMatcher(query)
+	if err != nil {
+		return nil, err
+	}
+	// Candidates contain the least common character in the query
+	var candidates []int
+	for _, c := range literals {
+		ids := searcher.charIndex[c]
+		if candidates == nil || len(ids) < len(candidates) {
+			candidates = ids
+		}
+	}
+	words := []dicttypes.Word{}
+	for _, id := range candidates {
+		w := searcher.dict.HeadwordIds[id]
+		if !match(w.Simplified) && !(w.Traditional != "\\N" && match(w.Traditional)) {
+			continue
+		}
+		if !inTopic(w, topic_en, subtopic_en) {
+			continue
+		}
+		word := *w
+		if word.Traditional == "\\N" {
+			word.Traditional = ""
+		}
+		words = append(words, word)
+	}
+	sort.SliceStable(words, func(i, j int) bool {
+		return utf8.RuneCountInString(words[i].Simplified) < utf8.RuneCountInString(words[j].Simplified)
+	})
+	if len(words) > maxSubstrResults {
+		words = words[:maxSubstrResults]
+	}
+	return &Results{words}, nil
+}
+
+// substrMatcher gives a function matching text containing the query, with
+// wildcards, and the characters in the query that are not wildcards
+func substrMatcher(query string) (func(string) bool, []rune, error) {
+	literals := []rune{}
+	var b strings.Builder
+	for _, c := range query {
+		switch c {
+		case '_':
+			b.WriteString(".")
+		case '*':
+			b.WriteString(".*")
+		default:
+			literals = append(literals, c)
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if len(literals) == 0 {
+		return nil, nil, fmt.Errorf("query %q has only wildcards", query)
+	}
+	if len(literals) == utf8.RuneCountInString(query) {
+		return func(text string) bool {
+			return strings.Contains(text, query)
+		}, literals, nil
+	}
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, nil, fmt.Errorf("substrMatcher, bad query %q: %v", query, err)
+	}
+	return re.MatchString, literals, nil
+}
+
+// inTopic tests whether any sense of the word is in the topic and subtopic
+func inTopic(w *dicttypes.Word, topic_en, subtopic_en string) bool {
+	if topic_en == "" && subtopic_en == "" {
+		return true
+	}
+	for _, ws := range w.Senses {
+		if (topic_en == "" || strings.EqualFold(ws.Domain, topic_en)) &&
+			(subtopic_en == "" || strings.EqualFold(ws.Subdomain, subtopic_en)) {
+			return true
+		}
+	}
+	return false
 }
 
 // Used for grouping word senses by similar headwords in result sets
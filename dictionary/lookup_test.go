@@ -29,7 +29,17 @@ func TestAddWordSense2Map(t *testing.T) {
 func TestLookupSubstr(t *testing.T) {
 	t.Log("TestLookupSubstr: Begin unit tests")
 	ctx := context.Background()
-	dictSearcher, err := NewSubstringIndexMem(ctx)
+	zhiyi := diffSense(4, 4, "知意", "to know the meaning")
+	zhiyi.Traditional = "知意"
+	zhiyi.Domain = "Buddhism"
+	zhiyi.Subdomain = "Concept"
+	dict := diffTestDict(
+		diffSense(1, 1, "不可思议", "inconceivable"),
+		diffSense(2, 2, "思议", "to conceive"),
+		diffSense(3, 3, "知道", "to know"),
+		zhiyi,
+	)
+	dictSearcher, err := NewSubstringIndexMem(ctx, dict)
 	if err != nil {
 		t.Fatalf("could not initialize SubstringIndexMem: %v", err)
 	}
@@ -37,8 +47,10 @@ func TestLookupSubstr(t *testing.T) {
 		name string
 		query string
 		domain string
+		subdomain string
 		expectErr bool
 		expectNum int
+		expectFirst string
   }
   tests := []test{
 		{	name: "expect error",
@@ -59,9 +71,54 @@ func TestLookupSubstr(t *testing.T) {
 			expectErr: false,
 		 	expectNum: 0,
 		 },
+		{	name: "only wildcards",
+			query: "*_",
+			expectErr: true,
+		 },
+		{	name: "substring, shorter first",
+			query: "思议",
+			expectNum: 2,
+			expectFirst: "思议",
+		 },
+		{	name: "one character wildcard",
+			query: "知_",
+			expectNum: 2,
+			expectFirst: "知道",
+		 },
+		{	name: "any characters wildcard",
+			query: "不*议",
+			expectNum: 1,
+			expectFirst: "不可思议",
+		 },
+		{	name: "wildcard matches one character only",
+			query: "不_思议",
+			expectNum: 1,
+		 },
+		{	name: "wildcard does not match none",
+			query: "不_可思议",
+			expectNum: 0,
+		 },
+		{	name: "topic",
+			query: "知",
+			domain: "buddhism",
+			expectNum: 1,
+			expectFirst: "知意",
+		 },
+		{	name: "topic and subtopic",
+			query: "知",
+			domain: "Buddhism",
+			subdomain: "Concept",
+			expectNum: 1,
+		 },
+		{	name: "other subtopic",
+			query: "知",
+			domain: "Buddhism",
+			subdomain: "Person",
+			expectNum: 0,
+		 },
   }
   for _, tc := range tests {
-		results, err := dictSearcher.LookupSubstr(ctx, tc.query, "", "")
+		results, err := dictSearcher.LookupSubstr(ctx, tc.query, tc.domain, tc.subdomain)
 		if tc.expectErr && err == nil {
 			t.Errorf("TestLookupSubstr: %s, expect an error, got none", tc.name)
 			continue
@@ -82,5 +139,15 @@ func TestLookupSubstr(t *testing.T) {
 			t.Errorf("TestLookupSubstr: %s, expected %d results, got: %v", tc.name,
 					tc.expectNum, resNum)
 		}
+		if len(tc.expectFirst) > 0 && resNum > 0 && results.Words[0].Simplified != tc.expectFirst {
+			t.Errorf("TestLookupSubstr: %s, expected first %s, got: %s", tc.name,
+					tc.expectFirst, results.Words[0].Simplified)
+		}
+		for _, w := range results.Words {
+			if w.Traditional == "\\N" {
+				t.Errorf("TestLookupSubstr: %s, expected empty traditional for %s", tc.name,
+					w.Simplified)
+			}
+		}
 	}
 }
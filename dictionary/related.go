@@ -0,0 +1,181 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dictionary
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+)
+
+// Maximum number of words in each list of related words
+const maxRelated = 20
+
+// RelatedWords holds the words related to a headword
+type RelatedWords struct {
+	HeadwordId  int
+	Compounds   []dicttypes.Word      // Longer headwords containing the word
+	SubWords    []dicttypes.Word      // Shorter headwords within the word
+	SameConcept []dicttypes.WordSense // Senses of other words with a shared concept
+	SameDomain  []dicttypes.WordSense // Senses of other words in a shared subdomain
+}
+
+// RelatedFinder finds the compounds, sub-words, and words with the same
+// concept or domain as a headword
+type RelatedFinder interface {
+	FindRelated(ctx context.Context, hwId int) (*RelatedWords, bool)
+}
+
+type relatedFinderMem struct {
+	dict        *Dictionary
+	substrIndex SubstringIndex
+	concepts    map[string][]dicttypes.WordSense
+	domains     map[string][]dicttypes.WordSense
+}
+
+// NewRelatedFinder creates a RelatedFinder for the dictionary, using the
+// substring index to find compounds, none if the index is nil
+func NewRelatedFinder(dict *Dictionary, substrIndex SubstringIndex) RelatedFinder {
+	ids := []int{}
+	for id := range dict.HeadwordIds {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	concepts := make(map[string][]dicttypes.WordSense)
+	domains := make(map[string][]dicttypes.WordSense)
+	for _, id := range ids {
+		for _, ws := range dict.HeadwordIds[id].Senses {
+			if c := conceptKey(ws); len(c) > 0 {
+				concepts[c] = append(concepts[c], ws)
+			}
+			if d := domainKey(ws); len(d) > 0 {
+				domains[d] = append(domains[d], ws)
+			}
+		}
+	}
+	return relatedFinderMem{
+		dict:        dict,
+		substrIndex: substrIndex,
+		concepts:    concepts,
+		domains:     domains,
+	}
+}
+
+// conceptKey gives the concept of a sense, English if given, else Chinese
+func conceptKey(ws dicttypes.WordSense) string {
+	if len(ws.Concept) > 0 && ws.Concept != "\\N" {
+		return ws.Concept
+	}
+	if len(ws.ConceptCN) > 0 && ws.ConceptCN != "\\N" {
+		return ws.ConceptCN
+	}
+	return ""
+}
+
+// domainKey gives the domain and subdomain of a sense, empty if there is no
+// subdomain, since a domain alone is too broad to relate words
+func domainKey(ws dicttypes.WordSense) string {
+	if len(ws.Domain) == 0 || ws.Domain == "\\N" || len(ws.Subdomain) == 0 || ws.Subdomain == "\\N" {
+		return ""
+	}
+	return ws.Domain + "/" + ws.Subdomain
+}
+
+func (r relatedFinderMem) FindRelated(ctx context.Context, hwId int) (*RelatedWords, bool) {
+	w, ok := r.dict.HeadwordIds[hwId]
+	if !ok {
+		return nil, false
+	}
+	related := RelatedWords{
+		HeadwordId:  hwId,
+		Compounds:   r.compounds(ctx, *w),
+		SubWords:    r.subWords(*w),
+		SameConcept: relatedSenses(*w, r.concepts, conceptKey),
+		SameDomain:  relatedSenses(*w, r.domains, domainKey),
+	}
+	return &related, true
+}
+
+// compounds finds the headwords containing the word, shortest first
+func (r relatedFinderMem) compounds(ctx context.Context, w dicttypes.Word) []dicttypes.Word {
+	compounds := []dicttypes.Word{}
+	if r.substrIndex == nil {
+		return compounds
+	}
+	results, err := r.substrIndex.LookupSubstr(ctx, w.Simplified, "", "")
+	if err != nil {
+		log.Printf("RelatedFinder.compounds, error looking up %s: %v", w.Simplified, err)
+		return compounds
+	}
+	for _, c := range results.Words {
+		if c.HeadwordId == w.HeadwordId || c.Simplified == w.Simplified {
+			continue
+		}
+		compounds = append(compounds, c)
+		if len(compounds) == maxRelated {
+			break
+		}
+	}
+	return compounds
+}
+
+// subWords finds the headwords within the word, longest first
+func (r relatedFinderMem) subWords(w dicttypes.Word) []dicttypes.Word {
+	subWords := []dicttypes.Word{}
+	seen := map[int]bool{w.HeadwordId: true}
+	for _, ngram := range Ngrams(strings.Split(w.Simplified, ""), 1) {
+		sw, ok := r.dict.Wdict[ngram]
+		if !ok || seen[sw.HeadwordId] || ngram == w.Simplified {
+			continue
+		}
+		seen[sw.HeadwordId] = true
+		subWords = append(subWords, *sw)
+	}
+	sort.SliceStable(subWords, func(i, j int) bool {
+		return utf8.RuneCountInString(subWords[i].Simplified) >
+			utf8.RuneCountInString(subWords[j].Simplified)
+	})
+	if len(subWords) > maxRelated {
+		subWords = subWords[:maxRelated]
+	}
+	return subWords
+}
+
+// relatedSenses finds the senses of other words with the same key as any of
+// the senses of the word
+func relatedSenses(w dicttypes.Word, index map[string][]dicttypes.WordSense,
+	key func(dicttypes.WordSense) string) []dicttypes.WordSense {
+	related := []dicttypes.WordSense{}
+	seen := make(map[int]bool)
+	for _, ws := range w.Senses {
+		k := key(ws)
+		if len(k) == 0 {
+			continue
+		}
+		for _, s := range index[k] {
+			if s.HeadwordId == w.HeadwordId || seen[s.Id] {
+				continue
+			}
+			seen[s.Id] = true
+			related = append(related, s)
+			if len(related) == maxRelated {
+				return related
+			}
+		}
+	}
+	return related
+}
@@ -0,0 +1,140 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dictionary
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+)
+
+// relatedSense creates a sense with a concept and domain
+func relatedSense(id, hwId int, simp, concept, subdomain string) dicttypes.WordSense {
+	ws := diffSense(id, hwId, simp, simp)
+	ws.Concept = concept
+	ws.Domain = "Buddhism"
+	ws.Subdomain = subdomain
+	return ws
+}
+
+// wordList gives the simplified forms of the words
+func wordList(words []dicttypes.Word) []string {
+	l := []string{}
+	for _, w := range words {
+		l = append(l, w.Simplified)
+	}
+	return l
+}
+
+// senseList gives the simplified forms of the senses
+func senseList(senses []dicttypes.WordSense) []string {
+	l := []string{}
+	for _, ws := range senses {
+		l = append(l, ws.Simplified)
+	}
+	return l
+}
+
+// TestFindRelated tests finding compounds, sub-words, and senses with the same
+// concept or subdomain
+func TestFindRelated(t *testing.T) {
+	dict := diffTestDict(
+		relatedSense(1, 1, "佛", "Buddha", "\\N"),
+		relatedSense(2, 2, "佛法", "Dharma", "Concepts"),
+		relatedSense(3, 3, "佛法僧", "Three Jewels", "Concepts"),
+		relatedSense(4, 4, "法", "Dharma", "Concepts"),
+		relatedSense(5, 5, "僧", "Sangha", "\\N"),
+		relatedSense(6, 6, "达摩", "Dharma", "\\N"),
+		relatedSense(7, 7, "学佛法", "\\N", "Practice"),
+		relatedSense(8, 4, "法", "Law", "\\N"),
+	)
+	ctx := context.Background()
+	substrIndex, err := NewSubstringIndexMem(ctx, dict)
+	if err != nil {
+		t.Fatalf("TestFindRelated: unexpected error: %v", err)
+	}
+	type test struct {
+		name              string
+		hwId              int
+		substrIndex       SubstringIndex
+		expectCompounds   []string
+		expectSubWords    []string
+		expectSameConcept []string
+		expectSameDomain  []string
+	}
+	tests := []test{
+		{
+			name:              "Compounds and sub-words",
+			hwId:              2,
+			substrIndex:       substrIndex,
+			expectCompounds:   []string{"佛法僧", "学佛法"},
+			expectSubWords:    []string{"佛", "法"},
+			expectSameConcept: []string{"法", "达摩"},
+			expectSameDomain:  []string{"佛法僧", "法"},
+		},
+		{
+			name:              "Longest sub-words first",
+			hwId:              3,
+			substrIndex:       substrIndex,
+			expectCompounds:   []string{},
+			expectSubWords:    []string{"佛法", "佛", "法", "僧"},
+			expectSameConcept: []string{},
+			expectSameDomain:  []string{"佛法", "法"},
+		},
+		{
+			name:              "Single character",
+			hwId:              4,
+			substrIndex:       substrIndex,
+			expectCompounds:   []string{"佛法", "佛法僧", "学佛法"},
+			expectSubWords:    []string{},
+			expectSameConcept: []string{"佛法", "达摩"},
+			expectSameDomain:  []string{"佛法", "佛法僧"},
+		},
+		{
+			name:              "No substring index",
+			hwId:              4,
+			expectCompounds:   []string{},
+			expectSubWords:    []string{},
+			expectSameConcept: []string{"佛法", "达摩"},
+			expectSameDomain:  []string{"佛法", "佛法僧"},
+		},
+	}
+	for _, tc := range tests {
+		finder := NewRelatedFinder(dict, tc.substrIndex)
+		got, ok := finder.FindRelated(ctx, tc.hwId)
+		if !ok {
+			t.Errorf("TestFindRelated %s: not found", tc.name)
+			continue
+		}
+		if l := wordList(got.Compounds); !equalStrings(l, tc.expectCompounds) {
+			t.Errorf("TestFindRelated %s: expected compounds %v, got %v", tc.name,
+				tc.expectCompounds, l)
+		}
+		if l := wordList(got.SubWords); !equalStrings(l, tc.expectSubWords) {
+			t.Errorf("TestFindRelated %s: expected sub-words %v, got %v", tc.name,
+				tc.expectSubWords, l)
+		}
+		if l := senseList(got.SameConcept); !equalStrings(l, tc.expectSameConcept) {
+			t.Errorf("TestFindRelated %s: expected same concept %v, got %v", tc.name,
+				tc.expectSameConcept, l)
+		}
+		if l := senseList(got.SameDomain); !equalStrings(l, tc.expectSameDomain) {
+			t.Errorf("TestFindRelated %s: expected same domain %v, got %v", tc.name,
+				tc.expectSameDomain, l)
+		}
+	}
+	if _, ok := NewRelatedFinder(dict, substrIndex).FindRelated(ctx, 99); ok {
+		t.Error("TestFindRelated: expected headword 99 not to be found")
+	}
+}
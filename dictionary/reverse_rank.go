@@ -0,0 +1,409 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Ranking of reverse lookup results from English to Chinese
+
+package dictionary
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+)
+
+// How a word sense matched a reverse lookup query
+const (
+	// The whole query equals a gloss, pinyin, or equivalent in the notes
+	MatchExact = "exact"
+
+	// The query words occur together and in order in a gloss
+	MatchPhrase = "phrase"
+
+	// All the query words occur in a gloss, but not together
+	MatchAll = "all"
+
+	// Only some of the query words occur in a gloss
+	MatchPartial = "partial"
+)
+
+// Weights for ranking, the relative order of match types matters more than
+// the exact values
+const (
+	exactWeight      = 1.0
+	stemExactWeight  = 0.9
+	phraseWeight     = 0.6
+	allWordsWeight   = 0.4
+	partialWeight    = 0.2
+	coverageWeight   = 0.2
+	positionPenalty  = 0.02
+	glossOrderFactor = 0.1
+	domainBoost      = 1.2
+	freqWeight       = 0.1
+)
+
+// Words ignored in queries and glosses when ranking
+var rankStopWords = map[string]bool{
+	"a":   true,
+	"an":  true,
+	"the": true,
+	"to":  true,
+}
+
+// Irregular English forms and their lemmas
+var irregularLemmas = map[string]string{
+	"ran":      "run",
+	"went":     "go",
+	"gone":     "go",
+	"was":      "be",
+	"were":     "be",
+	"is":       "be",
+	"are":      "be",
+	"been":     "be",
+	"men":      "man",
+	"women":    "woman",
+	"children": "child",
+	"feet":     "foot",
+	"teeth":    "tooth",
+	"mice":     "mouse",
+	"geese":    "goose",
+	"people":   "person",
+	"ate":      "eat",
+	"eaten":    "eat",
+	"saw":      "see",
+	"seen":     "see",
+	"gave":     "give",
+	"given":    "give",
+	"taught":   "teach",
+	"thought":  "think",
+	"spoke":    "speak",
+	"spoken":   "speak",
+	"wrote":    "write",
+	"written":  "write",
+}
+
+// RankConfig gives optional data for ranking reverse lookup results
+type RankConfig struct {
+	// Word frequencies keyed by simplified Chinese, more frequent words are
+	// ranked higher when the match is otherwise similar
+	WordFreq map[string]int
+
+	// Domains or subdomains preferred, English or Chinese, eg Buddhism for a
+	// site with a Buddhist corpus
+	Domains []string
+}
+
+// RankedSense is a word sense found by reverse lookup with its relevance
+type RankedSense struct {
+	dicttypes.WordSense
+
+	// Higher is more relevant
+	Score float64
+
+	// One of MatchExact, MatchPhrase, MatchAll, or MatchPartial
+	Match string
+}
+
+// gloss is one of the semicolon separated English equivalents of a sense
+type gloss struct {
+	sense int    // Index in reverseIndexMem.senses
+	order int    // Position of the gloss in the English of the sense
+	text  string // Lower case
+	stems []string
+}
+
+// NewRankedReverseIndex creates a RankedReverseIndex that ranks results with
+// the given word frequencies and domain preferences
+func NewRankedReverseIndex(dict *Dictionary, nExtractor *NotesExtractor, rc RankConfig) RankedReverseIndex {
+	r := newReverseIndexMem(dict, nExtractor)
+	r.freq = rc.WordFreq
+	for _, f := range rc.WordFreq {
+		if f > r.maxFreq {
+			r.maxFreq = f
+		}
+	}
+	r.domains = make(map[string]bool)
+	for _, d := range rc.Domains {
+		if d = strings.TrimSpace(d); len(d) > 0 {
+			r.domains[strings.ToLower(d)] = true
+		}
+	}
+	for _, v := range dict.HeadwordIds {
+		for _, s := range v.Senses {
+			if s.Traditional == "\\N" {
+				s.Traditional = ""
+			}
+			i := len(r.senses)
+			r.senses = append(r.senses, s)
+			for order, g := range splitEnglish(s.English) {
+				text := strings.ToLower(g)
+				stems := englishStems(text)
+				if len(stems) == 0 {
+					continue
+				}
+				gi := len(r.glosses)
+				r.glosses = append(r.glosses, gloss{i, order, text, stems})
+				seen := make(map[string]bool)
+				for _, stem := range stems {
+					if !seen[stem] {
+						seen[stem] = true
+						r.stemIndex[stem] = append(r.stemIndex[stem], gi)
+					}
+				}
+			}
+		}
+	}
+	return r
+}
+
+// FindRanked finds word senses matching English, pinyin, or equivalents in
+// the notes, best first. All matching senses are kept, including several
+// senses of the same headword, each scored by its best matching gloss. English
+// queries are matched by word stems, so that a search for running water also
+// finds run water. A limit of zero gives all results.
+func (r reverseIndexMem) FindRanked(ctx context.Context, query string, limit int) ([]RankedSense, error) {
+	q := strings.ToLower(strings.TrimSpace(query))
+	type senseKey struct{ hwId, id int }
+	best := make(map[senseKey]RankedSense)
+	consider := func(ws dicttypes.WordSense, score float64, match string) {
+		score *= r.domainFactor(ws)
+		score += r.freqScore(ws.Simplified)
+		key := senseKey{ws.HeadwordId, ws.Id}
+		if prev, ok := best[key]; !ok || score > prev.Score {
+			best[key] = RankedSense{ws, score, match}
+		}
+	}
+	qStems := englishStems(q)
+	if len(qStems) > 0 {
+		qText := stripStopWords(q)
+		matched := make(map[int]int) // gloss index to number of query stems found
+		for _, stem := range uniqueStrings(qStems) {
+			for _, gi := range r.stemIndex[stem] {
+				matched[gi]++
+			}
+		}
+		nUnique := len(uniqueStrings(qStems))
+		for gi, n := range matched {
+			g := r.glosses[gi]
+			score, match := scoreGloss(g, qText, qStems, n, nUnique)
+			score /= 1.0 + glossOrderFactor*float64(g.order)
+			consider(r.senses[g.sense], score, match)
+		}
+	}
+	// Pinyin and equivalents in the notes, English glosses are scored above
+	for _, ws := range r.exactMatches(q) {
+		if _, ok := best[senseKey{ws.HeadwordId, ws.Id}]; !ok {
+			consider(ws, exactWeight, MatchExact)
+		}
+	}
+	results := make([]RankedSense, 0, len(best))
+	for _, rs := range best {
+		results = append(results, rs)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		fi, fj := r.freq[results[i].Simplified], r.freq[results[j].Simplified]
+		if fi != fj {
+			return fi > fj
+		}
+		if results[i].HeadwordId != results[j].HeadwordId {
+			return results[i].HeadwordId < results[j].HeadwordId
+		}
+		return results[i].Id < results[j].Id
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// scoreGloss scores a gloss containing n of the nUnique distinct query stems
+func scoreGloss(g gloss, qText string, qStems []string, n, nUnique int) (float64, string) {
+	if g.text == qText {
+		return exactWeight, MatchExact
+	}
+	if equalStrings(g.stems, qStems) {
+		return stemExactWeight, MatchExact
+	}
+	coverage := float64(len(qStems)) / float64(len(g.stems))
+	if pos := indexOfSeq(g.stems, qStems); pos >= 0 {
+		score := phraseWeight + coverageWeight*coverage - positionPenalty*float64(pos)
+		return score, MatchPhrase
+	}
+	if n == nUnique {
+		return allWordsWeight + coverageWeight*coverage, MatchAll
+	}
+	fraction := float64(n) / float64(nUnique)
+	return partialWeight * fraction * (1.0 + coverage), MatchPartial
+}
+
+// domainFactor boosts senses in the preferred domains
+func (r reverseIndexMem) domainFactor(ws dicttypes.WordSense) float64 {
+	if len(r.domains) == 0 {
+		return 1.0
+	}
+	if r.domains[strings.ToLower(ws.Domain)] || r.domains[ws.DomainCN] ||
+		r.domains[strings.ToLower(ws.Subdomain)] || r.domains[ws.SubdomainCN] {
+		return domainBoost
+	}
+	return 1.0
+}
+
+// freqScore gives a small score for frequent words, on a log scale relative
+// to the most frequent word
+func (r reverseIndexMem) freqScore(simplified string) float64 {
+	f, ok := r.freq[simplified]
+	if !ok || f <= 0 || r.maxFreq <= 0 {
+		return 0.0
+	}
+	return freqWeight * math.Log1p(float64(f)) / math.Log1p(float64(r.maxFreq))
+}
+
+// englishStems splits English text into words, drops stop words, and stems
+// the rest. If there are only stop words then they are kept.
+func englishStems(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	stems := []string{}
+	for _, w := range words {
+		if !rankStopWords[w] {
+			stems = append(stems, stemEnglish(w))
+		}
+	}
+	if len(stems) == 0 {
+		for _, w := range words {
+			stems = append(stems, stemEnglish(w))
+		}
+	}
+	return stems
+}
+
+// stemEnglish reduces an English word to a stem with light suffix stripping,
+// enough to match plurals and verb forms, eg running, runs, and ran to run. The
+// stems are not always words, eg make and making both give mak.
+func stemEnglish(w string) string {
+	if lemma, ok := irregularLemmas[w]; ok {
+		return lemma
+	}
+	if len(w) <= 3 {
+		return w
+	}
+	switch {
+	case strings.HasSuffix(w, "ies") && len(w) > 4:
+		w = w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "sses"):
+		w = w[:len(w)-2]
+	case strings.HasSuffix(w, "ss"), strings.HasSuffix(w, "us"),
+		strings.HasSuffix(w, "is"):
+	case strings.HasSuffix(w, "s"):
+		w = w[:len(w)-1]
+	}
+	for _, suffix := range []string{"ing", "ed"} {
+		stem := strings.TrimSuffix(w, suffix)
+		if stem == w || len(stem) < 3 || !strings.ContainsAny(stem, "aeiouy") ||
+			strings.HasSuffix(w, "eed") {
+			continue
+		}
+		w = undouble(stem)
+		break
+	}
+	if len(w) > 3 && strings.HasSuffix(w, "e") {
+		w = w[:len(w)-1]
+	}
+	return w
+}
+
+// undouble removes a doubled final consonant, eg runn to run, except l, s, and
+// z, which are often doubled in the base form, eg fall
+func undouble(w string) string {
+	n := len(w)
+	if n < 2 || w[n-1] != w[n-2] {
+		return w
+	}
+	if strings.ContainsRune("aeioulsz", rune(w[n-1])) {
+		return w
+	}
+	return w[:n-1]
+}
+
+// indexOfSeq finds the position of the sequence seq in s, -1 if not found
+func indexOfSeq(s, seq []string) int {
+	for i := 0; i+len(seq) <= len(s); i++ {
+		if equalStrings(s[i:i+len(seq)], seq) {
+			return i
+		}
+	}
+	return -1
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func uniqueStrings(s []string) []string {
+	seen := make(map[string]bool)
+	u := []string{}
+	for _, x := range s {
+		if !seen[x] {
+			seen[x] = true
+			u = append(u, x)
+		}
+	}
+	return u
+}
+
+// LoadWordFreq loads word frequencies from tab separated lines with a Chinese
+// word and its count in a corpus. Further columns and lines starting with #
+// are ignored.
+func LoadWordFreq(r io.Reader) (map[string]int, error) {
+	freq := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("dictionary.LoadWordFreq, line %d: expected a word and count", line)
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("dictionary.LoadWordFreq, line %d: bad count %q", line, fields[1])
+		}
+		freq[fields[0]] += count
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("dictionary.LoadWordFreq, could not read: %v", err)
+	}
+	return freq, nil
+}
@@ -0,0 +1,224 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dictionary
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func mockRankDict() *Dictionary {
+	huoshui := diffSense(5, 5, "活水", "running water")
+	huoshui.Domain = "Buddhism"
+	return diffTestDict(
+		diffSense(1, 1, "自来水", "running water; tap water"),
+		diffSense(2, 2, "流水", "flowing water; running water"),
+		diffSense(3, 3, "水", "water"),
+		diffSense(4, 4, "跑", "to run"),
+		huoshui,
+		diffSense(6, 6, "供水", "water supply"),
+		diffSense(7, 7, "奔跑", "to run quickly"),
+		diffSense(8, 8, "急流", "rapid running water"),
+	)
+}
+
+// TestFindRanked tests ranking of reverse lookup results
+func TestFindRanked(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  RankConfig
+		query   string
+		limit   int
+		want    []string
+		wantTop string
+	}{
+		{
+			name:  "Empty",
+			query: "",
+			want:  []string{},
+		},
+		{
+			name:    "Exact glosses first, then later glosses, then partial",
+			query:   "running water",
+			want:    []string{"自来水", "活水", "流水"},
+			wantTop: MatchExact,
+		},
+		{
+			name:    "Domain preference",
+			config:  RankConfig{Domains: []string{"buddhism"}},
+			query:   "Running Water",
+			want:    []string{"活水", "自来水", "流水"},
+			wantTop: MatchExact,
+		},
+		{
+			name:    "Word frequency",
+			config:  RankConfig{WordFreq: map[string]int{"活水": 10, "自来水": 1000}},
+			query:   "running water",
+			want:    []string{"自来水", "活水"},
+			wantTop: MatchExact,
+		},
+		{
+			name:    "Stemmed",
+			query:   "runs",
+			want:    []string{"跑"},
+			wantTop: MatchExact,
+		},
+		{
+			name:    "Irregular",
+			query:   "ran",
+			want:    []string{"跑"},
+			wantTop: MatchExact,
+		},
+		{
+			name:    "Phrase in gloss",
+			query:   "rapid running",
+			want:    []string{"急流"},
+			wantTop: MatchPhrase,
+		},
+		{
+			name:    "Pinyin",
+			query:   "pinyin",
+			limit:   2,
+			want:    []string{"自来水", "流水"},
+			wantTop: MatchExact,
+		},
+		{
+			name:    "Limit",
+			query:   "water",
+			limit:   1,
+			want:    []string{"水"},
+			wantTop: MatchExact,
+		},
+	}
+	ctx := context.Background()
+	for _, tc := range testCases {
+		revIndex := NewRankedReverseIndex(mockRankDict(), nil, tc.config)
+		limit := tc.limit
+		if limit == 0 {
+			limit = len(tc.want)
+		}
+		results, err := revIndex.FindRanked(ctx, tc.query, limit)
+		if err != nil {
+			t.Fatalf("TestFindRanked %s: unexpected error: %v", tc.name, err)
+		}
+		got := []string{}
+		for _, r := range results {
+			got = append(got, r.Simplified)
+		}
+		if strings.Join(got, " ") != strings.Join(tc.want, " ") {
+			t.Errorf("TestFindRanked %s: got %v, want %v", tc.name, got, tc.want)
+		}
+		if len(results) > 0 && results[0].Match != tc.wantTop {
+			t.Errorf("TestFindRanked %s: got match %s, want %s", tc.name,
+				results[0].Match, tc.wantTop)
+		}
+	}
+}
+
+// TestFindRankedAll tests that partial matches are included without a limit
+func TestFindRankedAll(t *testing.T) {
+	revIndex := NewRankedReverseIndex(mockRankDict(), nil, RankConfig{})
+	results, err := revIndex.FindRanked(context.Background(), "running water", 0)
+	if err != nil {
+		t.Fatalf("TestFindRankedAll: unexpected error: %v", err)
+	}
+	if len(results) != 8 {
+		t.Fatalf("TestFindRankedAll: expected all 8 senses, got %d", len(results))
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Score > results[i-1].Score {
+			t.Errorf("TestFindRankedAll: not in order at %d: %v", i, results)
+		}
+	}
+	if last := results[len(results)-1]; last.Match != MatchPartial {
+		t.Errorf("TestFindRankedAll: expected partial match last, got %v", last)
+	}
+	// Find is unchanged, exact keys only
+	senses, err := revIndex.Find(context.Background(), "running water")
+	if err != nil {
+		t.Fatalf("TestFindRankedAll: unexpected error from Find: %v", err)
+	}
+	if len(senses) != 3 {
+		t.Errorf("TestFindRankedAll: expected 3 senses from Find, got %v", senses)
+	}
+}
+
+// TestFindRankedSenses tests that all matching senses of a headword are kept
+func TestFindRankedSenses(t *testing.T) {
+	dict := diffTestDict(
+		diffSense(1, 1, "水", "water"),
+		diffSense(2, 1, "水", "river; water"),
+		diffSense(3, 1, "水", "a surname"),
+		diffSense(4, 2, "供水", "water supply"),
+	)
+	revIndex := NewRankedReverseIndex(dict, nil, RankConfig{})
+	results, err := revIndex.FindRanked(context.Background(), "water", 0)
+	if err != nil {
+		t.Fatalf("TestFindRankedSenses: unexpected error: %v", err)
+	}
+	got := []int{}
+	for _, r := range results {
+		got = append(got, r.Id)
+	}
+	want := []int{1, 2, 4}
+	if len(got) != len(want) {
+		t.Fatalf("TestFindRankedSenses: got sense ids %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("TestFindRankedSenses: got sense ids %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+// TestStemEnglish tests reduction of English words to stems
+func TestStemEnglish(t *testing.T) {
+	testCases := []struct {
+		words []string
+		want  string
+	}{
+		{[]string{"run", "runs", "running", "ran"}, "run"},
+		{[]string{"water", "waters", "watered", "watering"}, "water"},
+		{[]string{"city", "cities"}, "city"},
+		{[]string{"fall", "falls", "falling"}, "fall"},
+		{[]string{"kiss", "kisses", "kissing"}, "kiss"},
+		{[]string{"make", "makes", "making"}, "mak"},
+		{[]string{"child", "children"}, "child"},
+		{[]string{"bus"}, "bus"},
+		{[]string{"red"}, "red"},
+	}
+	for _, tc := range testCases {
+		for _, w := range tc.words {
+			if got := stemEnglish(w); got != tc.want {
+				t.Errorf("TestStemEnglish %s: got %s, want %s", w, got, tc.want)
+			}
+		}
+	}
+}
+
+// TestLoadWordFreq tests loading of word frequencies
+func TestLoadWordFreq(t *testing.T) {
+	r := strings.NewReader("# word\tcount\n水\t120\n自来水\t15\textra\n\n水\t5\n")
+	freq, err := LoadWordFreq(r)
+	if err != nil {
+		t.Fatalf("TestLoadWordFreq: unexpected error: %v", err)
+	}
+	if len(freq) != 2 || freq["水"] != 125 || freq["自来水"] != 15 {
+		t.Errorf("TestLoadWordFreq: unexpected frequencies %v", freq)
+	}
+	if _, err := LoadWordFreq(strings.NewReader("水\tmany\n")); err == nil {
+		t.Error("TestLoadWordFreq: expected error for a bad count")
+	}
+}
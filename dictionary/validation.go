@@ -4,7 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"log"
+	"sort"
 	"strings"
 
 	"github.com/alexamies/chinesenotes-go/dicttypes"
@@ -70,15 +70,150 @@ func (val validator) Validate(pos, domain string) error {
 	return nil
 }
 
-// ValidateDict check the Chinese-English for errors
+// Names of the columns in the dictionary TSV files, used in reporting
+var luColumns = []string{
+	"id",
+	"simplified",
+	"traditional",
+	"pinyin",
+	"english",
+	"grammar",
+	"concept_cn",
+	"concept_en",
+	"domain_cn",
+	"domain_en",
+	"subdomain_cn",
+	"subdomain_en",
+	"image",
+	"mp3",
+	"notes",
+	"headword",
+	"jyutping",
+}
+
+// Issue is a problem found with a dictionary entry
+type Issue struct {
+	// File name or URL that the entry was read from, empty if not known
+	Source string
+
+	// Line number in the source, starting from 1, or zero if not known
+	Row int
+
+	// Word sense id, or zero if it could not be read
+	Id int
+
+	// Name of the column, empty if the problem is with the whole row
+	Column string
+
+	// The value found in the column
+	Value string
+
+	// Description of the problem
+	Problem string
+}
+
+func (i Issue) String() string {
+	loc := fmt.Sprintf("id %d", i.Id)
+	if i.Row > 0 {
+		loc = fmt.Sprintf("%s:%d", i.Source, i.Row)
+	}
+	if len(i.Column) == 0 {
+		return fmt.Sprintf("%s: %s", loc, i.Problem)
+	}
+	return fmt.Sprintf("%s: %s %q: %s", loc, i.Column, i.Value, i.Problem)
+}
+
+// ValidationReport collects problems found when loading or checking the
+// dictionary.
+// Use NewValidationReport to create a ValidationReport.
+type ValidationReport struct {
+	// In strict mode any problem found is an error, in lenient mode invalid
+	// rows are skipped and other problems are only reported
+	Strict bool
+
+	// Number of rows read, excluding comments
+	Rows int
+
+	// Problems found in the order encountered
+	Issues []Issue
+}
+
+// NewValidationReport creates an empty report in strict or lenient mode
+func NewValidationReport(strict bool) *ValidationReport {
+	return &ValidationReport{
+		Strict: strict,
+		Issues: []Issue{},
+	}
+}
+
+// add records a problem with the given column, -1 for the whole row
+func (r *ValidationReport) add(source string, row, id, col int, value, problem string) {
+	column := ""
+	if col >= 0 && col < len(luColumns) {
+		column = luColumns[col]
+	}
+	r.Issues = append(r.Issues, Issue{
+		Source:  source,
+		Row:     row,
+		Id:      id,
+		Column:  column,
+		Value:   value,
+		Problem: problem,
+	})
+}
+
+// Err gives an error summarizing the problems found in strict mode, nil if
+// there were none or the report is lenient
+func (r *ValidationReport) Err() error {
+	if !r.Strict || len(r.Issues) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d problems found in dictionary, first: %v", len(r.Issues),
+		r.Issues[0])
+}
+
+// Write writes the problems found, one per line, followed by a summary
+func (r *ValidationReport) Write(w io.Writer) error {
+	for _, issue := range r.Issues {
+		if _, err := fmt.Fprintln(w, issue); err != nil {
+			return fmt.Errorf("ValidationReport.Write, could not write: %v", err)
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d rows read, %d problems found\n", r.Rows, len(r.Issues))
+	if err != nil {
+		return fmt.Errorf("ValidationReport.Write, could not write: %v", err)
+	}
+	return nil
+}
+
+// ValidateDict check the Chinese-English for errors, returning an error if
+// any word sense has an invalid part of speech or domain
 func ValidateDict(wdict map[string]*dicttypes.Word, validator Validator) error {
+	report := NewValidationReport(true)
+	CheckDict(wdict, validator, report)
+	return report.Err()
+}
+
+// CheckDict adds all word senses with an invalid part of speech or domain to
+// the report, in order of word sense id
+func CheckDict(wdict map[string]*dicttypes.Word, validator Validator, report *ValidationReport) {
+	senses := []dicttypes.WordSense{}
+	seen := make(map[int]bool)
 	for _, word := range wdict {
 		for _, ws := range word.Senses {
-			if err := validator.Validate(ws.Grammar, ws.Domain); err != nil {
-				log.Printf("ValidateDict, Line: %v", ws)
-				return fmt.Errorf("invalid entry %s: %v", ws.Simplified, err)
+			if seen[ws.Id] {
+				continue
 			}
+			seen[ws.Id] = true
+			senses = append(senses, ws)
+		}
+	}
+	sort.Slice(senses, func(i, j int) bool {
+		return senses[i].Id < senses[j].Id
+	})
+	for _, ws := range senses {
+		if err := validator.Validate(ws.Grammar, ws.Domain); err != nil {
+			report.add("", 0, ws.Id, -1, "", fmt.Sprintf("invalid entry %s: %v", ws.Simplified, err))
 		}
 	}
-	return nil
 }
@@ -3,6 +3,8 @@ package dictionary
 import (
 	"strings"
 	"testing"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
 )
 
 func simpleValidator() (Validator, error) {
@@ -49,3 +51,60 @@ func TestValidate(t *testing.T) {
 		}
 	}
 }
+
+// TestValidateDict tests checking all the senses in a dictionary
+func TestValidateDict(t *testing.T) {
+	validator, err := simpleValidator()
+	if err != nil {
+		t.Fatalf("TestValidateDict: Unexpected error: %v", err)
+	}
+	ws1 := dicttypes.WordSense{
+		Id:         1,
+		Simplified: "画",
+		Grammar:    "noun",
+		Domain:     "Art",
+	}
+	ws2 := dicttypes.WordSense{
+		Id:         2,
+		Simplified: "佛",
+		Grammar:    "noun",
+		Domain:     "Buddhist",
+	}
+	type test struct {
+		name         string
+		wdict        map[string]*dicttypes.Word
+		expectIssues int
+	}
+	tests := []test{
+		{
+			name: "Valid",
+			wdict: map[string]*dicttypes.Word{
+				"画": {Simplified: "画", Senses: []dicttypes.WordSense{ws1}},
+				"畫": {Simplified: "画", Senses: []dicttypes.WordSense{ws1}},
+			},
+			expectIssues: 0,
+		},
+		{
+			name: "Invalid domain",
+			wdict: map[string]*dicttypes.Word{
+				"画": {Simplified: "画", Senses: []dicttypes.WordSense{ws1}},
+				"佛": {Simplified: "佛", Senses: []dicttypes.WordSense{ws2}},
+			},
+			expectIssues: 1,
+		},
+	}
+	for _, tc := range tests {
+		report := NewValidationReport(false)
+		CheckDict(tc.wdict, validator, report)
+		if tc.expectIssues != len(report.Issues) {
+			t.Errorf("%s: expected %d issues, got %v", tc.name, tc.expectIssues, report.Issues)
+		}
+		err := ValidateDict(tc.wdict, validator)
+		if tc.expectIssues == 0 && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+		if tc.expectIssues > 0 && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dicttypes
+
+import (
+	"sort"
+	"unicode"
+)
+
+// CJKClass is the classification of a rune for the purpose of segmenting
+// Chinese text
+type CJKClass int
+
+const (
+	// Not a CJK character, eg Latin letters, digits, and Western punctuation
+	NonCJK CJKClass = iota
+
+	// A Han ideograph, including extensions and compatibility ideographs
+	CJKIdeograph
+
+	// A Kangxi radical or CJK radical supplement character
+	CJKRadical
+
+	// A CJK stroke
+	CJKStroke
+
+	// An ideographic description character, used in sequences like ⿰木目
+	CJKDescription
+
+	// CJK, full width, and vertical form punctuation
+	CJKPunctuation
+
+	// Japanese Hiragana or Katakana
+	CJKKana
+)
+
+var cjkClassNames = map[CJKClass]string{
+	NonCJK:         "NonCJK",
+	CJKIdeograph:   "Ideograph",
+	CJKRadical:     "Radical",
+	CJKStroke:      "Stroke",
+	CJKDescription: "Description",
+	CJKPunctuation: "Punctuation",
+	CJKKana:        "Kana",
+}
+
+func (c CJKClass) String() string {
+	return cjkClassNames[c]
+}
+
+// CJKBlock is a Unicode block containing CJK characters
+type CJKBlock struct {
+	Name        string
+	First, Last rune
+	Class       CJKClass
+}
+
+// CJKBlocks lists the Unicode blocks recognized, in code point order. The
+// class given is the default for the block. A few characters differ, eg
+// 〇 is an ideograph in the CJK Symbols and Punctuation block.
+var CJKBlocks = []CJKBlock{
+	{"CJK Radicals Supplement", 0x2E80, 0x2EFF, CJKRadical},
+	{"Kangxi Radicals", 0x2F00, 0x2FDF, CJKRadical},
+	{"Ideographic Description Characters", 0x2FF0, 0x2FFF, CJKDescription},
+	{"CJK Symbols and Punctuation", 0x3000, 0x303F, CJKPunctuation},
+	{"Hiragana", 0x3040, 0x309F, CJKKana},
+	{"Katakana", 0x30A0, 0x30FF, CJKKana},
+	{"CJK Strokes", 0x31C0, 0x31EF, CJKStroke},
+	{"Katakana Phonetic Extensions", 0x31F0, 0x31FF, CJKKana},
+	{"CJK Unified Ideographs Extension A", 0x3400, 0x4DBF, CJKIdeograph},
+	{"CJK Unified Ideographs", 0x4E00, 0x9FFF, CJKIdeograph},
+	{"CJK Compatibility Ideographs", 0xF900, 0xFAFF, CJKIdeograph},
+	{"Vertical Forms", 0xFE10, 0xFE1F, CJKPunctuation},
+	{"CJK Compatibility Forms", 0xFE30, 0xFE4F, CJKPunctuation},
+	{"Small Form Variants", 0xFE50, 0xFE6F, CJKPunctuation},
+	{"Halfwidth and Fullwidth Forms", 0xFF00, 0xFFEF, CJKPunctuation},
+	{"CJK Unified Ideographs Extension B", 0x20000, 0x2A6DF, CJKIdeograph},
+	{"CJK Unified Ideographs Extension C", 0x2A700, 0x2B73F, CJKIdeograph},
+	{"CJK Unified Ideographs Extension D", 0x2B740, 0x2B81F, CJKIdeograph},
+	{"CJK Unified Ideographs Extension E", 0x2B820, 0x2CEAF, CJKIdeograph},
+	{"CJK Unified Ideographs Extension F", 0x2CEB0, 0x2EBEF, CJKIdeograph},
+	{"CJK Unified Ideographs Extension I", 0x2EBF0, 0x2EE5F, CJKIdeograph},
+	{"CJK Compatibility Ideographs Supplement", 0x2F800, 0x2FA1F, CJKIdeograph},
+	{"CJK Unified Ideographs Extension G", 0x30000, 0x3134F, CJKIdeograph},
+	{"CJK Unified Ideographs Extension H", 0x31350, 0x323AF, CJKIdeograph},
+}
+
+// ClassifyRune gives the CJK classification of a rune
+func ClassifyRune(r rune) CJKClass {
+	i := sort.Search(len(CJKBlocks), func(i int) bool {
+		return CJKBlocks[i].Last >= r
+	})
+	if i == len(CJKBlocks) || CJKBlocks[i].First > r {
+		return NonCJK
+	}
+	block := CJKBlocks[i]
+	switch block.Class {
+	case CJKPunctuation:
+		// Iteration marks, ideographic zero and Hangzhou numerals
+		if unicode.Is(unicode.Han, r) {
+			return CJKIdeograph
+		}
+		if block.First == 0xFF00 {
+			return classifyHalfFullWidth(r)
+		}
+	case CJKKana:
+		// Katakana middle dot and similar
+		if unicode.IsPunct(r) {
+			return CJKPunctuation
+		}
+	case CJKStroke:
+		// The subtraction description character was added to the strokes block
+		if r == 0x31EF {
+			return CJKDescription
+		}
+	}
+	return block.Class
+}
+
+// classifyHalfFullWidth classifies a rune in the Halfwidth and Fullwidth Forms
+// block. Full width Latin letters and digits are not treated as CJK.
+func classifyHalfFullWidth(r rune) CJKClass {
+	if r >= 0xFF66 && r <= 0xFF9F {
+		return CJKKana
+	}
+	if unicode.IsPunct(r) || unicode.IsSymbol(r) {
+		return CJKPunctuation
+	}
+	return NonCJK
+}
+
+// IsCJKRune tests whether the rune belongs in a segment of Chinese text: an
+// ideograph, radical, stroke, ideographic description character, or kana.
+// Punctuation is excluded.
+func IsCJKRune(r rune) bool {
+	c := ClassifyRune(r)
+	return c != NonCJK && c != CJKPunctuation
+}
+
+// IsCJKPunct tests whether the rune is CJK, full width, or vertical form
+// punctuation
+func IsCJKPunct(r rune) bool {
+	return ClassifyRune(r) == CJKPunctuation
+}
+
+// ContainsCJK tests whether the string contains at least one ideograph
+func ContainsCJK(s string) bool {
+	for _, r := range s {
+		if ClassifyRune(r) == CJKIdeograph {
+			return true
+		}
+	}
+	return false
+}
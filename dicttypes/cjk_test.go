@@ -0,0 +1,130 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dicttypes
+
+import (
+	"testing"
+)
+
+// TestCJKBlocks checks that the blocks are ordered and do not overlap, which
+// the binary search in ClassifyRune depends on
+func TestCJKBlocks(t *testing.T) {
+	for i, block := range CJKBlocks {
+		if block.First > block.Last {
+			t.Errorf("%s: first %X after last %X", block.Name, block.First, block.Last)
+		}
+		if i > 0 && CJKBlocks[i-1].Last >= block.First {
+			t.Errorf("%s: overlaps or out of order with %s", block.Name, CJKBlocks[i-1].Name)
+		}
+	}
+}
+
+// TestClassifyRune tests a character from each Unicode block
+func TestClassifyRune(t *testing.T) {
+	type test struct {
+		name   string
+		input  rune
+		expect CJKClass
+	}
+	tests := []test{
+		{"Latin", 'A', NonCJK},
+		{"Digit", '7', NonCJK},
+		{"ASCII punctuation", ',', NonCJK},
+		{"Radicals Supplement", '⺮', CJKRadical},
+		{"Kangxi Radicals", '⼈', CJKRadical},
+		{"Ideographic Description", '⿰', CJKDescription},
+		{"Ideographic Description 15.1", 0x2FFC, CJKDescription},
+		{"Ideographic full stop", '。', CJKPunctuation},
+		{"Corner bracket", '「', CJKPunctuation},
+		{"Iteration mark", '々', CJKIdeograph},
+		{"Ideographic zero", '〇', CJKIdeograph},
+		{"Hiragana", 'あ', CJKKana},
+		{"Katakana", 'カ', CJKKana},
+		{"Katakana middle dot", '・', CJKPunctuation},
+		{"Strokes", '㇀', CJKStroke},
+		{"Subtraction description character", 0x31EF, CJKDescription},
+		{"Katakana Phonetic Extensions", 'ㇰ', CJKKana},
+		{"Extension A", '㐀', CJKIdeograph},
+		{"Unified Ideographs", '中', CJKIdeograph},
+		{"Last Unified Ideograph", 0x9FFF, CJKIdeograph},
+		{"Compatibility Ideographs", '豈', CJKIdeograph},
+		{"Vertical Forms", '︐', CJKPunctuation},
+		{"Compatibility Forms", '﹁', CJKPunctuation},
+		{"Small Form Variants", '﹐', CJKPunctuation},
+		{"Full width comma", '，', CJKPunctuation},
+		{"Full width letter", 'Ａ', NonCJK},
+		{"Half width katakana", 'ｶ', CJKKana},
+		{"Extension B", '𠀀', CJKIdeograph},
+		{"Extension C", '𪜀', CJKIdeograph},
+		{"Extension D", '𫝀', CJKIdeograph},
+		{"Extension E", '𫠠', CJKIdeograph},
+		{"Extension F", '𬺰', CJKIdeograph},
+		{"Extension I", 0x2EBF0, CJKIdeograph},
+		{"Compatibility Supplement", '丽', CJKIdeograph},
+		{"Extension G", 0x30000, CJKIdeograph},
+		{"Extension H", 0x31350, CJKIdeograph},
+		{"After Extension H", 0x323B0, NonCJK},
+	}
+	for _, tc := range tests {
+		got := ClassifyRune(tc.input)
+		if got != tc.expect {
+			t.Errorf("%s: ClassifyRune(%U) got %s but expected %s", tc.name, tc.input, got, tc.expect)
+		}
+	}
+}
+
+// TestIsCJKRune tests that description sequences and rare characters are
+// treated as Chinese text but punctuation is not
+func TestIsCJKRune(t *testing.T) {
+	type test struct {
+		name   string
+		input  string
+		expect bool
+	}
+	tests := []test{
+		{"Ideographic description sequence", "⿰木目", true},
+		{"Extension B", "𠮷", true},
+		{"Strokes", "㇀㇁", true},
+		{"Punctuation", "，。「」", false},
+		{"English", "abc", false},
+	}
+	for _, tc := range tests {
+		for _, r := range tc.input {
+			if got := IsCJKRune(r); got != tc.expect {
+				t.Errorf("%s: IsCJKRune(%c) got %t but expected %t", tc.name, r, got, tc.expect)
+			}
+		}
+	}
+}
+
+// TestContainsCJK tests ContainsCJK
+func TestContainsCJK(t *testing.T) {
+	type test struct {
+		name   string
+		input  string
+		expect bool
+	}
+	tests := []test{
+		{"Empty", "", false},
+		{"Chinese", "中国", true},
+		{"Mixed", "X光", true},
+		{"Punctuation only", "。", false},
+		{"Kana only", "あ", false},
+		{"English", "USA", false},
+	}
+	for _, tc := range tests {
+		if got := ContainsCJK(tc.input); got != tc.expect {
+			t.Errorf("%s: ContainsCJK(%s) got %t but expected %t", tc.name, tc.input, got, tc.expect)
+		}
+	}
+}
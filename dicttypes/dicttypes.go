@@ -15,7 +15,8 @@ package dicttypes
 
 import (
 	"strings"
-	"unicode"
+
+	"github.com/alexamies/chinesenotes-go/pinyin"
 )
 
 // A top level word structure that may include multiple word senses
@@ -30,6 +31,8 @@ type WordSense struct {
 	Id, HeadwordId int
 	Simplified, Traditional, Pinyin, English, Grammar, Concept, ConceptCN, Domain,
 	DomainCN, Subdomain, SubdomainCN, Image, MP3, Notes string
+	// Cantonese Jyutping, from the optional 17th column, empty if not known
+	Jyutping string
 }
 
 // May be sorted into descending order with most frequent bigram first
@@ -56,10 +59,12 @@ func CloneWord(w Word) Word {
 }
 
 // IsCJKChar tests whether the symbol is a CJK character, excluding punctuation
-// Only looks at the first charater in the string
+// Only looks at the first charater in the string, see IsCJKRune
 func IsCJKChar(character string) bool {
-	r := []rune(character)
-	return (unicode.Is(unicode.Han, r[0]) || unicode.Is(unicode.Hiragana, r[0]) || unicode.Is(unicode.Katakana, r[0])) && !unicode.IsPunct(r[0])
+	for _, r := range character {
+		return IsCJKRune(r)
+	}
+	return false
 }
 
 // Tests whether the word is a function word
@@ -149,59 +154,12 @@ func (senses WordSenses) Less(i, j int) bool {
 	return noTones1 < noTones2
 }
 
-// Removes the tone diacritics from a Pinyin string
-func NormalizePinyin(pinyin string) string {
-	runes := []rune{}
-	for _, r := range pinyin {
-		n, ok := normal[r]
-		if ok {
-			runes = append(runes, n)
-		} else {
-			runes = append(runes, r)
-		}
+// Removes the tone diacritics from a Pinyin string, giving lower case words
+// separated by single spaces, each normalized as by pinyin.Normalize
+func NormalizePinyin(p string) string {
+	words := strings.Fields(p)
+	for i, w := range words {
+		words[i] = pinyin.Normalize(w)
 	}
-	return strings.ToLower(string(runes))
-}
-
-var normal = map[rune]rune{
-	'ā': 'a',
-	'á': 'a',
-	'ǎ': 'a',
-	'à': 'a',
-	'ē': 'e',
-	'é': 'e',
-	'ě': 'e',
-	'è': 'e',
-	'ī': 'i',
-	'í': 'i',
-	'ǐ': 'i',
-	'ì': 'i',
-	'ō': 'o',
-	'ó': 'o',
-	'ǒ': 'o',
-	'ò': 'o',
-	'ū': 'u',
-	'ú': 'u',
-	'ǔ': 'u',
-	'ù': 'u',
-	'Ā': 'a',
-	'Á': 'a',
-	'Ǎ': 'a',
-	'À': 'a',
-	'Ē': 'e',
-	'É': 'e',
-	'Ě': 'e',
-	'È': 'e',
-	'Ī': 'i',
-	'Í': 'i',
-	'Ǐ': 'i',
-	'Ì': 'i',
-	'Ō': 'o',
-	'Ó': 'o',
-	'Ǒ': 'o',
-	'Ò': 'o',
-	'Ū': 'u',
-	'Ú': 'u',
-	'Ǔ': 'u',
-	'Ù': 'u',
+	return strings.Join(words, " ")
 }
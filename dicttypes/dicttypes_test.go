@@ -151,6 +151,16 @@ func TestNormalizePinyin(t *testing.T) {
 			input:  "Ēmítuó",
 			expect: "emituo",
 		},
+		{
+			name:   "u umlaut",
+			input:  "lǜsè",
+			expect: "lvse",
+		},
+		{
+			name:   "apostrophe",
+			input:  "Xī'ān",
+			expect: "xian",
+		},
 	}
 	for _, tc := range tests {
 		noTones := NormalizePinyin(tc.input)
@@ -21,6 +21,7 @@ import (
 	"strings"
 
 	"github.com/alexamies/chinesenotes-go/dictionary"
+	"github.com/alexamies/chinesenotes-go/dicttypes"
 	"github.com/alexamies/chinesenotes-go/fulltext"
 )
 
@@ -281,6 +282,28 @@ func (df docFinder) findDocumentsInCol(ctx context.Context, query string, terms
 	return relevantDocs, nil
 }
 
+// reverseLookup finds all the word senses matching English or pinyin, best
+// first if the index can rank them
+func reverseLookup(ctx context.Context, reverseIndex dictionary.ReverseIndex, query string) ([]dicttypes.WordSense, error) {
+	rankedIndex, ok := reverseIndex.(dictionary.RankedReverseIndex)
+	if !ok {
+		senses, err := reverseIndex.Find(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("reverseLookup, error for %q: %v", query, err)
+		}
+		return senses, nil
+	}
+	ranked, err := rankedIndex.FindRanked(ctx, query, 0)
+	if err != nil {
+		return nil, fmt.Errorf("reverseLookup, error for %q: %v", query, err)
+	}
+	senses := make([]dicttypes.WordSense, len(ranked))
+	for i, rs := range ranked {
+		senses[i] = rs.WordSense
+	}
+	return senses, nil
+}
+
 // FindDocuments returns a QueryResults object containing matching collections, documents,
 // and dictionary words. For dictionary lookup, a text segment will
 // contains the QueryText searched for and possibly a matching
@@ -296,7 +319,7 @@ func (df docFinder) FindDocuments(ctx context.Context, reverseIndex dictionary.R
 	log.Printf("FindDocuments, query: %q with %d terms, advanced: %t", query, len(terms), advanced)
 	if (len(terms) == 1) && (terms[0].DictEntry.HeadwordId == 0) {
 		q := strings.ToLower(query)
-		senses, err := reverseIndex.Find(ctx, q)
+		senses, err := reverseLookup(ctx, reverseIndex, q)
 		if err != nil {
 			return nil, err
 		}
@@ -348,7 +371,7 @@ func (df docFinder) FindDocumentsInCol(ctx context.Context, reverseIndex diction
 	terms := parser.ParseQuery(query)
 	if (len(terms) == 1) && (terms[0].DictEntry.HeadwordId == 0) {
 		log.Printf("FindDocumentsInCol, Query with no Chinese, look for English and Pinyin matches query: %s", query)
-		senses, err := reverseIndex.Find(ctx, terms[0].QueryText)
+		senses, err := reverseLookup(ctx, reverseIndex, terms[0].QueryText)
 		if err != nil {
 			return nil, err
 		} else {
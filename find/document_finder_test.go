@@ -21,19 +21,60 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/alexamies/chinesenotes-go/dictionary"
 	"github.com/alexamies/chinesenotes-go/dicttypes"
 	"github.com/alexamies/chinesenotes-go/fulltext"
 )
 
 type mockReverseIndex struct {
+	senses map[string][]dicttypes.WordSense
 }
 
 func (m mockReverseIndex) Find(ctx context.Context, query string) ([]dicttypes.WordSense, error) {
 	results := []dicttypes.WordSense{}
+	results = append(results, m.senses[query]...)
 	log.Printf("Find.FindWordsByEnglish: query: %s, results: %v", query, results)
 	return results, nil
 }
 
+type mockRankedReverseIndex struct {
+	mockReverseIndex
+	ranked map[string][]dictionary.RankedSense
+}
+
+func (m mockRankedReverseIndex) FindRanked(ctx context.Context, query string, limit int) ([]dictionary.RankedSense, error) {
+	results := m.ranked[query]
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func mockReverseSenses() (map[string][]dicttypes.WordSense, map[string][]dictionary.RankedSense) {
+	shui := dicttypes.WordSense{
+		Id:         1,
+		HeadwordId: 1,
+		Simplified: "水",
+		English:    "water",
+	}
+	gongshui := dicttypes.WordSense{
+		Id:         2,
+		HeadwordId: 2,
+		Simplified: "供水",
+		English:    "water supply",
+	}
+	senses := map[string][]dicttypes.WordSense{
+		"water": {shui},
+	}
+	ranked := map[string][]dictionary.RankedSense{
+		"water supply": {
+			{WordSense: gongshui, Score: 1.0, Match: dictionary.MatchExact},
+			{WordSense: shui, Score: 0.3, Match: dictionary.MatchPartial},
+		},
+	}
+	return senses, ranked
+}
+
 type mockDocFinder struct {
 	scores []BM25Score
 }
@@ -352,7 +393,9 @@ func TestFindDocuments(t *testing.T) {
 	}
 	oneTitleFinder := newMockTitleFinder(collections, documents, colMap, oneDocMap)
 	ctx := context.Background()
-	reverseIndex := mockReverseIndex{}
+	senses, ranked := mockReverseSenses()
+	reverseIndex := mockReverseIndex{senses}
+	rankedIndex := mockRankedReverseIndex{reverseIndex, ranked}
 	emptyDict := map[string]*dicttypes.Word{}
 	smallDict := mockSmallDict()
 
@@ -361,12 +404,14 @@ func TestFindDocuments(t *testing.T) {
 		name           string
 		query          string
 		dict           map[string]*dicttypes.Word
+		reverseIndex   dictionary.ReverseIndex
 		fullText       bool
 		expectError    bool
 		tdDocFinder    TermFreqDocFinder
 		titleFinder    TitleFinder
 		expectNoTerms  int
 		expectNoSenses int
+		expectFirst    string
 		expectNDoc     int
 	}
 	tests := []test{
@@ -406,6 +451,33 @@ func TestFindDocuments(t *testing.T) {
 			expectNoSenses: 0,
 			expectNDoc:     0,
 		},
+		{
+			name:           "Reverse lookup",
+			query:          "Water",
+			dict:           emptyDict,
+			fullText:       false,
+			tdDocFinder:    zeroDocFinder,
+			titleFinder:    zeroTitleFinder,
+			expectError:    false,
+			expectNoTerms:  1,
+			expectNoSenses: 1,
+			expectFirst:    "水",
+			expectNDoc:     0,
+		},
+		{
+			name:           "Ranked reverse lookup",
+			query:          "water supply",
+			dict:           emptyDict,
+			reverseIndex:   rankedIndex,
+			fullText:       false,
+			tdDocFinder:    zeroDocFinder,
+			titleFinder:    zeroTitleFinder,
+			expectError:    false,
+			expectNoTerms:  1,
+			expectNoSenses: 2,
+			expectFirst:    "供水",
+			expectNDoc:     0,
+		},
 		{
 			name:           "One term query",
 			query:          "前",
@@ -438,7 +510,11 @@ func TestFindDocuments(t *testing.T) {
 			titleFinder: tc.titleFinder,
 		}
 		parser := NewQueryParser(tc.dict)
-		qr, err := dFinder.FindDocuments(ctx, reverseIndex, parser, tc.query, tc.fullText)
+		revIndex := tc.reverseIndex
+		if revIndex == nil {
+			revIndex = reverseIndex
+		}
+		qr, err := dFinder.FindDocuments(ctx, revIndex, parser, tc.query, tc.fullText)
 		gotError := (err != nil)
 		if tc.expectError != gotError {
 			t.Errorf("TestFindDocuments.%s: expectError: %t vs got %t",
@@ -461,6 +537,9 @@ func TestFindDocuments(t *testing.T) {
 			if gotNoSenses != tc.expectNoSenses {
 				t.Errorf("TestFindDocuments.%s: gotNoSenses %d, want: %d, details: %v", tc.name, gotNoSenses, tc.expectNoSenses, senses)
 			}
+			if gotNoSenses > 0 && senses[0].Simplified != tc.expectFirst {
+				t.Errorf("TestFindDocuments.%s: got first sense %s, want: %s", tc.name, senses[0].Simplified, tc.expectFirst)
+			}
 		}
 		if qr.NumDocuments != tc.expectNDoc {
 			t.Errorf("TestFindDocuments.%s: qr.NumDocuments %d, want: %d, details: %v", tc.name, qr.NumDocuments, tc.expectNDoc, qr.Documents)
@@ -14,8 +14,6 @@
 package find
 
 import (
-	"unicode"
-
 	"github.com/alexamies/chinesenotes-go/dicttypes"
 	"github.com/alexamies/chinesenotes-go/tokenizer"
 )
@@ -58,7 +56,7 @@ func (parser DictQueryParser) get_chunks(text string) []TextSegment {
 	cjk := ""
 	noncjk := ""
 	for _, character := range text {
-		if is_cjk(character) {
+		if dicttypes.IsCJKRune(character) {
 			if noncjk != "" {
 				seg := TextSegment{}
 				seg.QueryText = noncjk
@@ -87,12 +85,6 @@ func (parser DictQueryParser) get_chunks(text string) []TextSegment {
 	return chunks
 }
 
-// Tests whether the symbol is a CJK character, excluding punctuation
-// Only looks at the first charater in the string
-func is_cjk(r rune) bool {
-	return unicode.Is(unicode.Han, r) && !unicode.IsPunct(r)
-}
-
 // Segments Chinese text based on dictionary entries
 func (parser DictQueryParser) parse_chinese(text string) []TextSegment {
 	tokens := parser.Tokenizer.Tokenize(text)
@@ -0,0 +1,157 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fulltext
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+	"github.com/alexamies/chinesenotes-go/tokenizer"
+)
+
+// Concordance is an occurrence of a term in a document with the text on
+// either side, within the same line
+type Concordance struct {
+	PlainTextFile     string
+	Left, Term, Right string
+}
+
+// Collocate is a word found next to a term and the number of times found
+type Collocate struct {
+	Word string
+	Freq int
+}
+
+// GetConcordance finds up to limit occurrences of any of the terms in the
+// documents, in the order given, with contextLen characters either side
+func GetConcordance(keys, terms []string, contextLen, limit int) []Concordance {
+	return getConcordance(getLoader(), keys, terms, contextLen, limit)
+}
+
+func getConcordance(loader FullTextLoader, keys, terms []string, contextLen, limit int) []Concordance {
+	lines := []Concordance{}
+	for _, key := range keys {
+		if len(lines) >= limit {
+			break
+		}
+		txt, err := loader.GetText(key)
+		if err != nil {
+			log.Printf("fulltext.GetConcordance, skipping %s: %v", key, err)
+			continue
+		}
+		found := findConcordance(key, txt, terms, contextLen, limit-len(lines))
+		lines = append(lines, found...)
+	}
+	return lines
+}
+
+// findConcordance finds occurrences of the terms in the text, preferring the
+// longest term where more than one matches at the same place
+func findConcordance(key, txt string, terms []string, contextLen, limit int) []Concordance {
+	runeTerms := [][]rune{}
+	for _, t := range terms {
+		if len(t) > 0 {
+			runeTerms = append(runeTerms, []rune(t))
+		}
+	}
+	sort.SliceStable(runeTerms, func(i, j int) bool {
+		return len(runeTerms[i]) > len(runeTerms[j])
+	})
+	lines := []Concordance{}
+	runes := []rune(txt)
+	for i := 0; i < len(runes) && len(lines) < limit; i++ {
+		for _, term := range runeTerms {
+			if !hasRunePrefix(runes[i:], term) {
+				continue
+			}
+			end := i + len(term)
+			start := i - contextLen
+			if start < 0 {
+				start = 0
+			}
+			right := end + contextLen
+			if right > len(runes) {
+				right = len(runes)
+			}
+			left := string(runes[start:i])
+			if k := strings.LastIndex(left, "\n"); k > -1 {
+				left = left[k+1:]
+			}
+			after := string(runes[end:right])
+			if k := strings.Index(after, "\n"); k > -1 {
+				after = after[:k]
+			}
+			lines = append(lines, Concordance{
+				PlainTextFile: key,
+				Left:          strings.TrimSpace(left),
+				Term:          string(term),
+				Right:         strings.TrimSpace(after),
+			})
+			i = end - 1
+			break
+		}
+	}
+	return lines
+}
+
+// hasRunePrefix tests whether the text starts with the prefix
+func hasRunePrefix(text, prefix []rune) bool {
+	if len(prefix) > len(text) {
+		return false
+	}
+	for i, r := range prefix {
+		if text[i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+// FindCollocates counts the dictionary words immediately before and after the
+// term in the concordance lines, giving up to limit of the most frequent
+func FindCollocates(lines []Concordance, tok tokenizer.Tokenizer, limit int) []Collocate {
+	counts := make(map[string]int)
+	for _, line := range lines {
+		if tokens := tok.Tokenize(line.Left); len(tokens) > 0 {
+			if w := tokens[len(tokens)-1]; isWord(w) && strings.HasSuffix(line.Left, w.Token) {
+				counts[w.Token]++
+			}
+		}
+		if tokens := tok.Tokenize(line.Right); len(tokens) > 0 {
+			if w := tokens[0]; isWord(w) && strings.HasPrefix(line.Right, w.Token) {
+				counts[w.Token]++
+			}
+		}
+	}
+	collocates := []Collocate{}
+	for w, n := range counts {
+		collocates = append(collocates, Collocate{Word: w, Freq: n})
+	}
+	sort.Slice(collocates, func(i, j int) bool {
+		if collocates[i].Freq != collocates[j].Freq {
+			return collocates[i].Freq > collocates[j].Freq
+		}
+		return collocates[i].Word < collocates[j].Word
+	})
+	if len(collocates) > limit {
+		collocates = collocates[:limit]
+	}
+	return collocates
+}
+
+// isWord tests whether the token is a Chinese word in the dictionary
+func isWord(t tokenizer.TextToken) bool {
+	return len(t.DictEntry.Simplified) > 0 && dicttypes.ContainsCJK(t.Token)
+}
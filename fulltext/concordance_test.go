@@ -0,0 +1,169 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fulltext
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+	"github.com/alexamies/chinesenotes-go/tokenizer"
+)
+
+// mockLoader loads text from a map
+type mockLoader struct {
+	texts map[string]string
+}
+
+func (m mockLoader) GetText(plainTextFile string) (string, error) {
+	txt, ok := m.texts[plainTextFile]
+	if !ok {
+		return "", fmt.Errorf("not found: %s", plainTextFile)
+	}
+	return txt, nil
+}
+
+// TestFindConcordance tests finding terms in context
+func TestFindConcordance(t *testing.T) {
+	const txt = "故詩有六義焉：一曰風，二曰賦。\n三曰比，四曰興"
+	type test struct {
+		name       string
+		terms      []string
+		contextLen int
+		limit      int
+		expect     []Concordance
+	}
+	tests := []test{
+		{
+			name:       "Two matches",
+			terms:      []string{"曰風"},
+			contextLen: 3,
+			limit:      10,
+			expect: []Concordance{
+				{"a.txt", "焉：一", "曰風", "，二曰"},
+			},
+		},
+		{
+			name:       "Context within line",
+			terms:      []string{"曰"},
+			contextLen: 5,
+			limit:      10,
+			expect: []Concordance{
+				{"a.txt", "六義焉：一", "曰", "風，二曰賦"},
+				{"a.txt", "一曰風，二", "曰", "賦。"},
+				{"a.txt", "三", "曰", "比，四曰興"},
+				{"a.txt", "三曰比，四", "曰", "興"},
+			},
+		},
+		{
+			name:       "Limit",
+			terms:      []string{"曰"},
+			contextLen: 1,
+			limit:      1,
+			expect: []Concordance{
+				{"a.txt", "一", "曰", "風"},
+			},
+		},
+		{
+			name:       "Longest term first",
+			terms:      []string{"曰", "曰賦"},
+			contextLen: 1,
+			limit:      10,
+			expect: []Concordance{
+				{"a.txt", "一", "曰", "風"},
+				{"a.txt", "二", "曰賦", "。"},
+				{"a.txt", "三", "曰", "比"},
+				{"a.txt", "四", "曰", "興"},
+			},
+		},
+		{
+			name:       "No match",
+			terms:      []string{"頌"},
+			contextLen: 3,
+			limit:      10,
+			expect:     []Concordance{},
+		},
+	}
+	for _, tc := range tests {
+		got := findConcordance("a.txt", txt, tc.terms, tc.contextLen, tc.limit)
+		if len(got) != len(tc.expect) {
+			t.Errorf("TestFindConcordance %s: expected %v, got %v", tc.name,
+				tc.expect, got)
+			continue
+		}
+		for i, c := range tc.expect {
+			if got[i] != c {
+				t.Errorf("TestFindConcordance %s: %d expected %v, got %v", tc.name,
+					i, c, got[i])
+			}
+		}
+	}
+}
+
+// TestGetConcordance tests finding terms in several documents
+func TestGetConcordance(t *testing.T) {
+	loader := mockLoader{
+		texts: map[string]string{
+			"a.txt": "一曰風",
+			"b.txt": "風俗，風雅",
+		},
+	}
+	got := getConcordance(loader, []string{"b.txt", "missing.txt", "a.txt"},
+		[]string{"風"}, 2, 10)
+	expect := []Concordance{
+		{"b.txt", "", "風", "俗，"},
+		{"b.txt", "俗，", "風", "雅"},
+		{"a.txt", "一曰", "風", ""},
+	}
+	if len(got) != len(expect) {
+		t.Fatalf("TestGetConcordance: expected %v, got %v", expect, got)
+	}
+	for i, c := range expect {
+		if got[i] != c {
+			t.Errorf("TestGetConcordance: %d expected %v, got %v", i, c, got[i])
+		}
+	}
+	if got := getConcordance(loader, []string{"b.txt", "a.txt"}, []string{"風"}, 2, 1); len(got) != 1 {
+		t.Errorf("TestGetConcordance: expected 1 with limit, got %v", got)
+	}
+}
+
+// TestFindCollocates tests counting the words next to a term
+func TestFindCollocates(t *testing.T) {
+	wdict := map[string]*dicttypes.Word{
+		"詩":  {Simplified: "詩"},
+		"詩經": {Simplified: "詩經"},
+		"六義": {Simplified: "六義"},
+		"有":  {Simplified: "有"},
+	}
+	lines := []Concordance{
+		{Left: "故詩", Term: "曰", Right: "六義焉"},
+		{Left: "是以詩經", Term: "曰", Right: "有"},
+		{Left: "詩", Term: "曰", Right: "，六義"},
+		{Left: "", Term: "曰", Right: ""},
+	}
+	tok := tokenizer.NewDictTokenizer(wdict)
+	got := FindCollocates(lines, tok, 10)
+	expect := []Collocate{{"詩", 2}, {"六義", 1}, {"有", 1}, {"詩經", 1}}
+	if len(got) != len(expect) {
+		t.Fatalf("TestFindCollocates: expected %v, got %v", expect, got)
+	}
+	for i, c := range expect {
+		if got[i] != c {
+			t.Errorf("TestFindCollocates: %d expected %v, got %v", i, c, got[i])
+		}
+	}
+	if got := FindCollocates(lines, tok, 1); len(got) != 1 || got[0].Word != "詩" {
+		t.Errorf("TestFindCollocates: expected 詩 only with limit, got %v", got)
+	}
+}
@@ -51,8 +51,22 @@ type TextLoader interface {
 		queryTerms []string) (MatchingText, error)
 }
 
-// Implements the TextLoader interface, loads the text from a local file
-// mounted on the application server
+// Interface for retrieval of the full plain text of a document
+type FullTextLoader interface {
+
+	// Get the full plain text of the document
+	GetText(plainTextFile string) (string, error)
+}
+
+// A loader for both matching and full text, as for LocalTextLoader and
+// GCSLoader
+type textLoader interface {
+	TextLoader
+	FullTextLoader
+}
+
+// Implements the TextLoader and FullTextLoader interfaces, loads the text from
+// a local file mounted on the application server
 // Params:
 //
 //	corpusDir - The top level directory for the plain text files
@@ -61,16 +75,25 @@ type LocalTextLoader struct{ corpusDir string }
 // Gets the matching text from a local file and find the best match
 func (loader LocalTextLoader) GetMatching(plainTextFile string,
 	queryTerms []string) (MatchingText, error) {
+	txt, err := loader.GetText(plainTextFile)
+	if err != nil {
+		return MatchingText{}, err
+	}
+	return getMatch(txt, queryTerms, SNIPPET_LEN), nil
+}
+
+// Gets the text from a local file
+func (loader LocalTextLoader) GetText(plainTextFile string) (string, error) {
 	fullPath := loader.corpusDir + "/" + plainTextFile
 	bs, err := ioutil.ReadFile(fullPath)
 	if err != nil {
-		return MatchingText{}, err
+		return "", err
 	}
-	return getMatch(string(bs), queryTerms, SNIPPET_LEN), nil
+	return string(bs), nil
 }
 
-// Implements the TextLoader interface, loads the text from a Google Cloud
-// Storage.
+// Implements the TextLoader and FullTextLoader interfaces, loads the text from
+// a Google Cloud Storage.
 // Params:
 //
 //	Bucket - The base URL for the location of the plain text files
@@ -82,18 +105,10 @@ type GCSLoader struct {
 // Gets the matching text from a local file and find the best match
 func (loader GCSLoader) GetMatching(plainTextFile string, queryTerms []string) (MatchingText, error) {
 	log.Printf("GCSLoader.GetMatching %s", plainTextFile)
-	ctx := context.Background()
-	r, err := loader.client.Bucket(loader.bucket).Object(plainTextFile).NewReader(ctx)
-	if err != nil {
-		return MatchingText{}, fmt.Errorf("GCSLoader.GetMatching error loading for %s: %v", plainTextFile, err)
-	}
-	defer r.Close()
-
-	bs, err := ioutil.ReadAll(r)
+	txt, err := loader.GetText(plainTextFile)
 	if err != nil {
-		return MatchingText{}, fmt.Errorf("GCSLoader.GetMatching error reading for %s: %v", plainTextFile, err)
+		return MatchingText{}, fmt.Errorf("GCSLoader.GetMatching %v", err)
 	}
-	txt := string(bs)
 	match, err := getMatch(txt, queryTerms, SNIPPET_LEN), nil
 	if err != nil {
 		return MatchingText{}, fmt.Errorf("GCSLoader.GetMatching error finding snippet for %s: %v", plainTextFile, err)
@@ -102,9 +117,24 @@ func (loader GCSLoader) GetMatching(plainTextFile string, queryTerms []string) (
 	return match, nil
 }
 
+// Gets the text from Google Cloud Storage
+func (loader GCSLoader) GetText(plainTextFile string) (string, error) {
+	ctx := context.Background()
+	r, err := loader.client.Bucket(loader.bucket).Object(plainTextFile).NewReader(ctx)
+	if err != nil {
+		return "", fmt.Errorf("GCSLoader.GetText error loading for %s: %v", plainTextFile, err)
+	}
+	defer r.Close()
+	bs, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("GCSLoader.GetText error reading for %s: %v", plainTextFile, err)
+	}
+	return string(bs), nil
+}
+
 // Uses the environment variableS GOOGLE_APPLICATION_CREDENTIALS and TEXT_BUCKET
 // to determine whether to load the files from the local file system or GCS.
-func getLoader() TextLoader {
+func getLoader() textLoader {
 	if bucket, ok := os.LookupEnv("TEXT_BUCKET"); ok {
 		loader, err := NewGCSLoader(bucket)
 		if err == nil {
@@ -121,6 +151,12 @@ func getLoader() TextLoader {
 	return LocalTextLoader{"../corpus"}
 }
 
+// GetText gets the full plain text of a document from the local file system
+// or GCS, as for GetConcordance
+func GetText(plainTextFile string) (string, error) {
+	return getLoader().GetText(plainTextFile)
+}
+
 // Given the already retrieved text body, find the best match
 func getMatch(txt string, queryTerms []string, snippetLen int) MatchingText {
 	// log.Printf("fulltext.getMatch, txt = %s, query: %v", txt, queryTerms)
@@ -102,12 +102,32 @@ func InvalidUser() UserInfo {
 	}
 }
 
-// Generate a new session id after login
+// Permissions checked with IsAuthorized
+const (
+	PermTranslationPortal = "translation_portal"
+	PermProposeEdit       = "propose_edit"
+	PermReviewEdit        = "review_edit"
+)
+
+// Permissions granted to each role, admin is granted all permissions
+var rolePermissions = map[string]map[string]bool{
+	"editor": {
+		PermTranslationPortal: true,
+		PermProposeEdit:       true,
+		PermReviewEdit:        true,
+	},
+	"translator": {
+		PermTranslationPortal: true,
+		PermProposeEdit:       true,
+	},
+}
+
+// IsAuthorized checks whether the user's role grants the given permission
 func IsAuthorized(user UserInfo, permission string) bool {
-	if user.Role == "admin" || user.Role == "editor" || user.Role == "translator" {
+	if user.Role == "admin" {
 		return true
 	}
-	return false
+	return rolePermissions[user.Role][permission]
 }
 
 // Generate a new session id after login
@@ -23,3 +23,31 @@ func TestNewSessionId(t *testing.T) {
 		t.Error("TestNewSessionId: ", sessionid)
 	}
 }
+
+// TestIsAuthorized tests permissions for each role
+func TestIsAuthorized(t *testing.T) {
+	type test struct {
+		name       string
+		role       string
+		permission string
+		expect     bool
+	}
+	tests := []test{
+		{"Admin portal", "admin", PermTranslationPortal, true},
+		{"Admin review", "admin", PermReviewEdit, true},
+		{"Editor portal", "editor", PermTranslationPortal, true},
+		{"Editor propose", "editor", PermProposeEdit, true},
+		{"Editor review", "editor", PermReviewEdit, true},
+		{"Translator portal", "translator", PermTranslationPortal, true},
+		{"Translator propose", "translator", PermProposeEdit, true},
+		{"Translator review", "translator", PermReviewEdit, false},
+		{"No role", "", PermTranslationPortal, false},
+		{"Unknown permission", "editor", "delete_everything", false},
+	}
+	for _, tc := range tests {
+		user := UserInfo{UserName: "test", Role: tc.role}
+		if got := IsAuthorized(user, tc.permission); got != tc.expect {
+			t.Errorf("%s: got %t but expected %t", tc.name, got, tc.expect)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/alexamies/chinesenotes-go/config"
+	"github.com/alexamies/chinesenotes-go/fulltext"
+	"github.com/alexamies/chinesenotes-go/identity"
+	"github.com/alexamies/chinesenotes-go/tokenizer"
+	"github.com/alexamies/chinesenotes-go/transmemory"
+	"github.com/alexamies/chinesenotes-go/transtools"
+	"github.com/alexamies/chinesenotes-go/xliff"
+)
+
+// Maximum size of uploaded TMX and XLIFF files
+const maxUploadBytes = 32 << 20
+
+// textFunc gets the plain text of a corpus document
+type textFunc func(plainTextFile string) (string, error)
+
+// importResult gives the number of segments added to the translation memory
+// by an import and the number of segments in it afterwards
+type importResult struct {
+	Added int
+	Size  int
+}
+
+// tmxHandler exports the sentence level translation memory as a TMX file
+func tmxHandler(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
+	if config.PasswordProtected() {
+		sessionInfo := b.sessionEnforcer.EnforceValidSession(context.Background(), w, r)
+		if !sessionInfo.Valid {
+			return
+		}
+	}
+	if b.segmentStore == nil {
+		log.Println("main.tmxHandler sentence translation memory not configured")
+		http.Error(w, "Error, sentence translation memory not configured",
+			http.StatusInternalServerError)
+		return
+	}
+	segments := b.segmentStore.Segments()
+	log.Printf("main.tmxHandler exporting %d segments", len(segments))
+	w.Header().Set("Content-Type", "application/x-tmx+xml; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="translation_memory.tmx"`)
+	if err := transmemory.WriteTMX(w, segments); err != nil {
+		log.Printf("main.tmxHandler error writing TMX: %v", err)
+	}
+}
+
+// xliffHandler exports a corpus document as an XLIFF file for translation
+func xliffHandler(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
+	if config.PasswordProtected() {
+		sessionInfo := b.sessionEnforcer.EnforceValidSession(context.Background(), w, r)
+		if !sessionInfo.Valid {
+			return
+		}
+	}
+	exportXLIFF(w, r, b, fulltext.GetText)
+}
+
+// exportXLIFF writes the document given by the doc parameter, its gloss file,
+// as XLIFF segmented by sentence. The version parameter is 1.2, the default,
+// or 2.0. The optional platform parameter selects the machine translation,
+// as for the translation page, which needs a user with permission to use the
+// translation portal.
+func exportXLIFF(w http.ResponseWriter, r *http.Request, b *backends, getText textFunc) {
+	glossFile := getSingleValue(r, "doc")
+	d, ok := b.docMap[glossFile]
+	if !ok {
+		log.Printf("main.exportXLIFF document not found: %s", glossFile)
+		http.Error(w, "Document not found", http.StatusNotFound)
+		return
+	}
+	version := getSingleValue(r, "version")
+	if len(version) == 0 {
+		version = xliff.Version12
+	}
+	if version != xliff.Version12 && version != xliff.Version20 {
+		log.Printf("main.exportXLIFF bad version %s", version)
+		http.Error(w, "Bad version, expected 1.2 or 2.0", http.StatusBadRequest)
+		return
+	}
+	var mt transtools.ApiClient
+	if platform := getSingleValue(r, "platform"); len(platform) > 0 {
+		// Machine translation is paid for, so is only for translators
+		user, ok := authorizedUser(r.Context(), b, r, identity.PermTranslationPortal)
+		if !ok {
+			log.Printf("main.exportXLIFF %s not authorized for machine translation", user.UserName)
+			http.Error(w, "Not authorized for machine translation", http.StatusForbidden)
+			return
+		}
+		var err error
+		mt, err = translationClient(b, platform)
+		if err != nil {
+			log.Printf("main.exportXLIFF %v", err)
+			http.Error(w, "Machine translation not available", http.StatusBadRequest)
+			return
+		}
+	}
+	txt, err := getText(d.CorpusFile)
+	if err != nil {
+		log.Printf("main.exportXLIFF error getting text for %s: %v", d.CorpusFile, err)
+		http.Error(w, "Internal Error", http.StatusInternalServerError)
+		return
+	}
+	var tok tokenizer.Tokenizer
+	if b.dict != nil {
+		tok = tokenizer.NewDictTokenizer(b.dict.Wdict)
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), b.webConfig.XLIFFMTTimeout())
+	defer cancel()
+	doc := xliff.NewDocument(ctx, glossFile, txt, tok, mt, b.webConfig.XLIFFMaxMTUnits())
+	log.Printf("main.exportXLIFF exporting %d units for %s", len(doc.Units), glossFile)
+	fName := strings.TrimSuffix(path.Base(glossFile), path.Ext(glossFile)) + ".xlf"
+	w.Header().Set("Content-Type", "application/xliff+xml; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fName))
+	if err := xliff.Write(w, doc, version); err != nil {
+		log.Printf("main.exportXLIFF error writing XLIFF: %v", err)
+	}
+}
+
+// importTMXHandler adds the segments of an uploaded TMX file to the sentence
+// level translation memory
+func importTMXHandler(w http.ResponseWriter, r *http.Request) {
+	importSegments(w, r, func(f io.Reader, name string) ([]transmemory.Segment, error) {
+		return transmemory.LoadTMX(f, name)
+	})
+}
+
+// importXLIFFHandler adds the translated units of an uploaded XLIFF file to
+// the sentence level translation memory
+func importXLIFFHandler(w http.ResponseWriter, r *http.Request) {
+	importSegments(w, r, func(f io.Reader, name string) ([]transmemory.Segment, error) {
+		doc, err := xliff.Read(f)
+		if err != nil {
+			return nil, err
+		}
+		return doc.Segments(), nil
+	})
+}
+
+// importSegments reads segments from a file uploaded in the file field of a
+// multipart form, or else the request body, and adds them to the translation
+// memory. The user needs permission to use the translation portal.
+func importSegments(w http.ResponseWriter, r *http.Request,
+	load func(f io.Reader, name string) ([]transmemory.Segment, error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	b := getBackends()
+	if b == nil {
+		http.Error(w, "Server not initialized", http.StatusInternalServerError)
+		return
+	}
+	user, ok := authorizedUser(r.Context(), b, r, identity.PermTranslationPortal)
+	if !ok {
+		log.Printf("main.importSegments %s with role %s not authorized", user.UserName, user.Role)
+		http.Error(w, "Not authorized", http.StatusForbidden)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	var f io.Reader = r.Body
+	name := "upload"
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			log.Printf("main.importSegments error reading upload: %v", err)
+			http.Error(w, "Expected a file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		f = file
+		name = header.Filename
+	}
+	segments, err := load(f, name)
+	if err != nil {
+		log.Printf("main.importSegments could not load %s: %v", name, err)
+		http.Error(w, fmt.Sprintf("Could not read file: %v", err), http.StatusBadRequest)
+		return
+	}
+	// Add to the current store, which may have been swapped by a reload
+	segmentsMu.Lock()
+	store := getBackends().segmentStore
+	if store == nil {
+		segmentsMu.Unlock()
+		log.Println("main.importSegments sentence translation memory not initialized")
+		http.Error(w, "Error, sentence translation memory not initialized",
+			http.StatusInternalServerError)
+		return
+	}
+	if importFile := b.webConfig.SegmentImportFile(); len(importFile) > 0 {
+		if err := saveImports(importFile, segments); err != nil {
+			segmentsMu.Unlock()
+			log.Printf("main.importSegments could not save %s: %v", name, err)
+			http.Error(w, "Error, could not save the imported translations",
+				http.StatusInternalServerError)
+			return
+		}
+	}
+	before := store.Size()
+	store.Add(segments...)
+	result := importResult{
+		Added: store.Size() - before,
+		Size:  store.Size(),
+	}
+	segmentsMu.Unlock()
+	log.Printf("main.importSegments %s added %d segments from %s", user.UserName, result.Added, name)
+	sendJSON(w, result)
+}
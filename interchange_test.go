@@ -0,0 +1,290 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexamies/chinesenotes-go/config"
+	"github.com/alexamies/chinesenotes-go/dictionary"
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+	"github.com/alexamies/chinesenotes-go/find"
+	"github.com/alexamies/chinesenotes-go/transmemory"
+	"github.com/alexamies/chinesenotes-go/transtools"
+)
+
+type mockTranslateClient struct{}
+
+func (c mockTranslateClient) Translate(ctx context.Context, req transtools.Request) (*transtools.Result, error) {
+	return &transtools.Result{Text: "MT: " + req.Text}, nil
+}
+
+// TestTMXHandler tests exporting the translation memory as TMX
+func TestTMXHandler(t *testing.T) {
+	b = &backends{}
+	r := httptest.NewRequest(http.MethodGet, "/tmx", nil)
+	w := httptest.NewRecorder()
+	tmxHandler(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("TestTMXHandler not configured: got status %d", w.Code)
+	}
+	b.segmentStore = transmemory.NewSegmentStore()
+	b.segmentStore.Add(transmemory.Segment{Source: "学而时习之", Target: "To learn", Origin: "a.txt"})
+	w = httptest.NewRecorder()
+	tmxHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("TestTMXHandler: got status %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Disposition"); !strings.Contains(got, "translation_memory.tmx") {
+		t.Errorf("TestTMXHandler: got Content-Disposition %s", got)
+	}
+	if got := w.Body.String(); !strings.Contains(got, "<seg>学而时习之</seg>") {
+		t.Errorf("TestTMXHandler: unexpected body %s", got)
+	}
+	b = nil
+}
+
+// TestExportXLIFF tests exporting a document as XLIFF
+func TestExportXLIFF(t *testing.T) {
+	xue := dicttypes.Word{
+		Simplified: "学",
+		Pinyin:     "xué",
+		HeadwordId: 1,
+		Senses:     []dicttypes.WordSense{{English: "to learn"}},
+	}
+	b = &backends{
+		dict: dictionary.NewDictionary(map[string]*dicttypes.Word{"学": &xue}),
+		docMap: map[string]find.DocInfo{
+			"analects/analects001.html": {CorpusFile: "analects/analects001.txt"},
+			"missing/missing001.html":   {CorpusFile: "missing/missing001.txt"},
+		},
+		deepLApiClient: mockTranslateClient{},
+	}
+	getText := func(plainTextFile string) (string, error) {
+		if plainTextFile == "analects/analects001.txt" {
+			return "学而时习之。有朋自远方来。", nil
+		}
+		return "", fmt.Errorf("not found: %s", plainTextFile)
+	}
+	type test struct {
+		name           string
+		query          string
+		role           string
+		expectCode     int
+		expectContains []string
+	}
+	tests := []test{
+		{
+			name:       "XLIFF 1.2",
+			query:      "doc=analects/analects001.html",
+			expectCode: http.StatusOK,
+			expectContains: []string{`version="1.2"`, `original="analects/analects001.html"`,
+				"<source>学而时习之。</source>", `<note from="glossary">学 xué: to learn</note>`},
+		},
+		{
+			name:       "XLIFF 2.0 with machine translation",
+			query:      "doc=analects/analects001.html&version=2.0&platform=DeepL",
+			role:       "translator",
+			expectCode: http.StatusOK,
+			expectContains: []string{`version="2.0"`, `<unit id="2">`,
+				"<target>MT: 有朋自远方来。</target>"},
+		},
+		{
+			name:           "Machine translation not logged in",
+			query:          "doc=analects/analects001.html&platform=DeepL",
+			expectCode:     http.StatusForbidden,
+			expectContains: []string{"Not authorized"},
+		},
+		{
+			name:           "Machine translation without permission",
+			query:          "doc=analects/analects001.html&platform=DeepL",
+			role:           "user",
+			expectCode:     http.StatusForbidden,
+			expectContains: []string{"Not authorized"},
+		},
+		{
+			name:           "No document",
+			query:          "doc=nothere.html",
+			expectCode:     http.StatusNotFound,
+			expectContains: []string{"Document not found"},
+		},
+		{
+			name:           "Bad version",
+			query:          "doc=analects/analects001.html&version=1.0",
+			expectCode:     http.StatusBadRequest,
+			expectContains: []string{"Bad version"},
+		},
+		{
+			name:           "Machine translation not available",
+			query:          "doc=analects/analects001.html&platform=gcp",
+			role:           "translator",
+			expectCode:     http.StatusBadRequest,
+			expectContains: []string{"Machine translation not available"},
+		},
+		{
+			name:           "No text",
+			query:          "doc=missing/missing001.html",
+			expectCode:     http.StatusInternalServerError,
+			expectContains: []string{"Internal Error"},
+		},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/xliff?"+tc.query, nil)
+		b.authenticator = nil
+		if len(tc.role) > 0 {
+			b.authenticator = roleAuthenticatorMock{role: tc.role}
+			r.AddCookie(&http.Cookie{Name: "session", Value: "test"})
+		}
+		w := httptest.NewRecorder()
+		exportXLIFF(w, r, b, getText)
+		if w.Code != tc.expectCode {
+			t.Errorf("TestExportXLIFF %s: got status %d, want %d", tc.name, w.Code,
+				tc.expectCode)
+		}
+		result := w.Body.String()
+		for _, want := range tc.expectContains {
+			if !strings.Contains(result, want) {
+				t.Errorf("TestExportXLIFF %s: got %q, expectContains %q", tc.name,
+					result, want)
+			}
+		}
+	}
+	b = nil
+}
+
+// TestImportSegments tests importing TMX and XLIFF files into the translation
+// memory
+func TestImportSegments(t *testing.T) {
+	const tmx = `<tmx version="1.4"><body>
+<tu><tuv xml:lang="zh"><seg>学而时习之</seg></tuv><tuv xml:lang="en"><seg>To learn</seg></tuv></tu>
+</body></tmx>`
+	const xlf = `<xliff version="1.2" xmlns="urn:oasis:names:tc:xliff:document:1.2">
+<file original="analects/analects001.html" source-language="zh" target-language="en" datatype="plaintext"><body>
+<trans-unit id="1"><source>学而时习之</source><target state="translated">To learn</target></trans-unit>
+<trans-unit id="2"><source>有朋自远方来</source><target state="translated">Friends come</target></trans-unit>
+<trans-unit id="3"><source>人不知而不愠</source><target state="needs-review-translation">MT</target></trans-unit>
+</body></file></xliff>`
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, err := mw.CreateFormFile("file", "analects.tmx")
+	if err != nil {
+		t.Fatalf("TestImportSegments: could not create form: %v", err)
+	}
+	fw.Write([]byte(tmx))
+	mw.Close()
+
+	importFile := filepath.Join(t.TempDir(), "imports", "imported.tmx")
+	b = &backends{
+		segmentStore: transmemory.NewSegmentStore(),
+		webConfig: config.WebAppConfig{
+			ConfigVars: map[string]string{"SegmentImportFile": importFile},
+		},
+	}
+	type test struct {
+		name           string
+		handler        http.HandlerFunc
+		method         string
+		body           string
+		contentType    string
+		role           string
+		expectCode     int
+		expectContains string
+	}
+	tests := []test{
+		{
+			name:           "Not logged in",
+			handler:        importTMXHandler,
+			method:         http.MethodPost,
+			body:           tmx,
+			expectCode:     http.StatusForbidden,
+			expectContains: "Not authorized",
+		},
+		{
+			name:           "GET",
+			handler:        importTMXHandler,
+			method:         http.MethodGet,
+			role:           "translator",
+			expectCode:     http.StatusMethodNotAllowed,
+			expectContains: "Method not allowed",
+		},
+		{
+			name:           "TMX form upload",
+			handler:        importTMXHandler,
+			method:         http.MethodPost,
+			body:           form.String(),
+			contentType:    mw.FormDataContentType(),
+			role:           "translator",
+			expectCode:     http.StatusOK,
+			expectContains: `{"Added":1,"Size":1}`,
+		},
+		{
+			name:           "XLIFF body",
+			handler:        importXLIFFHandler,
+			method:         http.MethodPost,
+			body:           xlf,
+			contentType:    "application/xliff+xml",
+			role:           "translator",
+			expectCode:     http.StatusOK,
+			expectContains: `{"Added":1,"Size":2}`,
+		},
+		{
+			name:           "Bad XLIFF",
+			handler:        importXLIFFHandler,
+			method:         http.MethodPost,
+			body:           "<xliff>",
+			role:           "translator",
+			expectCode:     http.StatusBadRequest,
+			expectContains: "Could not read file",
+		},
+	}
+	for _, tc := range tests {
+		b.authenticator = nil
+		r := httptest.NewRequest(tc.method, "/loggedin/import", strings.NewReader(tc.body))
+		if len(tc.contentType) > 0 {
+			r.Header.Set("Content-Type", tc.contentType)
+		}
+		if len(tc.role) > 0 {
+			b.authenticator = roleAuthenticatorMock{role: tc.role}
+			r.AddCookie(&http.Cookie{Name: "session", Value: "test"})
+		}
+		w := httptest.NewRecorder()
+		tc.handler(w, r)
+		if w.Code != tc.expectCode {
+			t.Errorf("TestImportSegments %s: got status %d, want %d", tc.name, w.Code,
+				tc.expectCode)
+		}
+		if result := w.Body.String(); !strings.Contains(result, tc.expectContains) {
+			t.Errorf("TestImportSegments %s: got %q, expectContains %q", tc.name,
+				result, tc.expectContains)
+		}
+	}
+	// Imported translations that were in the TMX are not duplicated
+	segments := b.segmentStore.Segments()
+	if len(segments) != 2 || segments[0].Origin != "analects.tmx" ||
+		segments[1].Origin != "analects/analects001.html" {
+		t.Errorf("TestImportSegments: unexpected segments %v", segments)
+	}
+	// Imports are loaded again after a restart
+	if store := loadSegmentStore(b.webConfig, nil); store.Size() != 2 {
+		t.Errorf("TestImportSegments: got %d segments after restart, want 2", store.Size())
+	}
+	b = nil
+}
@@ -0,0 +1,523 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for parsing Hanyu pinyin written with tone marks, tone numbers, or
+// no tones, with or without spaces between syllables, and converting between
+// the forms.
+package pinyin
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Tone marked vowels, indexed by tone 1 to 4
+var toneMarks = map[rune][]rune{
+	'a': {'ā', 'á', 'ǎ', 'à'},
+	'e': {'ē', 'é', 'ě', 'è'},
+	'i': {'ī', 'í', 'ǐ', 'ì'},
+	'o': {'ō', 'ó', 'ǒ', 'ò'},
+	'u': {'ū', 'ú', 'ǔ', 'ù'},
+	'ü': {'ǖ', 'ǘ', 'ǚ', 'ǜ'},
+	'A': {'Ā', 'Á', 'Ǎ', 'À'},
+	'E': {'Ē', 'É', 'Ě', 'È'},
+	'O': {'Ō', 'Ó', 'Ǒ', 'Ò'},
+}
+
+// Valid pinyin syllables without tones, used to split tone marked pinyin
+var validSyllables = func() map[string]bool {
+	list := `a ai an ang ao ba bai ban bang bao bei ben beng bi bian biao bie bin
+	bing bo bu ca cai can cang cao ce cen ceng cha chai chan chang chao che chen
+	cheng chi chong chou chu chua chuai chuan chuang chui chun chuo ci cong cou cu
+	cuan cui cun cuo da dai dan dang dao de dei den deng di dia dian diao die ding
+	diu dong dou du duan dui dun duo e ei en eng er fa fan fang fei fen feng fo fou
+	fu ga gai gan gang gao ge gei gen geng gong gou gu gua guai guan guang gui gun
+	guo ha hai han hang hao he hei hen heng hm hng hong hou hu hua huai huan huang
+	hui hun huo ji jia jian jiang jiao jie jin jing jiong jiu ju juan jue jun ka kai
+	kan kang kao ke kei ken keng kong kou ku kua kuai kuan kuang kui kun kuo la lai
+	lan lang lao le lei leng li lia lian liang liao lie lin ling liu lo long lou lu
+	luan lun luo lü lüe m ma mai man mang mao me mei men meng mi mian miao mie min
+	ming miu mo mou mu n na nai nan nang nao ne nei nen neng ng ni nian niang niao
+	nie nin ning niu nong nou nu nuan nun nuo nü nüe o ou pa pai pan pang pao pei
+	pen peng pi pian piao pie pin ping po pou pu qi qia qian qiang qiao qie qin
+	qing qiong qiu qu quan que qun ran rang rao re ren reng ri rong rou ru rua ruan
+	rui run ruo sa sai san sang sao se sen seng sha shai shan shang shao she shei
+	shen sheng shi shou shu shua shuai shuan shuang shui shun shuo si song sou su
+	suan sui sun suo ta tai tan tang tao te teng ti tian tiao tie ting tong tou tu
+	tuan tui tun tuo wa wai wan wang wei wen weng wo wu xi xia xian xiang xiao xie
+	xin xing xiong xiu xu xuan xue xun ya yan yang yao ye yi yin ying yo yong you
+	yu yuan yue yun za zai zan zang zao ze zei zen zeng zha zhai zhan zhang zhao
+	zhe zhei zhen zheng zhi zhong zhou zhu zhua zhuai zhuan zhuang zhui zhun zhuo
+	zi zong zou zu zuan zui zun zuo`
+	syllables := make(map[string]bool)
+	for _, s := range strings.Fields(list) {
+		syllables[s] = true
+	}
+	return syllables
+}()
+
+// toneNumbers gives the vowel and tone number for tone marked vowels
+var toneNumbers = func() map[rune][2]rune {
+	numbers := make(map[rune][2]rune)
+	for vowel, marks := range toneMarks {
+		for i, m := range marks {
+			numbers[m] = [2]rune{vowel, rune('1' + i)}
+		}
+	}
+	return numbers
+}()
+
+// Syllable is a single pinyin syllable
+type Syllable struct {
+	// Lower case without tone, with ü for u umlaut, eg lü, and without the
+	// erhua r
+	Plain string
+
+	// 1 to 4, 5 for the neutral tone, or 0 if not given
+	Tone int
+
+	// The syllable has an erhua r suffix, eg huār
+	Erhua bool
+
+	// The syllable was capitalized, eg in a proper noun
+	Upper bool
+}
+
+// Marked gives the syllable with a tone mark, eg lǜ
+func (s Syllable) Marked() string {
+	m := markSyllable(s.Plain, s.Tone)
+	if s.Erhua {
+		m += "r"
+	}
+	return capitalize(m, s.Upper)
+}
+
+// Numbered gives the syllable with a tone number, as used in CC-CEDICT, eg
+// lu:4. A syllable without a tone is given the neutral tone 5. The erhua r is
+// written as a separate syllable, eg hua1 r5.
+func (s Syllable) Numbered() string {
+	n := strings.ReplaceAll(s.Plain, "ü", "u:")
+	tone := s.Tone
+	if tone == 0 {
+		tone = 5
+	}
+	numbered := fmt.Sprintf("%s%d", capitalize(n, s.Upper), tone)
+	if s.Erhua {
+		numbered += " r5"
+	}
+	return numbered
+}
+
+// Parse parses pinyin into syllables. Syllables may be separated by spaces,
+// apostrophes, or hyphens, or not at all, eg ni3 hao3, nǐhǎo, and ni hao. The
+// u umlaut may be written as ü, v, or u:. An error is returned if any part of
+// the text is not pinyin.
+func Parse(text string) ([]Syllable, error) {
+	syllables := []Syllable{}
+	for _, word := range splitWords(text) {
+		s, err := parseWord(word, -1)
+		if err != nil {
+			return nil, err
+		}
+		syllables = append(syllables, s...)
+	}
+	if len(syllables) == 0 {
+		return nil, fmt.Errorf("pinyin.Parse, no syllables in %q", text)
+	}
+	return syllables, nil
+}
+
+// IsPinyin tests whether the text can be parsed as pinyin
+func IsPinyin(text string) bool {
+	_, err := Parse(text)
+	return err == nil
+}
+
+// Segment splits pinyin written without spaces into syllables without tones,
+// eg nihao to ni and hao. Returns nil if the text is not pinyin.
+func Segment(text string) []string {
+	syllables, err := Parse(text)
+	if err != nil {
+		return nil
+	}
+	segments := []string{}
+	for _, s := range syllables {
+		seg := s.Plain
+		if s.Erhua {
+			seg += "r"
+		}
+		segments = append(segments, seg)
+	}
+	return segments
+}
+
+// Join writes the syllables with tone marks as a single word, with apostrophes
+// where needed to separate syllables, eg Xī'ān
+func Join(syllables []Syllable) string {
+	var b strings.Builder
+	for _, s := range syllables {
+		m := s.Marked()
+		if b.Len() > 0 && NeedsApostrophe(m) {
+			b.WriteRune('\'')
+		}
+		b.WriteString(m)
+	}
+	return b.String()
+}
+
+// ToMarked converts pinyin with tone numbers to tone marks, keeping the
+// spaces between words, eg ni3hao3 ma5 to nǐhǎo ma. Words that are not pinyin
+// only have the tone numbers removed.
+func ToMarked(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		if syllables, err := parseWord(word, -1); err == nil {
+			words[i] = Join(syllables)
+			continue
+		}
+		var b strings.Builder
+		for _, chunk := range splitTones(word) {
+			b.WriteString(markSyllable(chunk.text, chunk.tone))
+		}
+		words[i] = b.String()
+	}
+	return strings.Join(words, " ")
+}
+
+// ToNumbered converts tone marked pinyin, eg Hànyǔ, to numbered syllables
+// separated by spaces as used in CC-CEDICT, eg Han4 yu3. For pinyin written as
+// a single word the number of syllables expected, eg from the number of
+// Chinese characters, if greater than zero, is used to choose between ways of
+// splitting, eg xiān and xī'ān without the apostrophe. The natural split is
+// used if the number expected gives syllables that could not be written
+// together in a word, eg zhōngguó split into three. Words that cannot be split
+// are returned unchanged.
+func ToNumbered(marked string, nSyllables int) string {
+	words := splitWords(marked)
+	numbered := []string{}
+	for _, word := range words {
+		var syllables []Syllable
+		var err error
+		if len(words) == 1 && nSyllables > 0 {
+			syllables, err = parseWord(word, nSyllables)
+			if err == nil && !writtenTogether(syllables) {
+				syllables = nil
+			}
+		}
+		if syllables == nil {
+			syllables, err = parseWord(word, -1)
+		}
+		if err != nil {
+			numbered = append(numbered, word)
+			continue
+		}
+		for _, s := range syllables {
+			numbered = append(numbered, s.Numbered())
+		}
+	}
+	return strings.Join(numbered, " ")
+}
+
+// writtenTogether tests whether the syllables can be written as one word
+// without apostrophes, which separate syllables starting with a, e, or o and
+// are not used with the nasals n, ng, and m
+func writtenTogether(syllables []Syllable) bool {
+	for _, s := range syllables[1:] {
+		if NeedsApostrophe(s.Plain) || nasals[s.Plain] {
+			return false
+		}
+	}
+	return true
+}
+
+// Variants of ü after j, q, x, and y, which are written as u
+var (
+	jqxyReplacer = strings.NewReplacer("jv", "ju", "qv", "qu", "xv", "xu", "yv", "yu")
+	jqxyUmlaut   = strings.NewReplacer("jü", "ju", "qü", "qu", "xü", "xu", "yü", "yu")
+)
+
+// Normalize gives a key for matching pinyin however it is written, lower case
+// without tones or separators and with v for ü, eg Nǚ'ér, nu:3 er2, and nv er
+// all give nver
+func Normalize(text string) string {
+	var b strings.Builder
+	s := strings.ReplaceAll(strings.ToLower(text), "u:", "v")
+	for _, r := range s {
+		if n, ok := toneNumbers[r]; ok {
+			r = n[0]
+		}
+		switch {
+		case r == 'ü':
+			b.WriteRune('v')
+		case unicode.IsSpace(r), unicode.IsDigit(r), isSeparator(r):
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return jqxyReplacer.Replace(b.String())
+}
+
+// NeedsApostrophe tests whether a syllable starts with a, e, or o, which
+// needs an apostrophe to separate it from the previous syllable
+func NeedsApostrophe(syllable string) bool {
+	for _, r := range strings.ToLower(syllable) {
+		if n, ok := toneNumbers[r]; ok {
+			r = n[0]
+		}
+		return r == 'a' || r == 'e' || r == 'o'
+	}
+	return false
+}
+
+func isSeparator(r rune) bool {
+	return r == '\'' || r == '’' || r == '-'
+}
+
+// splitWords splits text into words at spaces and syllable separators
+func splitWords(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || isSeparator(r)
+	})
+}
+
+// toneChunk is part of a word ending in a tone number, or the end of the word
+type toneChunk struct {
+	text string
+	tone int
+}
+
+// splitTones splits a word after each tone number, eg ni3hao3 to ni and hao
+func splitTones(word string) []toneChunk {
+	chunks := []toneChunk{}
+	var b strings.Builder
+	for _, r := range word {
+		if r >= '0' && r <= '5' {
+			chunks = append(chunks, toneChunk{b.String(), int(r - '0')})
+			b.Reset()
+			continue
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() > 0 {
+		chunks = append(chunks, toneChunk{b.String(), 0})
+	}
+	return chunks
+}
+
+// parseWord parses a word without spaces into syllables. If n is not negative
+// and the word has no tone numbers then only a split into exactly n syllables
+// is accepted, counting an erhua r as a syllable, as for the characters of 花儿.
+func parseWord(word string, n int) ([]Syllable, error) {
+	word = strings.NewReplacer("u:", "ü", "U:", "Ü", "v", "ü", "V", "Ü").Replace(word)
+	chunks := splitTones(word)
+	syllables := []Syllable{}
+	for _, chunk := range chunks {
+		plain, tones := stripTones(chunk.text)
+		nChunk := -1
+		if len(chunks) == 1 {
+			nChunk = n
+		}
+		split := splitSyllables(plain, tones, nChunk)
+		if len(split) == 0 {
+			return nil, fmt.Errorf("pinyin.Parse, not pinyin: %q", word)
+		}
+		pos := 0
+		for _, head := range split {
+			runes := []rune(head)
+			s := Syllable{
+				Plain: strings.ToLower(head),
+				Upper: unicode.IsUpper(runes[0]),
+			}
+			if !isSyllable(s.Plain) {
+				s.Plain = strings.TrimSuffix(s.Plain, "r")
+				s.Erhua = true
+			}
+			s.Plain = jqxyUmlaut.Replace(s.Plain)
+			for _, t := range tones[pos : pos+len(runes)] {
+				if t != 0 {
+					s.Tone = int(t - '0')
+				}
+			}
+			syllables = append(syllables, s)
+			pos += len(runes)
+		}
+		if chunk.tone > 0 {
+			syllables[len(syllables)-1].Tone = chunk.tone
+		}
+	}
+	return syllables, nil
+}
+
+// markSyllable adds a tone mark to a syllable without validating it
+func markSyllable(plain string, tone int) string {
+	runes := []rune(plain)
+	if tone < 1 || tone > 4 {
+		return plain
+	}
+	// The mark goes on a or e if present, the o in ou, otherwise the last vowel
+	pos := -1
+	for i, r := range runes {
+		l := unicode.ToLower(r)
+		if l == 'a' || l == 'e' {
+			pos = i
+			break
+		}
+		if l == 'o' && i+1 < len(runes) && unicode.ToLower(runes[i+1]) == 'u' {
+			pos = i
+			break
+		}
+		if _, ok := toneMarks[l]; ok {
+			pos = i
+		}
+	}
+	if pos < 0 {
+		return plain
+	}
+	marks, ok := toneMarks[runes[pos]]
+	if !ok {
+		marks = toneMarks[unicode.ToLower(runes[pos])]
+	}
+	runes[pos] = marks[tone-1]
+	return string(runes)
+}
+
+func capitalize(s string, upper bool) string {
+	if !upper || len(s) == 0 {
+		return s
+	}
+	runes := []rune(s)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// stripTones removes tone marks from a word, giving the tone number found on
+// each rune, or zero if none
+func stripTones(word string) (string, []rune) {
+	runes := []rune(word)
+	tones := make([]rune, len(runes))
+	for i, r := range runes {
+		if n, ok := toneNumbers[r]; ok {
+			runes[i] = n[0]
+			tones[i] = n[1]
+		}
+	}
+	return string(runes), tones
+}
+
+// Syllables that are only a nasal sound, eg the interjection ng
+var nasals = map[string]bool{
+	"hm":  true,
+	"hng": true,
+	"m":   true,
+	"n":   true,
+	"ng":  true,
+}
+
+// splitSyllables splits a word without tone marks into valid syllables with at
+// most one tone each. Where there is more than one way the split with the
+// lowest cost is chosen: fewer syllables, fewer erhua syllables, and no
+// syllables within the word starting with a vowel, which would be written
+// with an apostrophe, or only a nasal. If n is not negative then only a split
+// into exactly n syllables is accepted, counting an erhua r as a syllable.
+// Returns nil if there is none.
+func splitSyllables(word string, tones []rune, n int) []string {
+	runes := []rune(word)
+	lower := []rune(strings.ToLower(word))
+	type split struct {
+		cost      int
+		syllables []string
+		ok        bool
+	}
+	memo := make(map[[2]int]split)
+	var best func(i, n int) split
+	best = func(i, n int) split {
+		if i == len(runes) {
+			return split{ok: n <= 0, syllables: []string{}}
+		}
+		if n == 0 {
+			return split{}
+		}
+		key := [2]int{i, n}
+		if s, ok := memo[key]; ok {
+			return s
+		}
+		result := split{}
+		for j := min(i+7, len(runes)); j > i; j-- {
+			cost, erhua, ok := syllableCost(string(lower[i:j]), i > 0)
+			if !ok || countTones(tones[i:j]) > 1 {
+				continue
+			}
+			rest := -1
+			if n > 0 {
+				rest = n - 1
+				if erhua {
+					rest--
+				}
+				if rest < 0 {
+					continue
+				}
+			}
+			tail := best(j, rest)
+			if !tail.ok {
+				continue
+			}
+			if total := cost + tail.cost; !result.ok || total < result.cost {
+				syllables := append([]string{string(runes[i:j])}, tail.syllables...)
+				result = split{total, syllables, true}
+			}
+		}
+		memo[key] = result
+		return result
+	}
+	s := best(0, n)
+	if !s.ok {
+		return nil
+	}
+	return s.syllables
+}
+
+// syllableCost gives the cost of a lower case syllable in a split, whether it
+// has an erhua r, and whether it is valid
+func syllableCost(s string, withinWord bool) (int, bool, bool) {
+	cost := 1
+	erhua := false
+	base := jqxyUmlaut.Replace(s)
+	if !validSyllables[base] {
+		b, ok := strings.CutSuffix(base, "r")
+		if !ok || b == "e" || nasals[b] || !validSyllables[b] {
+			return 0, false, false
+		}
+		base = b
+		erhua = true
+		cost++
+	}
+	if withinWord && (NeedsApostrophe(base) || nasals[base]) {
+		cost += 2
+	}
+	return cost, erhua, true
+}
+
+func countTones(tones []rune) int {
+	n := 0
+	for _, t := range tones {
+		if t != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// isSyllable tests whether a lower case syllable is valid without erhua
+func isSyllable(s string) bool {
+	return validSyllables[jqxyUmlaut.Replace(s)]
+}
@@ -0,0 +1,161 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Unit tests for the pinyin package
+package pinyin
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParse tests parsing of the different ways of writing pinyin
+func TestParse(t *testing.T) {
+	type test struct {
+		input     string
+		expectErr bool
+		expect    []Syllable
+	}
+	nihao := []Syllable{{Plain: "ni", Tone: 3}, {Plain: "hao", Tone: 3}}
+	tests := []test{
+		{input: "ni3 hao3", expect: nihao},
+		{input: "ni3hao3", expect: nihao},
+		{input: "nǐhǎo", expect: nihao},
+		{input: "nǐ hǎo", expect: nihao},
+		{input: "ni hao", expect: []Syllable{{Plain: "ni"}, {Plain: "hao"}}},
+		{input: "lv4", expect: []Syllable{{Plain: "lü", Tone: 4}}},
+		{input: "lu:4", expect: []Syllable{{Plain: "lü", Tone: 4}}},
+		{input: "lǜ", expect: []Syllable{{Plain: "lü", Tone: 4}}},
+		{input: "jü", expect: []Syllable{{Plain: "ju"}}},
+		{input: "Xī'ān", expect: []Syllable{{Plain: "xi", Tone: 1, Upper: true}, {Plain: "an", Tone: 1}}},
+		{input: "huār", expect: []Syllable{{Plain: "hua", Tone: 1, Erhua: true}}},
+		{input: "shǐrán", expect: []Syllable{{Plain: "shi", Tone: 3}, {Plain: "ran", Tone: 2}}},
+		{input: "ér", expect: []Syllable{{Plain: "er", Tone: 2}}},
+		{input: "", expectErr: true},
+		{input: "ABC", expectErr: true},
+		{input: "hello", expectErr: true},
+	}
+	for _, tc := range tests {
+		got, err := Parse(tc.input)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("Parse(%q): expected an error, got %v", tc.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", tc.input, err)
+			continue
+		}
+		if len(got) != len(tc.expect) {
+			t.Errorf("Parse(%q): expected %v, got %v", tc.input, tc.expect, got)
+			continue
+		}
+		for i, s := range tc.expect {
+			if got[i] != s {
+				t.Errorf("Parse(%q): syllable %d expected %v, got %v", tc.input, i, s, got[i])
+			}
+		}
+	}
+}
+
+// TestToMarked tests conversion of tone numbers to tone marks
+func TestToMarked(t *testing.T) {
+	tests := map[string]string{
+		"zhong1 guo2": "zhōng guó",
+		"zhong1guo2":  "zhōngguó",
+		"gou3":        "gǒu",
+		"liu2":        "liú",
+		"gui4":        "guì",
+		"nu:3":        "nǚ",
+		"nv3":         "nǚ",
+		"ma5":         "ma",
+		"Xi1an1":      "Xī'ān",
+		"hua1r":       "huār",
+		"xx5":         "xx",
+		"nǐhǎo":       "nǐhǎo",
+	}
+	for input, expect := range tests {
+		if got := ToMarked(input); got != expect {
+			t.Errorf("ToMarked(%s): expected %s, got %s", input, expect, got)
+		}
+	}
+}
+
+// TestToNumbered tests conversion of tone marks to numbered pinyin
+func TestToNumbered(t *testing.T) {
+	type test struct {
+		input      string
+		nSyllables int
+		expect     string
+	}
+	tests := []test{
+		{"hànyǔ", 2, "han4 yu3"},
+		{"Hànyǔ", 0, "Han4 yu3"},
+		{"xiān", 1, "xian1"},
+		{"xīān", 2, "xi1 an1"},
+		{"xiān", 2, "xian1"},
+		{"zhōngguó", 3, "zhong1 guo2"},
+		{"Běijīng", 3, "Bei3 jing1"},
+		{"Xī'ān", 2, "Xi1 an1"},
+		{"lǜsè", 2, "lu:4 se4"},
+		{"le", 1, "le5"},
+		{"zhōngguó rén", 3, "zhong1 guo2 ren2"},
+		{"píngdì", 2, "ping2 di4"},
+		{"huār", 2, "hua1 r5"},
+		{"ABC", 3, "ABC"},
+	}
+	for _, tc := range tests {
+		if got := ToNumbered(tc.input, tc.nSyllables); got != tc.expect {
+			t.Errorf("ToNumbered(%s, %d): expected %s, got %s", tc.input,
+				tc.nSyllables, tc.expect, got)
+		}
+	}
+}
+
+// TestNormalize tests that different ways of writing the same pinyin match
+func TestNormalize(t *testing.T) {
+	tests := map[string][]string{
+		"nihao": {"ni3 hao3", "nǐhǎo", "Nǐ hǎo", "ni hao", "ni3hao3"},
+		"lvse":  {"lǜsè", "lv4 se4", "lu:4 se4", "lü se"},
+		"xian":  {"Xī'ān", "xi1 an1", "xiān"},
+		"nver":  {"Nǚ'ér", "nu:3 er2", "nv er"},
+		"huar":  {"huār", "hua1 r5"},
+		"ju":    {"jü", "jv", "ju"},
+	}
+	for expect, inputs := range tests {
+		for _, input := range inputs {
+			if got := Normalize(input); got != expect {
+				t.Errorf("Normalize(%s): expected %s, got %s", input, expect, got)
+			}
+		}
+	}
+}
+
+// TestSegment tests splitting of pinyin without spaces
+func TestSegment(t *testing.T) {
+	tests := map[string]string{
+		"nihao":                   "ni hao",
+		"zhonghuarenmingongheguo": "zhong hua ren min gong he guo",
+		"beijing":                 "bei jing",
+		"lvse":                    "lü se",
+		"yidianr":                 "yi dianr",
+	}
+	for input, expect := range tests {
+		if got := strings.Join(Segment(input), " "); got != expect {
+			t.Errorf("Segment(%s): expected %s, got %s", input, expect, got)
+		}
+	}
+	if got := Segment("hello"); got != nil {
+		t.Errorf("Segment(hello): expected nil, got %v", got)
+	}
+}
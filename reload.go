@@ -0,0 +1,225 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Reloading of the dictionary and indexes without restarting the server
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexamies/chinesenotes-go/config"
+	"github.com/alexamies/chinesenotes-go/dictedit"
+)
+
+var (
+	// Guards the global backends b
+	backendsMu sync.RWMutex
+
+	// Only one reload runs at a time
+	reloadMu sync.Mutex
+
+	// Held while adding to the sentence translation memory and while copying
+	// it to new backends, so that no import is lost in a reload
+	segmentsMu sync.Mutex
+)
+
+// getBackends gets the current backends. Handlers should call this once and
+// use the snapshot for the whole request, so that a reload completing during
+// the request does not mix old and new data.
+func getBackends() *backends {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	return b
+}
+
+// setBackends swaps in new backends
+func setBackends(bends *backends) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	b = bends
+}
+
+// reloadBackends builds new backends and swaps them in when complete. Requests
+// in progress, and any that start before the swap, keep using the old ones.
+// Translation clients, the authenticator and the dictionary edit store are
+// carried over from the old backends, see carryOver. Sentences in the old translation memory are added to the new one,
+// so that those imported since the last load are kept.
+func reloadBackends(ctx context.Context) (*backends, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	start := time.Now()
+	bends, err := initApp(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reloadBackends: %v", err)
+	}
+	segmentsMu.Lock()
+	if old := getBackends(); old != nil {
+		carryOver(old, bends)
+	} else {
+		initTranslationClients(bends)
+	}
+	setBackends(bends)
+	segmentsMu.Unlock()
+	log.Printf("reloadBackends: reloaded in %d millis with %d dictionary entries",
+		time.Since(start).Milliseconds(), len(bends.dict.Wdict))
+	return bends, nil
+}
+
+// carryOver copies the backends that do not depend on the files reloaded from
+// the old backends to the new ones. The post-editor is rebuilt, since it
+// tokenizes with the new dictionary. The caller must hold segmentsMu until
+// the new backends are swapped in.
+func carryOver(old, bends *backends) {
+	bends.deepLApiClient = old.deepLApiClient
+	bends.translateApiClient = old.translateApiClient
+	bends.glossaryApiClient = old.glossaryApiClient
+	bends.translationProcessor = old.translationProcessor
+	initPostEditor(bends)
+	if a := loadedAuthenticator(old); a != nil {
+		bends.authenticator = a
+	}
+	if old.dictEdit != nil {
+		// Keep the proposals but give new senses ids above the new dictionary's
+		bends.dictEdit = old.dictEdit
+		if a, ok := bends.dictEdit.(dictedit.IdAllocator); ok && bends.dict != nil {
+			a.SetFirstId(firstNewId(bends.dict))
+		}
+	}
+	if old.segmentStore != nil && bends.segmentStore != nil {
+		bends.segmentStore.Add(old.segmentStore.Segments()...)
+	}
+}
+
+// reloadHandler reloads the dictionary and indexes. The request must be a
+// POST, either with the reload token as a bearer token or, for a password
+// protected site, from a user with the admin role.
+func reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	b := getBackends()
+	if !reloadAuthorized(r, b) {
+		http.Error(w, "Not authorized", http.StatusForbidden)
+		return
+	}
+	ctx := context.Background()
+	bends, err := reloadBackends(ctx)
+	if err != nil {
+		log.Printf("reloadHandler: error reloading, keeping old data: %v", err)
+		http.Error(w, "Reload failed", http.StatusInternalServerError)
+		return
+	}
+	sendJSON(w, struct {
+		Message string
+		Entries int
+	}{
+		Message: "Reloaded",
+		Entries: len(bends.dict.Wdict),
+	})
+}
+
+// reloadAuthorized checks the reload token or the session of an admin user
+func reloadAuthorized(r *http.Request, b *backends) bool {
+	if b == nil {
+		return false
+	}
+	token := b.webConfig.GetReloadToken()
+	auth := r.Header.Get("Authorization")
+	if len(token) > 0 && strings.HasPrefix(auth, "Bearer ") {
+		given := strings.TrimPrefix(auth, "Bearer ")
+		return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+	}
+	authenticator := loadedAuthenticator(b)
+	if !config.PasswordProtected() || authenticator == nil {
+		return false
+	}
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		return false
+	}
+	sessionInfo := authenticator.CheckSession(r.Context(), cookie.Value)
+	return sessionInfo.Valid && sessionInfo.User.Role == "admin"
+}
+
+// watchFiles lists the local files that the backends are loaded from
+func watchFiles(b *backends) []string {
+	fNames := append([]string{}, b.appConfig.LUFileNames...)
+	fNames = append(fNames, b.appConfig.CorpusDataDir()+"/"+colFileName)
+	fNames = append(fNames, b.appConfig.IndexDir()+"/"+titleIndexFN)
+	fNames = append(fNames, b.appConfig.IndexDir()+"/"+keywordIndexFN)
+	return fNames
+}
+
+// modTimes gets the modification times of the files, skipping missing files
+func modTimes(fNames []string) map[string]time.Time {
+	times := make(map[string]time.Time)
+	for _, fName := range fNames {
+		if fi, err := os.Stat(fName); err == nil {
+			times[fName] = fi.ModTime()
+		}
+	}
+	return times
+}
+
+// changed tests whether any file has been added, removed, or modified
+func changed(before, after map[string]time.Time) bool {
+	if len(before) != len(after) {
+		return true
+	}
+	for fName, t := range after {
+		if prev, ok := before[fName]; !ok || !prev.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// pollForChanges checks the dictionary and index files at the given interval
+// and reloads when they change, until the context is done
+func pollForChanges(ctx context.Context, interval time.Duration) {
+	log.Printf("pollForChanges: checking for changes every %v", interval)
+	b := getBackends()
+	if b == nil {
+		log.Println("pollForChanges: backends not initialized, not polling")
+		return
+	}
+	before := modTimes(watchFiles(b))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			after := modTimes(watchFiles(getBackends()))
+			if !changed(before, after) {
+				continue
+			}
+			log.Println("pollForChanges: files changed, reloading")
+			if _, err := reloadBackends(ctx); err != nil {
+				log.Printf("pollForChanges: error reloading, keeping old data: %v", err)
+				continue
+			}
+			before = after
+		}
+	}
+}
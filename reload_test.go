@@ -0,0 +1,221 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexamies/chinesenotes-go/config"
+	"github.com/alexamies/chinesenotes-go/dictedit"
+	"github.com/alexamies/chinesenotes-go/dictionary"
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+	"github.com/alexamies/chinesenotes-go/transmemory"
+)
+
+// TestSetBackends tests that a snapshot taken before a swap is unchanged
+func TestSetBackends(t *testing.T) {
+	old := &backends{appConfig: config.AppConfig{ProjectHome: "old"}}
+	setBackends(old)
+	snapshot := getBackends()
+	setBackends(&backends{appConfig: config.AppConfig{ProjectHome: "new"}})
+	if snapshot.appConfig.ProjectHome != "old" {
+		t.Errorf("TestSetBackends: snapshot changed to %s", snapshot.appConfig.ProjectHome)
+	}
+	if got := getBackends().appConfig.ProjectHome; got != "new" {
+		t.Errorf("TestSetBackends: expected new backends, got %s", got)
+	}
+}
+
+// TestReloadHandler tests authorization of the reload endpoint
+func TestReloadHandler(t *testing.T) {
+	os.Setenv("RELOAD_TOKEN", "secret")
+	defer os.Unsetenv("RELOAD_TOKEN")
+	setBackends(&backends{
+		webConfig: config.WebAppConfig{ConfigVars: map[string]string{}},
+	})
+	type test struct {
+		name       string
+		method     string
+		auth       string
+		wantStatus int
+	}
+	tests := []test{
+		{
+			name:       "Wrong method",
+			method:     http.MethodGet,
+			auth:       "Bearer secret",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "No token",
+			method:     http.MethodPost,
+			auth:       "",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Wrong token",
+			method:     http.MethodPost,
+			auth:       "Bearer guess",
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest(tc.method, "/loggedin/admin/reload", nil)
+		if len(tc.auth) > 0 {
+			r.Header.Set("Authorization", tc.auth)
+		}
+		w := httptest.NewRecorder()
+		reloadHandler(w, r)
+		if w.Code != tc.wantStatus {
+			t.Errorf("TestReloadHandler %s: got status %d, want %d", tc.name, w.Code, tc.wantStatus)
+		}
+	}
+}
+
+// TestCarryOver tests that the post-editor is rebuilt for the new backends and
+// imported sentences are kept
+func TestCarryOver(t *testing.T) {
+	fName := filepath.Join(t.TempDir(), "preferred.csv")
+	if err := os.WriteFile(fName, []byte("觀音,,Avalokitesvara\n"), 0644); err != nil {
+		t.Fatalf("TestCarryOver: could not write glossary: %v", err)
+	}
+	webConfig := config.WebAppConfig{
+		ConfigVars: map[string]string{"PostEditGlossaryFile": fName},
+	}
+	wdict := map[string]*dicttypes.Word{
+		"觀音": {Simplified: "观音", Traditional: "觀音", HeadwordId: 1},
+	}
+	old := &backends{
+		webConfig:    webConfig,
+		dict:         dictionary.NewDictionary(wdict),
+		segmentStore: transmemory.NewSegmentStore(),
+	}
+	old.segmentStore.Add(transmemory.Segment{Source: "学而时习之", Target: "To learn"})
+	initPostEditor(old)
+	if old.postEditor == nil {
+		t.Fatal("TestCarryOver: post-editor not initialized")
+	}
+	bends := &backends{
+		webConfig:    webConfig,
+		dict:         dictionary.NewDictionary(wdict),
+		segmentStore: transmemory.NewSegmentStore(),
+	}
+	carryOver(old, bends)
+	if bends.segmentStore.Size() != 1 {
+		t.Errorf("TestCarryOver: got %d segments, want 1", bends.segmentStore.Size())
+	}
+	if bends.postEditor == nil {
+		t.Fatal("TestCarryOver: post-editor lost on reload")
+	}
+	got := bends.postEditor.PostEdit("觀音", "Guanyin", "")
+	if len(got.Notes) != 1 {
+		t.Errorf("TestCarryOver: got notes %v, want 1", got.Notes)
+	}
+}
+
+// TestCarryOverDictEdit tests that new senses proposed after a reload get ids
+// above those in the new dictionary
+func TestCarryOverDictEdit(t *testing.T) {
+	ctx := context.Background()
+	oldDict := dictionary.NewDictionary(map[string]*dicttypes.Word{
+		"中文": {Simplified: "中文", HeadwordId: 10,
+			Senses: []dicttypes.WordSense{{Id: 10, HeadwordId: 10}}},
+	})
+	store, err := dictedit.NewFileStore(t.TempDir(), firstNewId(oldDict))
+	if err != nil {
+		t.Fatalf("TestCarryOverDictEdit: unexpected error: %v", err)
+	}
+	old := &backends{dict: oldDict, dictEdit: store}
+	newDict := dictionary.NewDictionary(map[string]*dicttypes.Word{
+		"汉字": {Simplified: "汉字", HeadwordId: 20,
+			Senses: []dicttypes.WordSense{{Id: 20, HeadwordId: 20}}},
+	})
+	bends := &backends{dict: newDict}
+	carryOver(old, bends)
+	if bends.dictEdit != store {
+		t.Fatal("TestCarryOverDictEdit: proposals not carried over")
+	}
+	p, err := bends.dictEdit.Propose(ctx, dictedit.Proposal{
+		Kind:  dictedit.KindNew,
+		Sense: dicttypes.WordSense{Simplified: "错字", English: "typo"},
+	})
+	if err != nil {
+		t.Fatalf("TestCarryOverDictEdit: unexpected error proposing: %v", err)
+	}
+	approved, err := bends.dictEdit.Approve(ctx, p.Id, "carol", "")
+	if err != nil {
+		t.Fatalf("TestCarryOverDictEdit: unexpected error approving: %v", err)
+	}
+	if approved.Sense.Id != 21 {
+		t.Errorf("TestCarryOverDictEdit: got id %d, want 21", approved.Sense.Id)
+	}
+}
+
+// TestCarryOverAuthenticator tests that the authenticator can be read while it
+// is carried over to new backends
+func TestCarryOverAuthenticator(t *testing.T) {
+	old := &backends{authenticator: roleAuthenticatorMock{role: "admin"}}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := getAuthenticator(context.Background(), old); err != nil {
+				t.Errorf("TestCarryOverAuthenticator: unexpected error: %v", err)
+			}
+		}()
+	}
+	bends := &backends{}
+	carryOver(old, bends)
+	wg.Wait()
+	if loadedAuthenticator(bends) == nil {
+		t.Error("TestCarryOverAuthenticator: authenticator not carried over")
+	}
+}
+
+// TestChanged tests detection of changed files
+func TestChanged(t *testing.T) {
+	dir := t.TempDir()
+	fName := filepath.Join(dir, "words.txt")
+	if err := os.WriteFile(fName, []byte("1"), 0644); err != nil {
+		t.Fatalf("TestChanged: could not write file: %v", err)
+	}
+	missing := filepath.Join(dir, "missing.txt")
+	before := modTimes([]string{fName, missing})
+	if len(before) != 1 {
+		t.Fatalf("TestChanged: expected 1 file, got %v", before)
+	}
+	if changed(before, modTimes([]string{fName, missing})) {
+		t.Error("TestChanged: no change expected")
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(fName, later, later); err != nil {
+		t.Fatalf("TestChanged: could not change time: %v", err)
+	}
+	if !changed(before, modTimes([]string{fName, missing})) {
+		t.Error("TestChanged: expected modification to be detected")
+	}
+	if err := os.WriteFile(missing, []byte("2"), 0644); err != nil {
+		t.Fatalf("TestChanged: could not write file: %v", err)
+	}
+	if !changed(before, modTimes([]string{fName, missing})) {
+		t.Error("TestChanged: expected new file to be detected")
+	}
+}
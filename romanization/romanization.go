@@ -0,0 +1,329 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for romanizations other than Hanyu pinyin. Zhuyin (bopomofo) and
+// Wade-Giles are derived from the pinyin in the dictionary. Cantonese Jyutping
+// cannot be derived from Mandarin and is taken from the optional jyutping
+// column of the dictionary.
+package romanization
+
+import (
+	"strings"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+	"github.com/alexamies/chinesenotes-go/pinyin"
+)
+
+// Names of the romanization systems
+const (
+	Pinyin    = "pinyin"
+	Zhuyin    = "zhuyin"
+	WadeGiles = "wadegiles"
+	Jyutping  = "jyutping"
+)
+
+// Systems lists the romanization systems supported, pinyin first
+var Systems = []string{Pinyin, Zhuyin, WadeGiles, Jyutping}
+
+// IsValid tests whether the name is a supported romanization system
+func IsValid(system string) bool {
+	for _, s := range Systems {
+		if s == system {
+			return true
+		}
+	}
+	return false
+}
+
+// Convert converts pinyin, eg nǐhǎo, to the given system, either Zhuyin or
+// Wade-Giles. Pinyin is returned unchanged for other systems.
+func Convert(p, system string) string {
+	switch system {
+	case Zhuyin:
+		return ToZhuyin(p)
+	case WadeGiles:
+		return ToWadeGiles(p)
+	}
+	return p
+}
+
+// Romanize gives the romanization of a word sense in the given system, empty
+// for Jyutping if there is none in the dictionary
+func Romanize(ws dicttypes.WordSense, system string) string {
+	if system == Jyutping {
+		if ws.Jyutping == "\\N" {
+			return ""
+		}
+		return ws.Jyutping
+	}
+	return Convert(ws.Pinyin, system)
+}
+
+// ApplyWord gives a copy of the word with the pinyin of the headword and each
+// sense replaced by the given romanization
+func ApplyWord(w dicttypes.Word, system string) dicttypes.Word {
+	if system == Pinyin || !IsValid(system) {
+		return w
+	}
+	senses := make([]dicttypes.WordSense, len(w.Senses))
+	for i, ws := range w.Senses {
+		senses[i] = ApplySense(ws, system)
+	}
+	r := w
+	r.Senses = senses
+	if system == Jyutping {
+		r.Pinyin = ""
+		for _, ws := range senses {
+			if len(ws.Pinyin) > 0 {
+				r.Pinyin = ws.Pinyin
+				break
+			}
+		}
+		return r
+	}
+	r.Pinyin = Convert(w.Pinyin, system)
+	return r
+}
+
+// ApplySense gives a copy of the word sense with the pinyin replaced by the
+// given romanization
+func ApplySense(ws dicttypes.WordSense, system string) dicttypes.WordSense {
+	if system == Pinyin || !IsValid(system) || ws.Pinyin == "\\N" {
+		return ws
+	}
+	ws.Pinyin = Romanize(ws, system)
+	return ws
+}
+
+// Zhuyin symbols for pinyin initials
+var zhuyinInitials = map[string]string{
+	"b": "ㄅ", "p": "ㄆ", "m": "ㄇ", "f": "ㄈ",
+	"d": "ㄉ", "t": "ㄊ", "n": "ㄋ", "l": "ㄌ",
+	"g": "ㄍ", "k": "ㄎ", "h": "ㄏ",
+	"j": "ㄐ", "q": "ㄑ", "x": "ㄒ",
+	"zh": "ㄓ", "ch": "ㄔ", "sh": "ㄕ", "r": "ㄖ",
+	"z": "ㄗ", "c": "ㄘ", "s": "ㄙ",
+}
+
+// Zhuyin symbols for pinyin finals, written as after a consonant
+var zhuyinFinals = map[string]string{
+	"a": "ㄚ", "o": "ㄛ", "e": "ㄜ", "ê": "ㄝ",
+	"ai": "ㄞ", "ei": "ㄟ", "ao": "ㄠ", "ou": "ㄡ",
+	"an": "ㄢ", "en": "ㄣ", "ang": "ㄤ", "eng": "ㄥ", "ong": "ㄨㄥ", "er": "ㄦ",
+	"i": "ㄧ", "ia": "ㄧㄚ", "io": "ㄧㄛ", "ie": "ㄧㄝ", "iao": "ㄧㄠ", "iu": "ㄧㄡ",
+	"ian": "ㄧㄢ", "in": "ㄧㄣ", "iang": "ㄧㄤ", "ing": "ㄧㄥ", "iong": "ㄩㄥ",
+	"u": "ㄨ", "ua": "ㄨㄚ", "uo": "ㄨㄛ", "uai": "ㄨㄞ", "ui": "ㄨㄟ",
+	"uan": "ㄨㄢ", "un": "ㄨㄣ", "uang": "ㄨㄤ", "ueng": "ㄨㄥ",
+	"ü": "ㄩ", "üe": "ㄩㄝ", "üan": "ㄩㄢ", "ün": "ㄩㄣ",
+	"m": "ㄇ", "n": "ㄋ", "ng": "ㄫ",
+}
+
+// Zhuyin tone marks, indexed by tone, with the neutral tone written before
+var zhuyinTones = []string{"", "", "ˊ", "ˇ", "ˋ", "˙"}
+
+// Syllables spelled with y or w and the finals they are written for
+var ywFinals = map[string]string{
+	"yi": "i", "ya": "ia", "yo": "io", "ye": "ie", "yao": "iao", "you": "iu",
+	"yan": "ian", "yin": "in", "yang": "iang", "ying": "ing", "yong": "iong",
+	"yu": "ü", "yue": "üe", "yuan": "üan", "yun": "ün",
+	"wu": "u", "wa": "ua", "wo": "uo", "wai": "uai", "wei": "ui",
+	"wan": "uan", "wen": "un", "wang": "uang", "weng": "ueng",
+}
+
+// splitSyllable splits a pinyin syllable without tone into initial and final,
+// with the final written as after a consonant, eg yue to "" and üe and ju to
+// j and ü
+func splitSyllable(plain string) (string, string) {
+	if f, ok := ywFinals[plain]; ok {
+		return "", f
+	}
+	if plain == "hm" || plain == "hng" {
+		return "h", plain[1:]
+	}
+	initial := ""
+	for _, i := range []string{"zh", "ch", "sh"} {
+		if strings.HasPrefix(plain, i) {
+			initial = i
+			break
+		}
+	}
+	if initial == "" && len(plain) > 1 {
+		if _, ok := zhuyinInitials[plain[:1]]; ok {
+			initial = plain[:1]
+		}
+	}
+	final := plain[len(initial):]
+	if initial == "j" || initial == "q" || initial == "x" {
+		if strings.HasPrefix(final, "u") {
+			final = "ü" + final[1:]
+		}
+	}
+	return initial, final
+}
+
+// ToZhuyin converts pinyin to Zhuyin, with a space between syllables, eg nǐhǎo
+// to ㄋㄧˇ ㄏㄠˇ. Words that are not pinyin are kept unchanged.
+func ToZhuyin(p string) string {
+	return convertWords(p, " ", zhuyinSyllable)
+}
+
+// zhuyinSyllable converts a single syllable
+func zhuyinSyllable(s pinyin.Syllable) string {
+	initial, final := splitSyllable(s.Plain)
+	var b strings.Builder
+	if s.Tone == 5 {
+		b.WriteString(zhuyinTones[5])
+	}
+	b.WriteString(zhuyinInitials[initial])
+	switch {
+	case isApical(initial) && final == "i":
+		// The vowel in zhi, chi, shi, ri, zi, ci, and si is not written
+	default:
+		b.WriteString(zhuyinFinals[final])
+	}
+	if s.Erhua {
+		b.WriteString("ㄦ")
+	}
+	if s.Tone > 1 && s.Tone < 5 {
+		b.WriteString(zhuyinTones[s.Tone])
+	}
+	return b.String()
+}
+
+// isApical tests whether the initial is one after which i is not the vowel i
+func isApical(initial string) bool {
+	switch initial {
+	case "zh", "ch", "sh", "r", "z", "c", "s":
+		return true
+	}
+	return false
+}
+
+// Wade-Giles for pinyin initials
+var wgInitials = map[string]string{
+	"b": "p", "p": "p'", "m": "m", "f": "f",
+	"d": "t", "t": "t'", "n": "n", "l": "l",
+	"g": "k", "k": "k'", "h": "h",
+	"j": "ch", "q": "ch'", "x": "hs",
+	"zh": "ch", "ch": "ch'", "sh": "sh", "r": "j",
+	"z": "ts", "c": "ts'", "s": "s",
+}
+
+// Wade-Giles for pinyin finals that are spelled differently
+var wgFinals = map[string]string{
+	"e":    "ê",
+	"en":   "ên",
+	"eng":  "êng",
+	"ie":   "ieh",
+	"ian":  "ien",
+	"ong":  "ung",
+	"iong": "iung",
+	"uo":   "o",
+	"üe":   "üeh",
+	"er":   "êrh",
+	"ueng": "ung",
+}
+
+// Wade-Giles for whole syllables that do not follow from the parts
+var wgSyllables = map[string]string{
+	"zhi":  "chih",
+	"chi":  "ch'ih",
+	"shi":  "shih",
+	"ri":   "jih",
+	"zi":   "tzŭ",
+	"ci":   "tz'ŭ",
+	"si":   "ssŭ",
+	"ge":   "ko",
+	"ke":   "k'o",
+	"he":   "ho",
+	"guo":  "kuo",
+	"kuo":  "k'uo",
+	"huo":  "huo",
+	"gui":  "kuei",
+	"kui":  "k'uei",
+	"hui":  "huei",
+	"shuo": "shuo",
+	"yi":   "i",
+	"you":  "yu",
+	"yan":  "yen",
+	"ye":   "yeh",
+	"yong": "yung",
+	"yu":   "yü",
+	"yue":  "yüeh",
+	"yuan": "yüan",
+	"yun":  "yün",
+	"wo":   "wo",
+	"wen":  "wên",
+	"weng": "wêng",
+}
+
+// Superscript tone numbers for Wade-Giles, indexed by tone
+var wgTones = []string{"", "¹", "²", "³", "⁴", ""}
+
+// ToWadeGiles converts pinyin to Wade-Giles, with hyphens between syllables
+// of a word and superscript tone numbers, eg Běijīng to Pei³-ching¹. Words
+// that are not pinyin are kept unchanged.
+func ToWadeGiles(p string) string {
+	return convertWords(p, "-", wadeGilesSyllable)
+}
+
+// wadeGilesSyllable converts a single syllable
+func wadeGilesSyllable(s pinyin.Syllable) string {
+	wg, ok := wgSyllables[s.Plain]
+	if !ok {
+		initial, final := splitSyllable(s.Plain)
+		if strings.HasPrefix(s.Plain, "y") || strings.HasPrefix(s.Plain, "w") {
+			initial, final = s.Plain[:1], s.Plain[1:]
+		}
+		if f, ok := wgFinals[final]; ok {
+			final = f
+		}
+		if i, ok := wgInitials[initial]; ok {
+			initial = i
+		}
+		wg = initial + final
+	}
+	if s.Upper {
+		wg = strings.ToUpper(wg[:1]) + wg[1:]
+	}
+	wg += wgTones[s.Tone]
+	if s.Erhua {
+		wg += "-êrh"
+	}
+	return wg
+}
+
+// convertWords converts each space separated word of pinyin, joining the
+// syllables of a word with the separator given
+func convertWords(p, sep string, convert func(pinyin.Syllable) string) string {
+	words := strings.Fields(p)
+	for i, word := range words {
+		syllables, err := pinyin.Parse(word)
+		if err != nil {
+			continue
+		}
+		// In a word with tone marks, a syllable without one is in neutral tone
+		marked := false
+		for _, s := range syllables {
+			marked = marked || s.Tone > 0
+		}
+		converted := make([]string, len(syllables))
+		for j, s := range syllables {
+			if marked && s.Tone == 0 {
+				s.Tone = 5
+			}
+			converted[j] = convert(s)
+		}
+		words[i] = strings.Join(converted, sep)
+	}
+	return strings.Join(words, " ")
+}
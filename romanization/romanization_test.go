@@ -0,0 +1,143 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Unit tests for the romanization package
+package romanization
+
+import (
+	"testing"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+)
+
+// TestToZhuyin tests conversion of pinyin to Zhuyin
+func TestToZhuyin(t *testing.T) {
+	tests := map[string]string{
+		"nǐhǎo":    "ㄋㄧˇ ㄏㄠˇ",
+		"ni3 hao3": "ㄋㄧˇ ㄏㄠˇ",
+		"zhōngguó": "ㄓㄨㄥ ㄍㄨㄛˊ",
+		"shì":      "ㄕˋ",
+		"zìjǐ":     "ㄗˋ ㄐㄧˇ",
+		"xué":      "ㄒㄩㄝˊ",
+		"yuè":      "ㄩㄝˋ",
+		"wǒmen":    "ㄨㄛˇ ˙ㄇㄣ",
+		"lǜsè":     "ㄌㄩˋ ㄙㄜˋ",
+		"huār":     "ㄏㄨㄚㄦ",
+		"ér":       "ㄦˊ",
+		"Běijīng":  "ㄅㄟˇ ㄐㄧㄥ",
+		"yīdiǎnr":  "ㄧ ㄉㄧㄢㄦˇ",
+		"ABC":      "ABC",
+		"kǎ'ěr OK": "ㄎㄚˇ ㄦˇ OK",
+	}
+	for input, expect := range tests {
+		if got := ToZhuyin(input); got != expect {
+			t.Errorf("ToZhuyin(%s): expected %s, got %s", input, expect, got)
+		}
+	}
+}
+
+// TestToWadeGiles tests conversion of pinyin to Wade-Giles
+func TestToWadeGiles(t *testing.T) {
+	tests := map[string]string{
+		"Běijīng":    "Pei³-ching¹",
+		"Máo Zédōng": "Mao² Tsê²-tung¹",
+		"zhōngguó":   "chung¹-kuo²",
+		"Táiběi":     "T'ai²-pei³",
+		"xiè":        "hsieh⁴",
+		"qián":       "ch'ien²",
+		"rén":        "jên²",
+		"shì":        "shih⁴",
+		"sì":         "ssŭ⁴",
+		"cí":         "tz'ŭ²",
+		"yǔ":         "yü³",
+		"xué":        "hsüeh²",
+		"gè":         "ko⁴",
+		"duì":        "tui⁴",
+		"shuǐ":       "shui³",
+		"zuì":        "tsui⁴",
+		"chuí":       "ch'ui²",
+		"guì":        "kuei⁴",
+		"kuì":        "k'uei⁴",
+		"huì":        "huei⁴",
+		"ma":         "ma",
+		"huār":       "hua¹-êrh",
+		"Xī'ān":      "Hsi¹-an¹",
+		"hello 1":    "hello 1",
+	}
+	for input, expect := range tests {
+		if got := ToWadeGiles(input); got != expect {
+			t.Errorf("ToWadeGiles(%s): expected %s, got %s", input, expect, got)
+		}
+	}
+}
+
+// TestApplyWord tests replacing the pinyin of a word
+func TestApplyWord(t *testing.T) {
+	ws1 := dicttypes.WordSense{
+		Id:          8422,
+		HeadwordId:  8422,
+		Simplified:  "汉语",
+		Traditional: "漢語",
+		Pinyin:      "hànyǔ",
+		English:     "Chinese language",
+		Jyutping:    "hon3 jyu5",
+	}
+	w := dicttypes.Word{
+		Simplified:  "汉语",
+		Traditional: "漢語",
+		Pinyin:      "hànyǔ",
+		HeadwordId:  8422,
+		Senses:      []dicttypes.WordSense{ws1},
+	}
+	type test struct {
+		system string
+		expect string
+	}
+	tests := []test{
+		{Pinyin, "hànyǔ"},
+		{Zhuyin, "ㄏㄢˋ ㄩˇ"},
+		{WadeGiles, "han⁴-yü³"},
+		{Jyutping, "hon3 jyu5"},
+		{"unknown", "hànyǔ"},
+	}
+	for _, tc := range tests {
+		got := ApplyWord(w, tc.system)
+		if got.Pinyin != tc.expect {
+			t.Errorf("ApplyWord(%s): expected %s, got %s", tc.system, tc.expect,
+				got.Pinyin)
+		}
+		if got.Senses[0].Pinyin != tc.expect {
+			t.Errorf("ApplyWord(%s): sense expected %s, got %s", tc.system,
+				tc.expect, got.Senses[0].Pinyin)
+		}
+	}
+	if w.Senses[0].Pinyin != "hànyǔ" {
+		t.Errorf("ApplyWord: modified the original, got %s", w.Senses[0].Pinyin)
+	}
+	ws1.Jyutping = ""
+	w.Senses = []dicttypes.WordSense{ws1}
+	if got := ApplyWord(w, Jyutping); got.Pinyin != "" {
+		t.Errorf("ApplyWord: expected no Jyutping, got %s", got.Pinyin)
+	}
+}
+
+// TestIsValid tests checking the name of a romanization system
+func TestIsValid(t *testing.T) {
+	for _, s := range Systems {
+		if !IsValid(s) {
+			t.Errorf("IsValid(%s): expected true", s)
+		}
+	}
+	if IsValid("") || IsValid("Zhuyin") {
+		t.Error("IsValid: expected false for unknown system")
+	}
+}
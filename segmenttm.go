@@ -0,0 +1,254 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/alexamies/chinesenotes-go/bibnotes"
+	"github.com/alexamies/chinesenotes-go/config"
+	"github.com/alexamies/chinesenotes-go/find"
+	"github.com/alexamies/chinesenotes-go/httphandling"
+	"github.com/alexamies/chinesenotes-go/tokenizer"
+	"github.com/alexamies/chinesenotes-go/transmemory"
+)
+
+const (
+	bibNotesFile2RefFN   = "ref_no_file_map.csv"
+	bibNotesParallelFN   = "parallels.csv"
+	bibNotesTransFN      = "english_translations.csv"
+	defSegmentMinPercent = 50
+	maxSegmentMatches    = 10
+)
+
+// segmentTMResults holds prior translations of sentences similar to a query
+type segmentTMResults struct {
+	Query      string
+	MinPercent int
+	Matches    []transmemory.SegmentMatch
+}
+
+// loadSegmentStore loads aligned sentences from the TMX file, the parallel
+// translations in the bibliographic notes of the documents, and the file of
+// imported translations. The store is empty if none is configured, so that
+// translations can be imported.
+func loadSegmentStore(webConfig config.WebAppConfig, docMap map[string]find.DocInfo) transmemory.SegmentStore {
+	tmxFile := webConfig.SegmentTMXFile()
+	bibNotesDir := webConfig.BibNotesDir()
+	store := transmemory.NewSegmentStore()
+	if len(tmxFile) > 0 {
+		f, err := os.Open(tmxFile)
+		if err != nil {
+			log.Printf("loadSegmentStore, non-fatal error, cannot open %s: %v", tmxFile, err)
+		} else {
+			segments, err := transmemory.LoadTMX(f, filepath.Base(tmxFile))
+			f.Close()
+			if err != nil {
+				log.Printf("loadSegmentStore, non-fatal error: %v", err)
+			}
+			store.Add(segments...)
+		}
+	}
+	if importFile := webConfig.SegmentImportFile(); len(importFile) > 0 {
+		segments, err := loadImports(importFile)
+		if err != nil {
+			log.Printf("loadSegmentStore, non-fatal error: %v", err)
+		}
+		store.Add(segments...)
+	}
+	if len(bibNotesDir) > 0 {
+		client, err := loadBibNotes(bibNotesDir)
+		if err != nil {
+			log.Printf("loadSegmentStore, non-fatal error: %v", err)
+		} else {
+			colFiles := []string{}
+			seen := make(map[string]bool)
+			for _, d := range docMap {
+				if len(d.CollectionFile) > 0 && !seen[d.CollectionFile] {
+					seen[d.CollectionFile] = true
+					colFiles = append(colFiles, d.CollectionFile)
+				}
+			}
+			sort.Strings(colFiles)
+			for _, colFile := range colFiles {
+				refs := client.GetTransRefs(colFile)
+				store.Add(transmemory.LoadTransRefs(refs, bibNotesDir)...)
+			}
+		}
+	}
+	log.Printf("loadSegmentStore, loaded %d segments", store.Size())
+	return store
+}
+
+// loadImports reads the TMX file of imported translations, empty if it does
+// not exist yet
+func loadImports(fileName string) ([]transmemory.Segment, error) {
+	f, err := os.Open(fileName)
+	if os.IsNotExist(err) {
+		return []transmemory.Segment{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loadImports, cannot open %s: %v", fileName, err)
+	}
+	defer f.Close()
+	return transmemory.LoadTMX(f, filepath.Base(fileName))
+}
+
+// saveImports adds the segments to the TMX file of imported translations, so
+// that they are loaded again after a restart. The file is written to a
+// temporary file and renamed. The caller must hold segmentsMu.
+func saveImports(fileName string, segments []transmemory.Segment) error {
+	saved, err := loadImports(fileName)
+	if err != nil {
+		return err
+	}
+	seen := make(map[transmemory.Segment]bool)
+	for _, seg := range saved {
+		seen[seg] = true
+	}
+	for _, seg := range segments {
+		if !seen[seg] {
+			seen[seg] = true
+			saved = append(saved, seg)
+		}
+	}
+	dir := filepath.Dir(fileName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("saveImports, cannot create %s: %v", dir, err)
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(fileName)+".*")
+	if err != nil {
+		return fmt.Errorf("saveImports, cannot create temporary file: %v", err)
+	}
+	if err := transmemory.WriteTMX(f, saved); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("saveImports, %v", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("saveImports, cannot close %s: %v", f.Name(), err)
+	}
+	if err := os.Rename(f.Name(), fileName); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("saveImports, cannot rename to %s: %v", fileName, err)
+	}
+	return nil
+}
+
+// loadBibNotes loads the bibliographic notes from the CSV files in the
+// directory
+func loadBibNotes(dir string) (bibnotes.BibNotesClient, error) {
+	readers := []*os.File{}
+	defer func() {
+		for _, f := range readers {
+			f.Close()
+		}
+	}()
+	for _, fName := range []string{bibNotesFile2RefFN, bibNotesParallelFN, bibNotesTransFN} {
+		f, err := os.Open(filepath.Join(dir, fName))
+		if err != nil {
+			return nil, fmt.Errorf("loadBibNotes, cannot open %s: %v", fName, err)
+		}
+		readers = append(readers, f)
+	}
+	return bibnotes.LoadBibNotes(readers[0], readers[1], readers[2])
+}
+
+// matchSentences finds prior translations of the query and, if it has more
+// than one sentence, of each sentence in it, best match first
+func matchSentences(ctx context.Context, store transmemory.SegmentStore, q string, minPercent, limit int) []transmemory.SegmentMatch {
+	matches := store.Match(ctx, q, minPercent, limit)
+	sentences := tokenizer.SegmentSentences(q)
+	if len(sentences) < 2 {
+		return matches
+	}
+	seen := make(map[transmemory.Segment]bool)
+	for _, m := range matches {
+		seen[m.Segment] = true
+	}
+	for _, s := range sentences {
+		for _, m := range store.Match(ctx, s.Text, minPercent, limit) {
+			if !seen[m.Segment] {
+				seen[m.Segment] = true
+				matches = append(matches, m)
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Percent != matches[j].Percent {
+			return matches[i].Percent > matches[j].Percent
+		}
+		if matches[i].Source != matches[j].Source {
+			return matches[i].Source < matches[j].Source
+		}
+		return matches[i].Target < matches[j].Target
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// findSegments handles translation memory requests for prior translations of
+// a sentence or of each sentence in a longer text
+func findSegments(w http.ResponseWriter, r *http.Request, b *backends, q, title string) {
+	if b.segmentStore == nil {
+		log.Println("main.findSegments sentence translation memory not configured")
+		http.Error(w, "Error, sentence translation memory not configured",
+			http.StatusInternalServerError)
+		return
+	}
+	minPercent := defSegmentMinPercent
+	if mp := getSingleValue(r, "minpercent"); len(mp) > 0 {
+		var err error
+		minPercent, err = strconv.Atoi(mp)
+		if err != nil || minPercent < 0 || minPercent > 100 {
+			log.Printf("main.findSegments bad minpercent %s", mp)
+			http.Error(w, "Bad minpercent, expected a number from 0 to 100",
+				http.StatusBadRequest)
+			return
+		}
+	}
+	results := segmentTMResults{
+		Query:      q,
+		MinPercent: minPercent,
+		Matches:    matchSentences(context.Background(), b.segmentStore, q, minPercent, maxSegmentMatches),
+	}
+	log.Printf("main.findSegments found %d matches for %s", len(results.Matches), q)
+	if httphandling.AcceptHTML(r) {
+		content := htmlContent{
+			Title: title,
+			Query: q,
+			Data:  results,
+		}
+		b.pageDisplayer.DisplayPage(w, "findtm.html", content)
+		return
+	}
+	resultsJson, err := json.Marshal(results)
+	if err != nil {
+		log.Printf("main.findSegments error marshalling JSON, %v", err)
+		http.Error(w, "Internal Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprint(w, string(resultsJson))
+}
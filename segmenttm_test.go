@@ -0,0 +1,163 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexamies/chinesenotes-go/config"
+	"github.com/alexamies/chinesenotes-go/find"
+	"github.com/alexamies/chinesenotes-go/httphandling"
+	"github.com/alexamies/chinesenotes-go/templates"
+	"github.com/alexamies/chinesenotes-go/transmemory"
+)
+
+// TestLoadSegmentStore tests loading sentences from a TMX file and parallel
+// translations in the bibliographic notes
+func TestLoadSegmentStore(t *testing.T) {
+	if store := loadSegmentStore(config.WebAppConfig{}, nil); store == nil || store.Size() != 0 {
+		t.Errorf("TestLoadSegmentStore: expected an empty store when not configured")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"tm.tmx": `<tmx version="1.4"><body>
+<tu><tuv xml:lang="zh"><seg>学而时习之</seg></tuv><tuv xml:lang="en"><seg>To learn and practice it</seg></tuv></tu>
+</body></tmx>`,
+		bibNotesFile2RefFN: "reference_no,file_name\n1,example_collection.tsv\n",
+		bibNotesParallelFN: "reference_no,language,citation\n",
+		bibNotesTransFN:    "reference_no,type,citation,url\n1,parallel,Bilingual edition,analects.tsv\n",
+		"analects.tsv":     "有朋自远方来\tFriends come from afar\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("TestLoadSegmentStore: could not write %s: %v", name, err)
+		}
+	}
+	webConfig := config.WebAppConfig{
+		ConfigVars: map[string]string{
+			"SegmentTMXFile": filepath.Join(dir, "tm.tmx"),
+			"BibNotesDir":    dir,
+		},
+	}
+	docMap := map[string]find.DocInfo{
+		"example_collection/example_collection001.html": {
+			CollectionFile: "example_collection.tsv",
+		},
+	}
+	store := loadSegmentStore(webConfig, docMap)
+	if store == nil {
+		t.Fatal("TestLoadSegmentStore: expected a store")
+	}
+	if store.Size() != 2 {
+		t.Errorf("TestLoadSegmentStore: got %d segments, want 2", store.Size())
+	}
+	matches := store.Match(context.TODO(), "有朋自远方来", 100, 1)
+	if len(matches) != 1 || matches[0].Origin != "analects.tsv" {
+		t.Errorf("TestLoadSegmentStore: unexpected matches %v", matches)
+	}
+}
+
+// TestFindSegments tests the sentence mode of the translation memory
+func TestFindSegments(t *testing.T) {
+	templates := templates.NewTemplateMap(config.WebAppConfig{})
+	pageDisplayer := httphandling.NewPageDisplayer(templates)
+	b = &backends{
+		templates:     templates,
+		pageDisplayer: pageDisplayer,
+	}
+	query := "mode=sentence&query=" + url.QueryEscape("学而时习之")
+	r := httptest.NewRequest(http.MethodGet, "/findtm?"+query, nil)
+	w := httptest.NewRecorder()
+	translationMemory(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("TestFindSegments not configured: got status %d", w.Code)
+	}
+	store := transmemory.NewSegmentStore()
+	store.Add(
+		transmemory.Segment{Source: "学而时习之", Target: "To learn & practice it", Origin: "tm.tmx"},
+		transmemory.Segment{Source: "有朋自远方来", Target: "Friends come from afar", Origin: "tm.tmx"},
+	)
+	b.segmentStore = store
+	type test struct {
+		name           string
+		query          string
+		html           bool
+		expectCode     int
+		expectContains string
+	}
+	tests := []test{
+		{
+			name:           "Fuzzy match",
+			query:          "mode=sentence&query=" + url.QueryEscape("学而常习之"),
+			expectCode:     http.StatusOK,
+			expectContains: `"Matches":[{"Source":"学而时习之","Target":"To learn \u0026 practice it","Origin":"tm.tmx","Percent":80}]`,
+		},
+		{
+			name:           "Sentence by sentence",
+			query:          "mode=sentence&query=" + url.QueryEscape("学而时习之\n有朋自远方来"),
+			expectCode:     http.StatusOK,
+			expectContains: `"Origin":"tm.tmx","Percent":100},{"Source":"有朋自远方来"`,
+		},
+		{
+			name:           "Below minimum percent",
+			query:          "mode=sentence&minpercent=90&query=" + url.QueryEscape("学而常习之"),
+			expectCode:     http.StatusOK,
+			expectContains: `"Matches":[]`,
+		},
+		{
+			name:           "Bad minimum percent",
+			query:          "mode=sentence&minpercent=200&query=" + url.QueryEscape("学而常习之"),
+			expectCode:     http.StatusBadRequest,
+			expectContains: "Bad minpercent",
+		},
+		{
+			name:           "HTML",
+			query:          "mode=sentence&query=" + url.QueryEscape("有朋自远方来"),
+			html:           true,
+			expectCode:     http.StatusOK,
+			expectContains: "100% 有朋自远方来",
+		},
+		{
+			name:           "HTML escaped",
+			query:          "mode=sentence&query=" + url.QueryEscape("学而时习之"),
+			html:           true,
+			expectCode:     http.StatusOK,
+			expectContains: "To learn &amp; practice it",
+		},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/findtm?"+tc.query, nil)
+		if tc.html {
+			r.Header.Set("Accept", "text/html")
+		}
+		w := httptest.NewRecorder()
+		translationMemory(w, r)
+		if w.Code != tc.expectCode {
+			t.Errorf("TestFindSegments %s: got status %d, want %d", tc.name,
+				w.Code, tc.expectCode)
+		}
+		result := w.Body.String()
+		if !strings.Contains(result, tc.expectContains) {
+			t.Errorf("TestFindSegments %s: got %q, expectContains %q", tc.name,
+				result, tc.expectContains)
+		}
+	}
+	b = nil
+}
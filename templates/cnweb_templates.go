@@ -257,12 +257,41 @@ const findTMTmpl = `
         <div>
           <label for="findInput">Search for</label>
           <input type="text" name="query" size="40" required value="{{.Query}}"/>
+          <select name="mode">
+            <option value="">Names and phrases</option>
+            <option value="sentence" {{if .Data}}selected{{end}}>Prior translations of a sentence</option>
+          </select>
           <button type="submit">Find</button>
         </div>
+        <div>
+          <label for="domainInput">Domains</label>
+          <input type="text" name="domain" id="domainInput" size="30"
+                 placeholder="Buddhism, Idiom"/>
+          <input type="checkbox" name="boost" id="boostInput" value="true"/>
+          <label for="boostInput">Prefer, but do not restrict to, the domains</label>
+        </div>
       </form>
       {{ end }}
-      {{if .TMResults}}
+      {{if .Data}}
+      <h4>Prior translations</h4>
+      <ul>
+        {{ range $m := .Data.Matches }}
+        <li>
+          {{ $m.Percent }}%% {{ $m.Source | html }}
+          <div>{{ $m.Target | html }}</div>
+          <div>Source: {{ $m.Origin | html }}</div>
+        </li>
+        {{ else }}
+          <p>No translations found matching at least {{ .Data.MinPercent }}%%</p>
+        {{ end }}
+      </ul>
+      {{ else if .TMResults}}
       <h4>Results</h4>
+      {{if .TMResults.DomainFacets}}
+      <p>Domains:
+        {{ range $f := .TMResults.DomainFacets }}{{ $f.Domain | html }} ({{ $f.Count }}) {{ end }}
+      </p>
+      {{ end }}
       <ul>
         {{ range $term := .TMResults.Words }}
         <li>
@@ -347,18 +376,117 @@ const wordDetailTmpl = `
            {{if .Data.Word.Traditional}} ({{ .Data.Word.Traditional }}) {{ end }}
         </span>
         <span class="dict-entry-pinyin">{{ .Data.Word.Pinyin }}</span>
+        <div class="romanization">
+          <a href="?romanization=pinyin">Pinyin</a> |
+          <a href="?romanization=zhuyin">Zhuyin</a> |
+          <a href="?romanization=wadegiles">Wade-Giles</a> |
+          <a href="?romanization=jyutping">Jyutping</a>
+        </div>
+        {{if .Data.Characters}}
+        <div class="characters">
+          Characters:
+          {{ range $c := .Data.Characters }}<a href="/chardata?char={{ $c | urlquery }}">{{ $c }}</a> {{ end }}
+        </div>
+        {{end}}
         <ol>
-        {{ range $ws := .Data.Word.Senses }}
+        {{ range $i, $ws := .Data.Word.Senses }}
           <li>
           {{if ne $ws.Pinyin "\\N"}}<span class="dict-entry-pinyin">{{ $ws.Pinyin }}</span>{{end}}
           {{if ne $ws.Grammar "\\N"}}<span class="dict-entry-grammar">{{ $ws.Grammar }}</span>{{end}}
           {{if ne $ws.English "\\N"}}<span class="dict-entry-definition">{{ $ws.English }}</span>{{end}}
           {{if ne $ws.Domain "\\N"}}<div class="dict-entry-domain">Domain: {{ $ws.Domain }}</div>{{end}}
           {{if ne $ws.Notes "\\N"}}<div class="dict-entry-notes">Notes: {{ $ws.Notes }}</div>{{end}}
+          {{if $.Data.CanPropose}}
+          <details>
+            <summary>Suggest a change</summary>
+            <form method="post" action="/loggedin/propose">
+              <input type="hidden" name="HeadwordId" value="{{ $.Data.Word.HeadwordId }}"/>
+              <input type="hidden" name="SenseId" value="{{ $ws.Id }}"/>
+              <div><label>Pinyin <input type="text" name="Pinyin" size="30" required value="{{ (index $.Data.Entry.Senses $i).Pinyin | html }}"/></label></div>
+              <div><label>English <input type="text" name="English" size="60" required value="{{ $ws.English | html }}"/></label></div>
+              <div><label>Grammar <input type="text" name="Grammar" size="20" value="{{if ne $ws.Grammar "\\N"}}{{ $ws.Grammar | html }}{{end}}"/></label></div>
+              <div><label>Notes <textarea name="Notes" rows="3" cols="60">{{ with index $.Data.Entry.Senses $i }}{{if ne .Notes "\\N"}}{{ .Notes | html }}{{end}}{{end}}</textarea></label></div>
+              <div><label>Reason for the change <textarea name="Comment" rows="2" cols="60"></textarea></label></div>
+              <button type="submit">Propose</button>
+            </form>
+          </details>
+          {{end}}
           </li>
         {{ end }}
         </ol>
+        {{if .Data.CanPropose}}
+        <details>
+          <summary>Suggest a new sense or word</summary>
+          <form method="post" action="/loggedin/propose">
+            <div><label>Simplified <input type="text" name="Simplified" size="20" required value="{{ .Data.Word.Simplified | html }}"/></label></div>
+            <div><label>Traditional <input type="text" name="Traditional" size="20" value=""/></label></div>
+            <div><label>Pinyin <input type="text" name="Pinyin" size="30" required value=""/></label></div>
+            <div><label>English <input type="text" name="English" size="60" required value=""/></label></div>
+            <div><label>Grammar <input type="text" name="Grammar" size="20" value=""/></label></div>
+            <div><label>Notes <textarea name="Notes" rows="3" cols="60"></textarea></label></div>
+            <div><label>Reason for the change <textarea name="Comment" rows="2" cols="60"></textarea></label></div>
+            <button type="submit">Propose</button>
+          </form>
+        </details>
+        {{end}}
       </div>
+      {{ with .Data.Related }}
+      <div class="related-words">
+        <h3>Related Words</h3>
+        {{if .Compounds }}
+        <h4>Compounds</h4>
+        <p>{{ range $w := .Compounds }}<a href="/words/{{ $w.HeadwordId }}.html">{{ $w.Simplified }}</a> {{ end }}</p>
+        {{end}}
+        {{if .SubWords }}
+        <h4>Component words</h4>
+        <p>{{ range $w := .SubWords }}<a href="/words/{{ $w.HeadwordId }}.html">{{ $w.Simplified }}</a> {{ end }}</p>
+        {{end}}
+        {{if .SameConcept }}
+        <h4>Same concept</h4>
+        <ul>
+          {{ range $ws := .SameConcept }}
+          <li><a href="/words/{{ $ws.HeadwordId }}.html">{{ $ws.Simplified }}</a> {{ $ws.Pinyin }} {{ $ws.English }}</li>
+          {{ end }}
+        </ul>
+        {{end}}
+        {{if .SameDomain }}
+        <h4>Same domain</h4>
+        <ul>
+          {{ range $ws := .SameDomain }}
+          <li><a href="/words/{{ $ws.HeadwordId }}.html">{{ $ws.Simplified }}</a> {{ $ws.Pinyin }} {{ $ws.English }}</li>
+          {{ end }}
+        </ul>
+        {{end}}
+      </div>
+      {{ end }}
+      {{ with .Data.Usage }}
+      <div class="word-usage">
+        <h3>Corpus Usage</h3>
+        <p>Frequency in the corpus: {{ .Freq }}</p>
+        {{if .Docs }}
+        <h4>Documents using the word most</h4>
+        <ul>
+          {{ range $d := .Docs }}
+          <li><a href="/{{ $d.GlossFile }}">{{ $d.Title }}</a> ({{ $d.Count }})</li>
+          {{ end }}
+        </ul>
+        {{end}}
+        {{if .Examples }}
+        <h4>Examples</h4>
+        <ul class="concordance">
+          {{ range $e := .Examples }}
+          <li>{{ $e.Left | html }}<b>{{ $e.Term }}</b>{{ $e.Right | html }}</li>
+          {{ end }}
+        </ul>
+        {{end}}
+        {{if .Collocates }}
+        <h4>Collocates</h4>
+        <p>
+          {{ range $c := .Collocates }}<a href="/find/?query={{ $c.Word | urlquery }}">{{ $c.Word }}</a> ({{ $c.Freq }}) {{ end }}
+        </p>
+        {{end}}
+      </div>
+      {{ end }}
       {{ else }}
       <p>Not found</p>
       {{ end }}
@@ -376,6 +504,119 @@ const wordDetailTmpl = `
 </html>
 `
 
+// Character lookup by radical or component, the query is escaped
+const charDataTmpl = `
+<!DOCTYPE html>
+<html lang="en">
+  %s
+  <body>
+    %s
+    %s
+    <main>
+      <h2>Character Lookup</h2>
+      <form name="charForm" method="get" action="/chardata">
+        <div>
+          <label>Character or component
+            <input type="text" name="component" size="4" value="{{ .Query | html }}"/>
+          </label>
+          <button type="submit">Find</button>
+        </div>
+      </form>
+      {{ with .Data.Results }}
+      {{if .Char }}
+      <div>
+        <span class="dict-entry-headword">{{ .Char.Character }}</span>
+        <a href="/chardata?radical={{ .Char.Radical }}">Radical {{ .Char.Radical }}</a>
+        + {{ .Char.ResidualStrokes }}, {{ .Char.Strokes }} strokes
+        {{if .Char.Components }}
+        <div>Components:
+          {{ range $c := .Char.Components }}<a href="/chardata?char={{ $c | urlquery }}">{{ $c }}</a> {{ end }}
+        </div>
+        {{end}}
+      </div>
+      {{end}}
+      {{if .RadicalChar }}
+      <h3>Radical {{ .Radical }} {{ .RadicalChar }}</h3>
+      {{end}}
+      {{if .Chars }}
+      <ul>
+        {{ range $ci := .Chars }}
+        <li><a href="/chardata?char={{ $ci.Character | urlquery }}">{{ $ci.Character }}</a>
+          {{ $ci.ResidualStrokes }} + radical {{ $ci.Radical }}, {{ $ci.Strokes }} strokes</li>
+        {{ end }}
+      </ul>
+      {{ else if .Query }}
+      <p>No characters found</p>
+      {{end}}
+      {{end}}
+      <h3>Radicals</h3>
+      <div class="radicals">
+        {{ range $r := .Data.Radicals }}<a href="/chardata?radical={{ $r.Number }}" title="{{ $r.Number }}">{{ $r.Character }}</a> {{ end }}
+      </div>
+    </main>
+    %s
+  <body>
+</html>
+`
+
+// Proposed dictionary changes, user entered text is escaped
+const dictEditsTmpl = `
+<!DOCTYPE html>
+<html lang="en">
+  %s
+  <body>
+    %s
+    %s
+    <main>
+      <h2>Proposed dictionary changes</h2>
+      {{if .Data.Message}}<p>{{ .Data.Message | html }}</p>{{end}}
+      {{if .Data.Proposals}}
+      <table>
+        <thead>
+          <tr>
+            <th>Id</th><th>Kind</th><th>Headword</th><th>Pinyin</th><th>Grammar</th>
+            <th>English</th><th>Notes</th><th>Proposed by</th><th>Reason</th>
+            {{if .Data.CanReview}}<th>Review</th>{{end}}
+          </tr>
+        </thead>
+        <tbody>
+        {{ range $p := .Data.Proposals }}
+          <tr>
+            <td>{{ $p.Id }}</td>
+            <td>{{ $p.Kind }}</td>
+            <td>{{ $p.Sense.Simplified | html }}</td>
+            <td>{{ $p.Sense.Pinyin | html }}{{if ne $p.Original.Pinyin $p.Sense.Pinyin}}{{if $p.Original.Pinyin}}<br/><del>{{ $p.Original.Pinyin | html }}</del>{{end}}{{end}}</td>
+            <td>{{ $p.Sense.Grammar | html }}{{if ne $p.Original.Grammar $p.Sense.Grammar}}{{if $p.Original.Grammar}}<br/><del>{{ $p.Original.Grammar | html }}</del>{{end}}{{end}}</td>
+            <td>{{ $p.Sense.English | html }}{{if ne $p.Original.English $p.Sense.English}}{{if $p.Original.English}}<br/><del>{{ $p.Original.English | html }}</del>{{end}}{{end}}</td>
+            <td>{{ $p.Sense.Notes | html }}{{if ne $p.Original.Notes $p.Sense.Notes}}{{if $p.Original.Notes}}<br/><del>{{ $p.Original.Notes | html }}</del>{{end}}{{end}}</td>
+            <td>{{ $p.ProposedBy | html }}</td>
+            <td>{{ $p.Comment | html }}</td>
+            {{if $.Data.CanReview}}
+            <td>
+              {{if eq $p.Status "pending"}}
+              <form method="post" action="/loggedin/review">
+                <input type="hidden" name="ProposalId" value="{{ $p.Id }}"/>
+                <input type="text" name="ReviewNote" size="20" placeholder="Note"/>
+                <button type="submit" name="Action" value="approve">Approve</button>
+                <button type="submit" name="Action" value="reject">Reject</button>
+              </form>
+              {{else}}{{ $p.Status }}{{end}}
+            </td>
+            {{end}}
+          </tr>
+        {{ end }}
+        </tbody>
+      </table>
+      {{else}}
+      <p>No proposals waiting for review</p>
+      {{end}}
+      {{if .Data.CanReview}}<p><a href="/loggedin/review">Proposals waiting for review</a></p>{{end}}
+    </main>
+    %s
+  <body>
+</html>
+`
+
 // Page not found
 const notFoundTmpl = `
 <!DOCTYPE html>
@@ -688,9 +929,43 @@ const translationTmpl = `
         <textarea rows="4" cols="100" name='translated' id="translated"
                   >{{.TranslatedText}}</textarea>
         </div>
+        <div>
+          <input type="checkbox" id="compare" name="platform" value="compare"
+                 {{.CompareChecked}}>
+          <label for="compare">Compare all platforms</label>
+        </div>
       <input type="hidden" id="processing" name="processing"
              value="{{.PostProcessing}}">
     </form>
+      <p>{{.Message}}</p>
+      {{if .Comparisons}}
+      <h2>Comparison</h2>
+      <form action='/translatechoice' method='POST'>
+        <input type="hidden" name="source" value="{{.SourceText | html}}">
+        {{range .Comparisons}}
+        <div>
+          {{if .Result}}
+          <input type="radio" name="engine" value="{{.Engine}}"
+                 id="engine-{{.Engine}}">
+          <label for="engine-{{.Engine}}">{{.Engine}}</label>
+          <p>{{range .Words}}{{if .Differs}}<mark>{{.Text | html}}</mark>{{else}}{{.Text | html}}{{end}} {{end}}</p>
+          <input type="hidden" name="output_{{.Engine}}"
+                 value="{{.Result.Text | html}}">
+          {{else}}
+          <span>{{.Engine}}: {{.Error | html}}</span>
+          {{end}}
+        </div>
+        {{end}}
+        <div>
+          Pick a translation above or edit to merge them
+        </div>
+        <textarea rows="4" cols="100" name='translated'
+                  >{{.TranslatedText | html}}</textarea>
+        <p>
+          <button type="submit" id="choiceSubmit">Use translation</button>
+        </p>
+      </form>
+      {{end}}
     </main>
     %s
   <body>
@@ -703,6 +978,8 @@ func NewTemplateMap(webConfig config.WebAppConfig) map[string]*template.Template
 		"404.html":                         notFoundTmpl,
 		"admin_portal.html":                adminPortalTmpl,
 		"change_password_form.html":        changePasswordTmpl,
+		"chardata.html":                    charDataTmpl,
+		"dict_edits.html":                  dictEditsTmpl,
 		"doc_results.html":                 docResultsTmpl,
 		"find_results.html":                findResultsTmpl,
 		"findtm.html":                      findTMTmpl,
@@ -0,0 +1,71 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package termfreq
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// DocFreq is the number of times a term occurs in a document
+type DocFreq struct {
+	Filename string // The gloss file of the document
+	Count    int
+}
+
+// TermIndex gives the frequency of terms in the corpus
+type TermIndex interface {
+
+	// Freq gives the number of times the term occurs in the corpus
+	Freq(term string) int
+
+	// Docs gives the documents the term occurs in, most frequent first
+	Docs(term string) []DocFreq
+}
+
+type termIndexMem struct {
+	docs map[string][]DocFreq
+	freq map[string]int
+}
+
+// LoadKeywordIndex loads the term index from a JSON file, as generated by the
+// corpus indexer, with terms as keys and arrays of document frequencies
+func LoadKeywordIndex(r io.Reader) (TermIndex, error) {
+	docs := make(map[string][]DocFreq)
+	if err := json.NewDecoder(r).Decode(&docs); err != nil {
+		return nil, fmt.Errorf("termfreq.LoadKeywordIndex, could not decode: %v", err)
+	}
+	freq := make(map[string]int)
+	for term, d := range docs {
+		sort.SliceStable(d, func(i, j int) bool {
+			return d[i].Count > d[j].Count
+		})
+		for _, df := range d {
+			freq[term] += df.Count
+		}
+	}
+	return termIndexMem{
+		docs: docs,
+		freq: freq,
+	}, nil
+}
+
+func (ti termIndexMem) Freq(term string) int {
+	return ti.freq[term]
+}
+
+func (ti termIndexMem) Docs(term string) []DocFreq {
+	return append([]DocFreq(nil), ti.docs[term]...)
+}
@@ -0,0 +1,44 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package termfreq
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestLoadKeywordIndex tests loading the term index and getting frequencies
+func TestLoadKeywordIndex(t *testing.T) {
+	const input = `{"曰":[{"Filename":"a.html","Count":1},{"Filename":"b.html","Count":3}],"風":[{"Filename":"a.html","Count":2}]}`
+	ti, err := LoadKeywordIndex(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("TestLoadKeywordIndex: unexpected error: %v", err)
+	}
+	freq := map[string]int{"曰": 4, "風": 2, "雅": 0}
+	for term, expect := range freq {
+		if got := ti.Freq(term); got != expect {
+			t.Errorf("TestLoadKeywordIndex: Freq(%s) expected %d, got %d", term,
+				expect, got)
+		}
+	}
+	docs := ti.Docs("曰")
+	if len(docs) != 2 || docs[0].Filename != "b.html" || docs[0].Count != 3 {
+		t.Errorf("TestLoadKeywordIndex: expected b.html first, got %v", docs)
+	}
+	if docs := ti.Docs("雅"); len(docs) != 0 {
+		t.Errorf("TestLoadKeywordIndex: expected no docs, got %v", docs)
+	}
+	if _, err := LoadKeywordIndex(strings.NewReader("not json")); err == nil {
+		t.Error("TestLoadKeywordIndex: expected an error for bad input")
+	}
+}
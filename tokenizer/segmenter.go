@@ -33,7 +33,7 @@ func Segment(text string) []TextSegment {
 	cjk := ""
 	noncjk := ""
 	for _, character := range text {
-		if dicttypes.IsCJKChar(string(character)) {
+		if dicttypes.IsCJKRune(character) {
 			if noncjk != "" {
 				s := TextSegment{noncjk, false}
 				segments = append(segments, s)
@@ -0,0 +1,214 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tokenizer
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A sentence or clause in a source text
+type Sentence struct {
+
+	// The text of the sentence, with surrounding whitespace trimmed
+	Text string
+
+	// Byte offsets of the sentence in the source, source[Start:End] == Text
+	Start, End int
+
+	// Zero based index of the paragraph (non-blank line) containing the sentence
+	Paragraph int
+
+	// The paragraph number, eg 1. or 一、, if the sentence opens a numbered
+	// paragraph. The label is not included in Text.
+	Label string
+}
+
+// Punctuation ending a sentence
+var sentenceEnd = map[rune]bool{
+	'。': true,
+	'！': true,
+	'？': true,
+	'｡': true,
+	'!': true,
+	'?': true,
+}
+
+// Punctuation ending a clause within a sentence
+var clauseEnd = map[rune]bool{
+	'，': true,
+	'、': true,
+	'；': true,
+	'：': true,
+	'﹐': true,
+	'﹑': true,
+	'﹔': true,
+	'﹕': true,
+	',': true,
+	';': true,
+	':': true,
+}
+
+// Closing quotes and brackets that belong to the sentence they follow
+var closers = map[rune]bool{
+	'」': true,
+	'』': true,
+	'”': true,
+	'’': true,
+	'）': true,
+	'》': true,
+	'〉': true,
+	'】': true,
+	'〕': true,
+	')': true,
+}
+
+// Straight quotes, which are the same opening and closing, so belong to the
+// sentence they follow only if they close a quote opened in it
+var straightQuotes = map[rune]bool{
+	'"':  true,
+	'\'': true,
+}
+
+// Paragraph numbers at the start of a line, eg 1. 2、 一、 （三）
+var paragraphLabel = regexp.MustCompile(`^(?:[0-9０-９]{1,3}(?:[.．](?:[ \t　]|$)|[、)）])|[一二三四五六七八九十百]{1,4}、|[（(][一二三四五六七八九十0-9]{1,4}[)）])[ \t　]*`)
+
+// SegmentSentences splits a text into sentences delimited by Chinese or
+// Western sentence ending punctuation and line breaks. Closing quotes and
+// brackets following the punctuation are kept with the sentence. Paragraph
+// numbers at the start of a line are returned in the Label field.
+func SegmentSentences(text string) []Sentence {
+	return segmentText(text, false)
+}
+
+// SegmentClauses splits a text into clauses, delimited by the same boundaries
+// as SegmentSentences plus commas, enumeration commas, semicolons and colons.
+func SegmentClauses(text string) []Sentence {
+	return segmentText(text, true)
+}
+
+// segmentText splits the text into lines and then each line into sentences or
+// clauses
+func segmentText(text string, clauses bool) []Sentence {
+	sentences := []Sentence{}
+	para := -1
+	lineStart := 0
+	for lineStart < len(text) {
+		lineEnd := strings.IndexByte(text[lineStart:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(text)
+		} else {
+			lineEnd += lineStart
+		}
+		if strings.TrimFunc(text[lineStart:lineEnd], isSpace) != "" {
+			para++
+			s := segmentLine(text, lineStart, lineEnd, para, clauses)
+			sentences = append(sentences, s...)
+		}
+		lineStart = lineEnd + 1
+	}
+	return sentences
+}
+
+// segmentLine splits a single line text[start:end] into sentences
+func segmentLine(text string, start, end, para int, clauses bool) []Sentence {
+	sentences := []Sentence{}
+	for start < end {
+		r, size := utf8.DecodeRuneInString(text[start:end])
+		if !isSpace(r) {
+			break
+		}
+		start += size
+	}
+	label := ""
+	if m := paragraphLabel.FindString(text[start:end]); m != "" {
+		label = strings.TrimFunc(m, isSpace)
+		start += len(m)
+	}
+	emit := func(s, e int) {
+		for s < e {
+			r, size := utf8.DecodeRuneInString(text[s:e])
+			if !isSpace(r) {
+				break
+			}
+			s += size
+		}
+		for e > s {
+			r, size := utf8.DecodeLastRuneInString(text[s:e])
+			if !isSpace(r) {
+				break
+			}
+			e -= size
+		}
+		if s == e {
+			return
+		}
+		sentences = append(sentences, Sentence{
+			Text:      text[s:e],
+			Start:     s,
+			End:       e,
+			Paragraph: para,
+			Label:     label,
+		})
+		label = ""
+	}
+	sStart := start
+	i := start
+	for i < end {
+		r, size := utf8.DecodeRuneInString(text[i:end])
+		i += size
+		if !isBoundary(text, i-size, i, end, clauses) {
+			continue
+		}
+		// Absorb repeated punctuation, eg ？！, and closing quotes
+		for i < end {
+			r, size = utf8.DecodeRuneInString(text[i:end])
+			if closers[r] || sentenceEnd[r] || (clauses && clauseEnd[r]) ||
+				(straightQuotes[r] && strings.Count(text[sStart:i], string(r))%2 == 1) {
+				i += size
+				continue
+			}
+			break
+		}
+		emit(sStart, i)
+		sStart = i
+	}
+	emit(sStart, end)
+	return sentences
+}
+
+// isBoundary tests whether the rune at text[i:j] ends a sentence or clause
+func isBoundary(text string, i, j, end int, clauses bool) bool {
+	r, _ := utf8.DecodeRuneInString(text[i:j])
+	if sentenceEnd[r] || (clauses && clauseEnd[r]) {
+		return true
+	}
+	if r != '.' {
+		return false
+	}
+	// A Western full stop needs to be followed by a space, so that decimal
+	// numbers and URLs are not split
+	if j == end {
+		return true
+	}
+	next, _ := utf8.DecodeRuneInString(text[j:end])
+	return isSpace(next) || closers[next] || straightQuotes[next]
+}
+
+// isSpace tests for whitespace, which includes the full width ideographic
+// space U+3000
+func isSpace(r rune) bool {
+	return unicode.IsSpace(r)
+}
@@ -0,0 +1,167 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tokenizer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// A basic example of the function SegmentSentences
+func ExampleSegmentSentences() {
+	sentences := SegmentSentences("子曰：「學而時習之，不亦說乎？」有朋自遠方來。")
+	for _, s := range sentences {
+		fmt.Printf("%d-%d: %s\n", s.Start, s.End, s.Text)
+	}
+	// Output: 0-48: 子曰：「學而時習之，不亦說乎？」
+	// 48-69: 有朋自遠方來。
+}
+
+func TestSegmentSentences(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		want []Sentence
+	}{
+		{
+			name: "Empty",
+			in:   "",
+			want: []Sentence{},
+		},
+		{
+			name: "No punctuation",
+			in:   "你好",
+			want: []Sentence{{Text: "你好", Start: 0, End: 6}},
+		},
+		{
+			name: "Two sentences",
+			in:   "你好。再見！",
+			want: []Sentence{
+				{Text: "你好。", Start: 0, End: 9},
+				{Text: "再見！", Start: 9, End: 18},
+			},
+		},
+		{
+			name: "Commas do not split sentences",
+			in:   "一曰風，二曰賦。",
+			want: []Sentence{{Text: "一曰風，二曰賦。", Start: 0, End: 24}},
+		},
+		{
+			name: "Repeated punctuation",
+			in:   "真的嗎？！好",
+			want: []Sentence{
+				{Text: "真的嗎？！", Start: 0, End: 15},
+				{Text: "好", Start: 15, End: 18},
+			},
+		},
+		{
+			name: "Closing quote",
+			in:   "曰：「善。」退",
+			want: []Sentence{
+				{Text: "曰：「善。」", Start: 0, End: 18},
+				{Text: "退", Start: 18, End: 21},
+			},
+		},
+		{
+			name: "Opening straight quote starts the next sentence",
+			in:   "他說。\"好的\"我們走吧。",
+			want: []Sentence{
+				{Text: "他說。", Start: 0, End: 9},
+				{Text: "\"好的\"我們走吧。", Start: 9, End: 32},
+			},
+		},
+		{
+			name: "Closing straight quote",
+			in:   "他說：\"好。\"然後",
+			want: []Sentence{
+				{Text: "他說：\"好。\"", Start: 0, End: 17},
+				{Text: "然後", Start: 17, End: 23},
+			},
+		},
+		{
+			name: "Line breaks",
+			in:   "天\n\n地\r\n",
+			want: []Sentence{
+				{Text: "天", Start: 0, End: 3, Paragraph: 0},
+				{Text: "地", Start: 5, End: 8, Paragraph: 1},
+			},
+		},
+		{
+			name: "Numbered paragraphs",
+			in:   "1. 天\n二、地。人",
+			want: []Sentence{
+				{Text: "天", Start: 3, End: 6, Paragraph: 0, Label: "1."},
+				{Text: "地。", Start: 13, End: 19, Paragraph: 1, Label: "二、"},
+				{Text: "人", Start: 19, End: 22, Paragraph: 1},
+			},
+		},
+		{
+			name: "English",
+			in:   "It costs 1.5 yuan. Buy it!",
+			want: []Sentence{
+				{Text: "It costs 1.5 yuan.", Start: 0, End: 18},
+				{Text: "Buy it!", Start: 19, End: 26},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		got := SegmentSentences(tc.in)
+		if !reflect.DeepEqual(tc.want, got) {
+			t.Errorf("%s, expected %v, got %v", tc.name, tc.want, got)
+		}
+		for _, s := range got {
+			if tc.in[s.Start:s.End] != s.Text {
+				t.Errorf("%s, offsets %d-%d do not match %q", tc.name, s.Start, s.End, s.Text)
+			}
+		}
+	}
+}
+
+func TestSegmentClauses(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{
+			name: "Empty",
+			in:   "",
+			want: []string{},
+		},
+		{
+			name: "Commas",
+			in:   "一曰風，二曰賦。",
+			want: []string{"一曰風，", "二曰賦。"},
+		},
+		{
+			name: "Quotation",
+			in:   "子曰：「學而時習之，不亦說乎？」",
+			want: []string{"子曰：", "「學而時習之，", "不亦說乎？」"},
+		},
+		{
+			name: "Enumeration comma and semicolon",
+			in:   "風、賦；比",
+			want: []string{"風、", "賦；", "比"},
+		},
+	}
+	for _, tc := range testCases {
+		got := []string{}
+		for _, s := range SegmentClauses(tc.in) {
+			got = append(got, s.Text)
+		}
+		if !reflect.DeepEqual(tc.want, got) {
+			t.Errorf("%s, expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
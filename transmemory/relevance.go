@@ -0,0 +1,175 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transmemory
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Number of features in the relevance model
+	numFeatures = 5
+	// Minimum score for a result to be relevant
+	relevanceThreshold = 0.5
+	// Training parameters for gradient descent
+	trainEpochs       = 2000
+	trainLearningRate = 0.5
+)
+
+// RelevanceModel is a logistic regression model of the relevance of a
+// translation memory result. The weights are for the features, in order,
+// unigram count divided by query length, Hamming distance divided by query
+// length, Pinyin match, query in notes, and either term a substring of the
+// other.
+type RelevanceModel struct {
+	Intercept float64
+	Weights   []float64
+}
+
+// DefaultModel gives weights that approximate the decision tree previously
+// trained on the Buddhist dictionary translation memory
+var DefaultModel = RelevanceModel{
+	Intercept: -1.5,
+	Weights:   []float64{12.0, -8.0, 6.0, 10.0, 10.0},
+}
+
+// Example is a translation memory result labelled as relevant or not, for
+// training
+type Example struct {
+	Query    string
+	Match    Match
+	Relevant bool
+}
+
+// Score gives the probability that the result is relevant to the query
+func (m RelevanceModel) Score(query string, match Match) float64 {
+	x := features(query, match)
+	z := m.Intercept
+	for i, w := range m.Weights {
+		if i < len(x) {
+			z += w * x[i]
+		}
+	}
+	return 1.0 / (1.0 + math.Exp(-z))
+}
+
+// TrainModel fits a model to labelled examples with gradient descent
+func TrainModel(examples []Example) (RelevanceModel, error) {
+	if len(examples) == 0 {
+		return RelevanceModel{}, fmt.Errorf("transmemory.TrainModel: no examples")
+	}
+	x := make([][]float64, len(examples))
+	y := make([]float64, len(examples))
+	for i, e := range examples {
+		x[i] = features(e.Query, e.Match)
+		if e.Relevant {
+			y[i] = 1.0
+		}
+	}
+	m := RelevanceModel{
+		Weights: make([]float64, numFeatures),
+	}
+	n := float64(len(examples))
+	for epoch := 0; epoch < trainEpochs; epoch++ {
+		gradIntercept := 0.0
+		grad := make([]float64, numFeatures)
+		for i := range x {
+			z := m.Intercept
+			for j, w := range m.Weights {
+				z += w * x[i][j]
+			}
+			diff := 1.0/(1.0+math.Exp(-z)) - y[i]
+			gradIntercept += diff
+			for j := range grad {
+				grad[j] += diff * x[i][j]
+			}
+		}
+		m.Intercept -= trainLearningRate * gradIntercept / n
+		for j := range m.Weights {
+			m.Weights[j] -= trainLearningRate * grad[j] / n
+		}
+	}
+	return m, nil
+}
+
+// LoadExamples reads labelled examples in CSV format, with the same columns
+// as the results logged by the searcher: query, rank, term, has Pinyin, in
+// notes, unigram count, Hamming distance, substring, and relevant, with a
+// header row. Further columns, such as the score, are ignored.
+func LoadExamples(r io.Reader) ([]Example, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("transmemory.LoadExamples: could not read CSV: %v", err)
+	}
+	examples := []Example{}
+	for i, row := range rows {
+		if i == 0 {
+			continue
+		}
+		if len(row) < 9 {
+			return nil, fmt.Errorf("transmemory.LoadExamples: line %d has %d columns, expected 9", i+1, len(row))
+		}
+		vals := []int{}
+		for _, s := range []string{row[3], row[4], row[5], row[6], row[7], row[8]} {
+			v, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return nil, fmt.Errorf("transmemory.LoadExamples: line %d, bad value %s: %v", i+1, s, err)
+			}
+			vals = append(vals, v)
+		}
+		examples = append(examples, Example{
+			Query: strings.TrimSpace(row[0]),
+			Match: Match{
+				Term:         strings.TrimSpace(row[2]),
+				HasPinyin:    vals[0] == 1,
+				InNotes:      vals[1] == 1,
+				UnigramCount: vals[2],
+				Hamming:      vals[3],
+				IsSubstring:  vals[4] == 1,
+			},
+			Relevant: vals[5] == 1,
+		})
+	}
+	return examples, nil
+}
+
+// features gives the feature vector for the model, normalized by query length
+func features(query string, m Match) []float64 {
+	l := len([]rune(query))
+	if l == 0 {
+		l = 1
+	}
+	return []float64{
+		float64(m.UnigramCount) / float64(l),
+		float64(m.Hamming) / float64(l),
+		boolFeature(m.HasPinyin),
+		boolFeature(m.InNotes),
+		boolFeature(m.IsSubstring),
+	}
+}
+
+func boolFeature(b bool) float64 {
+	if b {
+		return 1.0
+	}
+	return 0.0
+}
@@ -0,0 +1,435 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transmemory
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/alexamies/chinesenotes-go/bibnotes"
+)
+
+// Kind of bibnotes.TransRef that refers to a bilingual file
+const parallelKind = "parallel"
+
+// Segment is a Chinese sentence or phrase aligned with its English translation
+type Segment struct {
+	Source string // Chinese
+	Target string // English
+	Origin string // File or document the segment was imported from
+}
+
+// SegmentMatch is a segment similar to a query and the percentage match
+type SegmentMatch struct {
+	Segment
+	Percent int
+}
+
+// SegmentStore holds aligned segments for finding prior translations
+type SegmentStore interface {
+
+	// Add segments to the store, ignoring duplicates
+	Add(segments ...Segment)
+
+	// Match finds up to limit segments with source text matching the query by
+	// at least minPercent, best match first. The percentage is based on the
+	// character edit distance relative to the longer of the two.
+	Match(ctx context.Context, query string, minPercent, limit int) []SegmentMatch
+
+	// Segments gives all the segments in the order added
+	Segments() []Segment
+
+	// Size gives the number of segments in the store
+	Size() int
+}
+
+// memSegmentStore is an in-memory SegmentStore, indexed by character
+type memSegmentStore struct {
+	mu       sync.RWMutex
+	segments []Segment
+	sources  [][]rune
+	charIdx  map[rune][]int
+	seen     map[string]bool
+}
+
+// NewSegmentStore creates an empty in-memory SegmentStore
+func NewSegmentStore() SegmentStore {
+	return &memSegmentStore{
+		segments: []Segment{},
+		sources:  [][]rune{},
+		charIdx:  make(map[rune][]int),
+		seen:     make(map[string]bool),
+	}
+}
+
+func (s *memSegmentStore) Add(segments ...Segment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, seg := range segments {
+		src := normalizeSegment(seg.Source)
+		if len(src) == 0 || len(strings.TrimSpace(seg.Target)) == 0 {
+			continue
+		}
+		key := string(src) + "\t" + seg.Target
+		if s.seen[key] {
+			continue
+		}
+		s.seen[key] = true
+		i := len(s.segments)
+		s.segments = append(s.segments, seg)
+		s.sources = append(s.sources, src)
+		indexed := make(map[rune]bool)
+		for _, r := range src {
+			if !indexed[r] {
+				indexed[r] = true
+				s.charIdx[r] = append(s.charIdx[r], i)
+			}
+		}
+	}
+}
+
+func (s *memSegmentStore) Match(ctx context.Context, query string, minPercent, limit int) []SegmentMatch {
+	matches := []SegmentMatch{}
+	q := normalizeSegment(query)
+	if len(q) == 0 || limit < 1 {
+		return matches
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Only segments sharing a character with the query can match
+	candidates := make(map[int]bool)
+	for _, r := range q {
+		for _, i := range s.charIdx[r] {
+			candidates[i] = true
+		}
+	}
+	for i := range candidates {
+		src := s.sources[i]
+		maxLen := len(src)
+		if len(q) > maxLen {
+			maxLen = len(q)
+		}
+		// The edit distance is at least the difference in length
+		if matchPercent(absInt(len(src)-len(q)), maxLen) < minPercent {
+			continue
+		}
+		p := matchPercent(editDistance(q, src), maxLen)
+		if p < minPercent {
+			continue
+		}
+		matches = append(matches, SegmentMatch{
+			Segment: s.segments[i],
+			Percent: p,
+		})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Percent != matches[j].Percent {
+			return matches[i].Percent > matches[j].Percent
+		}
+		if matches[i].Source != matches[j].Source {
+			return matches[i].Source < matches[j].Source
+		}
+		return matches[i].Target < matches[j].Target
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+func (s *memSegmentStore) Segments() []Segment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Segment(nil), s.segments...)
+}
+
+func (s *memSegmentStore) Size() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.segments)
+}
+
+// normalizeSegment removes white space, which is not significant in Chinese
+func normalizeSegment(text string) []rune {
+	runes := []rune{}
+	for _, r := range text {
+		if !unicode.IsSpace(r) {
+			runes = append(runes, r)
+		}
+	}
+	return runes
+}
+
+// matchPercent converts an edit distance to a percentage match
+func matchPercent(dist, maxLen int) int {
+	if maxLen == 0 {
+		return 0
+	}
+	return 100 * (maxLen - dist) / maxLen
+}
+
+// editDistance computes the Levenshtein distance between two strings of
+// characters
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// Property type for the origin of a segment in TMX files written
+const tmxOriginProp = "x-origin"
+
+// Elements of a TMX file for reading and writing segments
+type tmxDoc struct {
+	XMLName xml.Name   `xml:"tmx"`
+	Version string     `xml:"version,attr"`
+	Header  *tmxHeader `xml:"header"`
+	TUs     []tmxTU    `xml:"body>tu"`
+}
+
+type tmxHeader struct {
+	CreationTool        string `xml:"creationtool,attr"`
+	CreationToolVersion string `xml:"creationtoolversion,attr"`
+	SegType             string `xml:"segtype,attr"`
+	OTMF                string `xml:"o-tmf,attr"`
+	AdminLang           string `xml:"adminlang,attr"`
+	SrcLang             string `xml:"srclang,attr"`
+	DataType            string `xml:"datatype,attr"`
+}
+
+type tmxTU struct {
+	Props []tmxProp `xml:"prop"`
+	TUVs  []tmxTUV  `xml:"tuv"`
+}
+
+type tmxProp struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type tmxTUV struct {
+	Lang    string `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	OldLang string `xml:"lang,attr,omitempty"` // TMX 1.1 and earlier
+	Seg     tmxSeg `xml:"seg"`
+}
+
+// tmxSeg is the text of a segment. Text in inline markup such as <hi> is kept
+// but the native codes in <ph>, <bpt>, <ept>, <it>, and <ut> are left out,
+// except for text in <sub> elements within them.
+type tmxSeg string
+
+func (t *tmxSeg) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var b strings.Builder
+	// Whether text is kept at each level of nesting, the segment at the bottom
+	keep := []bool{true}
+	for len(keep) > 0 {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := token.(type) {
+		case xml.StartElement:
+			k := keep[len(keep)-1]
+			switch tt.Name.Local {
+			case "ph", "bpt", "ept", "it", "ut":
+				k = false
+			case "sub":
+				k = true
+			}
+			keep = append(keep, k)
+		case xml.EndElement:
+			keep = keep[:len(keep)-1]
+		case xml.CharData:
+			if keep[len(keep)-1] {
+				b.Write(tt)
+			}
+		}
+	}
+	*t = tmxSeg(b.String())
+	return nil
+}
+
+// LoadTMX reads the Chinese and English segments of the translation units
+// in a TMX file. Units without both a Chinese and an English variant are
+// skipped. The origin is taken from an x-origin property of the unit, if any.
+func LoadTMX(r io.Reader, origin string) ([]Segment, error) {
+	var doc tmxDoc
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("transmemory.LoadTMX, could not decode %s: %v", origin, err)
+	}
+	segments := []Segment{}
+	for i, tu := range doc.TUs {
+		seg := Segment{Origin: origin}
+		for _, prop := range tu.Props {
+			if prop.Type == tmxOriginProp && len(prop.Value) > 0 {
+				seg.Origin = prop.Value
+			}
+		}
+		for _, tuv := range tu.TUVs {
+			lang := strings.ToLower(tuv.Lang)
+			if len(lang) == 0 {
+				lang = strings.ToLower(tuv.OldLang)
+			}
+			text := strings.TrimSpace(string(tuv.Seg))
+			if strings.HasPrefix(lang, "zh") && len(seg.Source) == 0 {
+				seg.Source = text
+			} else if strings.HasPrefix(lang, "en") && len(seg.Target) == 0 {
+				seg.Target = text
+			}
+		}
+		if len(seg.Source) == 0 || len(seg.Target) == 0 {
+			log.Printf("transmemory.LoadTMX, %s: skipping unit %d without Chinese and English", origin, i)
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	return segments, nil
+}
+
+// WriteTMX writes the segments to a TMX 1.4 file, with the origin of each
+// segment in an x-origin property
+func WriteTMX(w io.Writer, segments []Segment) error {
+	doc := tmxDoc{
+		Version: "1.4",
+		Header: &tmxHeader{
+			CreationTool:        "chinesenotes-go",
+			CreationToolVersion: "1",
+			SegType:             "sentence",
+			OTMF:                "chinesenotes",
+			AdminLang:           "en",
+			SrcLang:             "zh",
+			DataType:            "plaintext",
+		},
+		TUs: []tmxTU{},
+	}
+	for _, seg := range segments {
+		tu := tmxTU{
+			TUVs: []tmxTUV{
+				{Lang: "zh", Seg: tmxSeg(seg.Source)},
+				{Lang: "en", Seg: tmxSeg(seg.Target)},
+			},
+		}
+		if len(seg.Origin) > 0 {
+			tu.Props = []tmxProp{{Type: tmxOriginProp, Value: seg.Origin}}
+		}
+		doc.TUs = append(doc.TUs, tu)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("transmemory.WriteTMX, could not write header: %v", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("transmemory.WriteTMX, could not encode: %v", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("transmemory.WriteTMX, could not write: %v", err)
+	}
+	return nil
+}
+
+// LoadParallel reads segments from a bilingual file with Chinese and English
+// separated by a tab on each line. Blank lines and lines starting with # are
+// ignored.
+func LoadParallel(r io.Reader, origin string) ([]Segment, error) {
+	segments := []Segment{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			log.Printf("transmemory.LoadParallel, %s: line %d has no translation", origin, lineNo)
+			continue
+		}
+		segments = append(segments, Segment{
+			Source: strings.TrimSpace(fields[0]),
+			Target: strings.TrimSpace(fields[1]),
+			Origin: origin,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("transmemory.LoadParallel, error reading %s: %v", origin, err)
+	}
+	return segments, nil
+}
+
+// LoadTransRefs reads segments from the bilingual files of the parallel
+// translation references, with file names relative to the directory given.
+// Files that cannot be read are logged and skipped.
+func LoadTransRefs(refs []bibnotes.TransRef, dir string) []Segment {
+	segments := []Segment{}
+	for _, ref := range refs {
+		if !strings.EqualFold(ref.Kind, parallelKind) || len(ref.URL) == 0 {
+			continue
+		}
+		if strings.Contains(ref.URL, "://") {
+			log.Printf("transmemory.LoadTransRefs, skipping remote file %s", ref.URL)
+			continue
+		}
+		fName := filepath.Join(dir, ref.URL)
+		f, err := os.Open(fName)
+		if err != nil {
+			log.Printf("transmemory.LoadTransRefs, could not open %s: %v", fName, err)
+			continue
+		}
+		segs, err := LoadParallel(f, ref.URL)
+		f.Close()
+		if err != nil {
+			log.Printf("transmemory.LoadTransRefs, %v", err)
+			continue
+		}
+		segments = append(segments, segs...)
+	}
+	return segments
+}
@@ -0,0 +1,312 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Unit tests for the sentence level translation memory
+
+package transmemory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexamies/chinesenotes-go/bibnotes"
+)
+
+const tmxSmall = `<?xml version="1.0" encoding="UTF-8"?>
+<tmx version="1.4">
+  <header srclang="zh-CN" datatype="plaintext"/>
+  <body>
+    <tu>
+      <tuv xml:lang="zh-CN"><seg>学而时习之，不亦说乎？</seg></tuv>
+      <tuv xml:lang="en-US"><seg>Is it not pleasant to learn with a constant perseverance and application?</seg></tuv>
+    </tu>
+    <tu>
+      <tuv lang="ZH-TW"><seg>有朋自遠方來</seg></tuv>
+      <tuv lang="EN"><seg>That friends should come from distant quarters</seg></tuv>
+    </tu>
+    <tu>
+      <tuv xml:lang="zh"><seg>No English</seg></tuv>
+      <tuv xml:lang="fr"><seg>Pas d'anglais</seg></tuv>
+    </tu>
+  </body>
+</tmx>
+`
+
+func TestEditDistance(t *testing.T) {
+	testCases := []struct {
+		name string
+		a, b string
+		want int
+	}{
+		{
+			name: "Empty",
+			a:    "",
+			b:    "",
+			want: 0,
+		},
+		{
+			name: "Same",
+			a:    "学而时习之",
+			b:    "学而时习之",
+			want: 0,
+		},
+		{
+			name: "One substitution",
+			a:    "学而时习之",
+			b:    "学而常习之",
+			want: 1,
+		},
+		{
+			name: "Insertion and deletion",
+			a:    "学而时习之",
+			b:    "而时习之乎",
+			want: 2,
+		},
+		{
+			name: "One empty",
+			a:    "学而",
+			b:    "",
+			want: 2,
+		},
+	}
+	for _, tc := range testCases {
+		got := editDistance([]rune(tc.a), []rune(tc.b))
+		if got != tc.want {
+			t.Errorf("TestEditDistance %s: got %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSegmentMatch(t *testing.T) {
+	store := NewSegmentStore()
+	store.Add(
+		Segment{Source: "学而时习之", Target: "To learn and practice it", Origin: "a"},
+		Segment{Source: "学而时习之", Target: "To learn and practice it", Origin: "b"},
+		Segment{Source: "有朋自远方来", Target: "Friends come from afar", Origin: "a"},
+		Segment{Source: "人不知而不愠", Target: "Not upset when unrecognized", Origin: "a"},
+		Segment{Source: "", Target: "No source", Origin: "a"},
+		Segment{Source: "没有翻译", Target: " ", Origin: "a"},
+	)
+	if store.Size() != 3 {
+		t.Errorf("TestSegmentMatch: got size %d, want 3", store.Size())
+	}
+	testCases := []struct {
+		name        string
+		query       string
+		minPercent  int
+		limit       int
+		wantLen     int
+		wantSource  string
+		wantPercent int
+	}{
+		{
+			name:       "Empty query",
+			query:      "",
+			minPercent: 0,
+			limit:      5,
+			wantLen:    0,
+		},
+		{
+			name:        "Exact match",
+			query:       "学而时习之",
+			minPercent:  50,
+			limit:       5,
+			wantLen:     1,
+			wantSource:  "学而时习之",
+			wantPercent: 100,
+		},
+		{
+			name:        "White space ignored",
+			query:       " 学而 时习之 ",
+			minPercent:  50,
+			limit:       5,
+			wantLen:     1,
+			wantSource:  "学而时习之",
+			wantPercent: 100,
+		},
+		{
+			name:        "Fuzzy match",
+			query:       "学而常习之",
+			minPercent:  50,
+			limit:       5,
+			wantLen:     1,
+			wantSource:  "学而时习之",
+			wantPercent: 80,
+		},
+		{
+			name:       "Below threshold",
+			query:      "学而常习之",
+			minPercent: 90,
+			limit:      5,
+			wantLen:    0,
+		},
+		{
+			name:        "Several matches",
+			query:       "而不",
+			minPercent:  0,
+			limit:       5,
+			wantLen:     2,
+			wantSource:  "人不知而不愠",
+			wantPercent: 33,
+		},
+		{
+			name:        "Limit",
+			query:       "而不",
+			minPercent:  0,
+			limit:       1,
+			wantLen:     1,
+			wantSource:  "人不知而不愠",
+			wantPercent: 33,
+		},
+		{
+			name:       "No characters in common",
+			query:      "你好",
+			minPercent: 0,
+			limit:      5,
+			wantLen:    0,
+		},
+	}
+	for _, tc := range testCases {
+		got := store.Match(context.TODO(), tc.query, tc.minPercent, tc.limit)
+		if len(got) != tc.wantLen {
+			t.Fatalf("TestSegmentMatch %s: got %d matches, want %d: %v", tc.name,
+				len(got), tc.wantLen, got)
+		}
+		if tc.wantLen == 0 {
+			continue
+		}
+		if got[0].Source != tc.wantSource {
+			t.Errorf("TestSegmentMatch %s: got source %s, want %s", tc.name,
+				got[0].Source, tc.wantSource)
+		}
+		if got[0].Percent != tc.wantPercent {
+			t.Errorf("TestSegmentMatch %s: got percent %d, want %d", tc.name,
+				got[0].Percent, tc.wantPercent)
+		}
+	}
+}
+
+func TestLoadTMX(t *testing.T) {
+	segments, err := LoadTMX(strings.NewReader(tmxSmall), "small.tmx")
+	if err != nil {
+		t.Fatalf("TestLoadTMX: unexpected error: %v", err)
+	}
+	if len(segments) != 2 {
+		t.Fatalf("TestLoadTMX: got %d segments, want 2: %v", len(segments), segments)
+	}
+	if segments[0].Source != "学而时习之，不亦说乎？" {
+		t.Errorf("TestLoadTMX: got source %s", segments[0].Source)
+	}
+	if segments[1].Target != "That friends should come from distant quarters" {
+		t.Errorf("TestLoadTMX: got target %s", segments[1].Target)
+	}
+	if segments[1].Origin != "small.tmx" {
+		t.Errorf("TestLoadTMX: got origin %s", segments[1].Origin)
+	}
+	if _, err := LoadTMX(strings.NewReader("<tmx><body>"), "bad.tmx"); err == nil {
+		t.Error("TestLoadTMX: expected error for truncated file")
+	}
+}
+
+// TestLoadTMXInline tests segments with inline markup from CAT tools
+func TestLoadTMXInline(t *testing.T) {
+	const tmx = `<tmx version="1.4"><body>
+<tu>
+<tuv xml:lang="zh-CN"><seg>你<ph x="1">&lt;br/&gt;</ph>好<hi type="b">世界</hi></seg></tuv>
+<tuv xml:lang="en-US"><seg>hello <bpt i="1">&lt;b&gt;</bpt><hi>world</hi><ept i="1">&lt;/b&gt;</ept><ph>{1}<sub>note</sub></ph></seg></tuv>
+</tu>
+</body></tmx>`
+	segments, err := LoadTMX(strings.NewReader(tmx), "inline.tmx")
+	if err != nil {
+		t.Fatalf("TestLoadTMXInline: unexpected error: %v", err)
+	}
+	want := Segment{Source: "你好世界", Target: "hello worldnote", Origin: "inline.tmx"}
+	if len(segments) != 1 || segments[0] != want {
+		t.Errorf("TestLoadTMXInline: got %v, want %v", segments, want)
+	}
+}
+
+func TestLoadParallel(t *testing.T) {
+	const parallel = `# Analects
+学而时习之	To learn and practice it
+
+有朋自远方来	Friends come from afar
+没有翻译
+`
+	segments, err := LoadParallel(strings.NewReader(parallel), "analects.tsv")
+	if err != nil {
+		t.Fatalf("TestLoadParallel: unexpected error: %v", err)
+	}
+	if len(segments) != 2 {
+		t.Fatalf("TestLoadParallel: got %d segments, want 2: %v", len(segments), segments)
+	}
+	want := Segment{Source: "有朋自远方来", Target: "Friends come from afar", Origin: "analects.tsv"}
+	if segments[1] != want {
+		t.Errorf("TestLoadParallel: got %v, want %v", segments[1], want)
+	}
+}
+
+func TestLoadTransRefs(t *testing.T) {
+	dir := t.TempDir()
+	fName := "analects_parallel.tsv"
+	content := "学而时习之\tTo learn and practice it\n"
+	if err := os.WriteFile(filepath.Join(dir, fName), []byte(content), 0644); err != nil {
+		t.Fatalf("TestLoadTransRefs: could not write file: %v", err)
+	}
+	refs := []bibnotes.TransRef{
+		{Kind: "full", Ref: "Legge 1898", URL: "https://example.com/legge"},
+		{Kind: "parallel", Ref: "Bilingual edition", URL: fName},
+		{Kind: "parallel", Ref: "Missing", URL: "missing.tsv"},
+	}
+	segments := LoadTransRefs(refs, dir)
+	if len(segments) != 1 {
+		t.Fatalf("TestLoadTransRefs: got %d segments, want 1: %v", len(segments), segments)
+	}
+	if segments[0].Origin != fName {
+		t.Errorf("TestLoadTransRefs: got origin %s, want %s", segments[0].Origin, fName)
+	}
+}
+
+func TestWriteTMX(t *testing.T) {
+	segments := []Segment{
+		{Source: "学而时习之", Target: "To learn & practice it", Origin: "analects.tsv"},
+		{Source: "有朋自远方来", Target: "Friends come from afar"},
+	}
+	var buf strings.Builder
+	if err := WriteTMX(&buf, segments); err != nil {
+		t.Fatalf("TestWriteTMX: unexpected error: %v", err)
+	}
+	tmx := buf.String()
+	for _, want := range []string{`<tmx version="1.4">`, `srclang="zh"`,
+		`<tuv xml:lang="en">`, "To learn &amp; practice it"} {
+		if !strings.Contains(tmx, want) {
+			t.Errorf("TestWriteTMX: expected %q in %s", want, tmx)
+		}
+	}
+	got, err := LoadTMX(strings.NewReader(tmx), "export.tmx")
+	if err != nil {
+		t.Fatalf("TestWriteTMX: unexpected error reading back: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("TestWriteTMX: got %d segments back, want 2", len(got))
+	}
+	if got[0] != segments[0] {
+		t.Errorf("TestWriteTMX: got %v, want %v", got[0], segments[0])
+	}
+	want := Segment{Source: "有朋自远方来", Target: "Friends come from afar", Origin: "export.tmx"}
+	if got[1] != want {
+		t.Errorf("TestWriteTMX: got %v, want %v", got[1], want)
+	}
+}
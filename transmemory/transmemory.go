@@ -22,26 +22,48 @@ import (
 
 	"github.com/alexamies/chinesenotes-go/dictionary"
 	"github.com/alexamies/chinesenotes-go/dicttypes"
+	"github.com/alexamies/chinesenotes-go/pinyin"
 )
 
 const (
 	maxUnigram             = 8
 	maxResultsSubstrings   = 10
 	maxResultsNoSubstrings = 3
-	// Decision point from decision tree classification training for Unicode count / query len
-	// divided by query length
-	uniCountMin float64 = 0.37
-	// Decision point from training for Hamming distance divided by query length / query len
-	hammingDistMax float64 = 0.59
-	// Decision point from training with substrings excluded for Unicode count (greater than or equal)
-	uniCountNoSubMin int = 3
-	// Decision point from training with substrings excluded for Hamming distance (less than or equal)
-	hammingNoSubMax int = 8
+	// Added to the score of results in the preferred domains for ranking
+	domainBoost = 0.25
 )
 
-// Encapsulates search recults
+// Encapsulates search recults, with the matches giving the score and features
+// of the word at the same position
 type Results struct {
-	Words []dicttypes.Word
+	Words        []dicttypes.Word
+	Matches      []Match       `json:",omitempty"`
+	DomainFacets []DomainFacet `json:",omitempty"`
+}
+
+// DomainOptions gives the domains or subdomains to restrict results to or, if
+// boosted, to prefer
+type DomainOptions struct {
+	Domains []string
+	Boost   bool
+}
+
+// DomainFacet gives the number of relevant results in a domain
+type DomainFacet struct {
+	Domain string
+	Count  int
+}
+
+// Match gives the relevance score of a result and the features matched
+type Match struct {
+	Term         string
+	Score        float64
+	UnigramCount int
+	Hamming      int
+	HasPinyin    bool
+	InNotes      bool
+	IsSubstring  bool
+	InDomain     bool
 }
 
 // Encapsulates search recults
@@ -53,6 +75,8 @@ type tmResult struct {
 	inNotes      int
 	isSubstring  int
 	relevant     int
+	score        float64
+	inDomain     int
 }
 
 // Searcher finds similar phrases
@@ -69,6 +93,17 @@ type Searcher interface {
 	Search(ctx context.Context, query string, domain string, includeSubstrings bool, wdict map[string]*dicttypes.Word) (*Results, error)
 }
 
+// DomainSearcher is a Searcher that can also search several domains. The
+// Searchers created in this package are DomainSearchers.
+type DomainSearcher interface {
+	Searcher
+
+	// SearchDomains searches for phrases similar to the given query, restricted
+	// to or boosting results in the domains given, with the number of results
+	// in each domain
+	SearchDomains(ctx context.Context, query string, opts DomainOptions, includeSubstrings bool, wdict map[string]*dicttypes.Word) (*Results, error)
+}
+
 // pinyinSearcher finds similar phrases with matching Pinyin
 type pinyinSearcher interface {
 
@@ -99,18 +134,34 @@ type unigramSearcher interface {
 
 // searcher implements the Searcher interface with pinyin and unigram translation memory searchers
 type searcher struct {
-	ps pinyinSearcher
-	us unigramSearcher
+	ps    pinyinSearcher
+	us    unigramSearcher
+	model RelevanceModel
 }
 
 // newSearcher initializes an implementation of the Searcher interface
 func newSearcher(ps pinyinSearcher, us unigramSearcher) (Searcher, error) {
 	return searcher{
-		ps: ps,
-		us: us,
+		ps:    ps,
+		us:    us,
+		model: DefaultModel,
 	}, nil
 }
 
+// WithModel gives a copy of the Searcher that scores results with the given
+// relevance model, for a Searcher created in this package
+func WithModel(s Searcher, model RelevanceModel) (Searcher, error) {
+	ts, ok := s.(searcher)
+	if !ok {
+		return nil, fmt.Errorf("transmemory.WithModel: unexpected Searcher type %T", s)
+	}
+	if len(model.Weights) != numFeatures {
+		return nil, fmt.Errorf("transmemory.WithModel: got %d weights, expected %d", len(model.Weights), numFeatures)
+	}
+	ts.model = model
+	return ts, nil
+}
+
 // memPinyinSearcher is a translation memory Searcher implementation based on in memory queries for similar pinyin
 type memPinyinSearcher struct {
 	revIndex dictionary.ReverseIndex
@@ -125,12 +176,12 @@ func newMemPinyinSearcher(revIndex dictionary.ReverseIndex) (pinyinSearcher, err
 
 // queryPinyin searches for phrases with matching pinyin
 func (s memPinyinSearcher) queryPinyin(ctx context.Context, query, domain string, wdict map[string]*dicttypes.Word) ([]tmResult, error) {
-	pinyin := findPinyin(query, wdict)
-	if len(pinyin) == 0 {
+	p := findPinyin(query, wdict)
+	if len(p) == 0 {
 		return nil, fmt.Errorf("fsPinyinSearcher.queryPinyin, No pinyin for query,%s", query)
 	}
 	results := []tmResult{}
-	revResults, err := s.revIndex.Find(ctx, pinyin)
+	revResults, err := s.revIndex.Find(ctx, p)
 	if err != nil {
 		return nil, fmt.Errorf("memPinyinSearcher.queryPinyin error from revIndex: %v", err)
 	}
@@ -165,32 +216,86 @@ func (s memPinyinSearcher) queryPinyin(ctx context.Context, query, domain string
 //   A slice of approximate results
 func (s searcher) Search(ctx context.Context, query, domain string, includeSubstrings bool, wdict map[string]*dicttypes.Word) (*Results, error) {
 	// log.Printf("searcher.Search, query: %s domain: %s", query, domain)
+	opts := DomainOptions{}
+	if len(domain) > 0 {
+		opts.Domains = []string{domain}
+	}
+	return s.SearchDomains(ctx, query, opts, includeSubstrings, wdict)
+}
+
+// Searches the translation memory for approximate matches, restricting results
+// to the domains given or, if boosted, ranking results in them higher.
+// Subdomains are matched against the dictionary after querying since the index
+// only has domains.
+func (s searcher) SearchDomains(ctx context.Context, query string, opts DomainOptions, includeSubstrings bool, wdict map[string]*dicttypes.Word) (*Results, error) {
 	if s.ps == nil {
 		return nil, fmt.Errorf("searcher: ps is nil")
 	}
+	domains := []string{}
+	for _, d := range opts.Domains {
+		if d = strings.TrimSpace(d); len(d) > 0 {
+			domains = append(domains, d)
+		}
+	}
+	restrict := len(domains) > 0 && !opts.Boost
 	chars := strings.Split(query, "")
 	var matches []tmResult
-	var err error
 	if s.us != nil {
-		matches, err = s.us.queryUnigram(ctx, chars, domain)
+		var err error
+		matches, err = s.us.queryUnigram(ctx, chars, "")
 		if err != nil {
 			return nil, fmt.Errorf("Search query error:\n%v", err)
 		}
+		// The index for a domain may give matches beyond the query limit
+		for i := 0; restrict && i < len(domains); i++ {
+			domMatches, err := s.us.queryUnigram(ctx, chars, domains[i])
+			if err != nil {
+				return nil, fmt.Errorf("Search query error for domain %s:\n%v", domains[i], err)
+			}
+			matches = append(matches, domMatches...)
+		}
 	}
 	// log.Printf("searcher.Search, %d results found from queryUnigram", len(matches))
-	pinyinMatches, err := s.ps.queryPinyin(ctx, query, domain, wdict)
+	pinyinMatches, err := s.ps.queryPinyin(ctx, query, "", wdict)
+	if err != nil {
+		log.Printf("searcher.Search, no pinyin matches: %v", err)
+	}
+	if restrict {
+		matches = filterDomains(matches, domains, wdict)
+		pinyinMatches = filterDomains(pinyinMatches, domains, wdict)
+	}
 	// log.Printf("searcher.Search, %d results found from pinyinMatches", len(pinyinMatches))
 	if includeSubstrings {
-		words := combineResults(query, matches, pinyinMatches, wdict)
-		return &Results{
-			Words: words,
-		}, nil
-	}
-	words := combineResultsNoSubstrings(query, matches, pinyinMatches, wdict)
-	log.Printf("searcher.Search, %d results found for query: %s", len(words), query)
-	return &Results{
-		Words: words,
-	}, nil
+		return combineResults(s.model, query, domains, matches, pinyinMatches, wdict), nil
+	}
+	results := combineResultsNoSubstrings(s.model, query, domains, matches, pinyinMatches, wdict)
+	log.Printf("searcher.Search, %d results found for query: %s", len(results.Words), query)
+	return results, nil
+}
+
+// filterDomains keeps the matches for words with a sense in one of the domains
+func filterDomains(matches []tmResult, domains []string, wdict map[string]*dicttypes.Word) []tmResult {
+	filtered := []tmResult{}
+	for _, m := range matches {
+		if w, ok := wdict[m.term]; ok && inDomains(w, domains) {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+// inDomains tells whether the word has a sense with a domain or subdomain in
+// the list, in English or Chinese
+func inDomains(w *dicttypes.Word, domains []string) bool {
+	for _, ws := range w.Senses {
+		for _, d := range domains {
+			if strings.EqualFold(d, ws.Domain) || d == ws.DomainCN ||
+				strings.EqualFold(d, ws.Subdomain) || d == ws.SubdomainCN {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func absInt(x int) int {
@@ -201,81 +306,38 @@ func absInt(x int) int {
 }
 
 // combineResults combines matches with dictionary defintions to send back to client
-func combineResults(query string, matches, pinyinMatches []tmResult, wdict map[string]*dicttypes.Word) []dicttypes.Word {
+func combineResults(model RelevanceModel, query string, domains []string, matches, pinyinMatches []tmResult, wdict map[string]*dicttypes.Word) *Results {
 	// log.Printf("combineResults query: %s, uni matches: %d, pinyin matches: %d", query, len(matches), len(pinyinMatches))
 	relevantMap := map[string]tmResult{}
-	for _, m := range matches {
-		m.hamming = hammingDist(query, m.term)
-		m.isSubstring = eitherSubstring(query, m.term)
-		m.relevant = predictRelevanceNorm(query, m)
-		relevantMap[m.term] = m
-	}
-	for _, m := range pinyinMatches {
+	for _, m := range append(matches, pinyinMatches...) {
 		m.hamming = hammingDist(query, m.term)
 		m.isSubstring = eitherSubstring(query, m.term)
-		m.relevant = predictRelevanceNorm(query, m)
-		relevantMap[m.term] = m
+		m.inDomain = domainMatch(m.term, domains, wdict)
+		relevantMap[m.term] = scoreResult(model, query, m)
 	}
 	allMatches := []tmResult{}
 	for _, v := range relevantMap {
 		allMatches = append(allMatches, v)
 	}
+	sortByScore(allMatches)
 	printResults(query, allMatches, "with substrings")
-
-	// Eliminate dups with a map since simplified and traditional may both match
-	uMap := map[int]tmResult{}
-	for _, m := range allMatches {
-		// log.Printf("combineResults query: %s, m.term = %s, m.relevant = %d", query, m.term, m.relevant)
-		if m.relevant == 1 && len(uMap) < maxResultsSubstrings {
-			if w, ok := wdict[m.term]; ok {
-				uMap[w.HeadwordId] = m
-			}
-		}
-	}
-
-	// Sort for most relevant based on unigram count
-	relevantMatches := []tmResult{}
-	for _, m := range uMap {
-		relevantMatches = append(relevantMatches, m)
-	}
-	// log.Printf("combineResults query: %s, len(uMap) = %d, len(relevantMatches) = %d", query, len(uMap), len(relevantMatches))
-	sort.Slice(matches, func(i, j int) bool {
-		return matches[i].unigramCount > matches[j].unigramCount
-	})
-
-	// Transform into a slice of Words
-	var words []dicttypes.Word
-	for _, match := range relevantMatches {
-		if word, ok := wdict[match.term]; ok {
-			words = append(words, *word)
-		}
-	}
-	// log.Printf("transmemory.combineResults, query: %s, matchs (%d): ", query, len(words))
-	return words
+	return rankResults(allMatches, maxResultsSubstrings, wdict)
 }
 
 // Combines matches with dictionary defintions, excluding substrings.
 // It is ok for the query to be a substring of a similar term but not the other
 // way around.
-func combineResultsNoSubstrings(query string,
-	matches, pinyinMatches []tmResult,
-	wdict map[string]*dicttypes.Word) []dicttypes.Word {
+func combineResultsNoSubstrings(model RelevanceModel, query string,
+	domains []string, matches, pinyinMatches []tmResult,
+	wdict map[string]*dicttypes.Word) *Results {
 	relevantMap := map[string]tmResult{}
-	for _, m := range matches {
+	for _, m := range append(matches, pinyinMatches...) {
 		m.hamming = hammingDist(query, m.term)
 		if strings.Contains(m.term, query) {
 			m.isSubstring = 1
 		}
-		m.relevant = predictRelevance(query, m)
-		relevantMap[m.term] = m
-	}
-	for _, m := range pinyinMatches {
-		m.hamming = hammingDist(query, m.term)
-		if strings.Contains(m.term, query) {
-			m.isSubstring = 1
-		}
-		m.relevant = predictRelevance(query, m)
-		relevantMap[m.term] = m
+		m.inDomain = domainMatch(m.term, domains, wdict)
+		relevantMap[m.term] = scoreResult(model, query, m)
 	}
 	allMatches := []tmResult{}
 	for _, v := range relevantMap {
@@ -283,78 +345,112 @@ func combineResultsNoSubstrings(query string,
 			allMatches = append(allMatches, v)
 		}
 	}
+	sortByScore(allMatches)
 	printResults(query, allMatches, "substrings excluded")
+	return rankResults(allMatches, maxResultsNoSubstrings, wdict)
+}
 
-	// Eliminate dups with a map since simplified and traditional may both match
-	uMap := map[int]tmResult{}
-	for _, m := range allMatches {
-		if m.relevant == 1 && len(uMap) < maxResultsNoSubstrings {
-			if w, ok := wdict[m.term]; ok {
-				uMap[w.HeadwordId] = m
-			}
+// rankResults selects the relevant matches, highest score first, and looks up
+// their dictionary entries. Simplified and traditional may both match, only
+// the higher scoring is kept. The domain facets count all relevant words,
+// including those beyond the maximum number of results.
+func rankResults(sortedMatches []tmResult, maxResults int, wdict map[string]*dicttypes.Word) *Results {
+	results := Results{
+		Words:   []dicttypes.Word{},
+		Matches: []Match{},
+	}
+	hwIds := map[int]bool{}
+	domainCounts := map[string]int{}
+	for _, m := range sortedMatches {
+		if m.relevant != 1 {
+			continue
 		}
-	}
-
-	// Sort for most relevant based on unigram count
-	relevantMatches := []tmResult{}
-	for _, m := range uMap {
-		relevantMatches = append(relevantMatches, m)
-	}
-	sort.Slice(relevantMatches, func(i, j int) bool {
-		return relevantMatches[i].unigramCount > relevantMatches[j].unigramCount
-	})
-	var words []dicttypes.Word
-	for _, match := range relevantMatches {
-		// log.Printf("transmemory.combineResultsNoSubstrings, words: %s",match.term)
-		if word, ok := wdict[match.term]; ok {
-			words = append(words, *word)
+		w, ok := wdict[m.term]
+		if !ok || hwIds[w.HeadwordId] {
+			continue
 		}
-	}
-	//log.Printf("transmemory.combineResultsNoSubstrings, query: %s, matchs (%d): ", query, len(words))
-	return words
+		hwIds[w.HeadwordId] = true
+		for d := range wordDomains(w) {
+			domainCounts[d]++
+		}
+		if len(results.Words) >= maxResults {
+			continue
+		}
+		results.Words = append(results.Words, *w)
+		results.Matches = append(results.Matches, Match{
+			Term:         m.term,
+			Score:        m.score,
+			UnigramCount: m.unigramCount,
+			Hamming:      m.hamming,
+			HasPinyin:    m.hasPinyin == 1,
+			InNotes:      m.inNotes == 1,
+			IsSubstring:  m.isSubstring == 1,
+			InDomain:     m.inDomain == 1,
+		})
+	}
+	for d, n := range domainCounts {
+		results.DomainFacets = append(results.DomainFacets, DomainFacet{
+			Domain: d,
+			Count:  n,
+		})
+	}
+	sort.Slice(results.DomainFacets, func(i, j int) bool {
+		if results.DomainFacets[i].Count != results.DomainFacets[j].Count {
+			return results.DomainFacets[i].Count > results.DomainFacets[j].Count
+		}
+		return results.DomainFacets[i].Domain < results.DomainFacets[j].Domain
+	})
+	return &results
 }
 
-// Predict relevance based on parameters (not normaized by query length)
-// Returns
-//   1 if relevant, 0 if not relevant
-func predictRelevance(query string, m tmResult) int {
-	l := len([]rune(query))
-	if l == 0 {
-		return 0
-	}
-	if m.isSubstring == 1 || m.inNotes == 1 {
-		return 1
-	}
-	if m.unigramCount >= 1 && m.hasPinyin == 1 {
-		return 1
-	}
-	if m.unigramCount >= uniCountNoSubMin && m.hamming <= hammingNoSubMax {
+// domainMatch gives 1 if the term is in one of the domains, 0 otherwise
+func domainMatch(term string, domains []string, wdict map[string]*dicttypes.Word) int {
+	if w, ok := wdict[term]; ok && len(domains) > 0 && inDomains(w, domains) {
 		return 1
 	}
 	return 0
 }
 
-// Predict relevance based on parameters normaized by query length
-// Returns
-//   1 if relevant, 0 if not relevant
-func predictRelevanceNorm(query string, m tmResult) int {
-	l := len([]rune(query))
-	if l == 0 {
-		return 0
-	}
-	if m.isSubstring == 1 || m.inNotes == 1 {
-		return 1
+// wordDomains gives the set of domains of the senses of a word
+func wordDomains(w *dicttypes.Word) map[string]bool {
+	domains := map[string]bool{}
+	for _, ws := range w.Senses {
+		if len(ws.Domain) > 0 && ws.Domain != "\\N" {
+			domains[ws.Domain] = true
+		}
 	}
-	// log.Printf("predictRelevanceNorm, query: %s, term: %s, unigramCount: %d, hasPinyin: %d", query, m.term, m.unigramCount, m.hasPinyin)
-	if m.unigramCount >= 1 && m.hasPinyin == 1 {
-		return 1
+	return domains
+}
+
+// scoreResult sets the score and relevance of a match from the model
+func scoreResult(model RelevanceModel, query string, m tmResult) tmResult {
+	m.score = model.Score(query, Match{
+		Term:         m.term,
+		UnigramCount: m.unigramCount,
+		Hamming:      m.hamming,
+		HasPinyin:    m.hasPinyin == 1,
+		InNotes:      m.inNotes == 1,
+		IsSubstring:  m.isSubstring == 1,
+	})
+	m.relevant = 0
+	if len(query) > 0 && m.score >= relevanceThreshold {
+		m.relevant = 1
 	}
-	normalUni := float64(m.unigramCount) / float64(l)
-	normalHamming := float64(m.hamming) / float64(l)
-	if normalUni >= uniCountMin && normalHamming <= hammingDistMax {
-		return 1
+	return m
+}
+
+// sortByScore sorts matches with the highest score first, boosted for those
+// in the preferred domains, then by term
+func sortByScore(matches []tmResult) {
+	rankScore := func(m tmResult) float64 {
+		return m.score + domainBoost*float64(m.inDomain)
 	}
-	return 0
+	sort.Slice(matches, func(i, j int) bool {
+		if rankScore(matches[i]) != rankScore(matches[j]) {
+			return rankScore(matches[i]) > rankScore(matches[j])
+		}
+		return matches[i].term < matches[j].term
+	})
 }
 
 // charsContained computes the number of overlapping chars contained in the query and the term
@@ -380,17 +476,17 @@ func charsContained(query, simplified, traditional string) int {
 
 // Finds the pinyin for a given Chinese string
 func findPinyin(query string, wdict map[string]*dicttypes.Word) string {
-	pinyin := ""
+	p := ""
 	chars := strings.Split(query, "")
 	for _, ch := range chars {
 		word, ok := wdict[ch]
 		if ok {
-			pinyin += dicttypes.NormalizePinyin(word.Pinyin)
+			p += pinyin.Normalize(word.Pinyin)
 		} else {
 			log.Printf("findPinyin: query %s, char %s not found", query, ch)
 		}
 	}
-	return pinyin
+	return p
 }
 
 // Get the characters in the search query, padding to maxUnigram with the
@@ -448,14 +544,14 @@ func printResults(query string, matches []tmResult, description string) {
 		return
 	}
 	log.Printf("\nQuery, rank, Term, Has Pinyin, In Notes, Unigram count, " +
-		"Hamming, Substring, Relevant\n")
+		"Hamming, Substring, Relevant, Score\n")
 	for i, m := range matches {
 		if i == 10 {
 			break
 		}
 		log.Printf("transmemory.printTopResults result: %s, %d, %s, %d, %d, %d, "+
-			"%d, %d, %d\n", query, i, m.term, m.hasPinyin, m.inNotes,
-			m.unigramCount, m.hamming, m.isSubstring, m.relevant)
+			"%d, %d, %d, %.3f\n", query, i, m.term, m.hasPinyin, m.inNotes,
+			m.unigramCount, m.hamming, m.isSubstring, m.relevant, m.score)
 	}
 	log.Printf("transmemory.printResults %s, query: %s, matchs (%d): ",
 		description, query, len(matches))
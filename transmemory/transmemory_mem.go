@@ -0,0 +1,128 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transmemory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/alexamies/chinesenotes-go/dictionary"
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+)
+
+// Maximum number of unigram matches, the same as the Firestore query limit
+const maxUnigramResults = 2000
+
+// memUnigramSearcher is a unigramSearcher implementation with an in-memory
+// index of characters to the dictionary terms containing them, equivalent to
+// the tmindex_unigram and tmindex_uni_domain tables
+type memUnigramSearcher struct {
+	uniIndex map[string][]string            // character -> terms
+	domIndex map[string]map[string][]string // domain -> character -> terms
+}
+
+// NewMemSearcher initializes a Searcher implementation with in-memory unigram
+// and pinyin searchers, for when Firestore is not used
+func NewMemSearcher(wdict map[string]*dicttypes.Word, revIndex dictionary.ReverseIndex) (Searcher, error) {
+	us, err := newMemUnigramSearcher(wdict)
+	if err != nil {
+		return nil, fmt.Errorf("error from newMemUnigramSearcher: %v", err)
+	}
+	ps, err := newMemPinyinSearcher(revIndex)
+	if err != nil {
+		return nil, fmt.Errorf("error from newMemPinyinSearcher: %v", err)
+	}
+	return newSearcher(ps, us)
+}
+
+// newMemUnigramSearcher builds the character index from the simplified and
+// traditional terms in the dictionary, with each character indexed once per
+// term
+func newMemUnigramSearcher(wdict map[string]*dicttypes.Word) (unigramSearcher, error) {
+	if wdict == nil {
+		return nil, fmt.Errorf("dictionary is nil")
+	}
+	terms := []string{}
+	for term := range wdict {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	uniIndex := make(map[string][]string)
+	domIndex := make(map[string]map[string][]string)
+	for _, term := range terms {
+		domains := make(map[string]bool)
+		for _, ws := range wdict[term].Senses {
+			if len(ws.Domain) > 0 && ws.Domain != "\\N" {
+				domains[ws.Domain] = true
+			}
+		}
+		seen := make(map[rune]bool)
+		for _, r := range term {
+			if seen[r] {
+				continue
+			}
+			seen[r] = true
+			ch := string(r)
+			uniIndex[ch] = append(uniIndex[ch], term)
+			for d := range domains {
+				if _, ok := domIndex[d]; !ok {
+					domIndex[d] = make(map[string][]string)
+				}
+				domIndex[d][ch] = append(domIndex[d][ch], term)
+			}
+		}
+	}
+	return memUnigramSearcher{
+		uniIndex: uniIndex,
+		domIndex: domIndex,
+	}, nil
+}
+
+// queryUnigram searches for terms with matching characters, counting the
+// number of different characters matched, restricted to terms with a sense in
+// the domain, if given
+func (s memUnigramSearcher) queryUnigram(ctx context.Context, chars []string, domain string) ([]tmResult, error) {
+	index := s.uniIndex
+	if len(domain) > 0 {
+		index = s.domIndex[domain]
+	}
+	counts := make(map[string]int)
+	seen := make(map[string]bool)
+	for _, ch := range chars {
+		if seen[ch] {
+			continue
+		}
+		seen[ch] = true
+		for _, term := range index[ch] {
+			counts[term]++
+		}
+	}
+	results := []tmResult{}
+	for term, n := range counts {
+		results = append(results, tmResult{
+			term:         term,
+			unigramCount: n,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].unigramCount != results[j].unigramCount {
+			return results[i].unigramCount > results[j].unigramCount
+		}
+		return results[i].term < results[j].term
+	})
+	if len(results) > maxUnigramResults {
+		results = results[:maxUnigramResults]
+	}
+	return results, nil
+}
@@ -16,6 +16,7 @@ package transmemory
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/alexamies/chinesenotes-go/dictionary"
@@ -140,6 +141,16 @@ func (m mockUnigramSearcher) queryUnigram(ctx context.Context, chars []string, d
 	return []tmResult{}, nil
 }
 
+type mockPinyinSearcher struct{}
+
+func newMockPinyinSearcher() pinyinSearcher {
+	return mockPinyinSearcher{}
+}
+
+func (m mockPinyinSearcher) queryPinyin(ctx context.Context, query, domain string, wdict map[string]*dicttypes.Word) ([]tmResult, error) {
+	return []tmResult{{term: "開花結實", unigramCount: 2}, {term: "事實求是", unigramCount: 1}}, nil
+}
+
 // Test combineResults function
 func TestCombineResults(t *testing.T) {
 	type test struct {
@@ -225,12 +236,17 @@ func TestCombineResults(t *testing.T) {
 	}
 	wdict := mockDict()
 	for _, tc := range tests {
-		result := combineResults(tc.query, tc.matches, tc.pinyinMatches, wdict)
-		if tc.expectLen != len(result) {
+		result := combineResults(DefaultModel, tc.query, nil, tc.matches, tc.pinyinMatches, wdict)
+		if tc.expectLen != len(result.Words) || tc.expectLen != len(result.Matches) {
 			t.Errorf("%s: expected len %d, got %d", tc.name, tc.expectLen,
-				len(result))
+				len(result.Words))
 			continue
 		}
+		for i := 1; i < len(result.Matches); i++ {
+			if result.Matches[i-1].Score < result.Matches[i].Score {
+				t.Errorf("%s: results not sorted by score: %v", tc.name, result.Matches)
+			}
+		}
 	}
 }
 
@@ -316,185 +332,142 @@ func TestCombineResultsNoSubstrings(t *testing.T) {
 	}
 	wdict := mockDict()
 	for _, tc := range tests {
-		result := combineResultsNoSubstrings(tc.query, tc.matches,
+		result := combineResultsNoSubstrings(DefaultModel, tc.query, nil, tc.matches,
 			tc.pinyinMatches, wdict)
-		if tc.expectLen != len(result) {
+		if tc.expectLen != len(result.Words) {
 			t.Errorf("%s: expected len %d, got %d", tc.name, tc.expectLen,
-				len(result))
+				len(result.Words))
 			continue
 		}
 	}
 }
 
-// Test predictRelevance function (not normalized for query length)
-func TestPredictRelevance(t *testing.T) {
+// Test the relevance score of the default model
+func TestDefaultModelScore(t *testing.T) {
 	type test struct {
 		name   string
 		query  string
-		match  tmResult
-		expect int
-	}
-	mPartial := tmResult{
-		term:         "結",
-		unigramCount: 1,
-		hamming:      1,
-	}
-	mExact := tmResult{
-		term:         "結實",
-		unigramCount: 2,
-		hamming:      0,
-	}
-	mMostlyMatching := tmResult{
-		term:         "一指禪",
-		unigramCount: 3,
-		hamming:      2,
-	}
-	mNoOverlap := tmResult{
-		term:         "",
-		unigramCount: 0,
-		hamming:      2,
-	}
-	mLong := tmResult{
-		term:         "大方廣入如來智德不思議經",
-		unigramCount: 2,
-		hamming:      12,
-	}
-	mJizhuang := tmResult{
-		term:         "基樁",
-		unigramCount: 0,
-		hamming:      2,
-		hasPinyin:    1,
-	}
-	mXinshui := tmResult{
-		term:         "薪水",
-		unigramCount: 1,
-		hamming:      1,
-		hasPinyin:    1,
+		match  Match
+		expect bool
 	}
 	tests := []test{
 		{
 			name:   "Partial match",
 			query:  "結實",
-			match:  mPartial,
-			expect: 0,
+			match:  Match{Term: "結", UnigramCount: 1, Hamming: 1},
+			expect: true,
 		},
 		{
 			name:   "Exact match",
 			query:  "結實",
-			match:  mExact,
-			expect: 0,
+			match:  Match{Term: "結實", UnigramCount: 2, Hamming: 0},
+			expect: true,
 		},
 		{
 			name:   "Mostly matching",
 			query:  "一指頭禪",
-			match:  mMostlyMatching,
-			expect: 1,
+			match:  Match{Term: "一指禪", UnigramCount: 3, Hamming: 2},
+			expect: true,
 		},
 		{
 			name:   "differenter and differenter",
 			query:  "結實",
-			match:  mNoOverlap,
-			expect: 0,
+			match:  Match{Term: "", UnigramCount: 0, Hamming: 2},
+			expect: false,
 		},
 		{
 			name:   "long example",
 			query:  "把手拽不入",
-			match:  mLong,
-			expect: 0,
+			match:  Match{Term: "大方廣入如來智德不思議經", UnigramCount: 2, Hamming: 12},
+			expect: false,
 		},
 		{
 			name:   "pinyin match but no chars",
 			query:  "齎裝",
-			match:  mJizhuang,
-			expect: 0,
+			match:  Match{Term: "基樁", UnigramCount: 0, Hamming: 2, HasPinyin: true},
+			expect: false,
 		},
 		{
 			name:   "pinyin match, one char",
 			query:  "薪水",
-			match:  mXinshui,
-			expect: 1,
+			match:  Match{Term: "薪水", UnigramCount: 1, Hamming: 1, HasPinyin: true},
+			expect: true,
+		},
+		{
+			name:   "query is a substring",
+			query:  "結實",
+			match:  Match{Term: "開花結實", UnigramCount: 2, Hamming: 4, IsSubstring: true},
+			expect: true,
 		},
 	}
 	for _, tc := range tests {
-		result := predictRelevance(tc.query, tc.match)
-		if tc.expect != result {
-			t.Errorf("%s: query %s expected %d, got %d", tc.name, tc.query, tc.expect,
-				result)
+		score := DefaultModel.Score(tc.query, tc.match)
+		if tc.expect != (score >= relevanceThreshold) {
+			t.Errorf("%s: query %s expected relevant %t, got score %f", tc.name,
+				tc.query, tc.expect, score)
 		}
 	}
 }
 
-// Test predictRelevance function, normalized for query length
-func TestPredictRelevanceNorm(t *testing.T) {
-	type test struct {
-		name   string
-		query  string
-		match  tmResult
-		expect int
+// Test training a model from labelled examples
+func TestTrainModel(t *testing.T) {
+	const labelled = `Query, rank, Term, Has Pinyin, In Notes, Unigram count, Hamming, Substring, Relevant
+# Comments are ignored
+結實, 0, 結實, 1, 0, 2, 0, 0, 1
+結實, 1, 開花結實, 0, 0, 2, 4, 1, 1
+一指頭禪, 0, 一指禪, 0, 0, 3, 2, 0, 1
+薪水, 0, 薪水, 1, 0, 1, 1, 0, 1
+把手拽不入, 0, 大方廣入如來智德不思議經, 0, 0, 2, 12, 0, 0
+把手拽不入, 1, 從門入者不是家珍, 0, 0, 2, 8, 0, 0
+齎裝, 0, 基樁, 1, 0, 0, 2, 0, 0
+結實, 2, 事實求是, 0, 0, 1, 4, 0, 0
+`
+	examples, err := LoadExamples(strings.NewReader(labelled))
+	if err != nil {
+		t.Fatalf("TestTrainModel: unexpected error loading examples: %v", err)
 	}
-	mPartial := tmResult{
-		term:         "結",
-		unigramCount: 1,
-		hamming:      1,
+	if len(examples) != 8 {
+		t.Fatalf("TestTrainModel: got %d examples, want 8", len(examples))
 	}
-	mExact := tmResult{
-		term:         "結實",
-		unigramCount: 2,
-		hamming:      0,
+	want := Match{Term: "開花結實", UnigramCount: 2, Hamming: 4, IsSubstring: true}
+	if examples[1].Query != "結實" || examples[1].Match != want || !examples[1].Relevant {
+		t.Errorf("TestTrainModel: got example %v, want %v", examples[1], want)
 	}
-	mMostlyMatching := tmResult{
-		term:         "一指禪",
-		unigramCount: 3,
-		hamming:      2,
+	model, err := TrainModel(examples)
+	if err != nil {
+		t.Fatalf("TestTrainModel: unexpected error training: %v", err)
 	}
-	mNoOverlap := tmResult{
-		term:         "",
-		unigramCount: 0,
-		hamming:      2,
+	for _, e := range examples {
+		score := model.Score(e.Query, e.Match)
+		if e.Relevant != (score >= relevanceThreshold) {
+			t.Errorf("TestTrainModel: %s, %s expected relevant %t, got score %f",
+				e.Query, e.Match.Term, e.Relevant, score)
+		}
 	}
-	mLong := tmResult{
-		term:         "大方廣入如來智德不思議經)",
-		unigramCount: 2,
-		hamming:      12,
+	wdict := mockDict()
+	s, err := newSearcher(newMockPinyinSearcher(), nil)
+	if err != nil {
+		t.Fatalf("TestTrainModel: cannot create a searcher: %v", err)
 	}
-	tests := []test{
-		{
-			name:   "Partial match",
-			query:  "結實",
-			match:  mPartial,
-			expect: 1,
-		},
-		{
-			name:   "Exact match",
-			query:  "結實",
-			match:  mExact,
-			expect: 1,
-		},
-		{
-			name:   "Mostly matching",
-			query:  "一指頭禪",
-			match:  mMostlyMatching,
-			expect: 1,
-		},
-		{
-			name:   "differenter and differenter",
-			query:  "結實",
-			match:  mNoOverlap,
-			expect: 0,
-		},
-		{
-			name:   "long example",
-			query:  "把手拽不入",
-			match:  mLong,
-			expect: 0,
-		},
+	s, err = WithModel(s, model)
+	if err != nil {
+		t.Fatalf("TestTrainModel: unexpected error setting model: %v", err)
 	}
-	for _, tc := range tests {
-		result := predictRelevanceNorm(tc.query, tc.match)
-		if tc.expect != result {
-			t.Errorf("%s: query %s expected %d, got %d", tc.name, tc.query, tc.expect,
-				result)
-		}
+	results, err := s.Search(context.Background(), "結實", "", true, wdict)
+	if err != nil {
+		t.Fatalf("TestTrainModel: error calling search: %v", err)
+	}
+	if len(results.Matches) != 1 || results.Matches[0].Term != "開花結實" || !results.Matches[0].IsSubstring {
+		t.Errorf("TestTrainModel: unexpected matches %v", results.Matches)
+	}
+	if _, err := WithModel(s, RelevanceModel{}); err == nil {
+		t.Error("TestTrainModel: expected error for model without weights")
+	}
+	if _, err := TrainModel(nil); err == nil {
+		t.Error("TestTrainModel: expected error with no examples")
+	}
+	if _, err := LoadExamples(strings.NewReader("header\n結實, 0, 結實, x, 0, 2, 0, 0, 1\n")); err == nil {
+		t.Error("TestTrainModel: expected error with bad value")
 	}
 }
 
@@ -633,3 +606,217 @@ func TestSearch(t *testing.T) {
 		}
 	}
 }
+
+func TestMemUnigramSearcher(t *testing.T) {
+	wdict := mockDict()
+	idiom := dicttypes.Word{
+		Simplified:  "开花结实",
+		Traditional: "開花結實",
+		Pinyin:      "kāi huā jiē shi",
+		HeadwordId:  100973,
+		Senses:      []dicttypes.WordSense{{Domain: "Idiom"}},
+	}
+	wdict[idiom.Traditional] = &idiom
+	us, err := newMemUnigramSearcher(wdict)
+	if err != nil {
+		t.Fatalf("TestMemUnigramSearcher: cannot create a unigram searcher: %v", err)
+	}
+	type test struct {
+		name   string
+		chars  []string
+		domain string
+		expect []tmResult
+	}
+	tests := []test{
+		{
+			name:   "Happy path",
+			chars:  strings.Split("結識", ""),
+			domain: "",
+			expect: []tmResult{
+				{term: "結", unigramCount: 1},
+				{term: "結實", unigramCount: 1},
+				{term: "識", unigramCount: 1},
+				{term: "開花結實", unigramCount: 1},
+			},
+		},
+		{
+			name:   "Repeated character counted once",
+			chars:  strings.Split("結實結", ""),
+			domain: "",
+			expect: []tmResult{
+				{term: "結實", unigramCount: 2},
+				{term: "開花結實", unigramCount: 2},
+				{term: "事實求是", unigramCount: 1},
+				{term: "實", unigramCount: 1},
+				{term: "結", unigramCount: 1},
+			},
+		},
+		{
+			name:   "With domain",
+			chars:  strings.Split("結識", ""),
+			domain: "Idiom",
+			expect: []tmResult{
+				{term: "開花結實", unigramCount: 1},
+			},
+		},
+		{
+			name:   "No match in domain",
+			chars:  strings.Split("結識", ""),
+			domain: "Buddhism",
+			expect: []tmResult{},
+		},
+	}
+	for _, tc := range tests {
+		results, err := us.queryUnigram(context.Background(), tc.chars, tc.domain)
+		if err != nil {
+			t.Fatalf("TestMemUnigramSearcher.%s: unexpected error: %v", tc.name, err)
+		}
+		if len(results) != len(tc.expect) {
+			t.Fatalf("TestMemUnigramSearcher.%s: got %v, want %v", tc.name, results, tc.expect)
+		}
+		for i, r := range results {
+			if r.term != tc.expect[i].term || r.unigramCount != tc.expect[i].unigramCount {
+				t.Errorf("TestMemUnigramSearcher.%s: got %v at %d, want %v", tc.name, r, i,
+					tc.expect[i])
+			}
+		}
+	}
+}
+
+func TestNewMemSearcher(t *testing.T) {
+	wdict := mockDict()
+	dict := dictionary.NewDictionary(wdict)
+	extractor, err := dictionary.NewNotesExtractor("")
+	if err != nil {
+		t.Fatalf("TestNewMemSearcher: could not create extractor: %v", err)
+	}
+	revIndex := dictionary.NewReverseIndex(dict, extractor)
+	s, err := NewMemSearcher(wdict, revIndex)
+	if err != nil {
+		t.Fatalf("TestNewMemSearcher: cannot create a searcher: %v", err)
+	}
+	results, err := s.Search(context.Background(), "結識", "", true, wdict)
+	if err != nil {
+		t.Fatalf("TestNewMemSearcher: error calling search: %v", err)
+	}
+	if len(results.Matches) != len(results.Words) {
+		t.Errorf("TestNewMemSearcher: got %d matches for %d words", len(results.Matches),
+			len(results.Words))
+	}
+	found := false
+	for _, w := range results.Words {
+		if w.Traditional == "結實" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("TestNewMemSearcher: got %v, want results to include 結實", results.Words)
+	}
+	if _, err := NewMemSearcher(nil, revIndex); err == nil {
+		t.Error("TestNewMemSearcher: expected error for nil dictionary")
+	}
+}
+
+func TestSearchDomains(t *testing.T) {
+	wdict := mockDict()
+	idiom := dicttypes.Word{
+		Simplified:  "开花结实",
+		Traditional: "開花結實",
+		Pinyin:      "kāi huā jiē shi",
+		HeadwordId:  100973,
+		Senses: []dicttypes.WordSense{{
+			Domain:    "Literary Chinese",
+			DomainCN:  "文言文",
+			Subdomain: "Idiom",
+		}},
+	}
+	wdict[idiom.Traditional] = &idiom
+	dict := dictionary.NewDictionary(wdict)
+	extractor, err := dictionary.NewNotesExtractor("")
+	if err != nil {
+		t.Fatalf("TestSearchDomains: could not create extractor: %v", err)
+	}
+	revIndex := dictionary.NewReverseIndex(dict, extractor)
+	tms, err := NewMemSearcher(wdict, revIndex)
+	if err != nil {
+		t.Fatalf("TestSearchDomains: cannot create a searcher: %v", err)
+	}
+	s, ok := tms.(DomainSearcher)
+	if !ok {
+		t.Fatalf("TestSearchDomains: searcher is not a DomainSearcher: %T", tms)
+	}
+	type test struct {
+		name         string
+		opts         DomainOptions
+		expectTop    string
+		expectNo     int
+		expectFacets []DomainFacet
+	}
+	tests := []test{
+		{
+			name:         "No domain",
+			opts:         DomainOptions{},
+			expectTop:    "結實",
+			expectNo:     4,
+			expectFacets: []DomainFacet{{Domain: "Literary Chinese", Count: 1}},
+		},
+		{
+			name:         "Restrict to domain",
+			opts:         DomainOptions{Domains: []string{"Buddhism", "literary chinese"}},
+			expectTop:    "開花結實",
+			expectNo:     1,
+			expectFacets: []DomainFacet{{Domain: "Literary Chinese", Count: 1}},
+		},
+		{
+			name:         "Restrict to subdomain",
+			opts:         DomainOptions{Domains: []string{"Idiom"}},
+			expectTop:    "開花結實",
+			expectNo:     1,
+			expectFacets: []DomainFacet{{Domain: "Literary Chinese", Count: 1}},
+		},
+		{
+			name:         "Restrict to Chinese domain name",
+			opts:         DomainOptions{Domains: []string{"文言文"}},
+			expectTop:    "開花結實",
+			expectNo:     1,
+			expectFacets: []DomainFacet{{Domain: "Literary Chinese", Count: 1}},
+		},
+		{
+			name:         "Boost domain",
+			opts:         DomainOptions{Domains: []string{"Idiom"}, Boost: true},
+			expectTop:    "開花結實",
+			expectNo:     4,
+			expectFacets: []DomainFacet{{Domain: "Literary Chinese", Count: 1}},
+		},
+		{
+			name:      "No match in domain",
+			opts:      DomainOptions{Domains: []string{"Buddhism"}},
+			expectTop: "",
+			expectNo:  0,
+		},
+	}
+	for _, tc := range tests {
+		results, err := s.SearchDomains(context.Background(), "結實", tc.opts, true, wdict)
+		if err != nil {
+			t.Fatalf("TestSearchDomains.%s: error calling search: %v", tc.name, err)
+		}
+		if len(results.Words) != tc.expectNo {
+			t.Errorf("TestSearchDomains.%s: got %d results, want %d: %v", tc.name,
+				len(results.Words), tc.expectNo, results.Matches)
+		}
+		if len(results.Words) > 0 && results.Words[0].Traditional != tc.expectTop {
+			t.Errorf("TestSearchDomains.%s: got top %s, want %s", tc.name,
+				results.Words[0].Traditional, tc.expectTop)
+		}
+		if len(results.DomainFacets) != len(tc.expectFacets) {
+			t.Fatalf("TestSearchDomains.%s: got facets %v, want %v", tc.name,
+				results.DomainFacets, tc.expectFacets)
+		}
+		for i, f := range results.DomainFacets {
+			if f != tc.expectFacets[i] {
+				t.Errorf("TestSearchDomains.%s: got facet %v, want %v", tc.name, f,
+					tc.expectFacets[i])
+			}
+		}
+	}
+}
@@ -12,7 +12,57 @@
 
 package transtools
 
+import "context"
+
+// Names of the translation providers, given in the results
+const (
+	ProviderDeepL    = "DeepL"
+	ProviderGoogle   = "gcp"
+	ProviderGlossary = "glossary"
+)
+
+// Default languages, when not given in the request
+const (
+	DefaultSourceLang = "zh"
+	DefaultTargetLang = "en"
+)
+
+// Request holds text to translate and the translation options. Options that a
+// provider does not support are ignored.
+type Request struct {
+	Text string
+	// Language code of the source text, detected if empty by providers that can
+	SourceLang string
+	// Language code to translate to, default English
+	TargetLang string
+	// Glossary to use, instead of the client's glossary
+	GlossaryID string
+	// Formality of the translation, more or less, supported by DeepL
+	Formality string
+	// Surrounding text to help the translation, not translated itself,
+	// supported by DeepL
+	Context string
+}
+
+// Result holds a translation with the detected source language and metadata
+// about the provider
+type Result struct {
+	Text               string
+	DetectedSourceLang string
+	Provider           string
+	Model              string
+	GlossaryID         string
+}
+
 // ApiClient is an interface for translation API clients
 type ApiClient interface {
-	Translate(sourceText string) (*string, error)
+	Translate(ctx context.Context, req Request) (*Result, error)
+}
+
+// targetLang gives the target language of the request or the default
+func (req Request) targetLang() string {
+	if len(req.TargetLang) > 0 {
+		return req.TargetLang
+	}
+	return DefaultTargetLang
 }
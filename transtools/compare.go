@@ -0,0 +1,223 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Comparison of translations from several machine translation engines
+
+package transtools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// ChoiceMerged is the engine given for a choice when the user edited the
+// translation rather than picking one of the outputs as is
+const ChoiceMerged = "merged"
+
+// Engine is a translation client with a name to show the user
+type Engine struct {
+	Name   string
+	Client ApiClient
+}
+
+// Comparison is the output of one engine, with the words that differ from the
+// other engines highlighted
+type Comparison struct {
+	Engine string
+	Result *Result
+
+	// Error message if the engine failed or timed out, in which case Result is
+	// nil
+	Error string
+
+	// Hints from post-processing
+	Notes []Note
+
+	// The words of the translation, with those not found in all the other
+	// translations marked
+	Words []DiffWord
+}
+
+// DiffWord is a word in a translation, marked if it differs from the other
+// translations
+type DiffWord struct {
+	Text    string
+	Differs bool
+}
+
+// Choice is the translation a user chose from the outputs of the engines,
+// saved for later evaluation
+type Choice struct {
+	Time   time.Time
+	Source string
+
+	// Name of the engine picked or ChoiceMerged if the user edited it
+	Engine string
+	Text   string
+
+	// Translations by engine name
+	Outputs map[string]string
+}
+
+// CompareTranslations translates the request with all the engines
+// concurrently, each limited to the timeout. The comparisons are given in the
+// order of the engines.
+func CompareTranslations(ctx context.Context, engines []Engine, req Request, timeout time.Duration) []Comparison {
+	comparisons := make([]Comparison, len(engines))
+	var wg sync.WaitGroup
+	for i, e := range engines {
+		comparisons[i].Engine = e.Name
+		if e.Client == nil {
+			comparisons[i].Error = fmt.Sprintf("%s is not configured", e.Name)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, e Engine) {
+			defer wg.Done()
+			tctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			result, err := translateWithTimeout(tctx, e.Client, req)
+			if err != nil {
+				comparisons[i].Error = err.Error()
+				return
+			}
+			comparisons[i].Result = result
+		}(i, e)
+	}
+	wg.Wait()
+	return comparisons
+}
+
+// translateWithTimeout returns when the context is done even if the client
+// does not check it
+func translateWithTimeout(ctx context.Context, client ApiClient, req Request) (*Result, error) {
+	type response struct {
+		result *Result
+		err    error
+	}
+	c := make(chan response, 1)
+	go func() {
+		result, err := client.Translate(ctx, req)
+		c <- response{result, err}
+	}()
+	select {
+	case resp := <-c:
+		return resp.result, resp.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("translation did not complete: %v", ctx.Err())
+	}
+}
+
+// HighlightDifferences sets the words of each successful translation, marking
+// those that are not matched, in order, in every other successful translation.
+// Words are matched ignoring case and surrounding punctuation.
+func HighlightDifferences(comparisons []Comparison) {
+	words := make([][]string, len(comparisons))
+	for i, c := range comparisons {
+		if c.Result != nil {
+			words[i] = strings.Fields(c.Result.Text)
+		}
+	}
+	for i, c := range comparisons {
+		if c.Result == nil {
+			continue
+		}
+		common := make([]bool, len(words[i]))
+		for k := range common {
+			common[k] = true
+		}
+		for j, other := range comparisons {
+			if j == i || other.Result == nil {
+				continue
+			}
+			matched := alignWords(words[i], words[j])
+			for k := range common {
+				common[k] = common[k] && matched[k]
+			}
+		}
+		comparisons[i].Words = make([]DiffWord, len(words[i]))
+		for k, w := range words[i] {
+			comparisons[i].Words[k] = DiffWord{Text: w, Differs: !common[k]}
+		}
+	}
+}
+
+// alignWords finds the longest common subsequence of the two lists of words,
+// giving whether each word in the first list is in it
+func alignWords(a, b []string) []bool {
+	na := make([]string, len(a))
+	for i, w := range a {
+		na[i] = normalizeWord(w)
+	}
+	nb := make([]string, len(b))
+	for i, w := range b {
+		nb[i] = normalizeWord(w)
+	}
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if na[i] == nb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	matched := make([]bool, len(a))
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case na[i] == nb[j]:
+			matched[i] = true
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return matched
+}
+
+func normalizeWord(w string) string {
+	t := strings.TrimFunc(w, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(t) == 0 {
+		return w
+	}
+	return strings.ToLower(t)
+}
+
+// WriteChoice writes the choice as a line of JSON
+func WriteChoice(w io.Writer, c Choice) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("transtools.WriteChoice: could not encode: %v", err)
+	}
+	if _, err := w.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("transtools.WriteChoice: could not write: %v", err)
+	}
+	return nil
+}
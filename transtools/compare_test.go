@@ -0,0 +1,102 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transtools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type mockClient struct {
+	text  string
+	delay time.Duration
+}
+
+func (m mockClient) Translate(ctx context.Context, req Request) (*Result, error) {
+	time.Sleep(m.delay)
+	if len(m.text) == 0 {
+		return nil, fmt.Errorf("cannot translate %s", req.Text)
+	}
+	return &Result{Text: m.text, Provider: "mock"}, nil
+}
+
+func TestCompareTranslations(t *testing.T) {
+	engines := []Engine{
+		{Name: "fast", Client: mockClient{text: "The Buddha said"}},
+		{Name: "error", Client: mockClient{}},
+		{Name: "slow", Client: mockClient{text: "Too late", delay: time.Second}},
+		{Name: "missing"},
+	}
+	start := time.Now()
+	got := CompareTranslations(context.Background(), engines, Request{Text: "佛說"},
+		100*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("TestCompareTranslations: took %v, expected timeout", elapsed)
+	}
+	if len(got) != len(engines) {
+		t.Fatalf("TestCompareTranslations: got %d comparisons, want %d", len(got),
+			len(engines))
+	}
+	if got[0].Engine != "fast" || got[0].Result == nil ||
+		got[0].Result.Text != "The Buddha said" {
+		t.Errorf("TestCompareTranslations: got %+v for fast engine", got[0])
+	}
+	for _, c := range got[1:] {
+		if c.Result != nil || len(c.Error) == 0 {
+			t.Errorf("TestCompareTranslations: expected error for %s, got %+v", c.Engine, c)
+		}
+	}
+}
+
+func TestHighlightDifferences(t *testing.T) {
+	comparisons := []Comparison{
+		{Engine: "a", Result: &Result{Text: "Thus have I heard."}},
+		{Engine: "b", Error: "timed out"},
+		{Engine: "c", Result: &Result{Text: "thus I have heard"}},
+	}
+	HighlightDifferences(comparisons)
+	want := [][]DiffWord{
+		{{"Thus", false}, {"have", true}, {"I", false}, {"heard.", false}},
+		nil,
+		{{"thus", false}, {"I", true}, {"have", false}, {"heard", false}},
+	}
+	for i, c := range comparisons {
+		if !reflect.DeepEqual(c.Words, want[i]) {
+			t.Errorf("TestHighlightDifferences %s: got %v, want %v", c.Engine, c.Words,
+				want[i])
+		}
+	}
+}
+
+func TestWriteChoice(t *testing.T) {
+	c := Choice{
+		Time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Source:  "如是我聞",
+		Engine:  ChoiceMerged,
+		Text:    "Thus have I heard",
+		Outputs: map[string]string{"DeepL": "Thus I heard"},
+	}
+	var buf bytes.Buffer
+	if err := WriteChoice(&buf, c); err != nil {
+		t.Fatalf("TestWriteChoice: unexpected error: %v", err)
+	}
+	want := `{"Time":"2024-01-02T03:04:05Z","Source":"如是我聞","Engine":"merged",` +
+		`"Text":"Thus have I heard","Outputs":{"DeepL":"Thus I heard"}}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("TestWriteChoice: got %s, want %s", got, want)
+	}
+}
@@ -13,48 +13,89 @@
 package transtools
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
-const deepLURL = "https://api-free.deepl.com/v2/translate"
+const (
+	deepLFreeURL = "https://api-free.deepl.com/v2/translate"
+	deepLProURL  = "https://api.deepl.com/v2/translate"
+)
 
 type TrResult struct {
-	Translations []MT
+	Translations []MT `json:"translations"`
 }
 
 type MT struct {
-	DetectedSourceLanguage, Text string
+	DetectedSourceLanguage string `json:"detected_source_language"`
+	Text                   string `json:"text"`
 }
 
 type deepLApiClient struct {
-	authKey string
+	authKey, apiURL string
 }
 
-func NewDeepLClient(authKey string) ApiClient {
+// NewDeepLClient creates a DeepL API client with the given authentication
+// key. If apiURL is empty, the free or pro API is used depending on the key,
+// free API keys ending in :fx.
+func NewDeepLClient(authKey, apiURL string) ApiClient {
+	if len(apiURL) == 0 {
+		apiURL = deepLProURL
+		if strings.HasSuffix(authKey, ":fx") {
+			apiURL = deepLFreeURL
+		}
+	}
 	return deepLApiClient{
 		authKey: authKey,
+		apiURL:  apiURL,
 	}
 }
 
-func (client deepLApiClient) Translate(sourceText string) (*string, error) {
+func (client deepLApiClient) Translate(ctx context.Context, req Request) (*Result, error) {
+	if len(client.authKey) == 0 {
+		return nil, errors.New("DeepL auth key not set")
+	}
 	data := url.Values{
-		"auth_key":    {"c57803f2-4eef-e046-c535-1512b67ed5ec:fx"},
-		"text":        {sourceText},
-		"target_lang": {"EN"},
+		"text":        {req.Text},
+		"target_lang": {strings.ToUpper(req.targetLang())},
 	}
-
-	resp, err := http.PostForm(deepLURL, data)
+	if len(req.SourceLang) > 0 {
+		data.Set("source_lang", strings.ToUpper(req.SourceLang))
+	}
+	if len(req.GlossaryID) > 0 {
+		data.Set("glossary_id", req.GlossaryID)
+	}
+	if len(req.Formality) > 0 {
+		data.Set("formality", req.Formality)
+	}
+	if len(req.Context) > 0 {
+		data.Set("context", req.Context)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, client.apiURL,
+		strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "DeepL-Auth-Key "+client.authKey)
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(httpReq)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DeepL API returned status %d: %s", resp.StatusCode, body)
+	}
 	var trResult TrResult
 	err = json.Unmarshal(body, &trResult)
 	if err != nil {
@@ -63,6 +104,10 @@ func (client deepLApiClient) Translate(sourceText string) (*string, error) {
 	if len(trResult.Translations) < 1 {
 		return nil, errors.New("no translation returned")
 	}
-	transText := trResult.Translations[0].Text
-	return &transText, nil
+	return &Result{
+		Text:               trResult.Translations[0].Text,
+		DetectedSourceLang: strings.ToLower(trResult.Translations[0].DetectedSourceLanguage),
+		Provider:           ProviderDeepL,
+		GlossaryID:         req.GlossaryID,
+	}, nil
 }
@@ -0,0 +1,108 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transtools
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewDeepLClient(t *testing.T) {
+	testCases := []struct {
+		name    string
+		authKey string
+		apiURL  string
+		want    string
+	}{
+		{
+			name:    "Free key",
+			authKey: "abc:fx",
+			want:    deepLFreeURL,
+		},
+		{
+			name:    "Pro key",
+			authKey: "abc",
+			want:    deepLProURL,
+		},
+		{
+			name:    "Configured URL",
+			authKey: "abc:fx",
+			apiURL:  "http://localhost/v2/translate",
+			want:    "http://localhost/v2/translate",
+		},
+	}
+	for _, tc := range testCases {
+		client := NewDeepLClient(tc.authKey, tc.apiURL).(deepLApiClient)
+		if client.apiURL != tc.want {
+			t.Errorf("TestNewDeepLClient %s: got %s, want %s", tc.name, client.apiURL, tc.want)
+		}
+	}
+}
+
+func TestDeepLTranslate(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = r
+		if r.Header.Get("Authorization") != "DeepL-Auth-Key test-key" {
+			http.Error(w, `{"message":"Forbidden"}`, http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, `{"translations":[{"detected_source_language":"ZH","text":"To learn"}]}`)
+	}))
+	defer server.Close()
+
+	req := Request{
+		Text:       "学而时习之",
+		TargetLang: "en-us",
+		Formality:  "more",
+		Context:    "论语",
+	}
+	client := NewDeepLClient("test-key", server.URL)
+	result, err := client.Translate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("TestDeepLTranslate: unexpected error: %v", err)
+	}
+	want := Result{
+		Text:               "To learn",
+		DetectedSourceLang: "zh",
+		Provider:           ProviderDeepL,
+	}
+	if *result != want {
+		t.Errorf("TestDeepLTranslate: got %v, want %v", *result, want)
+	}
+	params := map[string]string{
+		"text":        "学而时习之",
+		"target_lang": "EN-US",
+		"source_lang": "",
+		"formality":   "more",
+		"context":     "论语",
+	}
+	for k, v := range params {
+		if got.PostForm.Get(k) != v {
+			t.Errorf("TestDeepLTranslate: got %s = %q, want %q", k, got.PostForm.Get(k), v)
+		}
+	}
+
+	badKey := NewDeepLClient("wrong-key", server.URL)
+	if _, err := badKey.Translate(context.Background(), req); err == nil {
+		t.Error("TestDeepLTranslate: expected error with wrong key")
+	}
+	noKey := NewDeepLClient("", server.URL)
+	if _, err := noKey.Translate(context.Background(), req); err == nil {
+		t.Error("TestDeepLTranslate: expected error with no key")
+	}
+}
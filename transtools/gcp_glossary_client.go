@@ -20,53 +20,71 @@ import (
 	translatepb "google.golang.org/genproto/googleapis/cloud/translate/v3"
 )
 
-const (
-	location   = "us-central1"
-	sourceLang = "zh"
-	targetLang = "en"
-)
+// Default location of the glossary
+const DefaultLocation = "us-central1"
 
 type glossaryApiClient struct {
-	projectID, glossaryID string
+	projectID, location, glossaryID string
 }
 
-// NewGoogleClient creates a Google Translation API that uses a glossary.
-func NewGlossaryClient(projectID, glossaryID string) ApiClient {
+// NewGlossaryClient creates a Google Translation API client that uses a
+// glossary, unless a different glossary is given in the request. If location
+// is empty, the default location is used.
+func NewGlossaryClient(projectID, location, glossaryID string) ApiClient {
+	if len(location) == 0 {
+		location = DefaultLocation
+	}
 	return glossaryApiClient{
 		projectID:  projectID,
+		location:   location,
 		glossaryID: glossaryID,
 	}
 }
 
-func (client glossaryApiClient) Translate(sourceText string) (*string, error) {
-	ctx := context.Background()
+func (client glossaryApiClient) Translate(ctx context.Context, req Request) (*Result, error) {
+	glossaryID := req.GlossaryID
+	if len(glossaryID) == 0 {
+		glossaryID = client.glossaryID
+	}
+	if len(glossaryID) == 0 {
+		return nil, fmt.Errorf("glossary not set")
+	}
+	// Glossaries need the source language
+	sourceLang := req.SourceLang
+	if len(sourceLang) == 0 {
+		sourceLang = DefaultSourceLang
+	}
 	cl, err := translate.NewTranslationClient(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("NewTranslationClient: %v", err)
 	}
 	defer cl.Close()
 
-	req := &translatepb.TranslateTextRequest{
-		Parent:             fmt.Sprintf("projects/%s/locations/%s", client.projectID, location),
+	parent := fmt.Sprintf("projects/%s/locations/%s", client.projectID, client.location)
+	tReq := &translatepb.TranslateTextRequest{
+		Parent:             parent,
 		SourceLanguageCode: sourceLang,
-		TargetLanguageCode: targetLang,
+		TargetLanguageCode: req.targetLang(),
 		MimeType:           "text/plain", // Mime types: "text/plain", "text/html"
-		Contents:           []string{sourceText},
+		Contents:           []string{req.Text},
 		GlossaryConfig: &translatepb.TranslateTextGlossaryConfig{
-			Glossary: fmt.Sprintf("projects/%s/locations/%s/glossaries/%s", client.projectID, location, client.glossaryID),
+			Glossary: fmt.Sprintf("%s/glossaries/%s", parent, glossaryID),
 		},
 	}
 
-	resp, err := cl.TranslateText(ctx, req)
+	resp, err := cl.TranslateText(ctx, tReq)
 	if err != nil {
 		return nil, fmt.Errorf("TranslateText: %v", err)
 	}
 
-	targetText := ""
+	result := Result{
+		Provider:   ProviderGlossary,
+		GlossaryID: glossaryID,
+	}
 	for _, translation := range resp.GetGlossaryTranslations() {
-		targetText = translation.GetTranslatedText()
-		// fmt.Printf("Translated text: %v\n", translation.GetTranslatedText())
+		result.Text = translation.GetTranslatedText()
+		result.DetectedSourceLang = translation.GetDetectedLanguageCode()
+		result.Model = translation.GetModel()
 	}
-
-	return &targetText, nil
+	return &result, nil
 }
@@ -0,0 +1,327 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Generation of machine translation glossaries from the dictionary
+
+package transtools
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/alexamies/chinesenotes-go/dictionary"
+)
+
+// Glossary file formats
+const (
+	// Google Translation unidirectional glossary, comma separated
+	GlossaryFormatCSV = "csv"
+	// Google Translation unidirectional glossary, tab separated
+	GlossaryFormatTSV = "tsv"
+	// DeepL glossary entries, tab separated
+	GlossaryFormatDeepL = "deepl"
+)
+
+// Kinds of problems found validating a glossary
+const (
+	IssueConflict  = "conflict"
+	IssueDuplicate = "duplicate"
+	IssueInvalid   = "invalid"
+)
+
+// GlossaryTerm is a source term and its translation in a machine translation
+// glossary
+type GlossaryTerm struct {
+	Source string
+	Target string
+
+	// The dictionary headword the term was generated from, zero if read from a
+	// file
+	HeadwordId int
+}
+
+// GlossaryIssue is a problem with a term, which is left out of the glossary
+type GlossaryIssue struct {
+	Kind string
+	Term GlossaryTerm
+
+	// The term kept instead, for conflicts and duplicates
+	Kept GlossaryTerm
+}
+
+func (i GlossaryIssue) String() string {
+	switch i.Kind {
+	case IssueConflict:
+		return fmt.Sprintf("conflict: %s -> %q (headword %d), kept %q (headword %d)",
+			i.Term.Source, i.Term.Target, i.Term.HeadwordId, i.Kept.Target,
+			i.Kept.HeadwordId)
+	case IssueDuplicate:
+		return fmt.Sprintf("duplicate: %s -> %q (headword %d)", i.Term.Source,
+			i.Term.Target, i.Term.HeadwordId)
+	}
+	return fmt.Sprintf("%s: %q -> %q (headword %d)", i.Kind, i.Term.Source,
+		i.Term.Target, i.Term.HeadwordId)
+}
+
+// GlossaryOptions selects the dictionary entries for a glossary
+type GlossaryOptions struct {
+	// Domains or subdomains of the word senses to include, all if empty
+	Domains []string
+
+	// Include proper nouns only, translated with a proper noun sense
+	ProperNouns bool
+}
+
+// GenerateGlossary gives glossary terms for the dictionary headwords selected,
+// in order of headword id. The target is the first English equivalent of the
+// first word sense selected, with terms for both simplified and traditional
+// forms. The terms should be checked with ValidateGlossary before writing.
+func GenerateGlossary(dict *dictionary.Dictionary, opts GlossaryOptions) []GlossaryTerm {
+	filter := dictionary.DomainFilter(opts.Domains...)
+	hwIds := []int{}
+	for id := range dict.HeadwordIds {
+		hwIds = append(hwIds, id)
+	}
+	sort.Ints(hwIds)
+	terms := []GlossaryTerm{}
+	for _, id := range hwIds {
+		w := dict.HeadwordIds[id]
+		if len(w.Senses) == 0 || (opts.ProperNouns && !w.IsProperNoun()) {
+			continue
+		}
+		target := ""
+		for _, ws := range w.Senses {
+			if !filter(ws) || (opts.ProperNouns && ws.Grammar != "proper noun") {
+				continue
+			}
+			if eq := splitEnglish(ws.English); len(eq) > 0 && eq[0] != "\\N" {
+				target = eq[0]
+				break
+			}
+		}
+		if len(target) == 0 {
+			continue
+		}
+		terms = append(terms, GlossaryTerm{w.Simplified, target, id})
+		if w.Traditional != "\\N" && len(w.Traditional) > 0 &&
+			w.Traditional != w.Simplified {
+			terms = append(terms, GlossaryTerm{w.Traditional, target, id})
+		}
+	}
+	return terms
+}
+
+// ValidateGlossary checks that terms are not empty, do not contain line breaks
+// or tabs, and that each source term occurs once. Only the first of terms with
+// the same source is kept, later ones are duplicates if the target is the same
+// and conflicts otherwise. The valid terms are returned in the original order.
+func ValidateGlossary(terms []GlossaryTerm) ([]GlossaryTerm, []GlossaryIssue) {
+	valid := []GlossaryTerm{}
+	issues := []GlossaryIssue{}
+	kept := make(map[string]GlossaryTerm)
+	for _, t := range terms {
+		if !validGlossaryText(t.Source) || !validGlossaryText(t.Target) {
+			issues = append(issues, GlossaryIssue{Kind: IssueInvalid, Term: t})
+			continue
+		}
+		if k, ok := kept[t.Source]; ok {
+			kind := IssueConflict
+			if k.Target == t.Target {
+				kind = IssueDuplicate
+			}
+			issues = append(issues, GlossaryIssue{Kind: kind, Term: t, Kept: k})
+			continue
+		}
+		kept[t.Source] = t
+		valid = append(valid, t)
+	}
+	return valid, issues
+}
+
+func validGlossaryText(s string) bool {
+	return len(s) > 0 && s == strings.TrimSpace(s) && !strings.ContainsAny(s, "\t\r\n")
+}
+
+// WriteGlossary writes the terms in one of the glossary formats. Tab separated
+// terms are written without quoting, since valid terms do not contain tabs or
+// line breaks.
+func WriteGlossary(w io.Writer, terms []GlossaryTerm, format string) error {
+	switch format {
+	case GlossaryFormatCSV:
+	case GlossaryFormatTSV, GlossaryFormatDeepL:
+		for _, t := range terms {
+			if _, err := fmt.Fprintf(w, "%s\t%s\n", t.Source, t.Target); err != nil {
+				return fmt.Errorf("transtools.WriteGlossary: could not write: %v", err)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("transtools.WriteGlossary: unknown format %s", format)
+	}
+	cw := csv.NewWriter(w)
+	for _, t := range terms {
+		if err := cw.Write([]string{t.Source, t.Target}); err != nil {
+			return fmt.Errorf("transtools.WriteGlossary: could not write: %v", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("transtools.WriteGlossary: could not write: %v", err)
+	}
+	return nil
+}
+
+// ReadGlossary reads terms in one of the glossary formats, for example the
+// previous version of a glossary. Lines starting with # are ignored.
+func ReadGlossary(r io.Reader, format string) ([]GlossaryTerm, error) {
+	switch format {
+	case GlossaryFormatCSV:
+	case GlossaryFormatTSV, GlossaryFormatDeepL:
+		return readTSVGlossary(r)
+	default:
+		return nil, fmt.Errorf("transtools.ReadGlossary: unknown format %s", format)
+	}
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("transtools.ReadGlossary: could not read: %v", err)
+	}
+	terms := []GlossaryTerm{}
+	for i, row := range rows {
+		if len(row) < 2 {
+			return nil, fmt.Errorf("transtools.ReadGlossary: row %d has %d fields, expected 2", i+1, len(row))
+		}
+		terms = append(terms, GlossaryTerm{Source: row[0], Target: row[1]})
+	}
+	return terms, nil
+}
+
+// readTSVGlossary reads tab separated terms, taking quotes literally
+func readTSVGlossary(r io.Reader) ([]GlossaryTerm, error) {
+	terms := []GlossaryTerm{}
+	scanner := bufio.NewScanner(r)
+	row := 0
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		row++
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("transtools.ReadGlossary: row %d has %d fields, expected 2", row, len(fields))
+		}
+		terms = append(terms, GlossaryTerm{Source: fields[0], Target: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("transtools.ReadGlossary: could not read: %v", err)
+	}
+	return terms, nil
+}
+
+// GlossaryChange is a source term with a different target in a new version of
+// a glossary
+type GlossaryChange struct {
+	Source string
+	Old    string
+	New    string
+}
+
+// GlossaryDiff lists the differences between two versions of a glossary, in
+// order of source term
+type GlossaryDiff struct {
+	Added   []GlossaryTerm
+	Removed []GlossaryTerm
+	Changed []GlossaryChange
+}
+
+// Empty tests whether there are no differences
+func (d GlossaryDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Write writes the differences as text, one line per term, like a unified
+// diff
+func (d GlossaryDiff) Write(w io.Writer) error {
+	for _, t := range d.Removed {
+		if _, err := fmt.Fprintf(w, "- %s\t%s\n", t.Source, t.Target); err != nil {
+			return fmt.Errorf("GlossaryDiff.Write, could not write: %v", err)
+		}
+	}
+	for _, t := range d.Added {
+		if _, err := fmt.Fprintf(w, "+ %s\t%s\n", t.Source, t.Target); err != nil {
+			return fmt.Errorf("GlossaryDiff.Write, could not write: %v", err)
+		}
+	}
+	for _, c := range d.Changed {
+		if _, err := fmt.Fprintf(w, "~ %s: %q -> %q\n", c.Source, c.Old, c.New); err != nil {
+			return fmt.Errorf("GlossaryDiff.Write, could not write: %v", err)
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d added, %d removed, %d changed\n", len(d.Added),
+		len(d.Removed), len(d.Changed))
+	if err != nil {
+		return fmt.Errorf("GlossaryDiff.Write, could not write: %v", err)
+	}
+	return nil
+}
+
+// DiffGlossary compares two versions of a glossary by source term. Only the
+// first of terms with the same source is compared.
+func DiffGlossary(oldTerms, newTerms []GlossaryTerm) GlossaryDiff {
+	oldMap := glossaryBySource(oldTerms)
+	newMap := glossaryBySource(newTerms)
+	sources := []string{}
+	for s := range oldMap {
+		sources = append(sources, s)
+	}
+	for s := range newMap {
+		if _, ok := oldMap[s]; !ok {
+			sources = append(sources, s)
+		}
+	}
+	sort.Strings(sources)
+	d := GlossaryDiff{
+		Added:   []GlossaryTerm{},
+		Removed: []GlossaryTerm{},
+		Changed: []GlossaryChange{},
+	}
+	for _, s := range sources {
+		o, inOld := oldMap[s]
+		n, inNew := newMap[s]
+		switch {
+		case !inOld:
+			d.Added = append(d.Added, n)
+		case !inNew:
+			d.Removed = append(d.Removed, o)
+		case o.Target != n.Target:
+			d.Changed = append(d.Changed, GlossaryChange{s, o.Target, n.Target})
+		}
+	}
+	return d
+}
+
+func glossaryBySource(terms []GlossaryTerm) map[string]GlossaryTerm {
+	m := make(map[string]GlossaryTerm)
+	for _, t := range terms {
+		if _, ok := m[t.Source]; !ok {
+			m[t.Source] = t
+		}
+	}
+	return m
+}
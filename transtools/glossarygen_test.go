@@ -0,0 +1,233 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transtools
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/alexamies/chinesenotes-go/dictionary"
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+)
+
+func mockGlossaryDict() *dictionary.Dictionary {
+	words := []dicttypes.Word{
+		{
+			Simplified:  "观音",
+			Traditional: "觀音",
+			HeadwordId:  1,
+			Senses: []dicttypes.WordSense{{
+				English: "Avalokitesvara; Guanyin",
+				Grammar: "proper noun",
+				Domain:  "Buddhism",
+			}},
+		},
+		{
+			Simplified:  "经",
+			Traditional: "經",
+			HeadwordId:  2,
+			Senses: []dicttypes.WordSense{
+				{English: "classic", Grammar: "noun", Domain: "Literary Chinese"},
+				{English: "sutra", Grammar: "noun", Domain: "Buddhism"},
+			},
+		},
+		{
+			Simplified:  "长安",
+			Traditional: "長安",
+			HeadwordId:  3,
+			Senses: []dicttypes.WordSense{{
+				English: "Chang'an",
+				Grammar: "proper noun",
+				Domain:  "Places",
+			}},
+		},
+		{
+			Simplified:  "经",
+			Traditional: "\\N",
+			HeadwordId:  4,
+			Senses: []dicttypes.WordSense{{
+				English: "to pass through",
+				Grammar: "verb",
+				Domain:  "Buddhism",
+			}},
+		},
+		{
+			Simplified:  "普贤",
+			Traditional: "普賢",
+			HeadwordId:  5,
+			Senses: []dicttypes.WordSense{
+				{English: "universal worthy", Grammar: "phrase", Domain: "Buddhism"},
+				{English: "Samantabhadra", Grammar: "proper noun", Domain: "Buddhism"},
+				{English: "Puxian", Grammar: "proper noun", Domain: "Places"},
+			},
+		},
+	}
+	wdict := map[string]*dicttypes.Word{}
+	for i := range words {
+		wdict[words[i].Simplified] = &words[i]
+		if words[i].Traditional != "\\N" {
+			wdict[words[i].Traditional] = &words[i]
+		}
+	}
+	// Both headwords 2 and 4 have the same simplified form, keep both
+	dict := dictionary.NewDictionary(wdict)
+	dict.HeadwordIds[2] = &words[1]
+	dict.HeadwordIds[4] = &words[3]
+	return dict
+}
+
+func TestGenerateGlossary(t *testing.T) {
+	dict := mockGlossaryDict()
+	testCases := []struct {
+		name string
+		opts GlossaryOptions
+		want []GlossaryTerm
+	}{
+		{
+			name: "Buddhism domain",
+			opts: GlossaryOptions{Domains: []string{"buddhism"}},
+			want: []GlossaryTerm{
+				{"观音", "Avalokitesvara", 1},
+				{"觀音", "Avalokitesvara", 1},
+				{"经", "sutra", 2},
+				{"經", "sutra", 2},
+				{"经", "to pass through", 4},
+				{"普贤", "universal worthy", 5},
+				{"普賢", "universal worthy", 5},
+			},
+		},
+		{
+			name: "Proper nouns",
+			opts: GlossaryOptions{ProperNouns: true},
+			want: []GlossaryTerm{
+				{"观音", "Avalokitesvara", 1},
+				{"觀音", "Avalokitesvara", 1},
+				{"长安", "Chang'an", 3},
+				{"長安", "Chang'an", 3},
+				{"普贤", "Samantabhadra", 5},
+				{"普賢", "Samantabhadra", 5},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		got := GenerateGlossary(dict, tc.opts)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("TestGenerateGlossary %s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestValidateGlossary(t *testing.T) {
+	terms := []GlossaryTerm{
+		{"经", "sutra", 2},
+		{"經", "sutra", 2},
+		{"经", "to pass through", 4},
+		{"經", "sutra", 0},
+		{"空", "", 5},
+		{"色", "form\t", 6},
+	}
+	gotValid, gotIssues := ValidateGlossary(terms)
+	wantValid := []GlossaryTerm{{"经", "sutra", 2}, {"經", "sutra", 2}}
+	if !reflect.DeepEqual(gotValid, wantValid) {
+		t.Errorf("TestValidateGlossary: got valid %v, want %v", gotValid, wantValid)
+	}
+	wantKinds := []string{IssueConflict, IssueDuplicate, IssueInvalid, IssueInvalid}
+	if len(gotIssues) != len(wantKinds) {
+		t.Fatalf("TestValidateGlossary: got %d issues, want %d: %v", len(gotIssues),
+			len(wantKinds), gotIssues)
+	}
+	for i, issue := range gotIssues {
+		if issue.Kind != wantKinds[i] {
+			t.Errorf("TestValidateGlossary: issue %d got %s, want %s", i, issue.Kind,
+				wantKinds[i])
+		}
+	}
+	if gotIssues[0].Kept != terms[0] {
+		t.Errorf("TestValidateGlossary: conflict kept %v, want %v", gotIssues[0].Kept,
+			terms[0])
+	}
+}
+
+func TestWriteReadGlossary(t *testing.T) {
+	terms := []GlossaryTerm{
+		{Source: "长安", Target: "Chang'an"},
+		{Source: "三论宗", Target: "Three Treatise School, Sanlun"},
+		{Source: "金刚经", Target: "\"Diamond\" Sutra"},
+	}
+	testCases := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: GlossaryFormatCSV,
+			want: "长安,Chang'an\n三论宗,\"Three Treatise School, Sanlun\"\n" +
+				"金刚经,\"\"\"Diamond\"\" Sutra\"\n",
+		},
+		{
+			format: GlossaryFormatDeepL,
+			want: "长安\tChang'an\n三论宗\tThree Treatise School, Sanlun\n" +
+				"金刚经\t\"Diamond\" Sutra\n",
+		},
+		{
+			format: GlossaryFormatTSV,
+			want: "长安\tChang'an\n三论宗\tThree Treatise School, Sanlun\n" +
+				"金刚经\t\"Diamond\" Sutra\n",
+		},
+	}
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		if err := WriteGlossary(&buf, terms, tc.format); err != nil {
+			t.Fatalf("TestWriteReadGlossary %s: unexpected error: %v", tc.format, err)
+		}
+		if buf.String() != tc.want {
+			t.Errorf("TestWriteReadGlossary %s: got %q, want %q", tc.format,
+				buf.String(), tc.want)
+		}
+		got, err := ReadGlossary(&buf, tc.format)
+		if err != nil {
+			t.Fatalf("TestWriteReadGlossary %s: unexpected error: %v", tc.format, err)
+		}
+		if !reflect.DeepEqual(got, terms) {
+			t.Errorf("TestWriteReadGlossary %s: got %v, want %v", tc.format, got, terms)
+		}
+	}
+	if err := WriteGlossary(&bytes.Buffer{}, terms, "xml"); err == nil {
+		t.Error("TestWriteReadGlossary: expected error for unknown format")
+	}
+}
+
+func TestDiffGlossary(t *testing.T) {
+	oldTerms := []GlossaryTerm{
+		{Source: "经", Target: "classic"},
+		{Source: "长安", Target: "Chang'an"},
+		{Source: "星際傳奇", Target: "Pitch Black"},
+	}
+	newTerms := []GlossaryTerm{
+		{Source: "经", Target: "sutra"},
+		{Source: "长安", Target: "Chang'an"},
+		{Source: "观音", Target: "Avalokitesvara"},
+	}
+	got := DiffGlossary(oldTerms, newTerms)
+	want := GlossaryDiff{
+		Added:   []GlossaryTerm{{Source: "观音", Target: "Avalokitesvara"}},
+		Removed: []GlossaryTerm{{Source: "星際傳奇", Target: "Pitch Black"}},
+		Changed: []GlossaryChange{{"经", "classic", "sutra"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TestDiffGlossary: got %v, want %v", got, want)
+	}
+	if !DiffGlossary(newTerms, newTerms).Empty() {
+		t.Error("TestDiffGlossary: expected no differences for the same version")
+	}
+}
@@ -24,18 +24,25 @@ type googleApiClient struct {
 }
 
 // NewGoogleClient creates a Google Translation API that does not use a glossary.
+// Credentials are found from the environment, as for other Google Cloud APIs.
 func NewGoogleClient() ApiClient {
 	return googleApiClient{}
 }
 
-func (client googleApiClient) Translate(sourceText string) (*string, error) {
-	// text := "The Go Gopher is cute"
-	ctx := context.Background()
-
-	lang, err := language.Parse("en")
+func (client googleApiClient) Translate(ctx context.Context, req Request) (*Result, error) {
+	lang, err := language.Parse(req.targetLang())
 	if err != nil {
 		return nil, fmt.Errorf("language.Parse: %v", err)
 	}
+	opts := &translate.Options{
+		Format: translate.Text,
+	}
+	if len(req.SourceLang) > 0 {
+		opts.Source, err = language.Parse(req.SourceLang)
+		if err != nil {
+			return nil, fmt.Errorf("language.Parse source: %v", err)
+		}
+	}
 
 	apiClient, err := translate.NewClient(ctx)
 	if err != nil {
@@ -43,12 +50,21 @@ func (client googleApiClient) Translate(sourceText string) (*string, error) {
 	}
 	defer apiClient.Close()
 
-	resp, err := apiClient.Translate(ctx, []string{sourceText}, lang, nil)
+	resp, err := apiClient.Translate(ctx, []string{req.Text}, lang, opts)
 	if err != nil {
 		return nil, fmt.Errorf("Translate: %v", err)
 	}
 	if len(resp) == 0 {
-		return nil, fmt.Errorf("Translate returned empty response to text: %s", sourceText)
+		return nil, fmt.Errorf("Translate returned empty response to text: %s", req.Text)
+	}
+	detected := ""
+	if resp[0].Source != language.Und {
+		detected = resp[0].Source.String()
 	}
-	return &resp[0].Text, nil
+	return &Result{
+		Text:               resp[0].Text,
+		DetectedSourceLang: detected,
+		Provider:           ProviderGoogle,
+		Model:              resp[0].Model,
+	}, nil
 }
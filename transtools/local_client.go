@@ -0,0 +1,118 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transtools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ProviderLocal is the name of the provider for a local translation server
+const ProviderLocal = "local"
+
+// localRequest is the body of a request to a LibreTranslate compatible server
+type localRequest struct {
+	Q      string `json:"q"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Format string `json:"format"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+// localResponse is the body of a response from a LibreTranslate compatible
+// server
+type localResponse struct {
+	TranslatedText   string `json:"translatedText"`
+	DetectedLanguage *struct {
+		Confidence float64 `json:"confidence"`
+		Language   string  `json:"language"`
+	} `json:"detectedLanguage,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type localApiClient struct {
+	apiURL, apiKey string
+}
+
+// NewLocalClient creates a client for a translation server with the
+// LibreTranslate JSON API, such as one run locally for development and
+// testing. The URL is the server address, with or without the /translate
+// path, and the API key is optional.
+func NewLocalClient(apiURL, apiKey string) ApiClient {
+	apiURL = strings.TrimSuffix(apiURL, "/")
+	if !strings.HasSuffix(apiURL, "/translate") {
+		apiURL += "/translate"
+	}
+	return localApiClient{
+		apiURL: apiURL,
+		apiKey: apiKey,
+	}
+}
+
+func (client localApiClient) Translate(ctx context.Context, req Request) (*Result, error) {
+	source := req.SourceLang
+	if len(source) == 0 {
+		source = "auto"
+	}
+	body, err := json.Marshal(localRequest{
+		Q:      req.Text,
+		Source: source,
+		Target: req.targetLang(),
+		Format: "text",
+		APIKey: client.apiKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, client.apiURL,
+		bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var lResp localResponse
+	if err := json.Unmarshal(respBody, &lResp); err != nil {
+		return nil, fmt.Errorf("local translation server returned status %d: %v",
+			resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("local translation server returned status %d: %s",
+			resp.StatusCode, lResp.Error)
+	}
+	if len(lResp.TranslatedText) == 0 {
+		return nil, errors.New("no translation returned")
+	}
+	result := Result{
+		Text:     lResp.TranslatedText,
+		Provider: ProviderLocal,
+	}
+	if lResp.DetectedLanguage != nil {
+		result.DetectedSourceLang = lResp.DetectedLanguage.Language
+	}
+	return &result, nil
+}
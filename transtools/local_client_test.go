@@ -0,0 +1,125 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transtools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeTranslations are the translations known to the fake server
+var fakeTranslations = map[string]string{
+	"学而时习之": "To learn and practice it",
+	"人":     "person",
+}
+
+// newFakeServer starts a deterministic LibreTranslate compatible server that
+// translates the fake translations, detecting the source language as Chinese
+func newFakeServer(apiKey string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req localRequest
+		if r.URL.Path != "/translate" || json.NewDecoder(r.Body).Decode(&req) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(localResponse{Error: "bad request"})
+			return
+		}
+		if req.APIKey != apiKey {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(localResponse{Error: "invalid API key"})
+			return
+		}
+		text, ok := fakeTranslations[req.Q]
+		if !ok || req.Target != "en" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(localResponse{Error: "cannot translate"})
+			return
+		}
+		resp := localResponse{TranslatedText: text}
+		if req.Source == "auto" {
+			resp.DetectedLanguage = &struct {
+				Confidence float64 `json:"confidence"`
+				Language   string  `json:"language"`
+			}{Confidence: 90, Language: "zh"}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func TestLocalTranslate(t *testing.T) {
+	server := newFakeServer("secret")
+	defer server.Close()
+	testCases := []struct {
+		name       string
+		apiURL     string
+		apiKey     string
+		req        Request
+		want       Result
+		expectFail bool
+	}{
+		{
+			name:   "Detect source language",
+			apiURL: server.URL,
+			apiKey: "secret",
+			req:    Request{Text: "学而时习之"},
+			want: Result{
+				Text:               "To learn and practice it",
+				DetectedSourceLang: "zh",
+				Provider:           ProviderLocal,
+			},
+		},
+		{
+			name:   "URL with path",
+			apiURL: server.URL + "/translate/",
+			apiKey: "secret",
+			req:    Request{Text: "人", SourceLang: "zh", TargetLang: "en"},
+			want: Result{
+				Text:     "person",
+				Provider: ProviderLocal,
+			},
+		},
+		{
+			name:       "Wrong key",
+			apiURL:     server.URL,
+			apiKey:     "wrong",
+			req:        Request{Text: "人"},
+			expectFail: true,
+		},
+		{
+			name:       "Unknown text",
+			apiURL:     server.URL,
+			apiKey:     "secret",
+			req:        Request{Text: "有朋自远方来"},
+			expectFail: true,
+		},
+	}
+	for _, tc := range testCases {
+		client := NewLocalClient(tc.apiURL, tc.apiKey)
+		result, err := client.Translate(context.Background(), tc.req)
+		if tc.expectFail {
+			if err == nil {
+				t.Errorf("TestLocalTranslate %s: expected error, got %v", tc.name, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestLocalTranslate %s: unexpected error: %v", tc.name, err)
+		}
+		if *result != tc.want {
+			t.Errorf("TestLocalTranslate %s: got %v, want %v", tc.name, *result, tc.want)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transtools
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+	"github.com/alexamies/chinesenotes-go/tokenizer"
+)
+
+// Actions taken by the post-editor, given in the notes
+const (
+	ActionFlagged  = "flagged"
+	ActionReplaced = "replaced"
+)
+
+// Post-editing modes, what is done with other translations of glossary terms
+const (
+	// Flag the terms only
+	PostEditFlag = "flag"
+	// Replace other translations listed in the glossary
+	PostEditGlossary = "glossary"
+	// Also replace the English for the term in the dictionary
+	PostEditDictionary = "dictionary"
+)
+
+// Span is a range of characters in a text, from Start up to but not including
+// End, counted in Unicode code points
+type Span struct {
+	Start, End int
+}
+
+// GlossaryEntry is the preferred translation of a Chinese term in a domain,
+// with other translations to replace with it. An empty domain applies to all
+// domains.
+type GlossaryEntry struct {
+	Term         string
+	Domain       string
+	Preferred    string
+	Alternatives []string
+}
+
+// Glossary holds the project's preferred translations, keyed by Chinese term
+type Glossary map[string][]GlossaryEntry
+
+// PostEditor checks machine translations against the glossary
+type PostEditor interface {
+
+	// PostEdit checks that the translation has the preferred English for the
+	// glossary terms in the source, for the given domain, replacing other
+	// translations of the term if found and otherwise flagging the term
+	PostEdit(source, translation, domain string) Results
+}
+
+type postEditor struct {
+	tok      tokenizer.Tokenizer
+	glossary Glossary
+	mode     string
+}
+
+// NewPostEditor creates a post-editor that tokenizes the source with the
+// tokenizer. The mode is one of PostEditFlag, PostEditGlossary, and
+// PostEditDictionary, with terms flagged rather than replaced for any other.
+func NewPostEditor(tok tokenizer.Tokenizer, glossary Glossary, mode string) PostEditor {
+	return postEditor{
+		tok:      tok,
+		glossary: glossary,
+		mode:     mode,
+	}
+}
+
+// LoadGlossary reads glossary entries in CSV format, with columns Chinese
+// term, domain, preferred English, and other translations to replace separated
+// by semicolons, with lines starting with # ignored
+func LoadGlossary(r io.Reader) (Glossary, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("transtools.LoadGlossary: could not read CSV: %v", err)
+	}
+	glossary := Glossary{}
+	for i, row := range rows {
+		if len(row) < 3 {
+			return nil, fmt.Errorf("transtools.LoadGlossary: row %d has %d fields, expected at least 3", i+1, len(row))
+		}
+		entry := GlossaryEntry{
+			Term:      strings.TrimSpace(row[0]),
+			Domain:    strings.TrimSpace(row[1]),
+			Preferred: strings.TrimSpace(row[2]),
+		}
+		if len(row) > 3 {
+			entry.Alternatives = splitEnglish(row[3])
+		}
+		glossary[entry.Term] = append(glossary[entry.Term], entry)
+	}
+	return glossary, nil
+}
+
+// Lookup finds the glossary entry for a term in the domain, or else for all
+// domains
+func (g Glossary) Lookup(term, domain string) (GlossaryEntry, bool) {
+	var general *GlossaryEntry
+	for i, e := range g[term] {
+		if len(domain) > 0 && strings.EqualFold(e.Domain, domain) {
+			return e, true
+		}
+		if len(e.Domain) == 0 && general == nil {
+			general = &g[term][i]
+		}
+	}
+	if general != nil {
+		return *general, true
+	}
+	return GlossaryEntry{}, false
+}
+
+func (p postEditor) PostEdit(source, translation, domain string) Results {
+	results := Results{
+		Replacement: translation,
+		Notes:       []Note{},
+	}
+	if p.tok == nil {
+		return results
+	}
+	pos := 0
+	for _, t := range p.tok.Tokenize(source) {
+		start := pos
+		pos += utf8.RuneCountInString(t.Token)
+		entry, ok := p.lookup(t, domain)
+		if !ok || len(entry.Preferred) == 0 {
+			continue
+		}
+		if _, found := findEnglish(results.Replacement, entry.Preferred); found {
+			continue
+		}
+		sourceSpan := Span{start, pos}
+		note := Note{
+			FoundCN:    t.Token,
+			ExpectedEN: []string{entry.Preferred},
+			Action:     ActionFlagged,
+			SourceSpan: &sourceSpan,
+		}
+		if p.mode == PostEditGlossary || p.mode == PostEditDictionary {
+			w := dicttypes.Word{}
+			if p.mode == PostEditDictionary {
+				w = t.DictEntry
+			}
+			for _, alt := range alternatives(entry, w) {
+				span, found := findEnglish(results.Replacement, alt)
+				if !found {
+					continue
+				}
+				replacement := matchCase(results.Replacement, span, entry.Preferred)
+				results.Replacement = replaceSpan(results.Replacement, span, replacement)
+				shiftSpans(results.Notes, span, utf8.RuneCountInString(replacement))
+				targetSpan := Span{span.Start, span.Start + utf8.RuneCountInString(replacement)}
+				note.FoundEN = alt
+				note.Replacement = replacement
+				note.Action = ActionReplaced
+				note.TargetSpan = &targetSpan
+				break
+			}
+		}
+		results.Notes = append(results.Notes, note)
+	}
+	return results
+}
+
+// lookup finds the glossary entry for a token in the domain, trying both the
+// simplified and traditional forms from the dictionary
+func (p postEditor) lookup(t tokenizer.TextToken, domain string) (GlossaryEntry, bool) {
+	terms := []string{t.Token, t.DictEntry.Simplified, t.DictEntry.Traditional}
+	for _, term := range terms {
+		if len(term) == 0 || term == "\\N" {
+			continue
+		}
+		if e, ok := p.glossary.Lookup(term, domain); ok {
+			return e, true
+		}
+	}
+	return GlossaryEntry{}, false
+}
+
+// alternatives gives the translations of the term to replace with the
+// preferred one, those in the glossary and the English of the dictionary word
+// given, longest first so that phrases are matched before the words in them
+func alternatives(entry GlossaryEntry, w dicttypes.Word) []string {
+	seen := map[string]bool{strings.ToLower(entry.Preferred): true}
+	alts := []string{}
+	add := func(s string) {
+		if len(s) > 0 && s != "\\N" && !seen[strings.ToLower(s)] {
+			seen[strings.ToLower(s)] = true
+			alts = append(alts, s)
+		}
+	}
+	for _, a := range entry.Alternatives {
+		add(a)
+	}
+	for _, ws := range w.Senses {
+		for _, e := range splitEnglish(ws.English) {
+			add(e)
+		}
+	}
+	sort.SliceStable(alts, func(i, j int) bool {
+		return len(alts[i]) > len(alts[j])
+	})
+	return alts
+}
+
+// findEnglish finds the first occurrence of the phrase in the text as whole
+// words, ignoring case
+func findEnglish(text, phrase string) (Span, bool) {
+	t := []rune(strings.ToLower(text))
+	p := []rune(strings.ToLower(phrase))
+	if len(p) == 0 {
+		return Span{}, false
+	}
+	for i := 0; i+len(p) <= len(t); i++ {
+		if string(t[i:i+len(p)]) != string(p) {
+			continue
+		}
+		before := i == 0 || !isWordRune(t[i-1])
+		after := i+len(p) == len(t) || !isWordRune(t[i+len(p)])
+		if before && after {
+			return Span{i, i + len(p)}, true
+		}
+	}
+	return Span{}, false
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// matchCase capitalizes the replacement if the text replaced is capitalized
+func matchCase(text string, span Span, replacement string) string {
+	found := []rune(text)[span.Start:span.End]
+	r := []rune(replacement)
+	if len(found) > 0 && len(r) > 0 && unicode.IsUpper(found[0]) {
+		r[0] = unicode.ToUpper(r[0])
+	}
+	return string(r)
+}
+
+// replaceSpan replaces the characters in the span of the text
+func replaceSpan(text string, span Span, replacement string) string {
+	r := []rune(text)
+	return string(r[:span.Start]) + replacement + string(r[span.End:])
+}
+
+// shiftSpans moves the target spans of notes after a replacement to account
+// for the change in length
+func shiftSpans(notes []Note, replaced Span, newLen int) {
+	delta := newLen - (replaced.End - replaced.Start)
+	for i := range notes {
+		if notes[i].TargetSpan != nil && notes[i].TargetSpan.Start >= replaced.End {
+			notes[i].TargetSpan.Start += delta
+			notes[i].TargetSpan.End += delta
+		}
+	}
+}
+
+// splitEnglish splits a list of English equivalents separated by semicolons
+func splitEnglish(s string) []string {
+	parts := []string{}
+	for _, p := range strings.Split(s, ";") {
+		if p = strings.TrimSpace(p); len(p) > 0 {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}
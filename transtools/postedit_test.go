@@ -0,0 +1,175 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transtools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+	"github.com/alexamies/chinesenotes-go/tokenizer"
+)
+
+const preferred = `# Chinese term,domain,preferred English,other translations
+觀音,,Avalokitesvara,Kuan Yin;Guan Yin
+经,Buddhism,sutra,scripture
+经,,classic
+`
+
+func mockPostEditor(mode string) (PostEditor, error) {
+	guanyin := dicttypes.Word{
+		Simplified:  "观音",
+		Traditional: "觀音",
+		Pinyin:      "guānyīn",
+		HeadwordId:  1,
+		Senses:      []dicttypes.WordSense{{English: "Guanyin; Avalokitesvara"}},
+	}
+	jing := dicttypes.Word{
+		Simplified:  "经",
+		Traditional: "經",
+		Pinyin:      "jīng",
+		HeadwordId:  2,
+		Senses: []dicttypes.WordSense{
+			{English: "scripture; classic"},
+			{English: "to pass through"},
+		},
+	}
+	wdict := map[string]*dicttypes.Word{
+		"观音": &guanyin,
+		"觀音": &guanyin,
+		"经":  &jing,
+		"經":  &jing,
+	}
+	glossary, err := LoadGlossary(strings.NewReader(preferred))
+	if err != nil {
+		return nil, err
+	}
+	return NewPostEditor(tokenizer.NewDictTokenizer(wdict), glossary, mode), nil
+}
+
+func TestPostEdit(t *testing.T) {
+	p, err := mockPostEditor(PostEditDictionary)
+	if err != nil {
+		t.Fatalf("TestPostEdit: could not load glossary: %v", err)
+	}
+	testCases := []struct {
+		name        string
+		source      string
+		translation string
+		domain      string
+		want        Results
+	}{
+		{
+			name:        "Preferred term present",
+			source:      "观音",
+			translation: "avalokitesvara appears",
+			want: Results{
+				Replacement: "avalokitesvara appears",
+				Notes:       []Note{},
+			},
+		},
+		{
+			name:        "Replace dictionary English",
+			source:      "觀音",
+			translation: "Guanyin appears",
+			want: Results{
+				Replacement: "Avalokitesvara appears",
+				Notes: []Note{{
+					FoundCN:     "觀音",
+					ExpectedEN:  []string{"Avalokitesvara"},
+					FoundEN:     "Guanyin",
+					Replacement: "Avalokitesvara",
+					Action:      ActionReplaced,
+					SourceSpan:  &Span{0, 2},
+					TargetSpan:  &Span{0, 14},
+				}},
+			},
+		},
+		{
+			name:        "Domain entry and spans after replacement",
+			source:      "经观音",
+			translation: "Guan Yin's scripture",
+			domain:      "buddhism",
+			want: Results{
+				Replacement: "Avalokitesvara's sutra",
+				Notes: []Note{
+					{
+						FoundCN:     "经",
+						ExpectedEN:  []string{"sutra"},
+						FoundEN:     "scripture",
+						Replacement: "sutra",
+						Action:      ActionReplaced,
+						SourceSpan:  &Span{0, 1},
+						TargetSpan:  &Span{17, 22},
+					},
+					{
+						FoundCN:     "观音",
+						ExpectedEN:  []string{"Avalokitesvara"},
+						FoundEN:     "Guan Yin",
+						Replacement: "Avalokitesvara",
+						Action:      ActionReplaced,
+						SourceSpan:  &Span{1, 3},
+						TargetSpan:  &Span{0, 14},
+					},
+				},
+			},
+		},
+		{
+			name:        "General entry flagged",
+			source:      "读经",
+			translation: "read the scriptures",
+			want: Results{
+				Replacement: "read the scriptures",
+				Notes: []Note{{
+					FoundCN:    "经",
+					ExpectedEN: []string{"classic"},
+					Action:     ActionFlagged,
+					SourceSpan: &Span{1, 2},
+				}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		got := p.PostEdit(tc.source, tc.translation, tc.domain)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("TestPostEdit %s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+	flagOnly, err := mockPostEditor(PostEditFlag)
+	if err != nil {
+		t.Fatalf("TestPostEdit: could not load glossary: %v", err)
+	}
+	got := flagOnly.PostEdit("观音", "Guan Yin appears", "")
+	if got.Replacement != "Guan Yin appears" || len(got.Notes) != 1 ||
+		got.Notes[0].Action != ActionFlagged || got.Notes[0].TargetSpan != nil {
+		t.Errorf("TestPostEdit without rewriting: got %+v", got)
+	}
+	glossaryOnly, err := mockPostEditor(PostEditGlossary)
+	if err != nil {
+		t.Fatalf("TestPostEdit: could not load glossary: %v", err)
+	}
+	got = glossaryOnly.PostEdit("观音", "Guanyin appears", "")
+	if got.Replacement != "Guanyin appears" || len(got.Notes) != 1 ||
+		got.Notes[0].Action != ActionFlagged {
+		t.Errorf("TestPostEdit glossary alternatives, dictionary English: got %+v", got)
+	}
+	got = glossaryOnly.PostEdit("观音", "Guan Yin appears", "")
+	if got.Replacement != "Avalokitesvara appears" || len(got.Notes) != 1 ||
+		got.Notes[0].Action != ActionReplaced {
+		t.Errorf("TestPostEdit glossary alternatives: got %+v", got)
+	}
+	if _, err := LoadGlossary(strings.NewReader("观音,Buddhism\n")); err == nil {
+		t.Error("TestPostEdit: expected error for glossary row without English")
+	}
+}
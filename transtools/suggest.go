@@ -39,10 +39,15 @@ type Results struct {
 	Notes       []Note
 }
 
+// Note explains a suggestion for the translation. The action and spans are
+// given by the post-editor, with the target span in the text after
+// replacement.
 type Note struct {
 	FoundCN string
 	ExpectedEN []string
 	FoundEN, Replacement string
+	Action string
+	SourceSpan, TargetSpan *Span
 }
 
 func loadData(f io.Reader) (*map[string]string, error) {
@@ -0,0 +1,274 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Proposing and reviewing changes to dictionary entries
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alexamies/chinesenotes-go/config"
+	"github.com/alexamies/chinesenotes-go/dictedit"
+	"github.com/alexamies/chinesenotes-go/dictionary"
+	"github.com/alexamies/chinesenotes-go/identity"
+	"github.com/alexamies/chinesenotes-go/pinyin"
+)
+
+// Content for the page listing proposed dictionary changes
+type dictEditsContent struct {
+	Message   string
+	Proposals []dictedit.Proposal
+	CanReview bool
+}
+
+// initDictEdit creates the store for proposed dictionary changes, with new
+// word senses numbered above those in the dictionary
+func initDictEdit(webConfig config.WebAppConfig, dict *dictionary.Dictionary) (dictedit.ProposalStore, error) {
+	return dictedit.NewFileStore(webConfig.DictEditDir(), firstNewId(dict))
+}
+
+// firstNewId gives the id above the highest word sense and headword ids in the
+// dictionary, for new word senses
+func firstNewId(dict *dictionary.Dictionary) int {
+	maxId := 0
+	for _, w := range dict.HeadwordIds {
+		if w.HeadwordId > maxId {
+			maxId = w.HeadwordId
+		}
+		for _, ws := range w.Senses {
+			if ws.Id > maxId {
+				maxId = ws.Id
+			}
+		}
+	}
+	return maxId + 1
+}
+
+// authorizedUser gets the user for the session cookie and whether they have
+// the permission given. Unlike EnforceValidSession, no login form is shown.
+func authorizedUser(ctx context.Context, b *backends, r *http.Request, permission string) (identity.UserInfo, bool) {
+	auth := loadedAuthenticator(b)
+	if auth == nil {
+		return identity.InvalidUser(), false
+	}
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		return identity.InvalidUser(), false
+	}
+	sessionInfo := auth.CheckSession(ctx, cookie.Value)
+	if sessionInfo.Authenticated != 1 {
+		return identity.InvalidUser(), false
+	}
+	return sessionInfo.User, identity.IsAuthorized(sessionInfo.User, permission)
+}
+
+// proposalFromForm builds a proposal from the form on the word detail page.
+// With a SenseId the existing sense is changed, otherwise a new sense is added
+// to the headword for Simplified, or a new headword if there is none.
+func proposalFromForm(dict *dictionary.Dictionary, r *http.Request, user identity.UserInfo) (dictedit.Proposal, error) {
+	formValue := func(name string) string {
+		return strings.TrimSpace(r.PostFormValue(name))
+	}
+	p := dictedit.Proposal{
+		ProposedBy: user.UserName,
+		Comment:    formValue("Comment"),
+	}
+	if senseId := formValue("SenseId"); len(senseId) > 0 {
+		id, err := strconv.Atoi(senseId)
+		if err != nil {
+			return p, fmt.Errorf("bad word sense id: %s", senseId)
+		}
+		hwId, err := strconv.Atoi(formValue("HeadwordId"))
+		if err != nil {
+			return p, fmt.Errorf("bad headword id: %s", formValue("HeadwordId"))
+		}
+		hw, ok := dict.HeadwordIds[hwId]
+		if !ok {
+			return p, fmt.Errorf("headword %d not found", hwId)
+		}
+		p.Kind = dictedit.KindChange
+		for _, ws := range hw.Senses {
+			if ws.Id == id {
+				p.Original = ws
+			} else {
+				p.Siblings = append(p.Siblings, ws)
+			}
+		}
+		if p.Original.Id != id {
+			return p, fmt.Errorf("word sense %d not found in headword %d", id, hwId)
+		}
+		p.Sense = p.Original
+	} else {
+		p.Kind = dictedit.KindNew
+		simp := formValue("Simplified")
+		if hw, ok := dict.Wdict[simp]; ok && hw.Simplified == simp {
+			p.Sense.HeadwordId = hw.HeadwordId
+			p.Sense.Traditional = hw.Traditional
+			p.Siblings = hw.Senses
+		} else {
+			p.Sense.Traditional = nullIfEmpty(formValue("Traditional"))
+		}
+		p.Sense.Simplified = simp
+		p.Sense.Domain = "\\N"
+		p.Sense.DomainCN = "\\N"
+		p.Sense.Image = "\\N"
+		p.Sense.MP3 = "\\N"
+	}
+	p.Sense.Pinyin = formValue("Pinyin")
+	if strings.ContainsAny(p.Sense.Pinyin, "12345") {
+		// Typed with tone numbers, eg ni3 hao3
+		p.Sense.Pinyin = pinyin.ToMarked(p.Sense.Pinyin)
+	}
+	p.Sense.English = formValue("English")
+	p.Sense.Grammar = nullIfEmpty(formValue("Grammar"))
+	p.Sense.Notes = formValue("Notes")
+	if p.Kind == dictedit.KindChange && p.Sense == p.Original {
+		return p, fmt.Errorf("no change to word sense %d", p.Sense.Id)
+	}
+	return p, nil
+}
+
+// nullIfEmpty gives the dictionary null value for empty strings
+func nullIfEmpty(s string) string {
+	if len(s) == 0 {
+		return "\\N"
+	}
+	return s
+}
+
+// proposeHandler saves a proposed dictionary change from a user with
+// permission to propose edits
+func proposeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	b := getBackends()
+	if b == nil || b.dictEdit == nil {
+		http.Error(w, "Dictionary editing is not enabled", http.StatusNotFound)
+		return
+	}
+	ctx := context.Background()
+	user, ok := authorizedUser(ctx, b, r, identity.PermProposeEdit)
+	if !ok {
+		log.Printf("proposeHandler %s with role %s not authorized", user.UserName, user.Role)
+		http.Error(w, "Not authorized", http.StatusForbidden)
+		return
+	}
+	proposal, err := proposalFromForm(b.dict, r, user)
+	if err == nil {
+		proposal, err = b.dictEdit.Propose(ctx, proposal)
+	}
+	if err != nil {
+		log.Printf("proposeHandler could not save proposal from %s: %v", user.UserName, err)
+		http.Error(w, fmt.Sprintf("Could not save proposal: %v", err), http.StatusBadRequest)
+		return
+	}
+	log.Printf("proposeHandler %s proposed %d for %s", user.UserName, proposal.Id, proposal.Sense.Simplified)
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		sendJSON(w, proposal)
+		return
+	}
+	_, canReview := authorizedUser(ctx, b, r, identity.PermReviewEdit)
+	content := htmlContent{
+		Title: b.webConfig.GetVarWithDefault("Title", defTitle),
+		Data: dictEditsContent{
+			Message:   fmt.Sprintf("Thank you, your proposal %d will be reviewed by an editor", proposal.Id),
+			Proposals: []dictedit.Proposal{proposal},
+			CanReview: canReview,
+		},
+	}
+	b.pageDisplayer.DisplayPage(w, "dict_edits.html", content)
+}
+
+// reviewHandler lists pending dictionary changes and, for a POST, approves or
+// rejects one, for users with permission to review edits
+func reviewHandler(w http.ResponseWriter, r *http.Request) {
+	b := getBackends()
+	if b == nil || b.dictEdit == nil {
+		http.Error(w, "Dictionary editing is not enabled", http.StatusNotFound)
+		return
+	}
+	ctx := context.Background()
+	user, ok := authorizedUser(ctx, b, r, identity.PermReviewEdit)
+	if !ok {
+		log.Printf("reviewHandler %s with role %s not authorized", user.UserName, user.Role)
+		http.Error(w, "Not authorized", http.StatusForbidden)
+		return
+	}
+	msg := ""
+	if r.Method == http.MethodPost {
+		id, err := strconv.Atoi(r.PostFormValue("ProposalId"))
+		if err != nil {
+			http.Error(w, "Bad proposal id", http.StatusBadRequest)
+			return
+		}
+		note := strings.TrimSpace(r.PostFormValue("ReviewNote"))
+		var p dictedit.Proposal
+		switch action := r.PostFormValue("Action"); action {
+		case "approve":
+			p, err = b.dictEdit.Approve(ctx, id, user.UserName, note)
+		case "reject":
+			p, err = b.dictEdit.Reject(ctx, id, user.UserName, note)
+		default:
+			http.Error(w, fmt.Sprintf("Unknown action: %s", action), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("reviewHandler error reviewing %d: %v", id, err)
+			http.Error(w, fmt.Sprintf("Could not review proposal: %v", err), http.StatusBadRequest)
+			return
+		}
+		log.Printf("reviewHandler %s %s proposal %d", user.UserName, p.Status, id)
+		msg = fmt.Sprintf("Proposal %d %s", id, p.Status)
+		if p.Status == dictedit.StatusApproved {
+			msg += fmt.Sprintf(", changes will show after the dictionary is reloaded with %s",
+				b.dictEdit.PatchFile())
+		}
+	}
+	pending, err := b.dictEdit.List(ctx, dictedit.StatusPending)
+	if err != nil {
+		log.Printf("reviewHandler error listing proposals: %v", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	results := dictEditsContent{
+		Message:   msg,
+		Proposals: pending,
+		CanReview: true,
+	}
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		sendJSON(w, results)
+		return
+	}
+	content := htmlContent{
+		Title: b.webConfig.GetVarWithDefault("Title", defTitle),
+		Data:  results,
+	}
+	b.pageDisplayer.DisplayPage(w, "dict_edits.html", content)
+}
+
+// canProposeEdits checks whether the user of the request may propose
+// dictionary changes, for showing the form on the word detail page
+func canProposeEdits(ctx context.Context, b *backends, r *http.Request) bool {
+	if b.dictEdit == nil {
+		return false
+	}
+	_, ok := authorizedUser(ctx, b, r, identity.PermProposeEdit)
+	return ok
+}
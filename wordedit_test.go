@@ -0,0 +1,348 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alexamies/chinesenotes-go/config"
+	"github.com/alexamies/chinesenotes-go/dictedit"
+	"github.com/alexamies/chinesenotes-go/dictionary"
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+	"github.com/alexamies/chinesenotes-go/httphandling"
+	"github.com/alexamies/chinesenotes-go/identity"
+	"github.com/alexamies/chinesenotes-go/templates"
+)
+
+// roleAuthenticatorMock gives a logged in session for a user with the role
+type roleAuthenticatorMock struct {
+	AuthenticatorMock
+	role string
+}
+
+func (a roleAuthenticatorMock) CheckSession(ctx context.Context, sessionid string) identity.SessionInfo {
+	return identity.SessionInfo{
+		Authenticated: 1,
+		Valid:         true,
+		User:          identity.UserInfo{UserName: a.role + "1", Role: a.role},
+	}
+}
+
+func mockEditDict() *dictionary.Dictionary {
+	s := "莲花"
+	senses := []dicttypes.WordSense{
+		{
+			Id:          11,
+			HeadwordId:  9,
+			Simplified:  s,
+			Traditional: "蓮花",
+			Pinyin:      "liánhuā",
+			English:     "lotus",
+			Grammar:     "noun",
+			Notes:       "FGDB entry 1",
+		},
+		{
+			Id:          12,
+			HeadwordId:  9,
+			Simplified:  s,
+			Traditional: "蓮花",
+			Pinyin:      "liánhuā",
+			English:     "Padma",
+			Grammar:     "proper noun",
+		},
+	}
+	hw := dicttypes.Word{
+		HeadwordId:  9,
+		Simplified:  s,
+		Traditional: "蓮花",
+		Pinyin:      "liánhuā",
+		Senses:      senses,
+	}
+	return dictionary.NewDictionary(map[string]*dicttypes.Word{
+		s:    &hw,
+		"蓮花": &hw,
+	})
+}
+
+func postForm(u string, form url.Values, role string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, u, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if len(role) > 0 {
+		r.AddCookie(&http.Cookie{Name: "session", Value: "test"})
+	}
+	return r
+}
+
+// TestProposalFromForm tests building proposals from the word detail form
+func TestProposalFromForm(t *testing.T) {
+	dict := mockEditDict()
+	user := identity.UserInfo{UserName: "alice", Role: "translator"}
+	type test struct {
+		name           string
+		form           url.Values
+		expectErr      bool
+		expectKind     string
+		expectPinyin   string
+		expectHwId     int
+		expectSiblings int
+	}
+	tests := []test{
+		{
+			name: "Change",
+			form: url.Values{
+				"HeadwordId": {"9"},
+				"SenseId":    {"11"},
+				"Pinyin":     {"liánhuā"},
+				"English":    {"lotus flower"},
+				"Grammar":    {"noun"},
+				"Notes":      {"FGDB entry 1"},
+			},
+			expectKind:     dictedit.KindChange,
+			expectHwId:     9,
+			expectSiblings: 1,
+		},
+		{
+			name: "No change",
+			form: url.Values{
+				"HeadwordId": {"9"},
+				"SenseId":    {"11"},
+				"Pinyin":     {"liánhuā"},
+				"English":    {"lotus"},
+				"Grammar":    {"noun"},
+				"Notes":      {"FGDB entry 1"},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Sense not in headword",
+			form: url.Values{
+				"HeadwordId": {"9"},
+				"SenseId":    {"99"},
+				"English":    {"lotus"},
+			},
+			expectErr: true,
+		},
+		{
+			name: "New sense for existing headword",
+			form: url.Values{
+				"Simplified": {"莲花"},
+				"Pinyin":     {"liánhuā"},
+				"English":    {"Lotus Sutra"},
+			},
+			expectKind:     dictedit.KindNew,
+			expectHwId:     9,
+			expectSiblings: 2,
+		},
+		{
+			name: "New headword",
+			form: url.Values{
+				"Simplified": {"荷花"},
+				"Pinyin":     {"he2hua1"},
+				"English":    {"lotus"},
+			},
+			expectKind:     dictedit.KindNew,
+			expectPinyin:   "héhuā",
+			expectHwId:     0,
+			expectSiblings: 0,
+		},
+	}
+	for _, tc := range tests {
+		r := postForm("/loggedin/propose", tc.form, "")
+		p, err := proposalFromForm(dict, r, user)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("TestProposalFromForm %s: expected error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("TestProposalFromForm %s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if p.Kind != tc.expectKind {
+			t.Errorf("TestProposalFromForm %s: expected kind %s, got %s", tc.name, tc.expectKind, p.Kind)
+		}
+		if p.Sense.HeadwordId != tc.expectHwId {
+			t.Errorf("TestProposalFromForm %s: expected headword id %d, got %d", tc.name, tc.expectHwId, p.Sense.HeadwordId)
+		}
+		if len(p.Siblings) != tc.expectSiblings {
+			t.Errorf("TestProposalFromForm %s: expected %d siblings, got %d", tc.name, tc.expectSiblings, len(p.Siblings))
+		}
+		if p.ProposedBy != "alice" {
+			t.Errorf("TestProposalFromForm %s: expected proposed by alice, got %s", tc.name, p.ProposedBy)
+		}
+		if len(tc.expectPinyin) > 0 && p.Sense.Pinyin != tc.expectPinyin {
+			t.Errorf("TestProposalFromForm %s: expected pinyin %s, got %s", tc.name, tc.expectPinyin, p.Sense.Pinyin)
+		}
+	}
+}
+
+// TestDictEditHandlers tests permissions for proposing and reviewing changes
+func TestDictEditHandlers(t *testing.T) {
+	dict := mockEditDict()
+	dir := t.TempDir()
+	store, err := dictedit.NewFileStore(dir, 100)
+	if err != nil {
+		t.Fatalf("TestDictEditHandlers: could not create store: %v", err)
+	}
+	templates := templates.NewTemplateMap(config.WebAppConfig{})
+	pageDisplayer := httphandling.NewPageDisplayer(templates)
+	newBackends := func(role string) *backends {
+		return &backends{
+			dict:          dict,
+			webConfig:     config.WebAppConfig{ConfigVars: map[string]string{}},
+			templates:     templates,
+			pageDisplayer: pageDisplayer,
+			authenticator: roleAuthenticatorMock{role: role},
+			dictEdit:      store,
+		}
+	}
+	proposal := url.Values{
+		"HeadwordId": {"9"},
+		"SenseId":    {"12"},
+		"Pinyin":     {"liánhuā"},
+		"English":    {"Padma <b>lotus</b>"},
+		"Grammar":    {"proper noun"},
+	}
+	type test struct {
+		name           string
+		role           string
+		handler        http.HandlerFunc
+		r              *http.Request
+		expectStatus   int
+		expectContains string
+	}
+	tests := []test{
+		{
+			name:         "Propose without session",
+			role:         "",
+			handler:      proposeHandler,
+			r:            postForm("/loggedin/propose", proposal, ""),
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			name:         "Propose without permission",
+			role:         "reader",
+			handler:      proposeHandler,
+			r:            postForm("/loggedin/propose", proposal, "reader"),
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Translator proposes",
+			role:           "translator",
+			handler:        proposeHandler,
+			r:              postForm("/loggedin/propose", proposal, "translator"),
+			expectStatus:   http.StatusOK,
+			expectContains: "Padma &lt;b&gt;lotus&lt;/b&gt;",
+		},
+		{
+			name:         "Translator cannot review",
+			role:         "translator",
+			handler:      reviewHandler,
+			r:            postForm("/loggedin/review", url.Values{"ProposalId": {"1"}, "Action": {"approve"}}, "translator"),
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			name:         "Unknown action",
+			role:         "editor",
+			handler:      reviewHandler,
+			r:            postForm("/loggedin/review", url.Values{"ProposalId": {"1"}, "Action": {"delete"}}, "editor"),
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Editor approves",
+			role:           "editor",
+			handler:        reviewHandler,
+			r:              postForm("/loggedin/review", url.Values{"ProposalId": {"1"}, "Action": {"approve"}}, "editor"),
+			expectStatus:   http.StatusOK,
+			expectContains: "Proposal 1 approved",
+		},
+		{
+			name:         "Approve twice",
+			role:         "editor",
+			handler:      reviewHandler,
+			r:            postForm("/loggedin/review", url.Values{"ProposalId": {"1"}, "Action": {"approve"}}, "editor"),
+			expectStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range tests {
+		setBackends(newBackends(tc.role))
+		w := httptest.NewRecorder()
+		tc.handler(w, tc.r)
+		if w.Code != tc.expectStatus {
+			t.Errorf("TestDictEditHandlers %s: expected status %d, got %d: %s",
+				tc.name, tc.expectStatus, w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), tc.expectContains) {
+			t.Errorf("TestDictEditHandlers %s: expected to contain %q, got %q",
+				tc.name, tc.expectContains, w.Body.String())
+		}
+	}
+	setBackends(nil)
+
+	patch, err := os.ReadFile(store.PatchFile())
+	if err != nil {
+		t.Fatalf("TestDictEditHandlers: could not read patch: %v", err)
+	}
+	if !strings.Contains(string(patch), "Padma <b>lotus</b>") {
+		t.Errorf("TestDictEditHandlers: patch does not contain change: %s", patch)
+	}
+}
+
+// TestWordDetailProposeForm tests that the form for proposing changes is only
+// shown to users with permission
+func TestWordDetailProposeForm(t *testing.T) {
+	store, err := dictedit.NewFileStore(t.TempDir(), 100)
+	if err != nil {
+		t.Fatalf("TestWordDetailProposeForm: could not create store: %v", err)
+	}
+	templates := templates.NewTemplateMap(config.WebAppConfig{})
+	pageDisplayer := httphandling.NewPageDisplayer(templates)
+	webConfig := config.WebAppConfig{
+		ConfigVars: map[string]string{
+			"NotesReMatch": `FGDB entry ([0-9]*)`,
+			"NotesReplace": `<a href="/web/${1}.html">FGDB entry</a>`,
+		},
+	}
+	for _, role := range []string{"", "translator"} {
+		setBackends(&backends{
+			dict:          mockEditDict(),
+			webConfig:     webConfig,
+			templates:     templates,
+			pageDisplayer: pageDisplayer,
+			authenticator: roleAuthenticatorMock{role: role},
+			dictEdit:      store,
+		})
+		r := httptest.NewRequest(http.MethodGet, "/words/9.html", nil)
+		if len(role) > 0 {
+			r.AddCookie(&http.Cookie{Name: "session", Value: "test"})
+		}
+		w := httptest.NewRecorder()
+		wordDetail(w, r)
+		result := w.Body.String()
+		hasForm := strings.Contains(result, `action="/loggedin/propose"`)
+		if hasForm != (len(role) > 0) {
+			t.Errorf("TestWordDetailProposeForm %q: form shown %t", role, hasForm)
+		}
+		if len(role) > 0 && !strings.Contains(result, `rows="3" cols="60">FGDB entry 1</textarea>`) {
+			t.Errorf("TestWordDetailProposeForm: expected unprocessed notes in form, got %s", result)
+		}
+	}
+	setBackends(nil)
+}
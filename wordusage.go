@@ -0,0 +1,176 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alexamies/chinesenotes-go/config"
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+	"github.com/alexamies/chinesenotes-go/fulltext"
+	"github.com/alexamies/chinesenotes-go/termfreq"
+	"github.com/alexamies/chinesenotes-go/tokenizer"
+)
+
+const (
+	keywordIndexFN  = "keyword_index.json"
+	maxUsageDocs    = 5  // Documents listed that the word is used in
+	maxExamples     = 5  // Concordance lines shown
+	maxConcordance  = 50 // Concordance lines used for finding collocates
+	maxCollocates   = 10
+	usageContextLen = 20 // Characters either side of the word in examples
+)
+
+// usageDoc is a document that a word is used in
+type usageDoc struct {
+	GlossFile, Title string
+	Count            int
+}
+
+// wordUsage holds examples of the use of a word in the corpus
+type wordUsage struct {
+	Freq       int
+	Docs       []usageDoc
+	Examples   []fulltext.Concordance
+	Collocates []fulltext.Collocate
+}
+
+// usageCache holds the usage found for words, since finding concordance lines
+// reads the documents. It is replaced with the backends on reload.
+type usageCache struct {
+	mu    sync.Mutex
+	usage map[string]*wordUsage
+}
+
+func newUsageCache() *usageCache {
+	return &usageCache{usage: make(map[string]*wordUsage)}
+}
+
+func (c *usageCache) get(key string) (*wordUsage, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u, ok := c.usage[key]
+	return u, ok
+}
+
+func (c *usageCache) put(key string, u *wordUsage) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.usage[key] = u
+}
+
+// concordanceFunc finds concordance lines in the plain text of documents
+type concordanceFunc func(keys, terms []string, contextLen, limit int) []fulltext.Concordance
+
+// initTermIndex loads the term index from the index directory
+func initTermIndex(appConfig config.AppConfig) (termfreq.TermIndex, error) {
+	fileName := appConfig.IndexDir() + "/" + keywordIndexFN
+	r, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("initTermIndex: Error opening %s: %v", fileName, err)
+	}
+	defer r.Close()
+	return termfreq.LoadKeywordIndex(r)
+}
+
+// getWordUsage finds the frequency of the word in the corpus, the documents it
+// is used in most, examples, and collocates, nil if there is no term index.
+// Examples are only taken from the documents listed and the usage is cached
+// by the forms of the word.
+func getWordUsage(b *backends, w dicttypes.Word, concordance concordanceFunc) *wordUsage {
+	if b.termIndex == nil {
+		return nil
+	}
+	terms := []string{w.Simplified}
+	if len(w.Traditional) > 0 && w.Traditional != "\\N" && w.Traditional != w.Simplified {
+		terms = append(terms, w.Traditional)
+	}
+	key := strings.Join(terms, "\t")
+	if u, ok := b.usageCache.get(key); ok {
+		return u
+	}
+	u := findWordUsage(b, w, terms, concordance)
+	b.usageCache.put(key, u)
+	return u
+}
+
+func findWordUsage(b *backends, w dicttypes.Word, terms []string, concordance concordanceFunc) *wordUsage {
+	usage := wordUsage{
+		Docs:       []usageDoc{},
+		Examples:   []fulltext.Concordance{},
+		Collocates: []fulltext.Collocate{},
+	}
+	counts := make(map[string]int)
+	for _, t := range terms {
+		usage.Freq += b.termIndex.Freq(t)
+		for _, df := range b.termIndex.Docs(t) {
+			counts[df.Filename] += df.Count
+		}
+	}
+	if usage.Freq == 0 {
+		return &usage
+	}
+	for glossFile, count := range counts {
+		doc := usageDoc{
+			GlossFile: glossFile,
+			Title:     glossFile,
+			Count:     count,
+		}
+		if d, ok := b.docMap[glossFile]; ok {
+			doc.Title = d.Title
+		}
+		usage.Docs = append(usage.Docs, doc)
+	}
+	sort.Slice(usage.Docs, func(i, j int) bool {
+		if usage.Docs[i].Count != usage.Docs[j].Count {
+			return usage.Docs[i].Count > usage.Docs[j].Count
+		}
+		return usage.Docs[i].GlossFile < usage.Docs[j].GlossFile
+	})
+
+	if len(usage.Docs) > maxUsageDocs {
+		usage.Docs = usage.Docs[:maxUsageDocs]
+	}
+
+	// Examples are taken from the documents where the word is used most
+	keys := []string{}
+	for _, d := range usage.Docs {
+		if info, ok := b.docMap[d.GlossFile]; ok {
+			keys = append(keys, info.CorpusFile)
+		}
+	}
+	if len(keys) == 0 {
+		return &usage
+	}
+	lines := concordance(keys, terms, usageContextLen, maxConcordance)
+	log.Printf("getWordUsage, found %d concordance lines for %s", len(lines),
+		w.Simplified)
+	usage.Examples = lines
+	if len(lines) > maxExamples {
+		usage.Examples = lines[:maxExamples]
+	}
+	tok := tokenizer.NewDictTokenizer(b.dict.Wdict)
+	usage.Collocates = fulltext.FindCollocates(lines, tok, maxCollocates)
+	return &usage
+}
@@ -0,0 +1,159 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alexamies/chinesenotes-go/config"
+	"github.com/alexamies/chinesenotes-go/dictionary"
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+	"github.com/alexamies/chinesenotes-go/find"
+	"github.com/alexamies/chinesenotes-go/fulltext"
+	"github.com/alexamies/chinesenotes-go/termfreq"
+)
+
+// TestInitTermIndex tests loading the term index from the index directory
+func TestInitTermIndex(t *testing.T) {
+	ti, err := initTermIndex(config.AppConfig{ProjectHome: "."})
+	if err != nil {
+		t.Fatalf("TestInitTermIndex: unexpected error: %v", err)
+	}
+	if got := ti.Freq("曰"); got != 2 {
+		t.Errorf("TestInitTermIndex: expected 2 for 曰, got %d", got)
+	}
+}
+
+// TestGetWordUsage tests finding frequencies, documents, examples, and
+// collocates for a word
+func TestGetWordUsage(t *testing.T) {
+	const index = `{
+		"汉代":[{"Filename":"a.html","Count":1}],
+		"漢代":[{"Filename":"b.html","Count":2},{"Filename":"a.html","Count":2}]
+	}`
+	ti, err := termfreq.LoadKeywordIndex(strings.NewReader(index))
+	if err != nil {
+		t.Fatalf("TestGetWordUsage: unexpected error: %v", err)
+	}
+	wdict := map[string]*dicttypes.Word{
+		"九卿": {Simplified: "九卿"},
+	}
+	docMap := map[string]find.DocInfo{
+		"a.html": {CorpusFile: "a.txt", GlossFile: "a.html", Title: "Chapter A"},
+		"b.html": {CorpusFile: "b.txt", GlossFile: "b.html", Title: "Chapter B"},
+	}
+	var gotKeys, gotTerms []string
+	concordance := func(keys, terms []string, contextLen, limit int) []fulltext.Concordance {
+		gotKeys, gotTerms = keys, terms
+		return []fulltext.Concordance{
+			{PlainTextFile: "a.txt", Left: "", Term: "漢代", Right: "九卿"},
+			{PlainTextFile: "b.txt", Left: "自", Term: "漢代", Right: "九卿、"},
+		}
+	}
+	w := dicttypes.Word{Simplified: "汉代", Traditional: "漢代"}
+
+	b := &backends{dict: dictionary.NewDictionary(wdict), docMap: docMap}
+	if usage := getWordUsage(b, w, concordance); usage != nil {
+		t.Errorf("TestGetWordUsage: expected nil without an index, got %v", usage)
+	}
+
+	b.termIndex = ti
+	usage := getWordUsage(b, w, concordance)
+	if usage == nil {
+		t.Fatal("TestGetWordUsage: got nil")
+	}
+	if usage.Freq != 5 {
+		t.Errorf("TestGetWordUsage: expected frequency 5, got %d", usage.Freq)
+	}
+	expectDocs := []usageDoc{
+		{GlossFile: "a.html", Title: "Chapter A", Count: 3},
+		{GlossFile: "b.html", Title: "Chapter B", Count: 2},
+	}
+	if len(usage.Docs) != len(expectDocs) {
+		t.Fatalf("TestGetWordUsage: expected docs %v, got %v", expectDocs, usage.Docs)
+	}
+	for i, d := range expectDocs {
+		if usage.Docs[i] != d {
+			t.Errorf("TestGetWordUsage: doc %d expected %v, got %v", i, d, usage.Docs[i])
+		}
+	}
+	if strings.Join(gotKeys, ",") != "a.txt,b.txt" {
+		t.Errorf("TestGetWordUsage: expected documents most used first, got %v", gotKeys)
+	}
+	if strings.Join(gotTerms, ",") != "汉代,漢代" {
+		t.Errorf("TestGetWordUsage: expected both forms, got %v", gotTerms)
+	}
+	if len(usage.Examples) != 2 {
+		t.Errorf("TestGetWordUsage: expected 2 examples, got %v", usage.Examples)
+	}
+	if len(usage.Collocates) != 1 || usage.Collocates[0] != (fulltext.Collocate{Word: "九卿", Freq: 2}) {
+		t.Errorf("TestGetWordUsage: expected collocate 九卿, got %v", usage.Collocates)
+	}
+
+	unused := dicttypes.Word{Simplified: "风", Traditional: "風"}
+	if usage := getWordUsage(b, unused, concordance); usage == nil || usage.Freq != 0 || len(usage.Docs) != 0 {
+		t.Errorf("TestGetWordUsage: expected no usage, got %v", usage)
+	}
+}
+
+// TestGetWordUsageCache tests that examples are only taken from the documents
+// listed and that the usage is found once for a word
+func TestGetWordUsageCache(t *testing.T) {
+	var sb strings.Builder
+	docMap := map[string]find.DocInfo{}
+	sb.WriteString(`{"佛":[`)
+	for i := 1; i <= maxUsageDocs+2; i++ {
+		if i > 1 {
+			sb.WriteString(",")
+		}
+		glossFile := fmt.Sprintf("d%d.html", i)
+		fmt.Fprintf(&sb, `{"Filename":%q,"Count":%d}`, glossFile, 100-i)
+		docMap[glossFile] = find.DocInfo{
+			CorpusFile: fmt.Sprintf("d%d.txt", i),
+			GlossFile:  glossFile,
+		}
+	}
+	sb.WriteString("]}")
+	ti, err := termfreq.LoadKeywordIndex(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatalf("TestGetWordUsageCache: unexpected error: %v", err)
+	}
+	calls := 0
+	var gotKeys []string
+	concordance := func(keys, terms []string, contextLen, limit int) []fulltext.Concordance {
+		calls++
+		gotKeys = keys
+		return []fulltext.Concordance{}
+	}
+	b := &backends{
+		dict:       dictionary.NewDictionary(map[string]*dicttypes.Word{}),
+		docMap:     docMap,
+		termIndex:  ti,
+		usageCache: newUsageCache(),
+	}
+	w := dicttypes.Word{Simplified: "佛", Traditional: "\\N"}
+	first := getWordUsage(b, w, concordance)
+	second := getWordUsage(b, w, concordance)
+	if calls != 1 {
+		t.Errorf("TestGetWordUsageCache: expected 1 concordance search, got %d", calls)
+	}
+	if first != second {
+		t.Error("TestGetWordUsageCache: expected the cached usage")
+	}
+	if len(gotKeys) != maxUsageDocs || gotKeys[0] != "d1.txt" {
+		t.Errorf("TestGetWordUsageCache: expected the top %d documents, got %v",
+			maxUsageDocs, gotKeys)
+	}
+}
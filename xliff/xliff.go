@@ -0,0 +1,498 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package for exchanging documents for translation with CAT tools in the
+// XLIFF 1.2 and 2.0 formats
+package xliff
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+	"github.com/alexamies/chinesenotes-go/tokenizer"
+	"github.com/alexamies/chinesenotes-go/transmemory"
+	"github.com/alexamies/chinesenotes-go/transtools"
+)
+
+// XLIFF versions supported
+const (
+	Version12 = "1.2"
+	Version20 = "2.0"
+)
+
+// Translation states of a unit, as for XLIFF 2.0
+const (
+	StateInitial    = "initial"
+	StateTranslated = "translated"
+	StateReviewed   = "reviewed"
+	StateFinal      = "final"
+)
+
+const (
+	ns12        = "urn:oasis:names:tc:xliff:document:1.2"
+	ns20        = "urn:oasis:names:tc:xliff:document:2.0"
+	sourceLang  = "zh"
+	targetLang  = "en"
+	noteFrom    = "glossary"
+	mtQualifier = "mt-suggestion"
+)
+
+// Unit is a sentence of a document for translation
+type Unit struct {
+	ID     string
+	Source string
+	Target string   // Machine translation when exported, if any
+	State  string   // One of the State constants
+	Notes  []string // Dictionary entries for words in the source
+}
+
+// Document is a source document segmented into units for translation
+type Document struct {
+	Original   string // File name of the source document
+	SourceLang string
+	TargetLang string
+	Units      []Unit
+}
+
+// NewDocument segments the text of a document into sentences, with notes for
+// the dictionary entries of the words found by the tokenizer and target text
+// from the machine translation client, given the previous sentence as context.
+// The client is optional and sentences that it fails to translate are left
+// without a target. No more sentences are translated once the context is done
+// or after the first maxMT, since each is a call to a paid service.
+func NewDocument(ctx context.Context, original, text string, tok tokenizer.Tokenizer, mt transtools.ApiClient, maxMT int) Document {
+	doc := Document{
+		Original:   original,
+		SourceLang: sourceLang,
+		TargetLang: targetLang,
+		Units:      []Unit{},
+	}
+	previous := ""
+	for i, s := range tokenizer.SegmentSentences(text) {
+		unit := Unit{
+			ID:     strconv.Itoa(i + 1),
+			Source: s.Text,
+			State:  StateInitial,
+			Notes:  glossaryNotes(s.Text, tok),
+		}
+		if mt != nil && ctx.Err() != nil {
+			log.Printf("xliff.NewDocument, not translating from unit %s: %v", unit.ID, ctx.Err())
+			mt = nil
+		}
+		if mt != nil && i >= maxMT {
+			log.Printf("xliff.NewDocument, not translating from unit %s, limit %d reached", unit.ID, maxMT)
+			mt = nil
+		}
+		if mt != nil {
+			req := transtools.Request{
+				Text:       s.Text,
+				SourceLang: doc.SourceLang,
+				TargetLang: doc.TargetLang,
+				Context:    previous,
+			}
+			result, err := mt.Translate(ctx, req)
+			if err != nil {
+				log.Printf("xliff.NewDocument, could not translate unit %s: %v", unit.ID, err)
+			} else if result != nil {
+				unit.Target = result.Text
+			}
+		}
+		previous = s.Text
+		doc.Units = append(doc.Units, unit)
+	}
+	return doc
+}
+
+// glossaryNotes gives the dictionary entries of the words in the text, once
+// each
+func glossaryNotes(text string, tok tokenizer.Tokenizer) []string {
+	notes := []string{}
+	if tok == nil {
+		return notes
+	}
+	seen := make(map[string]bool)
+	for _, t := range tok.Tokenize(text) {
+		w := t.DictEntry
+		if len(w.Simplified) == 0 || !dicttypes.ContainsCJK(t.Token) || seen[t.Token] {
+			continue
+		}
+		seen[t.Token] = true
+		english := []string{}
+		for _, ws := range w.Senses {
+			if len(ws.English) > 0 && ws.English != "\\N" {
+				english = append(english, ws.English)
+			}
+		}
+		if len(english) == 0 {
+			continue
+		}
+		notes = append(notes, fmt.Sprintf("%s %s: %s", t.Token, w.Pinyin,
+			strings.Join(english, "; ")))
+	}
+	return notes
+}
+
+// Segments gives the translated units as translation memory segments, leaving
+// out units in the initial state, such as unreviewed machine translations
+func (d Document) Segments() []transmemory.Segment {
+	segments := []transmemory.Segment{}
+	for _, u := range d.Units {
+		if u.State == StateInitial || len(strings.TrimSpace(u.Target)) == 0 {
+			continue
+		}
+		segments = append(segments, transmemory.Segment{
+			Source: u.Source,
+			Target: u.Target,
+			Origin: d.Original,
+		})
+	}
+	return segments
+}
+
+// inlineText is the text of an element, including that within inline markup
+// added by CAT tools, such as <g> and <mrk>
+type inlineText string
+
+func (t *inlineText) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var b strings.Builder
+	depth := 1
+	for depth > 0 {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := token.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			b.Write(tt)
+		}
+	}
+	*t = inlineText(b.String())
+	return nil
+}
+
+// XLIFF 1.2 elements
+type xliff12 struct {
+	XMLName xml.Name `xml:"xliff"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	Version string   `xml:"version,attr"`
+	File    file12   `xml:"file"`
+}
+
+type file12 struct {
+	Original   string        `xml:"original,attr"`
+	SourceLang string        `xml:"source-language,attr"`
+	TargetLang string        `xml:"target-language,attr,omitempty"`
+	DataType   string        `xml:"datatype,attr"`
+	Units      []transUnit12 `xml:"body>trans-unit"`
+}
+
+type transUnit12 struct {
+	ID     string     `xml:"id,attr"`
+	Source inlineText `xml:"source"`
+	Target *target12  `xml:"target"`
+	Notes  []note12   `xml:"note"`
+}
+
+type target12 struct {
+	State          string     `xml:"state,attr,omitempty"`
+	StateQualifier string     `xml:"state-qualifier,attr,omitempty"`
+	Text           inlineText `xml:",chardata"`
+}
+
+func (t *target12) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "state":
+			t.State = attr.Value
+		case "state-qualifier":
+			t.StateQualifier = attr.Value
+		}
+	}
+	return t.Text.UnmarshalXML(d, start)
+}
+
+type note12 struct {
+	From string `xml:"from,attr,omitempty"`
+	Text string `xml:",chardata"`
+}
+
+// XLIFF 2.0 elements
+type xliff20 struct {
+	XMLName    xml.Name `xml:"xliff"`
+	Xmlns      string   `xml:"xmlns,attr,omitempty"`
+	Version    string   `xml:"version,attr"`
+	SourceLang string   `xml:"srcLang,attr"`
+	TargetLang string   `xml:"trgLang,attr,omitempty"`
+	File       file20   `xml:"file"`
+}
+
+type file20 struct {
+	ID       string   `xml:"id,attr"`
+	Original string   `xml:"original,attr,omitempty"`
+	Units    []unit20 `xml:"unit"`
+}
+
+type unit20 struct {
+	ID       string      `xml:"id,attr"`
+	Notes    []note20    `xml:"notes>note"`
+	Segments []segment20 `xml:"segment"`
+}
+
+type note20 struct {
+	Category string `xml:"category,attr,omitempty"`
+	Text     string `xml:",chardata"`
+}
+
+type segment20 struct {
+	State  string     `xml:"state,attr,omitempty"`
+	Source inlineText `xml:"source"`
+	Target inlineText `xml:"target,omitempty"`
+}
+
+// Write writes the document in the XLIFF version given, 1.2 or 2.0
+func Write(w io.Writer, doc Document, version string) error {
+	var x interface{}
+	switch version {
+	case Version12:
+		x = toXLIFF12(doc)
+	case Version20:
+		x = toXLIFF20(doc)
+	default:
+		return fmt.Errorf("xliff.Write, unsupported version %s", version)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("xliff.Write, could not write header: %v", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(x); err != nil {
+		return fmt.Errorf("xliff.Write, could not encode: %v", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("xliff.Write, could not write: %v", err)
+	}
+	return nil
+}
+
+func toXLIFF12(doc Document) xliff12 {
+	f := file12{
+		Original:   doc.Original,
+		SourceLang: doc.SourceLang,
+		TargetLang: doc.TargetLang,
+		DataType:   "plaintext",
+		Units:      []transUnit12{},
+	}
+	for _, u := range doc.Units {
+		tu := transUnit12{
+			ID:     u.ID,
+			Source: inlineText(u.Source),
+			Notes:  []note12{},
+		}
+		if len(u.Target) > 0 {
+			tu.Target = &target12{
+				State: state12(u.State),
+				Text:  inlineText(u.Target),
+			}
+			if u.State == StateInitial {
+				tu.Target.StateQualifier = mtQualifier
+			}
+		}
+		for _, n := range u.Notes {
+			tu.Notes = append(tu.Notes, note12{From: noteFrom, Text: n})
+		}
+		f.Units = append(f.Units, tu)
+	}
+	return xliff12{
+		Xmlns:   ns12,
+		Version: Version12,
+		File:    f,
+	}
+}
+
+func toXLIFF20(doc Document) xliff20 {
+	f := file20{
+		ID:       "f1",
+		Original: doc.Original,
+		Units:    []unit20{},
+	}
+	for _, u := range doc.Units {
+		unit := unit20{
+			ID:    u.ID,
+			Notes: []note20{},
+			Segments: []segment20{
+				{
+					State:  u.State,
+					Source: inlineText(u.Source),
+					Target: inlineText(u.Target),
+				},
+			},
+		}
+		for _, n := range u.Notes {
+			unit.Notes = append(unit.Notes, note20{Category: noteFrom, Text: n})
+		}
+		f.Units = append(f.Units, unit)
+	}
+	return xliff20{
+		Xmlns:      ns20,
+		Version:    Version20,
+		SourceLang: doc.SourceLang,
+		TargetLang: doc.TargetLang,
+		File:       f,
+	}
+}
+
+// state12 gives the XLIFF 1.2 target state for a unit state
+func state12(state string) string {
+	switch state {
+	case StateTranslated:
+		return "translated"
+	case StateReviewed:
+		return "signed-off"
+	case StateFinal:
+		return "final"
+	}
+	return "needs-review-translation"
+}
+
+// stateFrom12 gives the unit state for an XLIFF 1.2 target state. Targets
+// without a state are taken as translated, since some tools leave it out.
+func stateFrom12(state string) string {
+	switch state {
+	case "", "translated":
+		return StateTranslated
+	case "signed-off":
+		return StateReviewed
+	case "final":
+		return StateFinal
+	}
+	return StateInitial
+}
+
+// Read reads a translated XLIFF 1.2 or 2.0 document, using the version
+// attribute of the root element
+func Read(r io.Reader) (*Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("xliff.Read, could not read: %v", err)
+	}
+	var root struct {
+		Version string `xml:"version,attr"`
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("xliff.Read, could not decode: %v", err)
+	}
+	switch {
+	case strings.HasPrefix(root.Version, "1."):
+		return read12(data)
+	case strings.HasPrefix(root.Version, "2."):
+		return read20(data)
+	}
+	return nil, fmt.Errorf("xliff.Read, unsupported version %q", root.Version)
+}
+
+func read12(data []byte) (*Document, error) {
+	var x xliff12
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&x); err != nil {
+		return nil, fmt.Errorf("xliff.Read, could not decode XLIFF 1.2: %v", err)
+	}
+	doc := Document{
+		Original:   x.File.Original,
+		SourceLang: x.File.SourceLang,
+		TargetLang: x.File.TargetLang,
+		Units:      []Unit{},
+	}
+	for _, tu := range x.File.Units {
+		unit := Unit{
+			ID:     tu.ID,
+			Source: strings.TrimSpace(string(tu.Source)),
+			State:  StateInitial,
+			Notes:  []string{},
+		}
+		if tu.Target != nil {
+			unit.Target = strings.TrimSpace(string(tu.Target.Text))
+			unit.State = stateFrom12(tu.Target.State)
+		}
+		for _, n := range tu.Notes {
+			unit.Notes = append(unit.Notes, n.Text)
+		}
+		doc.Units = append(doc.Units, unit)
+	}
+	return &doc, nil
+}
+
+func read20(data []byte) (*Document, error) {
+	var x xliff20
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&x); err != nil {
+		return nil, fmt.Errorf("xliff.Read, could not decode XLIFF 2.0: %v", err)
+	}
+	doc := Document{
+		Original:   x.File.Original,
+		SourceLang: x.SourceLang,
+		TargetLang: x.TargetLang,
+		Units:      []Unit{},
+	}
+	for _, u := range x.File.Units {
+		unit := Unit{
+			ID:    u.ID,
+			State: StateInitial,
+			Notes: []string{},
+		}
+		// Units may be split into several segments, which are joined, with
+		// the least advanced state of them
+		sources := []string{}
+		targets := []string{}
+		for i, seg := range u.Segments {
+			sources = append(sources, strings.TrimSpace(string(seg.Source)))
+			if t := strings.TrimSpace(string(seg.Target)); len(t) > 0 {
+				targets = append(targets, t)
+			}
+			state := seg.State
+			if len(state) == 0 {
+				state = StateInitial
+			}
+			if i == 0 || stateRank(state) < stateRank(unit.State) {
+				unit.State = state
+			}
+		}
+		unit.Source = strings.Join(sources, "")
+		unit.Target = strings.Join(targets, " ")
+		for _, n := range u.Notes {
+			unit.Notes = append(unit.Notes, n.Text)
+		}
+		doc.Units = append(doc.Units, unit)
+	}
+	return &doc, nil
+}
+
+// stateRank orders states by how far translation has progressed
+func stateRank(state string) int {
+	switch state {
+	case StateTranslated:
+		return 1
+	case StateReviewed:
+		return 2
+	case StateFinal:
+		return 3
+	}
+	return 0
+}
@@ -0,0 +1,241 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xliff
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alexamies/chinesenotes-go/dicttypes"
+	"github.com/alexamies/chinesenotes-go/tokenizer"
+	"github.com/alexamies/chinesenotes-go/transmemory"
+	"github.com/alexamies/chinesenotes-go/transtools"
+)
+
+type mockApiClient struct{}
+
+func (c mockApiClient) Translate(ctx context.Context, req transtools.Request) (*transtools.Result, error) {
+	if strings.Contains(req.Text, "错") {
+		return nil, fmt.Errorf("could not translate")
+	}
+	return &transtools.Result{Text: "MT: " + req.Text}, nil
+}
+
+func mockTokenizer() tokenizer.Tokenizer {
+	xue := dicttypes.Word{
+		Simplified: "学",
+		Pinyin:     "xué",
+		HeadwordId: 1,
+		Senses: []dicttypes.WordSense{
+			{English: "to learn"},
+			{English: "\\N"},
+			{English: "school"},
+		},
+	}
+	zhi := dicttypes.Word{
+		Simplified: "之",
+		Pinyin:     "zhī",
+		HeadwordId: 2,
+		Senses:     []dicttypes.WordSense{{English: "\\N"}},
+	}
+	wdict := map[string]*dicttypes.Word{
+		"学": &xue,
+		"之": &zhi,
+	}
+	return tokenizer.NewDictTokenizer(wdict)
+}
+
+func TestNewDocument(t *testing.T) {
+	text := "学而时习之，学！\n\n出错了。"
+	doc := NewDocument(context.Background(), "analects.txt", text, mockTokenizer(), mockApiClient{}, 10)
+	if len(doc.Units) != 2 {
+		t.Fatalf("TestNewDocument: got %d units, want 2: %v", len(doc.Units), doc.Units)
+	}
+	want := Unit{
+		ID:     "1",
+		Source: "学而时习之，学！",
+		Target: "MT: 学而时习之，学！",
+		State:  StateInitial,
+		Notes:  []string{"学 xué: to learn; school"},
+	}
+	got := doc.Units[0]
+	if got.ID != want.ID || got.Source != want.Source || got.Target != want.Target ||
+		got.State != want.State || strings.Join(got.Notes, "|") != strings.Join(want.Notes, "|") {
+		t.Errorf("TestNewDocument: got %v, want %v", got, want)
+	}
+	if doc.Units[1].Target != "" {
+		t.Errorf("TestNewDocument: expected no target when translation fails, got %s",
+			doc.Units[1].Target)
+	}
+	noMT := NewDocument(context.Background(), "analects.txt", text, nil, nil, 10)
+	if len(noMT.Units) != 2 || noMT.Units[0].Target != "" || len(noMT.Units[0].Notes) != 0 {
+		t.Errorf("TestNewDocument: unexpected units without tokenizer or MT: %v", noMT.Units)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cancelled := NewDocument(ctx, "analects.txt", text, nil, mockApiClient{}, 10)
+	if len(cancelled.Units) != 2 || cancelled.Units[0].Target != "" {
+		t.Errorf("TestNewDocument: expected no translation after cancel: %v", cancelled.Units)
+	}
+	limited := NewDocument(context.Background(), "analects.txt", "学而时习之。学而时习之。", nil,
+		mockApiClient{}, 1)
+	if len(limited.Units) != 2 || limited.Units[0].Target == "" || limited.Units[1].Target != "" {
+		t.Errorf("TestNewDocument: expected only the first unit translated: %v", limited.Units)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	doc := Document{
+		Original:   "analects.txt",
+		SourceLang: "zh",
+		TargetLang: "en",
+		Units: []Unit{
+			{ID: "1", Source: "学而时习之", Target: "To learn & practice", State: StateInitial,
+				Notes: []string{"学 xué: to learn"}},
+			{ID: "2", Source: "有朋自远方来", State: StateInitial},
+		},
+	}
+	testCases := []struct {
+		version string
+		want    []string
+	}{
+		{
+			version: Version12,
+			want: []string{
+				`<xliff xmlns="urn:oasis:names:tc:xliff:document:1.2" version="1.2">`,
+				`<file original="analects.txt" source-language="zh" target-language="en" datatype="plaintext">`,
+				`<trans-unit id="1">`,
+				`<target state="needs-review-translation" state-qualifier="mt-suggestion">To learn &amp; practice</target>`,
+				`<note from="glossary">学 xué: to learn</note>`,
+			},
+		},
+		{
+			version: Version20,
+			want: []string{
+				`<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="zh" trgLang="en">`,
+				`<unit id="1">`,
+				`<note category="glossary">学 xué: to learn</note>`,
+				`<segment state="initial">`,
+				`<target>To learn &amp; practice</target>`,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		var buf strings.Builder
+		if err := Write(&buf, doc, tc.version); err != nil {
+			t.Fatalf("TestWrite %s: unexpected error: %v", tc.version, err)
+		}
+		x := buf.String()
+		for _, w := range tc.want {
+			if !strings.Contains(x, w) {
+				t.Errorf("TestWrite %s: expected %q in %s", tc.version, w, x)
+			}
+		}
+		back, err := Read(strings.NewReader(x))
+		if err != nil {
+			t.Fatalf("TestWrite %s: unexpected error reading back: %v", tc.version, err)
+		}
+		if len(back.Units) != 2 || back.Units[0].Target != doc.Units[0].Target ||
+			back.Units[0].State != StateInitial || back.Units[1].Target != "" {
+			t.Errorf("TestWrite %s: unexpected units read back: %v", tc.version, back.Units)
+		}
+		if len(back.Segments()) != 0 {
+			t.Errorf("TestWrite %s: expected no segments before translation", tc.version)
+		}
+	}
+	if err := Write(&strings.Builder{}, doc, "3.0"); err == nil {
+		t.Error("TestWrite: expected error for unsupported version")
+	}
+}
+
+func TestRead(t *testing.T) {
+	const translated12 = `<?xml version="1.0" encoding="UTF-8"?>
+<xliff version="1.2" xmlns="urn:oasis:names:tc:xliff:document:1.2">
+  <file original="analects.txt" source-language="zh" target-language="en" datatype="plaintext">
+    <body>
+      <trans-unit id="1">
+        <source>学而时习之</source>
+        <target state="translated">To <g id="1">learn</g> and practice</target>
+      </trans-unit>
+      <trans-unit id="2">
+        <source>有朋自远方来</source>
+        <target state="needs-review-translation">MT: friends</target>
+      </trans-unit>
+      <trans-unit id="3">
+        <source>人不知而不愠</source>
+        <target>Not upset when unrecognized</target>
+      </trans-unit>
+    </body>
+  </file>
+</xliff>`
+	const translated20 = `<?xml version="1.0" encoding="UTF-8"?>
+<xliff version="2.0" xmlns="urn:oasis:names:tc:xliff:document:2.0" srcLang="zh" trgLang="en">
+  <file id="f1" original="analects.txt">
+    <unit id="1">
+      <segment state="final"><source>学而时习之，</source><target>To learn</target></segment>
+      <segment state="reviewed"><source>不亦说乎？</source><target>is it not a pleasure?</target></segment>
+    </unit>
+    <unit id="2">
+      <segment><source>有朋自远方来</source><target>MT: friends</target></segment>
+    </unit>
+  </file>
+</xliff>`
+	testCases := []struct {
+		name  string
+		input string
+		want  []transmemory.Segment
+	}{
+		{
+			name:  "XLIFF 1.2",
+			input: translated12,
+			want: []transmemory.Segment{
+				{Source: "学而时习之", Target: "To learn and practice", Origin: "analects.txt"},
+				{Source: "人不知而不愠", Target: "Not upset when unrecognized", Origin: "analects.txt"},
+			},
+		},
+		{
+			name:  "XLIFF 2.0",
+			input: translated20,
+			want: []transmemory.Segment{
+				{Source: "学而时习之，不亦说乎？", Target: "To learn is it not a pleasure?", Origin: "analects.txt"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		doc, err := Read(strings.NewReader(tc.input))
+		if err != nil {
+			t.Fatalf("TestRead %s: unexpected error: %v", tc.name, err)
+		}
+		got := doc.Segments()
+		if len(got) != len(tc.want) {
+			t.Fatalf("TestRead %s: got %d segments, want %d: %v", tc.name, len(got),
+				len(tc.want), got)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("TestRead %s: got %v, want %v", tc.name, got[i], tc.want[i])
+			}
+		}
+	}
+	if doc, _ := Read(strings.NewReader(translated20)); doc.Units[0].State != StateReviewed {
+		t.Errorf("TestRead: got state %s for unit with several segments, want %s",
+			doc.Units[0].State, StateReviewed)
+	}
+	for _, bad := range []string{`<xliff version="3.0"></xliff>`, "not xml"} {
+		if _, err := Read(strings.NewReader(bad)); err == nil {
+			t.Errorf("TestRead: expected error for %s", bad)
+		}
+	}
+}